@@ -0,0 +1,74 @@
+package otlpwire
+
+// defaultArenaChunkSize is the chunk size of an Arena created with a
+// non-positive size.
+const defaultArenaChunkSize = 1 << 20
+
+// Allocator provides the buffers that split and partition operations write
+// their outputs into.
+type Allocator interface {
+	// Alloc returns an empty slice with capacity for at least size bytes.
+	// Operations ask for an upper bound of their output size; appending
+	// past the capacity moves the output to the heap rather than failing.
+	Alloc(size int) []byte
+}
+
+// allocate returns a buffer for size bytes from alloc, or from the heap if
+// alloc is nil.
+func allocate(alloc Allocator, size int) []byte {
+	if alloc == nil {
+		return make([]byte, 0, size)
+	}
+	return alloc.Alloc(size)
+}
+
+// Arena is an Allocator that carves buffers out of large chunks, so that the
+// outputs of many split operations share a few allocations and are freed
+// together. A service splitting thousands of batches per second can Reset
+// one Arena per batch cycle instead of leaving every output to the garbage
+// collector.
+//
+// Since operations allocate for their worst case, an Arena holds some
+// unused slack until it is reset. An Arena is not safe for concurrent use.
+type Arena struct {
+	chunkSize int
+	chunks    [][]byte
+	current   int
+}
+
+// NewArena returns an Arena that allocates chunks of chunkSize bytes, or
+// 1 MiB if chunkSize is not positive. Requests larger than a chunk get a
+// dedicated allocation.
+func NewArena(chunkSize int) *Arena {
+	if chunkSize <= 0 {
+		chunkSize = defaultArenaChunkSize
+	}
+	return &Arena{chunkSize: chunkSize}
+}
+
+// Alloc returns an empty slice with a capacity of exactly size bytes, so
+// that appending to it never overwrites a neighboring allocation.
+func (a *Arena) Alloc(size int) []byte {
+	if size > a.chunkSize {
+		return make([]byte, 0, size)
+	}
+	for ; a.current < len(a.chunks); a.current++ {
+		chunk := a.chunks[a.current]
+		if start := len(chunk); cap(chunk)-start >= size {
+			a.chunks[a.current] = chunk[:start+size]
+			return chunk[start : start : start+size]
+		}
+	}
+	a.chunks = append(a.chunks, make([]byte, size, a.chunkSize))
+	return a.chunks[a.current][0:0:size]
+}
+
+// Reset makes the whole arena available again, keeping its chunks for
+// reuse. Every slice allocated before Reset, and every request sliced from
+// one, must no longer be used.
+func (a *Arena) Reset() {
+	for i := range a.chunks {
+		a.chunks[i] = a.chunks[i][:0]
+	}
+	a.current = 0
+}
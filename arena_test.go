@@ -0,0 +1,60 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArena_Alloc(t *testing.T) {
+	a := NewArena(64)
+
+	first := a.Alloc(16)
+	second := a.Alloc(16)
+	require.Empty(t, first)
+	require.Equal(t, 16, cap(first))
+	require.Equal(t, 16, cap(second))
+	// Both come from the same chunk.
+	require.Same(t, &first[:1][0], &a.chunks[0][0])
+	require.Same(t, &second[:1][0], &a.chunks[0][16])
+
+	// Appending past the capacity moves to the heap instead of overwriting
+	// the neighbor.
+	second = append(second, make([]byte, 16)...)
+	first = append(first, make([]byte, 17)...)
+	for i := range 16 {
+		first[i] = 0xff
+	}
+	require.Equal(t, make([]byte, 16), second)
+
+	// A request that does not fit the current chunk starts a new one; one
+	// larger than a chunk gets a dedicated buffer.
+	a.Alloc(40)
+	require.Len(t, a.chunks, 2)
+	big := a.Alloc(100)
+	require.Equal(t, 100, cap(big))
+	require.Len(t, a.chunks, 2)
+
+	// Reset reuses the chunks.
+	a.Reset()
+	again := a.Alloc(8)
+	require.Same(t, &again[:1][0], &a.chunks[0][0])
+	require.Len(t, a.chunks, 2)
+
+	require.Equal(t, defaultArenaChunkSize, NewArena(0).chunkSize)
+}
+
+func TestPartitionBySampledWith_Arena(t *testing.T) {
+	data := ExportTracesServiceRequest(marshalTraces(t, createBenchTraces()))
+	wantSampled, wantUnsampled, err := data.PartitionBySampled()
+	require.NoError(t, err)
+
+	a := NewArena(4 * len(data))
+	sampled, unsampled, err := data.PartitionBySampledWith(a)
+	require.NoError(t, err)
+	require.Equal(t, wantSampled, sampled)
+	require.Equal(t, wantUnsampled, unsampled)
+	// Both outputs were written into the arena's single chunk.
+	require.Len(t, a.chunks, 1)
+	require.Same(t, &unsampled[0], &a.chunks[0][len(data)])
+}
@@ -0,0 +1,265 @@
+package otlpwire
+
+import (
+	"iter"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// AnyValue represents a single AnyValue message (raw wire bytes), the value
+// of an attribute or a log body.
+type AnyValue []byte
+
+// Type returns the type of the value: the last oneof field present, as
+// protobuf parsers resolve repeated oneof members. An empty message is
+// ValueTypeEmpty.
+func (v AnyValue) Type() (ValueType, error) {
+	return anyValueType([]byte(v))
+}
+
+// Str returns the value if it is a string, as a view into the underlying
+// buffer. ok is false if the value holds another type.
+func (v AnyValue) Str() (s []byte, ok bool, err error) {
+	p, err := parseAnyValue([]byte(v))
+	if err != nil || p.typ != ValueTypeString {
+		return nil, false, err
+	}
+	return p.bytes, true, nil
+}
+
+// Int returns the value if it is an int. ok is false if the value holds
+// another type.
+func (v AnyValue) Int() (i int64, ok bool, err error) {
+	p, err := parseAnyValue([]byte(v))
+	if err != nil || p.typ != ValueTypeInt {
+		return 0, false, err
+	}
+	return int64(p.num), true, nil
+}
+
+// Double returns the value if it is a double. ok is false if the value
+// holds another type.
+func (v AnyValue) Double() (f float64, ok bool, err error) {
+	p, err := parseAnyValue([]byte(v))
+	if err != nil || p.typ != ValueTypeDouble {
+		return 0, false, err
+	}
+	return math.Float64frombits(p.num), true, nil
+}
+
+// Bool returns the value if it is a bool. ok is false if the value holds
+// another type.
+func (v AnyValue) Bool() (b bool, ok bool, err error) {
+	p, err := parseAnyValue([]byte(v))
+	if err != nil || p.typ != ValueTypeBool {
+		return false, false, err
+	}
+	return p.num != 0, true, nil
+}
+
+// Bytes returns the value if it is a byte string, as a view into the
+// underlying buffer. ok is false if the value holds another type.
+func (v AnyValue) Bytes() (b []byte, ok bool, err error) {
+	p, err := parseAnyValue([]byte(v))
+	if err != nil || p.typ != ValueTypeBytes {
+		return nil, false, err
+	}
+	return p.bytes, true, nil
+}
+
+// Array returns an iterator over the elements of the value if it is an
+// array; for any other type it yields nothing.
+// The returned function should be called after iteration to check for errors.
+func (v AnyValue) Array() (iter.Seq[AnyValue], func() error) {
+	var iterErr error
+
+	seq := func(yield func(AnyValue) bool) {
+		p, err := parseAnyValue([]byte(v))
+		if err != nil || p.typ != ValueTypeArray {
+			iterErr = err
+			return
+		}
+		forEachRepeatedField(p.bytes, 1, func(elem []byte, err error) bool {
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			return yield(AnyValue(elem))
+		})
+	}
+
+	return seq, func() error { return iterErr }
+}
+
+// KVList returns an iterator over the entries of the value if it is a
+// key-value list; for any other type it yields nothing.
+// The returned function should be called after iteration to check for errors.
+func (v AnyValue) KVList() (iter.Seq[KeyValue], func() error) {
+	var iterErr error
+
+	seq := func(yield func(KeyValue) bool) {
+		p, err := parseAnyValue([]byte(v))
+		if err != nil || p.typ != ValueTypeKvlist {
+			iterErr = err
+			return
+		}
+		forEachRepeatedField(p.bytes, 1, func(kv []byte, err error) bool {
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			return yield(KeyValue(kv))
+		})
+	}
+
+	return seq, func() error { return iterErr }
+}
+
+// AppendText appends the value as plain text: strings verbatim, scalars in
+// their Go formatting, bytes as standard base64, and arrays and key-value
+// lists as JSON. An empty value appends nothing.
+func (v AnyValue) AppendText(dst []byte) ([]byte, error) {
+	return appendAnyValueText(dst, []byte(v))
+}
+
+// Value returns the value of the key-value pair (field 2). Returns nil,
+// an empty value, if the field is not present.
+func (kv KeyValue) Value() (AnyValue, error) {
+	v, err := extractBytesField([]byte(kv), 2)
+	return AnyValue(v), err
+}
+
+// Attribute returns the value of the resource attribute key (field 1). ok
+// is false if the key is absent; if it repeats, the last occurrence wins.
+func (r Resource) Attribute(key string) (value AnyValue, ok bool, err error) {
+	return lookupAttribute([]byte(r), 1, key)
+}
+
+// StringAttribute returns the value of the resource attribute key if it is
+// a string. ok is false if the key is absent or holds another type. It
+// skips decoding the AnyValue into a generic form, which makes it the
+// cheapest way to read attributes such as service.name.
+func (r Resource) StringAttribute(key string) (value string, ok bool, err error) {
+	return stringAttribute([]byte(r), 1, key)
+}
+
+// ServiceName returns the service.name attribute, or "" if it is absent or
+// not a string.
+func (r Resource) ServiceName() (string, error) {
+	return serviceName([]byte(r))
+}
+
+// ServiceNamespace returns the service.namespace attribute, as ServiceName.
+func (r Resource) ServiceNamespace() (string, error) {
+	namespace, _, err := stringAttribute([]byte(r), 1, "service.namespace")
+	return namespace, err
+}
+
+// ServiceInstanceID returns the service.instance.id attribute, as
+// ServiceName.
+func (r Resource) ServiceInstanceID() (string, error) {
+	id, _, err := stringAttribute([]byte(r), 1, "service.instance.id")
+	return id, err
+}
+
+// ServiceName returns the service.name attribute of the Resource of this
+// ResourceSpans, or "" if the resource or the attribute is absent.
+func (r ResourceSpans) ServiceName() (string, error) {
+	return entryServiceName(r)
+}
+
+// ServiceName returns the service.name attribute of the Resource of this
+// ResourceMetrics, as ResourceSpans.ServiceName.
+func (r ResourceMetrics) ServiceName() (string, error) {
+	return entryServiceName(r)
+}
+
+// ServiceName returns the service.name attribute of the Resource of this
+// ResourceLogs, as ResourceSpans.ServiceName.
+func (r ResourceLogs) ServiceName() (string, error) {
+	return entryServiceName(r)
+}
+
+// entryServiceName returns the service.name attribute of the Resource
+// (field 1) of a resource entry.
+func entryServiceName(entry []byte) (string, error) {
+	resource, err := extractBytesField(entry, 1)
+	if err != nil {
+		return "", err
+	}
+	return serviceName(resource)
+}
+
+// Attribute returns the value of the span attribute key (field 9), as
+// Resource.Attribute.
+func (s Span) Attribute(key string) (value AnyValue, ok bool, err error) {
+	return lookupAttribute([]byte(s), 9, key)
+}
+
+// StringAttribute returns the value of the span attribute key if it is a
+// string, as Resource.StringAttribute.
+func (s Span) StringAttribute(key string) (value string, ok bool, err error) {
+	return stringAttribute([]byte(s), 9, key)
+}
+
+// Attribute returns the value of the log record attribute key (field 6),
+// as Resource.Attribute.
+func (r LogRecord) Attribute(key string) (value AnyValue, ok bool, err error) {
+	return lookupAttribute([]byte(r), 6, key)
+}
+
+// StringAttribute returns the value of the log record attribute key if it
+// is a string, as Resource.StringAttribute.
+func (r LogRecord) StringAttribute(key string) (value string, ok bool, err error) {
+	return stringAttribute([]byte(r), 6, key)
+}
+
+// Attributes returns an iterator over the attributes of the log record
+// (field 6), in wire order.
+// The returned function should be called after iteration to check for errors.
+func (r LogRecord) Attributes() (iter.Seq[KeyValue], func() error) {
+	var iterErr error
+
+	seq := func(yield func(KeyValue) bool) {
+		forEachRepeatedField([]byte(r), 6, func(kv []byte, err error) bool {
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			return yield(KeyValue(kv))
+		})
+	}
+
+	return seq, func() error { return iterErr }
+}
+
+// Attribute returns the value of the data point attribute key, whichever
+// data point kind it is, as Resource.Attribute.
+func (d DataPoint) Attribute(key string) (value AnyValue, ok bool, err error) {
+	return lookupAttribute(d.raw, d.attributesFieldNum(), key)
+}
+
+// StringAttribute returns the value of the data point attribute key if it
+// is a string, as Resource.StringAttribute.
+func (d DataPoint) StringAttribute(key string) (value string, ok bool, err error) {
+	return stringAttribute(d.raw, d.attributesFieldNum(), key)
+}
+
+// lookupAttribute looks up key in the repeated KeyValue field num of msg and
+// returns its raw AnyValue; the last occurrence of the key wins.
+func lookupAttribute(msg []byte, num protowire.Number, key string) (value AnyValue, ok bool, err error) {
+	err = forEachMessage(msg, num, func(kv []byte) error {
+		k, err := extractBytesField(kv, 1)
+		if err != nil || string(k) != key {
+			return err
+		}
+		v, err := extractBytesField(kv, 2)
+		value, ok = AnyValue(v), true
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, ok, nil
+}
@@ -0,0 +1,317 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestResource_Attribute(t *testing.T) {
+	traces := ptrace.NewTraces()
+	attrs := traces.ResourceSpans().AppendEmpty().Resource().Attributes()
+	attrs.PutStr("service.name", "checkout")
+	attrs.PutInt("process.pid", 42)
+	attrs.PutEmpty("empty")
+
+	var resource Resource
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range seq {
+		raw, err := rs.Resource()
+		require.NoError(t, err)
+		resource = Resource(raw)
+	}
+	require.NoError(t, errFn())
+
+	name, ok, err := resource.StringAttribute("service.name")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "checkout", name)
+	_, ok, err = resource.StringAttribute("process.pid")
+	require.NoError(t, err)
+	require.False(t, ok, "not a string")
+
+	value, ok, err := resource.Attribute("process.pid")
+	require.NoError(t, err)
+	require.True(t, ok)
+	typ, err := value.Type()
+	require.NoError(t, err)
+	require.Equal(t, ValueTypeInt, typ)
+
+	value, ok, err = resource.Attribute("empty")
+	require.NoError(t, err)
+	require.True(t, ok)
+	typ, err = value.Type()
+	require.NoError(t, err)
+	require.Equal(t, ValueTypeEmpty, typ)
+
+	value, ok, err = resource.Attribute("missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Nil(t, value)
+
+	// The last occurrence of a repeated key wins.
+	dup := appendStringKeyValue(appendStringKeyValue(nil, 1, "k", "first"), 1, "k", "second")
+	s, ok, err := Resource(dup).StringAttribute("k")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "second", s)
+
+	_, _, err = Resource{0x0a, 0x02, 0x0a, 0x05}.Attribute("k")
+	require.Error(t, err)
+}
+
+func TestServiceName(t *testing.T) {
+	traces := ptrace.NewTraces()
+	attrs := traces.ResourceSpans().AppendEmpty().Resource().Attributes()
+	attrs.PutStr("service.name", "checkout")
+	attrs.PutStr("service.namespace", "shop")
+	attrs.PutStr("service.instance.id", "pod-1")
+	traces.ResourceSpans().AppendEmpty().Resource().Attributes().PutInt("service.name", 1)
+
+	var names []string
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range seq {
+		name, err := rs.ServiceName()
+		require.NoError(t, err)
+		names = append(names, name)
+		if name == "" {
+			continue
+		}
+		raw, err := rs.Resource()
+		require.NoError(t, err)
+		resource := Resource(raw)
+		namespace, err := resource.ServiceNamespace()
+		require.NoError(t, err)
+		require.Equal(t, "shop", namespace)
+		id, err := resource.ServiceInstanceID()
+		require.NoError(t, err)
+		require.Equal(t, "pod-1", id)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{"checkout", ""}, names)
+
+	metrics := pmetric.NewMetrics()
+	metrics.ResourceMetrics().AppendEmpty().Resource().Attributes().PutStr("service.name", "db")
+	mSeq, mErr := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).ResourceMetrics()
+	for rm := range mSeq {
+		name, err := rm.ServiceName()
+		require.NoError(t, err)
+		require.Equal(t, "db", name)
+	}
+	require.NoError(t, mErr())
+
+	// A resource entry without a Resource message.
+	name, err := ResourceLogs{}.ServiceName()
+	require.NoError(t, err)
+	require.Empty(t, name)
+
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().Resource().Attributes().PutStr("service.name", "api")
+	lSeq, lErr := ExportLogsServiceRequest(marshalLogs(t, logs)).ResourceLogs()
+	for rl := range lSeq {
+		name, err := rl.ServiceName()
+		require.NoError(t, err)
+		require.Equal(t, "api", name)
+	}
+	require.NoError(t, lErr())
+}
+
+func TestAnyValue(t *testing.T) {
+	traces := ptrace.NewTraces()
+	attrs := traces.ResourceSpans().AppendEmpty().Resource().Attributes()
+	attrs.PutStr("str", "hello")
+	attrs.PutInt("int", -7)
+	attrs.PutDouble("double", 1.5)
+	attrs.PutBool("bool", true)
+	attrs.PutEmptyBytes("bytes").FromRaw([]byte{1, 2})
+	arr := attrs.PutEmptySlice("array")
+	arr.AppendEmpty().SetStr("a")
+	arr.AppendEmpty().SetInt(2)
+	kvs := attrs.PutEmptyMap("kvlist")
+	kvs.PutStr("k", "v")
+
+	var resource Resource
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range seq {
+		raw, err := rs.Resource()
+		require.NoError(t, err)
+		resource = Resource(raw)
+	}
+	require.NoError(t, errFn())
+	attr := func(key string) AnyValue {
+		v, ok, err := resource.Attribute(key)
+		require.NoError(t, err)
+		require.True(t, ok)
+		return v
+	}
+
+	s, ok, err := attr("str").Str()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(s))
+	_, ok, err = attr("str").Int()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	i, ok, err := attr("int").Int()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, int64(-7), i)
+
+	f, ok, err := attr("double").Double()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1.5, f)
+
+	b, ok, err := attr("bool").Bool()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, b)
+
+	raw, ok, err := attr("bytes").Bytes()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte{1, 2}, raw)
+
+	var elems []string
+	elemSeq, elemErr := attr("array").Array()
+	for elem := range elemSeq {
+		text, err := elem.AppendText(nil)
+		require.NoError(t, err)
+		elems = append(elems, string(text))
+	}
+	require.NoError(t, elemErr())
+	require.Equal(t, []string{"a", "2"}, elems)
+
+	kvSeq, kvErr := attr("kvlist").KVList()
+	n := 0
+	for kv := range kvSeq {
+		key, err := kv.Key()
+		require.NoError(t, err)
+		require.Equal(t, "k", string(key))
+		value, err := kv.Value()
+		require.NoError(t, err)
+		s, ok, err := value.Str()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "v", string(s))
+		n++
+	}
+	require.NoError(t, kvErr())
+	require.Equal(t, 1, n)
+
+	// Iterating a scalar as an array yields nothing.
+	elemSeq, elemErr = attr("int").Array()
+	for range elemSeq {
+		t.Fatal("no element expected")
+	}
+	require.NoError(t, elemErr())
+
+	text, err := attr("kvlist").AppendText(nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"k":"v"}`, string(text))
+
+	_, _, err = AnyValue{0x18, 0x80}.Int()
+	require.Error(t, err)
+}
+
+func TestSpan_Attribute(t *testing.T) {
+	traces := ptrace.NewTraces()
+	span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("http.route", "/users/{id}")
+	span.Attributes().PutInt("http.response.status_code", 500)
+
+	var s Span
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).AllSpans()
+	for scoped := range seq {
+		s = scoped.Span
+	}
+	require.NoError(t, errFn())
+
+	route, ok, err := s.StringAttribute("http.route")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "/users/{id}", route)
+
+	value, ok, err := s.Attribute("http.response.status_code")
+	require.NoError(t, err)
+	require.True(t, ok)
+	code, ok, err := value.Int()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, int64(500), code)
+
+	_, ok, err = s.Attribute("peer.service")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestLogRecord_Attributes(t *testing.T) {
+	logs := plog.NewLogs()
+	record := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.Attributes().PutStr("user.email", "jane@example.com")
+	record.Attributes().PutBool("retry", false)
+
+	var r LogRecord
+	seq, errFn := ExportLogsServiceRequest(marshalLogs(t, logs)).AllLogRecords()
+	for scoped := range seq {
+		r = scoped.LogRecord
+	}
+	require.NoError(t, errFn())
+
+	email, ok, err := r.StringAttribute("user.email")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "jane@example.com", email)
+
+	value, ok, err := r.Attribute("retry")
+	require.NoError(t, err)
+	require.True(t, ok)
+	typ, err := value.Type()
+	require.NoError(t, err)
+	require.Equal(t, ValueTypeBool, typ)
+
+	_, ok, err = r.Attribute("credit_card")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	var keys []string
+	kvs, kvErr := r.Attributes()
+	for kv := range kvs {
+		key, err := kv.Key()
+		require.NoError(t, err)
+		keys = append(keys, string(key))
+	}
+	require.NoError(t, kvErr())
+	require.Equal(t, []string{"user.email", "retry"}, keys)
+
+	kvs, kvErr = LogRecord{0x32, 0x05}.Attributes()
+	for range kvs {
+		t.Fatal("no attribute expected")
+	}
+	require.Error(t, kvErr())
+}
+
+func TestDataPoint_Attribute(t *testing.T) {
+	n := 0
+	forEachTestDataPoint(t, buildAllTypesMetrics(t), func(_ string, dp DataPoint) {
+		method, ok, err := dp.StringAttribute("method")
+		require.NoError(t, err)
+		require.True(t, ok, dp.Type())
+		require.Equal(t, "GET", method)
+		value, ok, err := dp.Attribute("status")
+		require.NoError(t, err)
+		require.True(t, ok)
+		status, _, err := value.Str()
+		require.NoError(t, err)
+		require.Equal(t, "200", string(status))
+		_, ok, err = dp.Attribute("missing")
+		require.NoError(t, err)
+		require.False(t, ok)
+		n++
+	})
+	require.Equal(t, 10, n)
+}
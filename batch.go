@@ -0,0 +1,199 @@
+package otlpwire
+
+import (
+	"errors"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// BatchConfig configures a batcher. A zero threshold is disabled.
+type BatchConfig struct {
+	// MaxBytes is the maximum encoded size of a flushed request. A resource
+	// that would push the pending batch past it flushes the batch first.
+	MaxBytes int
+	// MaxItems is the maximum number of spans, data points or log records
+	// in a flushed request, enforced like MaxBytes.
+	MaxItems int
+	// MaxAge flushes the pending batch once its first resource was added
+	// at least this long ago. Age is checked on every add and by
+	// FlushExpired; batchers start no timers of their own.
+	MaxAge time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+	// Pool, if set, provides the buffers of pending batches. Flushed
+	// requests then come from the pool and should be returned with
+	// Pool.Put once they have been sent.
+	Pool *BufferPool
+}
+
+// TracesBatcher accumulates ResourceSpans into export requests and hands
+// each request to a flush callback when a BatchConfig threshold is reached.
+// Resources are never split, so a single resource larger than MaxBytes or
+// MaxItems is flushed on its own. A TracesBatcher is not safe for
+// concurrent use.
+type TracesBatcher struct{ b batcher }
+
+// NewTracesBatcher returns a batcher that calls flush with each completed
+// request. The request is owned by flush. An error returned by flush is
+// reported by the call that triggered the flush; the batch is not retried.
+func NewTracesBatcher(cfg BatchConfig, flush func(ExportTracesServiceRequest) error) *TracesBatcher {
+	return &TracesBatcher{b: newBatcher(cfg, countInResourceSpans, func(data []byte) error {
+		return flush(ExportTracesServiceRequest(data))
+	})}
+}
+
+// Add appends a ResourceSpans to the pending batch, flushing before or after
+// as the thresholds require.
+func (b *TracesBatcher) Add(rs ResourceSpans) error { return b.b.add(rs) }
+
+// AddRequest adds every ResourceSpans of req in order, stopping at
+// the first error.
+func (b *TracesBatcher) AddRequest(req ExportTracesServiceRequest) error {
+	return b.b.addRequest(req)
+}
+
+// Flush flushes the pending batch, if any.
+func (b *TracesBatcher) Flush() error { return b.b.flush() }
+
+// FlushExpired flushes the pending batch if it is older than MaxAge. Call it
+// periodically to bound latency when adds are infrequent.
+func (b *TracesBatcher) FlushExpired() error { return b.b.flushExpired() }
+
+// Pending returns the encoded size and span count of the pending batch.
+func (b *TracesBatcher) Pending() (bytes, items int) { return len(b.b.buf), b.b.items }
+
+// MetricsBatcher is TracesBatcher for metrics; items are data points.
+type MetricsBatcher struct{ b batcher }
+
+// NewMetricsBatcher returns a batcher that calls flush with each completed
+// request, as NewTracesBatcher.
+func NewMetricsBatcher(cfg BatchConfig, flush func(ExportMetricsServiceRequest) error) *MetricsBatcher {
+	return &MetricsBatcher{b: newBatcher(cfg, countInResourceMetrics, func(data []byte) error {
+		return flush(ExportMetricsServiceRequest(data))
+	})}
+}
+
+// Add appends a ResourceMetrics to the pending batch, flushing before or
+// after as the thresholds require.
+func (b *MetricsBatcher) Add(rm ResourceMetrics) error { return b.b.add(rm) }
+
+// AddRequest adds every ResourceMetrics of req in order, stopping at
+// the first error.
+func (b *MetricsBatcher) AddRequest(req ExportMetricsServiceRequest) error {
+	return b.b.addRequest(req)
+}
+
+// Flush flushes the pending batch, if any.
+func (b *MetricsBatcher) Flush() error { return b.b.flush() }
+
+// FlushExpired flushes the pending batch if it is older than MaxAge.
+func (b *MetricsBatcher) FlushExpired() error { return b.b.flushExpired() }
+
+// Pending returns the encoded size and data point count of the pending
+// batch.
+func (b *MetricsBatcher) Pending() (bytes, items int) { return len(b.b.buf), b.b.items }
+
+// LogsBatcher is TracesBatcher for logs; items are log records.
+type LogsBatcher struct{ b batcher }
+
+// NewLogsBatcher returns a batcher that calls flush with each completed
+// request, as NewTracesBatcher.
+func NewLogsBatcher(cfg BatchConfig, flush func(ExportLogsServiceRequest) error) *LogsBatcher {
+	return &LogsBatcher{b: newBatcher(cfg, countInResourceLogs, func(data []byte) error {
+		return flush(ExportLogsServiceRequest(data))
+	})}
+}
+
+// Add appends a ResourceLogs to the pending batch, flushing before or after
+// as the thresholds require.
+func (b *LogsBatcher) Add(rl ResourceLogs) error { return b.b.add(rl) }
+
+// AddRequest adds every ResourceLogs of req in order, stopping at
+// the first error.
+func (b *LogsBatcher) AddRequest(req ExportLogsServiceRequest) error {
+	return b.b.addRequest(req)
+}
+
+// Flush flushes the pending batch, if any.
+func (b *LogsBatcher) Flush() error { return b.b.flush() }
+
+// FlushExpired flushes the pending batch if it is older than MaxAge.
+func (b *LogsBatcher) FlushExpired() error { return b.b.flushExpired() }
+
+// Pending returns the encoded size and log record count of the pending
+// batch.
+func (b *LogsBatcher) Pending() (bytes, items int) { return len(b.b.buf), b.b.items }
+
+// batcher is the signal-independent core of the batchers. buf holds the
+// pending request: resource messages wrapped as field 1.
+type batcher struct {
+	cfg     BatchConfig
+	count   func([]byte) (int, error)
+	onFlush func([]byte) error
+	buf     []byte
+	items   int
+	started time.Time
+}
+
+func newBatcher(cfg BatchConfig, count func([]byte) (int, error), onFlush func([]byte) error) batcher {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return batcher{cfg: cfg, count: count, onFlush: onFlush}
+}
+
+func (b *batcher) add(resource []byte) error {
+	items, err := b.count(resource)
+	if err != nil {
+		return err
+	}
+	size := protowire.SizeTag(1) + protowire.SizeBytes(len(resource))
+
+	var flushErr error
+	if len(b.buf) > 0 && (b.cfg.MaxBytes > 0 && len(b.buf)+size > b.cfg.MaxBytes ||
+		b.cfg.MaxItems > 0 && b.items+items > b.cfg.MaxItems) {
+		flushErr = b.flush()
+	}
+
+	if len(b.buf) == 0 {
+		b.started = b.cfg.Now()
+		if b.buf == nil && b.cfg.Pool != nil {
+			b.buf = b.cfg.Pool.Get(max(b.cfg.MaxBytes, size))
+		}
+	}
+	b.buf = protowire.AppendTag(b.buf, 1, protowire.BytesType)
+	b.buf = protowire.AppendBytes(b.buf, resource)
+	b.items += items
+
+	if b.cfg.MaxBytes > 0 && len(b.buf) >= b.cfg.MaxBytes ||
+		b.cfg.MaxItems > 0 && b.items >= b.cfg.MaxItems ||
+		b.expired() {
+		return errors.Join(flushErr, b.flush())
+	}
+	return flushErr
+}
+
+func (b *batcher) addRequest(req []byte) error {
+	return forEachMessage(req, 1, b.add)
+}
+
+func (b *batcher) expired() bool {
+	return b.cfg.MaxAge > 0 && len(b.buf) > 0 && b.cfg.Now().Sub(b.started) >= b.cfg.MaxAge
+}
+
+func (b *batcher) flushExpired() error {
+	if !b.expired() {
+		return nil
+	}
+	return b.flush()
+}
+
+func (b *batcher) flush() error {
+	if len(b.buf) == 0 {
+		return nil
+	}
+	data := b.buf
+	b.buf, b.items = nil, 0
+	return b.onFlush(data)
+}
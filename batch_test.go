@@ -0,0 +1,136 @@
+package otlpwire
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// tracesWithResources returns a request with one resource per entry of
+// spans, holding that many spans.
+func tracesWithResources(t testing.TB, spans ...int) ExportTracesServiceRequest {
+	t.Helper()
+	traces := ptrace.NewTraces()
+	for i, n := range spans {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutInt("index", int64(i))
+		ss := rs.ScopeSpans().AppendEmpty()
+		for range n {
+			ss.Spans().AppendEmpty().SetName("span")
+		}
+	}
+	return marshalTraces(t, traces)
+}
+
+func TestTracesBatcher_MaxItems(t *testing.T) {
+	var flushed []int
+	b := NewTracesBatcher(BatchConfig{MaxItems: 5}, func(req ExportTracesServiceRequest) error {
+		n, err := req.SpanCount()
+		require.NoError(t, err)
+		flushed = append(flushed, n)
+		return nil
+	})
+
+	require.NoError(t, b.AddRequest(tracesWithResources(t, 2, 2, 2, 7, 5, 1)))
+	// 2+2 flushes before the third resource would exceed 5; 7 is larger
+	// than the limit and flushed alone; 5 reaches the limit.
+	require.Equal(t, []int{4, 2, 7, 5}, flushed)
+	_, items := b.Pending()
+	require.Equal(t, 1, items)
+
+	require.NoError(t, b.Flush())
+	require.Equal(t, []int{4, 2, 7, 5, 1}, flushed)
+	bytes, items := b.Pending()
+	require.Zero(t, bytes)
+	require.Zero(t, items)
+
+	// Flushing an empty batcher is a no-op.
+	require.NoError(t, b.Flush())
+	require.Len(t, flushed, 5)
+}
+
+func TestTracesBatcher_MaxBytes(t *testing.T) {
+	req := tracesWithResources(t, 3, 3, 3)
+	var resources []ResourceSpans
+	seq, errFn := req.ResourceSpans()
+	for rs := range seq {
+		resources = append(resources, rs)
+	}
+	require.NoError(t, errFn())
+	// Room for two resources, but not three.
+	limit := len(req) - 1
+
+	var flushed []ExportTracesServiceRequest
+	b := NewTracesBatcher(BatchConfig{MaxBytes: limit}, func(req ExportTracesServiceRequest) error {
+		flushed = append(flushed, req)
+		return nil
+	})
+	for _, rs := range resources {
+		require.NoError(t, b.Add(rs))
+	}
+	require.NoError(t, b.Flush())
+
+	require.Len(t, flushed, 2)
+	for _, req := range flushed {
+		require.LessOrEqual(t, len(req), limit)
+	}
+	require.Equal(t, req, append(append(ExportTracesServiceRequest{}, flushed[0]...), flushed[1]...))
+
+	// The flushed requests are valid OTLP.
+	got, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(flushed[0])
+	require.NoError(t, err)
+	require.Equal(t, 6, got.SpanCount())
+}
+
+func TestLogsBatcher_MaxAge(t *testing.T) {
+	now := time.Unix(0, 0)
+	var flushed int
+	b := NewLogsBatcher(BatchConfig{MaxAge: time.Second, Now: func() time.Time { return now }}, func(ExportLogsServiceRequest) error {
+		flushed++
+		return nil
+	})
+
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	req := ExportLogsServiceRequest(marshalLogs(t, logs))
+
+	require.NoError(t, b.AddRequest(req))
+	now = now.Add(500 * time.Millisecond)
+	require.NoError(t, b.FlushExpired())
+	require.Zero(t, flushed)
+
+	// The age is measured from the first resource of the batch.
+	now = now.Add(500 * time.Millisecond)
+	require.NoError(t, b.AddRequest(req))
+	require.Equal(t, 1, flushed)
+
+	require.NoError(t, b.AddRequest(req))
+	now = now.Add(time.Second)
+	require.NoError(t, b.FlushExpired())
+	require.Equal(t, 2, flushed)
+}
+
+func TestMetricsBatcher_Errors(t *testing.T) {
+	errFlush := errors.New("flush failed")
+	var flushed int
+	b := NewMetricsBatcher(BatchConfig{MaxItems: 1}, func(ExportMetricsServiceRequest) error {
+		flushed++
+		return errFlush
+	})
+
+	data := ExportMetricsServiceRequest(marshalMetrics(t, createBenchMetrics()))
+	require.ErrorIs(t, b.AddRequest(data), errFlush)
+	require.Equal(t, 1, flushed)
+	// The failed batch is handed over, not kept.
+	bytes, _ := b.Pending()
+	require.Zero(t, bytes)
+
+	require.Error(t, b.AddRequest([]byte{0x0a, 0x05}))
+	require.Error(t, b.Add(ResourceMetrics{0x12, 0x05}))
+	bytes, _ = b.Pending()
+	require.Zero(t, bytes)
+}
@@ -0,0 +1,196 @@
+// Package batchproc is a wire-level batch processor: a goroutine-safe
+// batcher that accumulates resources of one signal and hands each assembled
+// export request to a callback once it reaches a size or item threshold or
+// its maximum age. It wraps the otlpwire batchers, which are single-threaded
+// and start no timers, with a lock and an age timer.
+package batchproc
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// ErrClosed is returned by operations on a closed Batcher.
+var ErrClosed = errors.New("batchproc: batcher closed")
+
+// Config configures a Batcher.
+type Config struct {
+	// BatchConfig holds the flush thresholds, as for the otlpwire
+	// batchers. MaxAge is enforced by a timer started with each batch, so
+	// a batch is flushed on time even if no more resources arrive.
+	otlpwire.BatchConfig
+	// OnError receives the errors of flushes triggered by the age timer,
+	// which have no caller to return them to. Defaults to discarding them.
+	OnError func(error)
+}
+
+// core is the otlpwire batcher of one signal.
+type core[E, R any] interface {
+	Add(E) error
+	AddRequest(R) error
+	Flush() error
+	Pending() (bytes, items int)
+}
+
+// Batcher is a goroutine-safe batcher of resource entries E into export
+// requests R. The flush callback runs with the batcher locked, so requests
+// are delivered one at a time and in order; it must not call back into
+// the batcher.
+type Batcher[E, R ~[]byte] struct {
+	cfg       Config
+	afterFunc func(time.Duration, func()) (stop func() bool)
+
+	mu      sync.Mutex
+	core    core[E, R]
+	flushed bool // set by the flush callback
+	gen     int  // incremented with every batch started
+	stop    func() bool
+	closed  bool
+}
+
+// TracesBatcher batches ResourceSpans into ExportTracesServiceRequests.
+type TracesBatcher = Batcher[otlpwire.ResourceSpans, otlpwire.ExportTracesServiceRequest]
+
+// MetricsBatcher batches ResourceMetrics into ExportMetricsServiceRequests.
+type MetricsBatcher = Batcher[otlpwire.ResourceMetrics, otlpwire.ExportMetricsServiceRequest]
+
+// LogsBatcher batches ResourceLogs into ExportLogsServiceRequests.
+type LogsBatcher = Batcher[otlpwire.ResourceLogs, otlpwire.ExportLogsServiceRequest]
+
+// NewTraces returns a batcher that calls flush with each completed request,
+// as otlpwire.NewTracesBatcher.
+func NewTraces(cfg Config, flush func(otlpwire.ExportTracesServiceRequest) error) *TracesBatcher {
+	b := newBatcher[otlpwire.ResourceSpans, otlpwire.ExportTracesServiceRequest](cfg)
+	b.core = otlpwire.NewTracesBatcher(cfg.BatchConfig, func(req otlpwire.ExportTracesServiceRequest) error {
+		b.flushed = true
+		return flush(req)
+	})
+	return b
+}
+
+// NewMetrics returns a batcher that calls flush with each completed
+// request, as NewTraces.
+func NewMetrics(cfg Config, flush func(otlpwire.ExportMetricsServiceRequest) error) *MetricsBatcher {
+	b := newBatcher[otlpwire.ResourceMetrics, otlpwire.ExportMetricsServiceRequest](cfg)
+	b.core = otlpwire.NewMetricsBatcher(cfg.BatchConfig, func(req otlpwire.ExportMetricsServiceRequest) error {
+		b.flushed = true
+		return flush(req)
+	})
+	return b
+}
+
+// NewLogs returns a batcher that calls flush with each completed request,
+// as NewTraces.
+func NewLogs(cfg Config, flush func(otlpwire.ExportLogsServiceRequest) error) *LogsBatcher {
+	b := newBatcher[otlpwire.ResourceLogs, otlpwire.ExportLogsServiceRequest](cfg)
+	b.core = otlpwire.NewLogsBatcher(cfg.BatchConfig, func(req otlpwire.ExportLogsServiceRequest) error {
+		b.flushed = true
+		return flush(req)
+	})
+	return b
+}
+
+func newBatcher[E, R ~[]byte](cfg Config) *Batcher[E, R] {
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+	return &Batcher[E, R]{
+		cfg: cfg,
+		afterFunc: func(d time.Duration, f func()) func() bool {
+			return time.AfterFunc(d, f).Stop
+		},
+	}
+}
+
+// Add appends a resource entry to the pending batch, flushing as the
+// thresholds require. It returns the error of a flush it triggered.
+func (b *Batcher[E, R]) Add(entry E) error {
+	return b.update(func() error { return b.core.Add(entry) })
+}
+
+// AddRequest adds every resource entry of req in order, stopping at the
+// first error.
+func (b *Batcher[E, R]) AddRequest(req R) error {
+	return b.update(func() error { return b.core.AddRequest(req) })
+}
+
+// Flush flushes the pending batch, if any.
+func (b *Batcher[E, R]) Flush() error {
+	return b.update(b.core.Flush)
+}
+
+// Pending returns the encoded size and item count of the pending batch.
+func (b *Batcher[E, R]) Pending() (bytes, items int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.core.Pending()
+}
+
+// Close flushes the pending batch and stops the age timer. Later calls
+// return ErrClosed.
+func (b *Batcher[E, R]) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	b.closed = true
+	b.stopTimer()
+	return b.core.Flush()
+}
+
+// update runs op on the core batcher and restarts the age timer if op left
+// a new batch pending: one that was empty before, or that op started after
+// flushing the previous one.
+func (b *Batcher[E, R]) update(op func() error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	before, _ := b.core.Pending()
+	b.flushed = false
+	err := op()
+	after, _ := b.core.Pending()
+	switch {
+	case after == 0:
+		b.stopTimer()
+	case before == 0 || b.flushed:
+		b.startTimer()
+	}
+	return err
+}
+
+// startTimer starts the age timer of a new batch.
+func (b *Batcher[E, R]) startTimer() {
+	b.stopTimer()
+	if b.cfg.MaxAge <= 0 {
+		return
+	}
+	b.gen++
+	gen := b.gen
+	b.stop = b.afterFunc(b.cfg.MaxAge, func() { b.expire(gen) })
+}
+
+func (b *Batcher[E, R]) stopTimer() {
+	if b.stop != nil {
+		b.stop()
+		b.stop = nil
+	}
+}
+
+// expire flushes batch gen if it is still pending.
+func (b *Batcher[E, R]) expire(gen int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || gen != b.gen {
+		return
+	}
+	b.stop = nil
+	if err := b.core.Flush(); err != nil {
+		b.cfg.OnError(err)
+	}
+}
@@ -0,0 +1,155 @@
+package batchproc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// logsResource returns a ResourceLogs holding records log records.
+func logsResource(t *testing.T, records int) otlpwire.ResourceLogs {
+	t.Helper()
+	logs := plog.NewLogs()
+	lrs := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for range records {
+		lrs.AppendEmpty().Body().SetStr("hello")
+	}
+	data, err := (&plog.ProtoMarshaler{}).MarshalLogs(logs)
+	require.NoError(t, err)
+	seq, errFn := otlpwire.ExportLogsServiceRequest(data).ResourceLogs()
+	for rl := range seq {
+		require.NoError(t, errFn())
+		return rl
+	}
+	t.Fatal("no ResourceLogs")
+	return nil
+}
+
+// fakeTimers replaces the age timer of b with timers fired by hand.
+type fakeTimers struct {
+	fns     []func()
+	stopped []bool
+}
+
+func (f *fakeTimers) install(b *LogsBatcher) {
+	b.afterFunc = func(_ time.Duration, fn func()) func() bool {
+		i := len(f.fns)
+		f.fns = append(f.fns, fn)
+		f.stopped = append(f.stopped, false)
+		return func() bool {
+			f.stopped[i] = true
+			return true
+		}
+	}
+}
+
+func TestBatcher_Thresholds(t *testing.T) {
+	var got []int
+	b := NewLogs(Config{BatchConfig: otlpwire.BatchConfig{MaxItems: 5}}, func(req otlpwire.ExportLogsServiceRequest) error {
+		n, err := req.LogRecordCount()
+		got = append(got, n)
+		return err
+	})
+	for range 4 {
+		require.NoError(t, b.Add(logsResource(t, 2)))
+	}
+	_, items := b.Pending()
+	require.Equal(t, 4, items)
+	require.NoError(t, b.Close())
+	require.Equal(t, []int{4, 4}, got)
+
+	require.ErrorIs(t, b.Add(logsResource(t, 1)), ErrClosed)
+	require.ErrorIs(t, b.Close(), ErrClosed)
+}
+
+func TestBatcher_MaxAge(t *testing.T) {
+	var got []int
+	var timers fakeTimers
+	flushErr := errors.New("backend down")
+	var reported []error
+	b := NewLogs(Config{
+		BatchConfig: otlpwire.BatchConfig{MaxAge: time.Second},
+		OnError:     func(err error) { reported = append(reported, err) },
+	}, func(req otlpwire.ExportLogsServiceRequest) error {
+		n, err := req.LogRecordCount()
+		got = append(got, n)
+		if len(got) == 2 {
+			return flushErr
+		}
+		return err
+	})
+	timers.install(b)
+
+	// The timer starts with the batch, not with every add.
+	require.NoError(t, b.Add(logsResource(t, 1)))
+	require.NoError(t, b.Add(logsResource(t, 1)))
+	require.Len(t, timers.fns, 1)
+	timers.fns[0]()
+	require.Equal(t, []int{2}, got)
+
+	// A stale timer does not flush a later batch early.
+	require.NoError(t, b.Add(logsResource(t, 3)))
+	require.Len(t, timers.fns, 2)
+	timers.fns[0]()
+	require.Equal(t, []int{2}, got)
+
+	// Errors of timer flushes go to OnError.
+	timers.fns[1]()
+	require.Equal(t, []int{2, 3}, got)
+	require.Equal(t, []error{flushErr}, reported)
+
+	// Flushing by hand stops the timer.
+	require.NoError(t, b.Add(logsResource(t, 1)))
+	require.NoError(t, b.Flush())
+	require.True(t, timers.stopped[2])
+	require.NoError(t, b.Close())
+	require.Equal(t, []int{2, 3, 1}, got)
+}
+
+func TestBatcher_Concurrent(t *testing.T) {
+	var mu sync.Mutex
+	total := 0
+	b := NewLogs(Config{BatchConfig: otlpwire.BatchConfig{MaxItems: 7, MaxAge: time.Millisecond}}, func(req otlpwire.ExportLogsServiceRequest) error {
+		n, err := req.LogRecordCount()
+		mu.Lock()
+		total += n
+		mu.Unlock()
+		return err
+	})
+	rl := logsResource(t, 1)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				require.NoError(t, b.Add(rl))
+			}
+		})
+	}
+	wg.Wait()
+	require.NoError(t, b.Close())
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 800, total)
+}
+
+func TestBatcher_RealTimer(t *testing.T) {
+	done := make(chan otlpwire.ExportTracesServiceRequest, 1)
+	b := NewTraces(Config{BatchConfig: otlpwire.BatchConfig{MaxAge: 10 * time.Millisecond}}, func(req otlpwire.ExportTracesServiceRequest) error {
+		done <- req
+		return nil
+	})
+	defer b.Close()
+	require.NoError(t, b.Add(otlpwire.ResourceSpans{}))
+	select {
+	case req := <-done:
+		require.NotEmpty(t, req)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not flushed by age")
+	}
+}
@@ -2,9 +2,12 @@ package otlpwire
 
 import (
 	"fmt"
+	"iter"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
@@ -790,3 +793,318 @@ func BenchmarkMetrics_DeepIteration_Unmarshal(b *testing.B) {
 		deepIteratePdata(b, unmarshaler, bytes)
 	}
 }
+
+// ========== Writers: split, partition, rebalance, merge and filter ==========
+//
+// These benchmarks cover the operations that write new requests. They use
+// the fixtures above: 5 resources of 100 spans, data points or log records.
+
+// benchTraces returns the trace fixture with distinct trace and span IDs,
+// ten traces per resource, and every tenth span marked as an error.
+func benchTraces(b *testing.B) ExportTracesServiceRequest {
+	b.Helper()
+	traces := createBenchTraces()
+	for ri, rs := range traces.ResourceSpans().All() {
+		for si, span := range rs.ScopeSpans().At(0).Spans().All() {
+			span.SetTraceID(pcommon.TraceID{byte(ri), byte(si % 10), 1})
+			span.SetSpanID(pcommon.SpanID{byte(ri), byte(si), 1})
+			if si%10 == 9 {
+				span.Status().SetCode(ptrace.StatusCodeError)
+			}
+		}
+	}
+	bytes, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(traces)
+	require.NoError(b, err)
+	return bytes
+}
+
+func benchMetrics(b *testing.B) ExportMetricsServiceRequest {
+	b.Helper()
+	bytes, err := (&pmetric.ProtoMarshaler{}).MarshalMetrics(createBenchMetrics())
+	require.NoError(b, err)
+	return bytes
+}
+
+func benchLogs(b *testing.B) ExportLogsServiceRequest {
+	b.Helper()
+	bytes, err := (&plog.ProtoMarshaler{}).MarshalLogs(createBenchLogs())
+	require.NoError(b, err)
+	return bytes
+}
+
+// drain ranges over seq and returns its deferred error.
+func drain[T any](seq iter.Seq[T], errFn func() error) error {
+	for range seq {
+	}
+	return errFn()
+}
+
+// drain2 is drain for keyed sequences.
+func drain2[K, T any](seq iter.Seq2[K, T], errFn func() error) error {
+	for range seq {
+	}
+	return errFn()
+}
+
+func BenchmarkTraces_SplitBySize_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain(req.SplitBySize(len(req) / 8)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_SplitByCount_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain(req.SplitByCount(64)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_ResourceSplitByCount_WireFormat(b *testing.B) {
+	rs, err := req0(benchTraces(b))
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain(rs.SplitByCount(16)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// req0 returns the first ResourceSpans of req.
+func req0(req ExportTracesServiceRequest) (ResourceSpans, error) {
+	seq, errFn := req.ResourceSpans()
+	for rs := range seq {
+		return rs, nil
+	}
+	return nil, errFn()
+}
+
+func BenchmarkTraces_SplitByResource_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain(req.SplitByResource()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_SplitByResourceAndSize_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain(req.SplitByResourceAndSize(len(req) / 8)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_SplitByAttribute_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain2(req.SplitByAttribute("service.name")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_SplitByTraceID_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain2(req.SplitByTraceID()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_SplitByTraceIDHash_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain2(req.SplitByTraceIDHash(4)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_SplitSplitter_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	s := SplitterFunc(func(level SplitLevel, msg []byte) (string, bool, error) {
+		if level != SplitScope {
+			return "", false, nil
+		}
+		return "scope", true, nil
+	})
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain2(req.Split(s)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMetrics_SplitByMetricPrefix_WireFormat(b *testing.B) {
+	req := benchMetrics(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain2(req.SplitByMetricPrefix("request.", "system.")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogs_SplitBySeverity_WireFormat(b *testing.B) {
+	req := benchLogs(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := req.SplitBySeverity(9, 17); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_Rebalance_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := drain(RebalanceTraces(len(req)/3, req, req, req)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_Rebatch_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := RebatchTraces([][]byte{req, req, req}, len(req)/3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_AsExportRequest_WireFormat(b *testing.B) {
+	rs, err := req0(benchTraces(b))
+	require.NoError(b, err)
+	buf := make([]byte, 0, rs.SizeOfAsExportRequest())
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = rs.AppendAsExportRequest(buf[:0])
+	}
+}
+
+func BenchmarkTraces_AppendResourceSpans_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	seq, errFn := req.ResourceSpans()
+	entries := slices.Collect(seq)
+	require.NoError(b, errFn())
+	buf := make(ExportTracesServiceRequest, 0, len(req))
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = buf[:0].AppendResourceSpans(entries...)
+	}
+}
+
+func BenchmarkTraces_MergeScopes_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.MergeScopes(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogs_DropDuplicateLogRecords_WireFormat(b *testing.B) {
+	req := benchLogs(b)
+	req = append(req[:len(req):len(req)], req...)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.DropDuplicateLogRecords(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_FilterResources_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		if _, _, err := req.FilterResources(func(ResourceSpans) bool { i++; return i%2 == 0 }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_DropResourcesWhere_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.DropResourcesWhere("service.name", "service-C"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_KeepErrorSpans_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.KeepErrorSpans(false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_KeepErrorSpansWithLocalRoots_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.KeepErrorSpans(true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMetrics_FilterMetricNames_WireFormat(b *testing.B) {
+	req := benchMetrics(b)
+	f := NameFilter{DenyPrefixes: []string{"request."}}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.FilterMetricNames(f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMetrics_FilterMetricNamesKeepAll_WireFormat(b *testing.B) {
+	req := benchMetrics(b)
+	f := NameFilter{Deny: []string{"system.cpu.time"}}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.FilterMetricNames(f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTraces_FilterScopeNames_WireFormat(b *testing.B) {
+	req := benchTraces(b)
+	f := NameFilter{Deny: []string{"test-instrumentation"}}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := req.FilterScopeNames(f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
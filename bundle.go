@@ -0,0 +1,112 @@
+package otlpwire
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// Bundle holds the traces, metrics and logs of one unit of pipeline work,
+// such as everything received from one tenant in a flush interval, with
+// metadata they share, so stages can pass one value instead of three. Any
+// of the payloads may be nil. Envelopes converts a Bundle to the per-signal
+// form for queues.
+type Bundle struct {
+	Traces     ExportTracesServiceRequest
+	Metrics    ExportMetricsServiceRequest
+	Logs       ExportLogsServiceRequest
+	Tenant     string
+	ReceivedAt time.Time // zero if unknown
+
+	// self and owned track the payloads Add allocated, which it appends to
+	// in place: only while the payload field still holds the slice Add
+	// returned and the bundle has not been copied since.
+	self  *Bundle
+	owned [3][]byte
+}
+
+// BundleStats summarizes the payloads of a Bundle.
+type BundleStats struct {
+	Spans      int
+	DataPoints int
+	LogRecords int
+	// Bytes is the combined encoded size of the payloads.
+	Bytes int
+}
+
+// Items returns the total number of spans, data points and log records.
+func (s BundleStats) Items() int {
+	return s.Spans + s.DataPoints + s.LogRecords
+}
+
+// Stats counts the items of every payload of the bundle.
+func (b Bundle) Stats() (BundleStats, error) {
+	var s BundleStats
+	var err error
+	if s.Spans, err = countSpans(b.Traces); err != nil {
+		return BundleStats{}, err
+	}
+	if s.DataPoints, err = countMetricDataPoints(b.Metrics); err != nil {
+		return BundleStats{}, err
+	}
+	if s.LogRecords, err = countLogRecords(b.Logs); err != nil {
+		return BundleStats{}, err
+	}
+	s.Bytes = len(b.Traces) + len(b.Metrics) + len(b.Logs)
+	return s, nil
+}
+
+// Envelopes returns an Envelope for every non-empty payload of the bundle,
+// in the order traces, metrics, logs, each carrying the bundle's metadata.
+// The payloads are not copied.
+func (b Bundle) Envelopes() []Envelope {
+	var out []Envelope
+	for _, p := range []struct {
+		signal  Signal
+		payload []byte
+	}{
+		{SignalTraces, b.Traces},
+		{SignalMetrics, b.Metrics},
+		{SignalLogs, b.Logs},
+	} {
+		if len(p.payload) > 0 {
+			out = append(out, Envelope{Signal: p.signal, Payload: p.payload, Tenant: b.Tenant, ReceivedAt: b.ReceivedAt})
+		}
+	}
+	return out
+}
+
+// Add appends the payload of e to the bundle's payload of the same signal.
+// Concatenated export requests form a valid request holding the resources
+// of both. The first Add to a payload copies it, so neither e's nor the
+// caller's backing array is written to; later ones append to that copy
+// with amortized growth. The metadata of e is ignored. Add returns an error
+// if e has an unknown signal.
+func (b *Bundle) Add(e Envelope) error {
+	switch e.Signal {
+	case SignalTraces:
+		b.Traces = b.grow(0, b.Traces, e.Payload)
+	case SignalMetrics:
+		b.Metrics = b.grow(1, b.Metrics, e.Payload)
+	case SignalLogs:
+		b.Logs = b.grow(2, b.Logs, e.Payload)
+	default:
+		return errors.New("envelope has unknown signal")
+	}
+	return nil
+}
+
+// grow appends data to payload, the payload in slot i of owned, in place if
+// the bundle owns it and to a copy otherwise.
+func (b *Bundle) grow(i int, payload, data []byte) []byte {
+	if b.self != b {
+		b.self, b.owned = b, [3][]byte{}
+	}
+	if owned := b.owned[i]; cap(owned) == 0 || len(payload) != len(owned) ||
+		cap(payload) != cap(owned) || &payload[:1][0] != &owned[:1][0] {
+		payload = slices.Clip(payload)
+	}
+	payload = append(payload, data...)
+	b.owned[i] = payload
+	return payload
+}
@@ -0,0 +1,116 @@
+package otlpwire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestBundle(t *testing.T) {
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	spans.AppendEmpty()
+	spans.AppendEmpty()
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+
+	received := time.Unix(1700000000, 0)
+	b := Bundle{
+		Traces:     ExportTracesServiceRequest(marshalTraces(t, traces)),
+		Metrics:    ExportMetricsServiceRequest(buildAllTypesMetrics(t)),
+		Tenant:     "acme",
+		ReceivedAt: received,
+	}
+	require.NoError(t, b.Add(Envelope{Signal: SignalLogs, Payload: marshalLogs(t, logs)}))
+	require.NoError(t, b.Add(Envelope{Signal: SignalLogs, Payload: marshalLogs(t, logs)}))
+
+	stats, err := b.Stats()
+	require.NoError(t, err)
+	require.Equal(t, BundleStats{
+		Spans:      2,
+		DataPoints: 10,
+		LogRecords: 2,
+		Bytes:      len(b.Traces) + len(b.Metrics) + len(b.Logs),
+	}, stats)
+	require.Equal(t, 14, stats.Items())
+
+	envs := b.Envelopes()
+	require.Len(t, envs, 3)
+	for i, signal := range []Signal{SignalTraces, SignalMetrics, SignalLogs} {
+		require.Equal(t, signal, envs[i].Signal)
+		require.Equal(t, "acme", envs[i].Tenant)
+		require.Equal(t, received, envs[i].ReceivedAt)
+	}
+	n, err := envs[2].ItemCount()
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	require.Empty(t, Bundle{Tenant: "acme"}.Envelopes())
+	stats, err = Bundle{}.Stats()
+	require.NoError(t, err)
+	require.Zero(t, stats)
+}
+
+func TestBundleAdd(t *testing.T) {
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	payload := marshalLogs(t, logs)
+
+	// A caller's payload with spare capacity must not have its tail
+	// overwritten when Add grows the bundle.
+	backing := make([]byte, len(payload), 2*len(payload)+8)
+	copy(backing, payload)
+	sentinel := backing[:cap(backing)]
+	for i := len(payload); i < len(sentinel); i++ {
+		sentinel[i] = 0xff
+	}
+
+	b := Bundle{Logs: backing}
+	require.NoError(t, b.Add(Envelope{Signal: SignalLogs, Payload: payload}))
+	for _, c := range sentinel[len(payload):] {
+		require.Equal(t, byte(0xff), c)
+	}
+	n, err := b.Logs.LogRecordCount()
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	// Later adds append to the bundle's own buffer in place whenever it has
+	// room, so the buffer is reallocated only as it doubles.
+	reallocs := 0
+	for range 32 {
+		before := &b.Logs[0]
+		require.NoError(t, b.Add(Envelope{Signal: SignalLogs, Payload: payload}))
+		if &b.Logs[0] != before {
+			reallocs++
+		}
+	}
+	require.LessOrEqual(t, reallocs, 6)
+	n, err = b.Logs.LogRecordCount()
+	require.NoError(t, err)
+	require.Equal(t, 34, n)
+
+	// A copy of the bundle does not share the buffer it grows.
+	logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().AppendEmpty()
+	double := marshalLogs(t, logs)
+	c := b
+	require.NoError(t, c.Add(Envelope{Signal: SignalLogs, Payload: double}))
+	require.NoError(t, b.Add(Envelope{Signal: SignalLogs, Payload: payload}))
+	for want, logs := range map[int]ExportLogsServiceRequest{36: c.Logs, 35: b.Logs} {
+		n, err = logs.LogRecordCount()
+		require.NoError(t, err)
+		require.Equal(t, want, n)
+	}
+
+	// Nor does a payload the caller assigned.
+	b.Logs = backing
+	require.NoError(t, b.Add(Envelope{Signal: SignalLogs, Payload: payload}))
+	for _, c := range sentinel[len(payload):] {
+		require.Equal(t, byte(0xff), c)
+	}
+
+	require.Error(t, b.Add(Envelope{Signal: SignalUnspecified, Payload: payload}))
+	require.Error(t, b.Add(Envelope{Signal: Signal(9), Payload: payload}))
+}
@@ -0,0 +1,604 @@
+package otlpwire
+
+import (
+	"errors"
+	"iter"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SplitBySize returns an iterator over requests of at most maxBytes encoded
+// bytes that together hold the spans of t, in order. Whole resources are
+// packed into each request as they fit. A resource too large for a request
+// of its own is split between its scopes, and a scope between its spans,
+// with the Resource, scope and other envelope fields repeated in every
+// request that holds part of it. A single span too large for maxBytes in
+// its envelopes is yielded alone, oversized. Each request is newly
+// allocated.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) SplitBySize(maxBytes int) (iter.Seq[ExportTracesServiceRequest], func() error) {
+	return t.SplitBySizeWith(maxBytes, nil)
+}
+
+// SplitBySize returns an iterator over requests of at most maxBytes encoded
+// bytes, as ExportTracesServiceRequest.SplitBySize. Metrics are split
+// between their data points if needed, repeating the metric's name,
+// description, unit and aggregation fields.
+func (m ExportMetricsServiceRequest) SplitBySize(maxBytes int) (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	return m.SplitBySizeWith(maxBytes, nil)
+}
+
+// SplitBySize returns an iterator over requests of at most maxBytes encoded
+// bytes, as ExportTracesServiceRequest.SplitBySize.
+func (l ExportLogsServiceRequest) SplitBySize(maxBytes int) (iter.Seq[ExportLogsServiceRequest], func() error) {
+	return l.SplitBySizeWith(maxBytes, nil)
+}
+
+// SplitBySizeWith is SplitBySize writing every request into a buffer from
+// alloc. A nil alloc allocates from the heap. Each request belongs to the
+// caller, who may return it to a BufferPool once it is sent.
+func (t ExportTracesServiceRequest) SplitBySizeWith(maxBytes int, alloc Allocator) (iter.Seq[ExportTracesServiceRequest], func() error) {
+	return splitSeq[ExportTracesServiceRequest](t, &tracesChunking, chunkLimits{maxBytes: maxBytes}, alloc)
+}
+
+// SplitBySizeWith is SplitBySize writing every request into a buffer from
+// alloc, as ExportTracesServiceRequest.SplitBySizeWith.
+func (m ExportMetricsServiceRequest) SplitBySizeWith(maxBytes int, alloc Allocator) (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	return splitSeq[ExportMetricsServiceRequest](m, &metricsChunking, chunkLimits{maxBytes: maxBytes}, alloc)
+}
+
+// SplitBySizeWith is SplitBySize writing every request into a buffer from
+// alloc, as ExportTracesServiceRequest.SplitBySizeWith.
+func (l ExportLogsServiceRequest) SplitBySizeWith(maxBytes int, alloc Allocator) (iter.Seq[ExportLogsServiceRequest], func() error) {
+	return splitSeq[ExportLogsServiceRequest](l, &logsChunking, chunkLimits{maxBytes: maxBytes}, alloc)
+}
+
+// SplitByResource returns an iterator over requests holding one resource
+// of t each, in order. Requests are built one at a time as iteration
+// proceeds, so at most one is held in memory; each is newly allocated, and
+// may be retained or modified. To write resources out without copying
+// them, iterate ResourceSpans and use ResourceSpans.WriteTo instead.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) SplitByResource() (iter.Seq[ExportTracesServiceRequest], func() error) {
+	return t.SplitByResourceWith(nil)
+}
+
+// SplitByResource returns an iterator over requests holding one resource
+// each, as ExportTracesServiceRequest.SplitByResource.
+func (m ExportMetricsServiceRequest) SplitByResource() (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	return m.SplitByResourceWith(nil)
+}
+
+// SplitByResource returns an iterator over requests holding one resource
+// each, as ExportTracesServiceRequest.SplitByResource.
+func (l ExportLogsServiceRequest) SplitByResource() (iter.Seq[ExportLogsServiceRequest], func() error) {
+	return l.SplitByResourceWith(nil)
+}
+
+// SplitByResourceWith is SplitByResource writing every request into a
+// buffer from alloc, as ExportTracesServiceRequest.SplitBySizeWith.
+func (t ExportTracesServiceRequest) SplitByResourceWith(alloc Allocator) (iter.Seq[ExportTracesServiceRequest], func() error) {
+	return splitSeq[ExportTracesServiceRequest](t, &tracesChunking, chunkLimits{perResource: true}, alloc)
+}
+
+// SplitByResourceWith is SplitByResource writing every request into a
+// buffer from alloc, as ExportTracesServiceRequest.SplitBySizeWith.
+func (m ExportMetricsServiceRequest) SplitByResourceWith(alloc Allocator) (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	return splitSeq[ExportMetricsServiceRequest](m, &metricsChunking, chunkLimits{perResource: true}, alloc)
+}
+
+// SplitByResourceWith is SplitByResource writing every request into a
+// buffer from alloc, as ExportTracesServiceRequest.SplitBySizeWith.
+func (l ExportLogsServiceRequest) SplitByResourceWith(alloc Allocator) (iter.Seq[ExportLogsServiceRequest], func() error) {
+	return splitSeq[ExportLogsServiceRequest](l, &logsChunking, chunkLimits{perResource: true}, alloc)
+}
+
+// SplitByResourceAndSize returns an iterator over requests holding one
+// resource each, with every resource of t whose request would exceed
+// maxBytes encoded bytes chunked as by SplitBySize. Unlike SplitBySize,
+// small resources are never packed together.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) SplitByResourceAndSize(maxBytes int) (iter.Seq[ExportTracesServiceRequest], func() error) {
+	if maxBytes <= 0 {
+		return failedSeq[ExportTracesServiceRequest](errors.New("maxBytes must be positive"))
+	}
+	return splitSeq[ExportTracesServiceRequest](t, &tracesChunking, chunkLimits{maxBytes: maxBytes, perResource: true}, nil)
+}
+
+// SplitByResourceAndSize returns an iterator over requests holding one
+// resource each, as ExportTracesServiceRequest.SplitByResourceAndSize.
+func (m ExportMetricsServiceRequest) SplitByResourceAndSize(maxBytes int) (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	if maxBytes <= 0 {
+		return failedSeq[ExportMetricsServiceRequest](errors.New("maxBytes must be positive"))
+	}
+	return splitSeq[ExportMetricsServiceRequest](m, &metricsChunking, chunkLimits{maxBytes: maxBytes, perResource: true}, nil)
+}
+
+// SplitByResourceAndSize returns an iterator over requests holding one
+// resource each, as ExportTracesServiceRequest.SplitByResourceAndSize.
+func (l ExportLogsServiceRequest) SplitByResourceAndSize(maxBytes int) (iter.Seq[ExportLogsServiceRequest], func() error) {
+	if maxBytes <= 0 {
+		return failedSeq[ExportLogsServiceRequest](errors.New("maxBytes must be positive"))
+	}
+	return splitSeq[ExportLogsServiceRequest](l, &logsChunking, chunkLimits{maxBytes: maxBytes, perResource: true}, nil)
+}
+
+// SplitByCount returns an iterator over requests of at most maxSpans spans
+// that together hold the spans of t, in order, as SplitBySize: resources
+// and scopes are split between requests only when they hold more than
+// maxSpans spans, with their envelopes repeated in every request.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) SplitByCount(maxSpans int) (iter.Seq[ExportTracesServiceRequest], func() error) {
+	return splitSeq[ExportTracesServiceRequest](t, &tracesChunking, chunkLimits{maxItems: maxSpans}, nil)
+}
+
+// SplitByCount returns an iterator over requests of at most maxDataPoints
+// data points, as ExportTracesServiceRequest.SplitByCount. Metrics are split
+// between their data points if needed.
+func (m ExportMetricsServiceRequest) SplitByCount(maxDataPoints int) (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	return splitSeq[ExportMetricsServiceRequest](m, &metricsChunking, chunkLimits{maxItems: maxDataPoints}, nil)
+}
+
+// SplitByCount returns an iterator over requests of at most maxLogRecords
+// log records, as ExportTracesServiceRequest.SplitByCount.
+func (l ExportLogsServiceRequest) SplitByCount(maxLogRecords int) (iter.Seq[ExportLogsServiceRequest], func() error) {
+	return splitSeq[ExportLogsServiceRequest](l, &logsChunking, chunkLimits{maxItems: maxLogRecords}, nil)
+}
+
+// SplitByCount returns an iterator over copies of r holding at most maxSpans
+// spans each, together all spans of r in order, for a resource too large to
+// send or process at once. Every copy repeats the Resource, schema URL and
+// the envelopes of the scopes it holds spans of; a scope is split only when
+// it does not fit a copy of its own.
+// The returned function should be called after iteration to check for errors.
+func (r ResourceSpans) SplitByCount(maxSpans int) (iter.Seq[ResourceSpans], func() error) {
+	return splitSeqAt[ResourceSpans](r, 1, &tracesChunking, chunkLimits{maxItems: maxSpans}, nil)
+}
+
+// SplitByCount returns an iterator over copies of r holding at most
+// maxDataPoints data points each, as ResourceSpans.SplitByCount.
+func (r ResourceMetrics) SplitByCount(maxDataPoints int) (iter.Seq[ResourceMetrics], func() error) {
+	return splitSeqAt[ResourceMetrics](r, 1, &metricsChunking, chunkLimits{maxItems: maxDataPoints}, nil)
+}
+
+// SplitByCount returns an iterator over copies of r holding at most
+// maxLogRecords log records each, as ResourceSpans.SplitByCount.
+func (r ResourceLogs) SplitByCount(maxLogRecords int) (iter.Seq[ResourceLogs], func() error) {
+	return splitSeqAt[ResourceLogs](r, 1, &logsChunking, chunkLimits{maxItems: maxLogRecords}, nil)
+}
+
+// RebalanceTraces returns an iterator over requests of at most targetBytes
+// encoded bytes that together hold the resources of reqs, in order. Small
+// resources, such as the single-span entries of fragmented agent traffic,
+// are packed together across request boundaries, and resources larger than
+// targetBytes are split as by ExportTracesServiceRequest.SplitBySize, so
+// that every request but the last is close to targetBytes. Entries are
+// packed as they are; merge equal resources first with MergeTracesDedup.
+// The returned function should be called after iteration to check for errors.
+func RebalanceTraces(targetBytes int, reqs ...ExportTracesServiceRequest) (iter.Seq[ExportTracesServiceRequest], func() error) {
+	return rebalanceSeq(targetBytes, &tracesChunking, reqs)
+}
+
+// RebalanceMetrics returns an iterator over requests of at most targetBytes
+// encoded bytes, as RebalanceTraces.
+func RebalanceMetrics(targetBytes int, reqs ...ExportMetricsServiceRequest) (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	return rebalanceSeq(targetBytes, &metricsChunking, reqs)
+}
+
+// RebalanceLogs returns an iterator over requests of at most targetBytes
+// encoded bytes, as RebalanceTraces.
+func RebalanceLogs(targetBytes int, reqs ...ExportLogsServiceRequest) (iter.Seq[ExportLogsServiceRequest], func() error) {
+	return rebalanceSeq(targetBytes, &logsChunking, reqs)
+}
+
+// RebatchTraces turns many small raw requests, such as the messages of a
+// Kafka partition, into requests of at most targetBytes encoded bytes: it
+// coalesces entries with equal resources as MergeTracesDedup, combines
+// their identical scopes as MergeScopes, and packs and splits the result as
+// RebalanceTraces. Resources keep the order of their first occurrence.
+func RebatchTraces(reqs [][]byte, targetBytes int) ([]ExportTracesServiceRequest, error) {
+	return rebatch[ExportTracesServiceRequest](reqs, targetBytes, &tracesChunking)
+}
+
+// RebatchMetrics turns many small raw requests into requests of at most
+// targetBytes encoded bytes, as RebatchTraces.
+func RebatchMetrics(reqs [][]byte, targetBytes int) ([]ExportMetricsServiceRequest, error) {
+	return rebatch[ExportMetricsServiceRequest](reqs, targetBytes, &metricsChunking)
+}
+
+// RebatchLogs turns many small raw requests into requests of at most
+// targetBytes encoded bytes, as RebatchTraces.
+func RebatchLogs(reqs [][]byte, targetBytes int) ([]ExportLogsServiceRequest, error) {
+	return rebatch[ExportLogsServiceRequest](reqs, targetBytes, &logsChunking)
+}
+
+func rebatch[R ~[]byte](reqs [][]byte, targetBytes int, schema *chunkSchema) ([]R, error) {
+	if targetBytes <= 0 {
+		return nil, errors.New("target size must be positive")
+	}
+	merged, _, err := mergeDedup(len(reqs), func(i int) []byte { return reqs[i] })
+	if err != nil {
+		return nil, err
+	}
+	merged, _, err = mergeScopes(merged)
+	if err != nil {
+		return nil, err
+	}
+	var out []R
+	err = rebalanceChunks([][]byte{merged}, schema, chunkLimits{maxBytes: targetBytes}, func(chunk []byte) bool {
+		out = append(out, R(chunk))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rebalanceSeq adapts rebalanceChunks to the iterator convention of the
+// package.
+func rebalanceSeq[R ~[]byte](targetBytes int, schema *chunkSchema, reqs []R) (iter.Seq[R], func() error) {
+	var iterErr error
+	seq := func(yield func(R) bool) {
+		data := make([][]byte, len(reqs))
+		for i, req := range reqs {
+			data[i] = req
+		}
+		iterErr = rebalanceChunks(data, schema, chunkLimits{maxBytes: targetBytes}, func(chunk []byte) bool {
+			return yield(R(chunk))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// splitSeq adapts splitChunks to the iterator convention of the package.
+func splitSeq[R ~[]byte](data []byte, schema *chunkSchema, limits chunkLimits, alloc Allocator) (iter.Seq[R], func() error) {
+	return splitSeqAt[R](data, 0, schema, limits, alloc)
+}
+
+// failedSeq returns an empty iterator reporting err.
+func failedSeq[R any](err error) (iter.Seq[R], func() error) {
+	return func(func(R) bool) {}, func() error { return err }
+}
+
+// splitSeqAt is splitSeq for a message at depth of the schema.
+func splitSeqAt[R ~[]byte](data []byte, depth int, schema *chunkSchema, limits chunkLimits, alloc Allocator) (iter.Seq[R], func() error) {
+	var iterErr error
+	seq := func(yield func(R) bool) {
+		iterErr = splitChunks(data, depth, schema, limits, alloc, func(chunk []byte) bool {
+			return yield(R(chunk))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// chunkLimits bounds the requests produced by splitChunks. A zero limit is
+// disabled.
+type chunkLimits struct {
+	maxBytes int
+	maxItems int
+	// perResource starts a new request for every resource entry.
+	perResource bool
+}
+
+// chunkSchema describes the nesting of a signal for splitChunks. Depth 0 is
+// the export request, 1 a resource entry, 2 a scope entry and so on down to
+// the items: spans and log records at depth 3, data points at depth 5
+// (metric 3, metric body 4).
+type chunkSchema struct {
+	// child returns the field holding the children of a message at depth,
+	// or 0 if the message is an item.
+	child func(depth int, msg []byte) (protowire.Number, error)
+	// count returns the number of items in a message at depth.
+	count func(depth int, msg []byte) (int, error)
+}
+
+var (
+	tracesChunking = chunkSchema{
+		child: recordChild,
+		count: recordCounter(countInResourceSpans, countInScopeSpans),
+	}
+	logsChunking = chunkSchema{
+		child: recordChild,
+		count: recordCounter(countInResourceLogs, countInScopeLogs),
+	}
+	metricsChunking = chunkSchema{
+		child: func(depth int, msg []byte) (protowire.Number, error) {
+			switch depth {
+			case 0:
+				return 1, nil
+			case 1, 2:
+				return 2, nil
+			case 3:
+				typ, err := metricBodyType(msg)
+				return protowire.Number(typ), err
+			case 4:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		},
+		count: func(depth int, msg []byte) (int, error) {
+			switch depth {
+			case 1:
+				return countInResourceMetrics(msg)
+			case 2:
+				return countInScopeMetrics(msg)
+			case 3:
+				return countInMetric(msg)
+			case 4:
+				return countOccurrences(msg, 1)
+			default:
+				return 1, nil
+			}
+		},
+	}
+)
+
+// recordChild is chunkSchema.child for traces and logs.
+func recordChild(depth int, _ []byte) (protowire.Number, error) {
+	switch depth {
+	case 0:
+		return 1, nil
+	case 1, 2:
+		return 2, nil
+	default:
+		return 0, nil
+	}
+}
+
+// recordCounter returns chunkSchema.count for traces or logs.
+func recordCounter(inResource, inScope func([]byte) (int, error)) func(int, []byte) (int, error) {
+	return func(depth int, msg []byte) (int, error) {
+		switch depth {
+		case 1:
+			return inResource(msg)
+		case 2:
+			return inScope(msg)
+		default:
+			return 1, nil
+		}
+	}
+}
+
+// errChunkStop unwinds splitChunks when yield returns false.
+var errChunkStop = errors.New("stop")
+
+// splitChunks splits data, a message at depth of the schema, usually an
+// export request, into messages within limits written into buffers from
+// alloc and calls yield with each, stopping when it returns false.
+func splitChunks(data []byte, depth int, schema *chunkSchema, limits chunkLimits, alloc Allocator, yield func([]byte) bool) error {
+	if limits.maxBytes < 0 || limits.maxItems < 0 || limits == (chunkLimits{}) {
+		return errors.New("split limit must be positive")
+	}
+	child, err := schema.child(depth, data)
+	if err != nil {
+		return err
+	}
+	hdr, err := appendFieldsExcept(nil, data, child)
+	if err != nil {
+		return err
+	}
+	c := &chunker{
+		schema: schema,
+		limits: limits,
+		alloc:  alloc,
+		frames: []chunkFrame{{hdr: hdr}},
+		yield:  yield,
+	}
+	return c.run(func() error {
+		return forEachMessage(data, child, func(msg []byte) error {
+			if limits.perResource && depth == 0 {
+				if err := c.flush(); err != nil {
+					return err
+				}
+			}
+			return c.visit(msg, depth+1, child)
+		})
+	})
+}
+
+// rebalanceChunks is splitChunks for the resource entries of several export
+// requests at once, packed across request boundaries. Fields of the
+// requests other than their resource entries are dropped.
+func rebalanceChunks(reqs [][]byte, schema *chunkSchema, limits chunkLimits, yield func([]byte) bool) error {
+	if limits.maxBytes <= 0 {
+		return errors.New("target size must be positive")
+	}
+	c := &chunker{
+		schema: schema,
+		limits: limits,
+		frames: []chunkFrame{{}},
+		yield:  yield,
+	}
+	return c.run(func() error {
+		for _, req := range reqs {
+			err := forEachMessage(req, 1, func(entry []byte) error {
+				return c.visit(entry, 1, 1)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// run calls add, which visits the input, and flushes the last request.
+func (c *chunker) run(add func() error) error {
+	err := add()
+	if err == nil {
+		err = c.flush()
+	}
+	if err == errChunkStop {
+		return nil
+	}
+	return err
+}
+
+// chunkFrame is a message of the pending request that is being split: its
+// fields other than the children field, and the children added so far.
+type chunkFrame struct {
+	num      protowire.Number // field number in the parent; 0 for the request
+	hdr      []byte
+	children []byte
+}
+
+// chunker accumulates the pending request of splitChunks. frames holds the
+// chain of messages from the request down to the one whose children are
+// being added; flushing closes the chain into a request and reopens it
+// empty, which repeats the envelopes in the next request.
+type chunker struct {
+	schema *chunkSchema
+	limits chunkLimits
+	alloc  Allocator // nil allocates requests from the heap
+	frames []chunkFrame
+	lens   []int // scratch space of flush
+	items  int
+	yield  func([]byte) bool
+}
+
+// visit adds msg, field num of the innermost frame, at depth.
+func (c *chunker) visit(msg []byte, depth int, num protowire.Number) error {
+	fieldLen := protowire.SizeTag(num) + protowire.SizeBytes(len(msg))
+	items := 0
+	if c.limits.maxItems > 0 {
+		var err error
+		if items, err = c.schema.count(depth, msg); err != nil {
+			return err
+		}
+	}
+	if c.fits(c.size(fieldLen), c.items+items) {
+		c.appendWhole(num, msg, items)
+		return nil
+	}
+
+	child, err := c.schema.child(depth, msg)
+	if err != nil {
+		return err
+	}
+	if child == 0 || c.fits(c.envelopeSize(fieldLen), items) {
+		if err := c.flush(); err != nil {
+			return err
+		}
+		c.appendWhole(num, msg, items)
+		return nil
+	}
+
+	// Reuse the buffers of the frame last popped from this level.
+	var prev chunkFrame
+	if n := len(c.frames); n < cap(c.frames) {
+		prev = c.frames[:n+1][n]
+	}
+	hdr, err := appendFieldsExcept(prev.hdr[:0], msg, child)
+	if err != nil {
+		return err
+	}
+	c.frames = append(c.frames, chunkFrame{num: num, hdr: hdr, children: prev.children[:0]})
+	err = forEachMessage(msg, child, func(ch []byte) error {
+		return c.visit(ch, depth+1, child)
+	})
+	if err != nil {
+		return err
+	}
+	f := c.frames[len(c.frames)-1]
+	c.frames = c.frames[:len(c.frames)-1]
+	if len(f.children) > 0 {
+		parent := &c.frames[len(c.frames)-1]
+		parent.children = appendFrame(parent.children, f, nil)
+	}
+	return nil
+}
+
+// fits reports whether a pending request of size bytes and items items is
+// within the limits.
+func (c *chunker) fits(size, items int) bool {
+	return (c.limits.maxBytes == 0 || size <= c.limits.maxBytes) &&
+		(c.limits.maxItems == 0 || items <= c.limits.maxItems)
+}
+
+// appendWhole adds msg verbatim as field num of the innermost frame.
+func (c *chunker) appendWhole(num protowire.Number, msg []byte, items int) {
+	f := &c.frames[len(c.frames)-1]
+	f.children = protowire.AppendTag(f.children, num, protowire.BytesType)
+	f.children = protowire.AppendBytes(f.children, msg)
+	c.items += items
+}
+
+// size returns the encoded size of the pending request with extra more
+// bytes of children in the innermost frame.
+func (c *chunker) size(extra int) int {
+	return c.frameSize(extra, true)
+}
+
+// envelopeSize returns the encoded size of a request holding only the
+// envelopes of the frames and extra bytes of children.
+func (c *chunker) envelopeSize(extra int) int {
+	return c.frameSize(extra, false)
+}
+
+func (c *chunker) frameSize(extra int, withChildren bool) int {
+	s := extra
+	for i := len(c.frames) - 1; i >= 0; i-- {
+		f := c.frames[i]
+		s += len(f.hdr)
+		if withChildren {
+			s += len(f.children)
+		}
+		if i > 0 {
+			s = protowire.SizeTag(f.num) + protowire.SizeBytes(s)
+		}
+	}
+	return s
+}
+
+// flush yields the pending request, if it holds anything, and empties the
+// frames. Frames without children are left out of the request. The request
+// is sized first and then encoded into a single buffer from alloc.
+func (c *chunker) flush() error {
+	// c.lens[i] is the encoded length of frame i with the frames below it.
+	c.lens = append(c.lens[:0], make([]int, len(c.frames))...)
+	last, inner := 0, 0
+	for i := len(c.frames) - 1; i >= 1; i-- {
+		f := c.frames[i]
+		if len(f.children) == 0 && last == 0 {
+			continue
+		}
+		if last == 0 {
+			last = i
+		}
+		c.lens[i] = len(f.hdr) + len(f.children) + inner
+		inner = protowire.SizeTag(f.num) + protowire.SizeBytes(c.lens[i])
+	}
+	root := &c.frames[0]
+	if len(root.children) == 0 && last == 0 {
+		return nil
+	}
+	out := allocate(c.alloc, len(root.hdr)+len(root.children)+inner)
+	out = append(append(out, root.hdr...), root.children...)
+	for i := 1; i <= last; i++ {
+		f := c.frames[i]
+		out = protowire.AppendTag(out, f.num, protowire.BytesType)
+		out = protowire.AppendVarint(out, uint64(c.lens[i]))
+		out = append(append(out, f.hdr...), f.children...)
+	}
+
+	for i := range c.frames {
+		c.frames[i].children = c.frames[i].children[:0]
+	}
+	c.items = 0
+	if !c.yield(out) {
+		return errChunkStop
+	}
+	return nil
+}
+
+// appendFrame appends f, followed by the already encoded child field inner,
+// as field f.num to dst.
+func appendFrame(dst []byte, f chunkFrame, inner []byte) []byte {
+	dst = protowire.AppendTag(dst, f.num, protowire.BytesType)
+	dst = protowire.AppendVarint(dst, uint64(len(f.hdr)+len(f.children)+len(inner)))
+	dst = append(dst, f.hdr...)
+	dst = append(dst, f.children...)
+	return append(dst, inner...)
+}
@@ -0,0 +1,468 @@
+package otlpwire
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestSplitBySize_Traces(t *testing.T) {
+	traces := ptrace.NewTraces()
+	small := traces.ResourceSpans().AppendEmpty()
+	small.Resource().Attributes().PutStr("service.name", "small")
+	small.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("small-op")
+	big := traces.ResourceSpans().AppendEmpty()
+	big.SetSchemaUrl("big-schema")
+	big.Resource().Attributes().PutStr("service.name", "big")
+	for s := range 2 {
+		ss := big.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(fmt.Sprintf("scope-%d", s))
+		for i := range 20 {
+			ss.Spans().AppendEmpty().SetName(fmt.Sprintf("op-%d-%d", s, i))
+		}
+	}
+	data := marshalTraces(t, traces)
+
+	const maxBytes = 200
+	seq, errFn := ExportTracesServiceRequest(data).SplitBySize(maxBytes)
+	var names []string
+	chunks := 0
+	for req := range seq {
+		chunks++
+		require.LessOrEqual(t, len(req), maxBytes)
+		td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(req)
+		require.NoError(t, err)
+		for _, rs := range td.ResourceSpans().All() {
+			service, _ := rs.Resource().Attributes().Get("service.name")
+			if service.Str() == "big" {
+				require.Equal(t, "big-schema", rs.SchemaUrl())
+			}
+			for _, ss := range rs.ScopeSpans().All() {
+				require.Positive(t, ss.Spans().Len())
+				for _, span := range ss.Spans().All() {
+					names = append(names, service.Str()+"/"+ss.Scope().Name()+"/"+span.Name())
+				}
+			}
+		}
+	}
+	require.NoError(t, errFn())
+	require.Greater(t, chunks, 2)
+
+	var want []string
+	want = append(want, "small//small-op")
+	for s := range 2 {
+		for i := range 20 {
+			want = append(want, fmt.Sprintf("big/scope-%d/op-%d-%d", s, s, i))
+		}
+	}
+	require.Equal(t, want, names)
+
+	// A budget that holds the whole request yields it unchanged.
+	seq, errFn = ExportTracesServiceRequest(data).SplitBySize(len(data))
+	var all []ExportTracesServiceRequest
+	for req := range seq {
+		all = append(all, req)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []ExportTracesServiceRequest{ExportTracesServiceRequest(data)}, all)
+
+	// Stopping early.
+	seq, errFn = ExportTracesServiceRequest(data).SplitBySize(maxBytes)
+	for range seq {
+		break
+	}
+	require.NoError(t, errFn())
+
+	seq, errFn = ExportTracesServiceRequest(data).SplitBySize(0)
+	for range seq {
+		t.Fatal("unexpected request")
+	}
+	require.Error(t, errFn())
+}
+
+func TestSplitBySize_OversizedSpan(t *testing.T) {
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	spans.AppendEmpty().SetName("a")
+	spans.AppendEmpty().SetName(string(make([]byte, 500)))
+	spans.AppendEmpty().SetName("b")
+
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).SplitBySize(100)
+	var counts []int
+	for req := range seq {
+		n, err := req.SpanCount()
+		require.NoError(t, err)
+		counts = append(counts, n)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []int{1, 1, 1}, counts)
+}
+
+func TestSplitBySize_Metrics(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "api")
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("requests")
+	m.SetUnit("1")
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	for i := range 50 {
+		dp := sum.DataPoints().AppendEmpty()
+		dp.SetIntValue(int64(i))
+		dp.Attributes().PutInt("i", int64(i))
+	}
+	data := marshalMetrics(t, metrics)
+
+	const maxBytes = 300
+	seq, errFn := ExportMetricsServiceRequest(data).SplitBySize(maxBytes)
+	var values []int64
+	for req := range seq {
+		require.LessOrEqual(t, len(req), maxBytes)
+		md, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(req)
+		require.NoError(t, err)
+		require.Equal(t, 1, md.MetricCount())
+		m := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+		require.Equal(t, "requests", m.Name())
+		require.Equal(t, "1", m.Unit())
+		require.True(t, m.Sum().IsMonotonic())
+		require.Equal(t, pmetric.AggregationTemporalityCumulative, m.Sum().AggregationTemporality())
+		for _, dp := range m.Sum().DataPoints().All() {
+			values = append(values, dp.IntValue())
+		}
+	}
+	require.NoError(t, errFn())
+	require.Len(t, values, 50)
+	for i, v := range values {
+		require.Equal(t, int64(i), v)
+	}
+}
+
+func TestSplitBySize_Logs(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "api")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	for i := range 30 {
+		records.AppendEmpty().Body().SetStr(fmt.Sprintf("message %d", i))
+	}
+
+	const maxBytes = 150
+	seq, errFn := ExportLogsServiceRequest(marshalLogs(t, logs)).SplitBySize(maxBytes)
+	total := 0
+	for req := range seq {
+		require.LessOrEqual(t, len(req), maxBytes)
+		n, err := req.LogRecordCount()
+		require.NoError(t, err)
+		total += n
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 30, total)
+}
+
+func TestSplitByCount(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for r := range 3 {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("service-%d", r))
+		for s := range 2 {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(fmt.Sprintf("scope-%d", s))
+			for range r + 2 {
+				ss.Spans().AppendEmpty()
+			}
+		}
+	}
+	// 4, 6 and 8 spans per resource; scopes that fit a request are not split.
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).SplitByCount(5)
+	var counts []int
+	for req := range seq {
+		n, err := req.SpanCount()
+		require.NoError(t, err)
+		counts = append(counts, n)
+		td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(req)
+		require.NoError(t, err)
+		for _, rs := range td.ResourceSpans().All() {
+			_, ok := rs.Resource().Attributes().Get("service.name")
+			require.True(t, ok)
+			for _, ss := range rs.ScopeSpans().All() {
+				require.NotEmpty(t, ss.Scope().Name())
+			}
+		}
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []int{4, 3, 3, 4, 4}, counts)
+
+	metrics := buildAllTypesMetrics(t)
+	mseq, errFn := ExportMetricsServiceRequest(metrics).SplitByCount(3)
+	total := 0
+	for req := range mseq {
+		n, err := req.DataPointCount()
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 3)
+		total += n
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 10, total)
+
+	logs := plog.NewLogs()
+	records := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for range 7 {
+		records.AppendEmpty()
+	}
+	lseq, errFn := ExportLogsServiceRequest(marshalLogs(t, logs)).SplitByCount(3)
+	counts = counts[:0]
+	for req := range lseq {
+		n, err := req.LogRecordCount()
+		require.NoError(t, err)
+		counts = append(counts, n)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []int{3, 3, 1}, counts)
+}
+
+func TestResourceSplitByCount(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("schema")
+	rs.Resource().Attributes().PutStr("service.name", "api")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("http")
+	for i := range 10 {
+		ss.Spans().AppendEmpty().SetName(fmt.Sprintf("op-%d", i))
+	}
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	var entry ResourceSpans
+	for entry = range seq {
+	}
+	require.NoError(t, errFn())
+
+	chunks, errFn := entry.SplitByCount(4)
+	var names []string
+	var counts []int
+	for chunk := range chunks {
+		service, err := chunk.ServiceName()
+		require.NoError(t, err)
+		require.Equal(t, "api", service)
+		schema, err := chunk.SchemaURL()
+		require.NoError(t, err)
+		require.Equal(t, "schema", schema)
+		n, err := chunk.SpanCount()
+		require.NoError(t, err)
+		counts = append(counts, n)
+		spans, spanErr := chunk.Spans()
+		for span := range spans {
+			name, err := span.Name()
+			require.NoError(t, err)
+			names = append(names, string(name))
+		}
+		require.NoError(t, spanErr())
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []int{4, 4, 2}, counts)
+	require.Len(t, names, 10)
+	require.Equal(t, "op-9", names[9])
+
+	metrics, errFn := ExportMetricsServiceRequest(buildAllTypesMetrics(t)).ResourceMetrics()
+	for rm := range metrics {
+		chunks, chunkErr := rm.SplitByCount(4)
+		total := 0
+		for chunk := range chunks {
+			n, err := chunk.DataPointCount()
+			require.NoError(t, err)
+			require.LessOrEqual(t, n, 4)
+			total += n
+		}
+		require.NoError(t, chunkErr())
+		require.Equal(t, 10, total)
+	}
+	require.NoError(t, errFn())
+}
+
+func TestSplitByResourceAndSize(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for r, spans := range []int{1, 1, 30} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("service-%d", r))
+		ss := rs.ScopeSpans().AppendEmpty()
+		for i := range spans {
+			ss.Spans().AppendEmpty().SetName(fmt.Sprintf("op-%d", i))
+		}
+	}
+
+	const maxBytes = 200
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).SplitByResourceAndSize(maxBytes)
+	var services []string
+	total := 0
+	for req := range seq {
+		require.LessOrEqual(t, len(req), maxBytes)
+		entries, entryErr := req.ResourceSpans()
+		n := 0
+		for rs := range entries {
+			service, err := rs.ServiceName()
+			require.NoError(t, err)
+			services = append(services, service)
+			n++
+		}
+		require.NoError(t, entryErr())
+		require.Equal(t, 1, n)
+		count, err := req.SpanCount()
+		require.NoError(t, err)
+		total += count
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 32, total)
+	require.Equal(t, []string{"service-0", "service-1"}, services[:2])
+	require.Greater(t, len(services), 3)
+	for _, service := range services[2:] {
+		require.Equal(t, "service-2", service)
+	}
+
+	mseq, errFn := ExportMetricsServiceRequest(buildScopedMetrics(t, 3, 1, 1)).SplitByResourceAndSize(1 << 20)
+	n := 0
+	for range mseq {
+		n++
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 3, n)
+
+	_, errFn = ExportLogsServiceRequest(nil).SplitByResourceAndSize(0)
+	require.Error(t, errFn())
+}
+
+func TestSplitByResource_Lazy(t *testing.T) {
+	data := buildScopedMetrics(t, 3, 2, 1)
+	seq, errFn := ExportMetricsServiceRequest(data).SplitByResource()
+	var services []string
+	for req := range seq {
+		entries, entryErr := req.ResourceMetrics()
+		for rm := range entries {
+			service, err := rm.ServiceName()
+			require.NoError(t, err)
+			services = append(services, service)
+		}
+		require.NoError(t, entryErr())
+		n, err := req.DataPointCount()
+		require.NoError(t, err)
+		require.Positive(t, n)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{"service-0", "service-1", "service-2"}, services)
+
+	// Requests are copies, and stopping early ends the walk.
+	n := 0
+	for req := range seq {
+		clear(req)
+		n++
+		break
+	}
+	require.Equal(t, 1, n)
+	require.NoError(t, errFn())
+	require.Equal(t, buildScopedMetrics(t, 3, 2, 1), data)
+}
+
+func TestRebalance(t *testing.T) {
+	// Many tiny single-span requests and one oversized resource.
+	var reqs []ExportTracesServiceRequest
+	for i := range 40 {
+		traces := ptrace.NewTraces()
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("agent-%d", i))
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("op")
+		reqs = append(reqs, ExportTracesServiceRequest(marshalTraces(t, traces)))
+	}
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for range 200 {
+		spans.AppendEmpty().SetName("batch-op")
+	}
+	reqs = append(reqs, ExportTracesServiceRequest(marshalTraces(t, traces)))
+
+	const target = 512
+	seq, errFn := RebalanceTraces(target, reqs...)
+	var sizes []int
+	total := 0
+	for req := range seq {
+		require.LessOrEqual(t, len(req), target)
+		sizes = append(sizes, len(req))
+		n, err := req.SpanCount()
+		require.NoError(t, err)
+		total += n
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 240, total)
+	require.Less(t, len(sizes), len(reqs))
+	for _, size := range sizes[:len(sizes)-1] {
+		require.Greater(t, size, target*3/4)
+	}
+
+	mseq, errFn := RebalanceMetrics(1<<20, ExportMetricsServiceRequest(buildScopedMetrics(t, 2, 1, 1)), ExportMetricsServiceRequest(buildAllTypesMetrics(t)))
+	n := 0
+	for req := range mseq {
+		count, err := req.DataPointCount()
+		require.NoError(t, err)
+		require.Equal(t, 12, count)
+		n++
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 1, n)
+
+	lseq, errFn := RebalanceLogs(0)
+	for range lseq {
+		t.Fatal("unexpected request")
+	}
+	require.Error(t, errFn())
+}
+
+func TestRebatch(t *testing.T) {
+	// Agents send a few spans of the same two services per request.
+	var reqs [][]byte
+	for i := range 30 {
+		traces := ptrace.NewTraces()
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("service-%d", i%2))
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName("http")
+		ss.Spans().AppendEmpty().SetName(fmt.Sprintf("op-%d", i))
+		reqs = append(reqs, marshalTraces(t, traces))
+	}
+
+	const target = 256
+	out, err := RebatchTraces(reqs, target)
+	require.NoError(t, err)
+	total := 0
+	for _, req := range out {
+		require.LessOrEqual(t, len(req), target)
+		td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(req)
+		require.NoError(t, err)
+		// One entry and one scope per service.
+		require.LessOrEqual(t, td.ResourceSpans().Len(), 2)
+		for _, rs := range td.ResourceSpans().All() {
+			require.Equal(t, 1, rs.ScopeSpans().Len())
+		}
+		total += td.SpanCount()
+	}
+	require.Equal(t, 30, total)
+
+	size := 0
+	for _, req := range reqs {
+		size += len(req)
+	}
+	outSize := 0
+	for _, req := range out {
+		outSize += len(req)
+	}
+	require.Less(t, outSize, size)
+
+	logs, err := RebatchLogs(nil, target)
+	require.NoError(t, err)
+	require.Empty(t, logs)
+
+	_, err = RebatchMetrics([][]byte{buildAllTypesMetrics(t)}, 0)
+	require.Error(t, err)
+}
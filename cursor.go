@@ -0,0 +1,96 @@
+package otlpwire
+
+import (
+	"errors"
+	"iter"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Cursor is a resumable position between the resources of an export request:
+// the byte offset at which parsing continues and the index of the next
+// resource. The zero Cursor is the start of the request. A Cursor is only
+// meaningful for the exact bytes it was obtained from.
+type Cursor struct {
+	Offset int
+	Index  int
+}
+
+// ResourceMetricsFrom iterates over the ResourceMetrics that follow c. Each
+// resource is yielded with the Cursor just past it; a consumer that persists
+// that Cursor after processing the resource can resume there after a crash
+// without reprocessing earlier resources. The returned function should be
+// called after iteration to check for errors.
+func (m ExportMetricsServiceRequest) ResourceMetricsFrom(c Cursor) (iter.Seq2[ResourceMetrics, Cursor], func() error) {
+	var iterErr error
+	seq := func(yield func(ResourceMetrics, Cursor) bool) {
+		iterErr = forEachResourceFrom([]byte(m), c, func(rb []byte, next Cursor) bool {
+			return yield(ResourceMetrics(rb), next)
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// ResourceLogsFrom is ResourceMetricsFrom for logs.
+func (l ExportLogsServiceRequest) ResourceLogsFrom(c Cursor) (iter.Seq2[ResourceLogs, Cursor], func() error) {
+	var iterErr error
+	seq := func(yield func(ResourceLogs, Cursor) bool) {
+		iterErr = forEachResourceFrom([]byte(l), c, func(rb []byte, next Cursor) bool {
+			return yield(ResourceLogs(rb), next)
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// ResourceSpansFrom is ResourceMetricsFrom for traces.
+func (t ExportTracesServiceRequest) ResourceSpansFrom(c Cursor) (iter.Seq2[ResourceSpans, Cursor], func() error) {
+	var iterErr error
+	seq := func(yield func(ResourceSpans, Cursor) bool) {
+		iterErr = forEachResourceFrom([]byte(t), c, func(rb []byte, next Cursor) bool {
+			return yield(ResourceSpans(rb), next)
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// forEachResourceFrom calls fn for every resource (field 1) of an export
+// request at or after c, together with the cursor past it.
+func forEachResourceFrom(data []byte, c Cursor, fn func([]byte, Cursor) bool) error {
+	if c.Offset < 0 || c.Offset > len(data) || c.Index < 0 {
+		return errors.New("cursor out of range")
+	}
+	pos, index := c.Offset, c.Index
+
+	for pos < len(data) {
+		num, wireType, tagLen := consumeTag(data[pos:])
+		if tagLen < 0 {
+			return errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if num != 1 {
+			n := skipField(data[pos:], wireType)
+			if n < 0 {
+				return errors.New("failed to skip field")
+			}
+			pos += n
+			continue
+		}
+
+		if wireType != protowire.BytesType {
+			return errors.New("wrong wire type for field")
+		}
+		msgBytes, n := consumeBytes(data[pos:])
+		if n < 0 {
+			return errors.New("invalid bytes in repeated field")
+		}
+		pos += n
+		index++
+
+		if !fn(msgBytes, Cursor{Offset: pos, Index: index}) {
+			return nil
+		}
+	}
+
+	return nil
+}
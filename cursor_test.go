@@ -0,0 +1,95 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestResourceLogsFrom_Resume(t *testing.T) {
+	logs := plog.NewLogs()
+	for _, svc := range []string{"a", "b", "c", "d"} {
+		logs.ResourceLogs().AppendEmpty().Resource().Attributes().PutStr("service.name", svc)
+	}
+	data := ExportLogsServiceRequest(marshalLogs(t, logs))
+
+	// Process two resources, then "crash" keeping the last cursor.
+	var processed []string
+	var saved Cursor
+	seq, errFn := data.ResourceLogsFrom(Cursor{})
+	for rl, next := range seq {
+		resource, err := rl.Resource()
+		require.NoError(t, err)
+		name, _, err := stringAttribute(resource, 1, "service.name")
+		require.NoError(t, err)
+		processed = append(processed, name)
+		saved = next
+		if len(processed) == 2 {
+			break
+		}
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 2, saved.Index)
+
+	seq, errFn = data.ResourceLogsFrom(saved)
+	var last Cursor
+	for rl, next := range seq {
+		resource, err := rl.Resource()
+		require.NoError(t, err)
+		name, _, err := stringAttribute(resource, 1, "service.name")
+		require.NoError(t, err)
+		processed = append(processed, name)
+		last = next
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{"a", "b", "c", "d"}, processed)
+	require.Equal(t, Cursor{Offset: len(data), Index: 4}, last)
+
+	// Resuming at the end yields nothing.
+	seq, errFn = data.ResourceLogsFrom(last)
+	for range seq {
+		t.Fatal("unexpected resource")
+	}
+	require.NoError(t, errFn())
+}
+
+func TestResourceFrom_AllSignals(t *testing.T) {
+	traces := ExportTracesServiceRequest(marshalTraces(t, createBenchTraces()))
+	want, err := traces.SpanCount()
+	require.NoError(t, err)
+	seq, errFn := traces.ResourceSpansFrom(Cursor{})
+	total := 0
+	for rs := range seq {
+		n, err := rs.SpanCount()
+		require.NoError(t, err)
+		total += n
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, want, total)
+
+	metrics := ExportMetricsServiceRequest(marshalMetrics(t, createBenchMetrics()))
+	seqM, errFn := metrics.ResourceMetricsFrom(Cursor{})
+	resources := 0
+	for range seqM {
+		resources++
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, createBenchMetrics().ResourceMetrics().Len(), resources)
+}
+
+func TestResourceFrom_InvalidCursor(t *testing.T) {
+	data := ExportLogsServiceRequest(marshalLogs(t, createBenchLogs()))
+	for _, c := range []Cursor{{Offset: -1}, {Offset: len(data) + 1}, {Index: -1}} {
+		seq, errFn := data.ResourceLogsFrom(c)
+		for range seq {
+		}
+		require.Error(t, errFn())
+	}
+
+	// A cursor that does not point at a field boundary fails to parse.
+	seq, errFn := ExportLogsServiceRequest([]byte{0x0a, 0x05}).ResourceLogsFrom(Cursor{})
+	for range seq {
+	}
+	require.Error(t, errFn())
+}
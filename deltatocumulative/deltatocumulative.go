@@ -0,0 +1,612 @@
+// Package deltatocumulative converts delta-temporality sums and histograms in
+// OTLP metrics requests to cumulative temporality, working on wire bytes.
+//
+// A Converter keeps a running total per series and rewrites every delta data
+// point as the total since the first point of that series. A series is
+// identified by the exact wire bytes of its resource, instrumentation scope
+// and data point attributes together with the metric name, unit and kind, so
+// two senders that encode the same attributes in a different order produce
+// different series. Exponential histograms and metrics that are already
+// cumulative pass through unchanged.
+package deltatocumulative
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// OTLP AggregationTemporality values.
+const (
+	temporalityDelta      = 1
+	temporalityCumulative = 2
+)
+
+// Options configures a Converter.
+type Options struct {
+	// MaxStale forgets series that have not received a point for this long.
+	// The next point of a forgotten series starts a new cumulative series.
+	// Zero keeps series forever.
+	MaxStale time.Duration
+	// MaxStreams bounds the number of tracked series. Points of new series
+	// beyond the limit are dropped. Zero means unbounded.
+	MaxStreams int
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Stats reports the state of a Converter.
+type Stats struct {
+	// Streams is the number of series currently tracked.
+	Streams int
+	// DroppedOutOfOrder counts points dropped because they were not newer
+	// than the last point of their series.
+	DroppedOutOfOrder uint64
+	// DroppedOverLimit counts points of new series dropped because of
+	// MaxStreams.
+	DroppedOverLimit uint64
+	// Evicted counts series forgotten because of MaxStale.
+	Evicted uint64
+}
+
+// stream is the accumulated state of one series.
+type stream struct {
+	start uint64 // start_time_unix_nano of the cumulative series
+	last  uint64 // time_unix_nano of the last accepted point
+	seen  time.Time
+
+	// Sums.
+	isInt    bool
+	intTotal int64
+	total    float64 // also the histogram sum
+
+	// Histograms.
+	bounds         string // raw explicit_bounds fields
+	count          uint64
+	hasSum         bool
+	buckets        []uint64
+	hasMin, hasMax bool
+	min, max       float64
+}
+
+func (s *stream) clone() *stream {
+	c := *s
+	c.buckets = slices.Clone(s.buckets)
+	return &c
+}
+
+// Converter rewrites delta metrics as cumulative. It is safe for concurrent
+// use; requests are converted one at a time.
+type Converter struct {
+	opts Options
+
+	mu      sync.Mutex
+	streams map[string]*stream
+	stats   Stats
+}
+
+// New returns a Converter with no tracked series.
+func New(opts Options) *Converter {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Converter{opts: opts, streams: make(map[string]*stream)}
+}
+
+// Stats returns a snapshot of the converter's series count and counters.
+func (c *Converter) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Streams = len(c.streams)
+	return s
+}
+
+// Convert returns a copy of req in which every delta sum and delta
+// explicit-bucket histogram is cumulative. Points that cannot be converted
+// (out of order, or over MaxStreams) are dropped, as are metrics, scopes and
+// resources left without points. If req is malformed, Convert returns an
+// error and the converter state is unchanged.
+func (c *Converter) Convert(req otlpwire.ExportMetricsServiceRequest) (otlpwire.ExportMetricsServiceRequest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.Now()
+	c.expireLocked(now)
+
+	b := &batch{c: c, now: now, pending: make(map[string]*stream)}
+	out, _, err := rewriteFields(make([]byte, 0, len(req)), req, 1, nil, b.resourceMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, s := range b.pending {
+		c.streams[key] = s
+	}
+	c.stats.DroppedOutOfOrder += b.outOfOrder
+	c.stats.DroppedOverLimit += b.overLimit
+	return out, nil
+}
+
+// expireLocked forgets series older than MaxStale. c.mu must be held.
+func (c *Converter) expireLocked(now time.Time) {
+	if c.opts.MaxStale <= 0 {
+		return
+	}
+	cutoff := now.Add(-c.opts.MaxStale)
+	for key, s := range c.streams {
+		if s.seen.Before(cutoff) {
+			delete(c.streams, key)
+			c.stats.Evicted++
+		}
+	}
+}
+
+// batch holds the series updated by one Convert call until the whole request
+// has been converted successfully.
+type batch struct {
+	c       *Converter
+	now     time.Time
+	pending map[string]*stream
+	created int // series in pending that are not in c.streams
+
+	outOfOrder, overLimit uint64
+}
+
+// lookup returns the working copy of the series at key, or nil if the series
+// is new.
+func (b *batch) lookup(key string) *stream {
+	if s, ok := b.pending[key]; ok {
+		return s
+	}
+	if s, ok := b.c.streams[key]; ok {
+		s = s.clone()
+		b.pending[key] = s
+		return s
+	}
+	return nil
+}
+
+// create starts a new series at key, or restarts an existing one, reporting
+// false if a new series would exceed MaxStreams.
+func (b *batch) create(key string, s *stream) bool {
+	_, pending := b.pending[key]
+	_, tracked := b.c.streams[key]
+	if !pending && !tracked {
+		if limit := b.c.opts.MaxStreams; limit > 0 && len(b.c.streams)+b.created >= limit {
+			b.overLimit++
+			return false
+		}
+		b.created++
+	}
+	b.pending[key] = s
+	return true
+}
+
+func (b *batch) resourceMetrics(dst, rm []byte) ([]byte, bool, error) {
+	resource, err := bytesField(rm, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	key := protowire.AppendBytes(nil, resource)
+	dst, kept, err := rewriteFields(dst, rm, 2, nil, func(dst, sm []byte) ([]byte, bool, error) {
+		return b.scopeMetrics(dst, sm, key)
+	})
+	return dst, kept > 0, err
+}
+
+func (b *batch) scopeMetrics(dst, sm, key []byte) ([]byte, bool, error) {
+	scope, err := bytesField(sm, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	key = protowire.AppendBytes(slices.Clip(key), scope)
+	dst, kept, err := rewriteFields(dst, sm, 2, nil, func(dst, m []byte) ([]byte, bool, error) {
+		return b.metric(dst, m, key)
+	})
+	return dst, kept > 0, err
+}
+
+func (b *batch) metric(dst, m, key []byte) ([]byte, bool, error) {
+	name, err := bytesField(m, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	unit, err := bytesField(m, 3)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var field protowire.Number
+	var convert func(dst, point, key []byte) ([]byte, bool, error)
+	if sum, err := bytesField(m, 7); err != nil {
+		return nil, false, err
+	} else if sum != nil {
+		t, err := temporality(sum)
+		if err != nil {
+			return nil, false, err
+		}
+		if t == temporalityDelta {
+			field, convert = 7, b.numberPoint
+		}
+	}
+	if hist, err := bytesField(m, 9); err != nil {
+		return nil, false, err
+	} else if hist != nil {
+		t, err := temporality(hist)
+		if err != nil {
+			return nil, false, err
+		}
+		if t == temporalityDelta {
+			field, convert = 9, b.histogramPoint
+		}
+	}
+	if convert == nil {
+		return append(dst, m...), true, nil
+	}
+
+	key = protowire.AppendVarint(slices.Clip(key), uint64(field))
+	key = protowire.AppendBytes(key, name)
+	key = protowire.AppendBytes(key, unit)
+	dst, kept, err := rewriteFields(dst, m, field, nil, func(dst, agg []byte) ([]byte, bool, error) {
+		dst, kept, err := rewriteFields(dst, agg, 1, []protowire.Number{2}, func(dst, point []byte) ([]byte, bool, error) {
+			return convert(dst, point, key)
+		})
+		dst = protowire.AppendTag(dst, 2, protowire.VarintType)
+		dst = protowire.AppendVarint(dst, temporalityCumulative)
+		return dst, kept > 0, err
+	})
+	return dst, kept > 0, err
+}
+
+// numberPoint converts one delta NumberDataPoint.
+func (b *batch) numberPoint(dst, p, key []byte) ([]byte, bool, error) {
+	var (
+		rest, attrs    []byte
+		start, ts      uint64
+		hasValue       bool
+		isInt          bool
+		intVal         int64
+		floatVal       float64
+		pos, fieldFrom int
+	)
+	for pos < len(p) {
+		fieldFrom = pos
+		num, typ, n := protowire.ConsumeTag(p[pos:])
+		if n < 0 {
+			return nil, false, errors.New("malformed protobuf tag")
+		}
+		pos += n
+		n = protowire.ConsumeFieldValue(num, typ, p[pos:])
+		if n < 0 {
+			return nil, false, errors.New("failed to skip field")
+		}
+		value := p[pos : pos+n]
+		pos += n
+
+		switch num {
+		case 2, 3, 4, 6:
+			if typ != protowire.Fixed64Type {
+				return nil, false, errors.New("wrong wire type for field")
+			}
+			v := binary.LittleEndian.Uint64(value)
+			switch num {
+			case 2:
+				start = v
+			case 3:
+				ts = v
+			case 4:
+				hasValue, isInt, floatVal = true, false, math.Float64frombits(v)
+			case 6:
+				hasValue, isInt, intVal = true, true, int64(v)
+			}
+		case 7:
+			attrs = append(attrs, p[fieldFrom:pos]...)
+			rest = append(rest, p[fieldFrom:pos]...)
+		default:
+			rest = append(rest, p[fieldFrom:pos]...)
+		}
+	}
+
+	seriesKey := string(key) + string(attrs)
+	s := b.lookup(seriesKey)
+	if s != nil && ts <= s.last {
+		b.outOfOrder++
+		return dst, false, nil
+	}
+	if !hasValue && s != nil {
+		isInt = s.isInt
+	}
+	if s == nil || s.isInt != isInt {
+		s = &stream{start: startOrTime(start, ts), isInt: isInt}
+		if !b.create(seriesKey, s) {
+			return dst, false, nil
+		}
+	}
+	s.intTotal += intVal
+	s.total += floatVal
+	s.last, s.seen = ts, b.now
+
+	dst = append(dst, rest...)
+	dst = appendFixed64(dst, 2, s.start)
+	dst = appendFixed64(dst, 3, ts)
+	if s.isInt {
+		dst = appendFixed64(dst, 6, uint64(s.intTotal))
+	} else {
+		dst = appendFixed64(dst, 4, math.Float64bits(s.total))
+	}
+	return dst, true, nil
+}
+
+// histogramPoint converts one delta HistogramDataPoint. A change of bucket
+// boundaries starts a new cumulative series.
+func (b *batch) histogramPoint(dst, p, key []byte) ([]byte, bool, error) {
+	var (
+		rest, attrs, bounds []byte
+		start, ts, count    uint64
+		sum, minV, maxV     float64
+		hasSum              bool
+		hasMin, hasMax      bool
+		buckets             []uint64
+		pos, fieldFrom      int
+	)
+	for pos < len(p) {
+		fieldFrom = pos
+		num, typ, n := protowire.ConsumeTag(p[pos:])
+		if n < 0 {
+			return nil, false, errors.New("malformed protobuf tag")
+		}
+		pos += n
+		n = protowire.ConsumeFieldValue(num, typ, p[pos:])
+		if n < 0 {
+			return nil, false, errors.New("failed to skip field")
+		}
+		value := p[pos : pos+n]
+		pos += n
+
+		switch num {
+		case 2, 3, 4, 5, 11, 12:
+			if typ != protowire.Fixed64Type {
+				return nil, false, errors.New("wrong wire type for field")
+			}
+			v := binary.LittleEndian.Uint64(value)
+			switch num {
+			case 2:
+				start = v
+			case 3:
+				ts = v
+			case 4:
+				count = v
+			case 5:
+				hasSum, sum = true, math.Float64frombits(v)
+			case 11:
+				hasMin, minV = true, math.Float64frombits(v)
+			case 12:
+				hasMax, maxV = true, math.Float64frombits(v)
+			}
+		case 6:
+			var err error
+			if buckets, err = appendFixed64s(buckets, typ, value); err != nil {
+				return nil, false, err
+			}
+		case 7:
+			bounds = append(bounds, p[fieldFrom:pos]...)
+			rest = append(rest, p[fieldFrom:pos]...)
+		case 9:
+			attrs = append(attrs, p[fieldFrom:pos]...)
+			rest = append(rest, p[fieldFrom:pos]...)
+		default:
+			rest = append(rest, p[fieldFrom:pos]...)
+		}
+	}
+
+	seriesKey := string(key) + string(attrs)
+	s := b.lookup(seriesKey)
+	if s != nil && ts <= s.last {
+		b.outOfOrder++
+		return dst, false, nil
+	}
+	if s == nil || s.bounds != string(bounds) || len(s.buckets) != len(buckets) {
+		s = &stream{start: startOrTime(start, ts), bounds: string(bounds), buckets: make([]uint64, len(buckets))}
+		if !b.create(seriesKey, s) {
+			return dst, false, nil
+		}
+	}
+	s.count += count
+	if hasSum {
+		s.hasSum = true
+		s.total += sum
+	}
+	for i, c := range buckets {
+		s.buckets[i] += c
+	}
+	if hasMin && (!s.hasMin || minV < s.min) {
+		s.hasMin, s.min = true, minV
+	}
+	if hasMax && (!s.hasMax || maxV > s.max) {
+		s.hasMax, s.max = true, maxV
+	}
+	s.last, s.seen = ts, b.now
+
+	dst = append(dst, rest...)
+	dst = appendFixed64(dst, 2, s.start)
+	dst = appendFixed64(dst, 3, ts)
+	dst = appendFixed64(dst, 4, s.count)
+	if s.hasSum {
+		dst = appendFixed64(dst, 5, math.Float64bits(s.total))
+	}
+	if len(s.buckets) > 0 {
+		dst = protowire.AppendTag(dst, 6, protowire.BytesType)
+		dst = protowire.AppendVarint(dst, uint64(8*len(s.buckets)))
+		for _, c := range s.buckets {
+			dst = protowire.AppendFixed64(dst, c)
+		}
+	}
+	if s.hasMin {
+		dst = appendFixed64(dst, 11, math.Float64bits(s.min))
+	}
+	if s.hasMax {
+		dst = appendFixed64(dst, 12, math.Float64bits(s.max))
+	}
+	return dst, true, nil
+}
+
+// startOrTime returns the start time of a new cumulative series: the point's
+// start time, or its sample time if the start is unset.
+func startOrTime(start, ts uint64) uint64 {
+	if start != 0 {
+		return start
+	}
+	return ts
+}
+
+// rewriteFields appends msg to dst, passing every occurrence of the
+// length-delimited field num through fn and leaving out every field listed in
+// drop. Other fields are copied verbatim. It returns the number of children
+// fn kept.
+func rewriteFields(dst, msg []byte, num protowire.Number, drop []protowire.Number, fn func(dst, child []byte) ([]byte, bool, error)) ([]byte, int, error) {
+	kept := 0
+	var scratch []byte
+	pos := 0
+
+	for pos < len(msg) {
+		fieldFrom := pos
+		n, typ, tagLen := protowire.ConsumeTag(msg[pos:])
+		if tagLen < 0 {
+			return nil, 0, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if n != num {
+			l := protowire.ConsumeFieldValue(n, typ, msg[pos:])
+			if l < 0 {
+				return nil, 0, errors.New("failed to skip field")
+			}
+			pos += l
+			if !slices.Contains(drop, n) {
+				dst = append(dst, msg[fieldFrom:pos]...)
+			}
+			continue
+		}
+
+		if typ != protowire.BytesType {
+			return nil, 0, errors.New("wrong wire type for field")
+		}
+		child, l := protowire.ConsumeBytes(msg[pos:])
+		if l < 0 {
+			return nil, 0, errors.New("invalid bytes in repeated field")
+		}
+		pos += l
+
+		body, ok, err := fn(scratch[:0], child)
+		if err != nil {
+			return nil, 0, err
+		}
+		scratch = body
+		if !ok {
+			continue
+		}
+		dst = protowire.AppendTag(dst, num, protowire.BytesType)
+		dst = protowire.AppendBytes(dst, body)
+		kept++
+	}
+
+	return dst, kept, nil
+}
+
+// bytesField returns the last occurrence of the length-delimited field num
+// in msg, or nil if there is none.
+func bytesField(msg []byte, num protowire.Number) ([]byte, error) {
+	var found []byte
+	pos := 0
+
+	for pos < len(msg) {
+		n, typ, tagLen := protowire.ConsumeTag(msg[pos:])
+		if tagLen < 0 {
+			return nil, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if n == num {
+			if typ != protowire.BytesType {
+				return nil, errors.New("wrong wire type for field")
+			}
+			v, l := protowire.ConsumeBytes(msg[pos:])
+			if l < 0 {
+				return nil, errors.New("invalid bytes in field")
+			}
+			found = v
+			pos += l
+			continue
+		}
+
+		l := protowire.ConsumeFieldValue(n, typ, msg[pos:])
+		if l < 0 {
+			return nil, errors.New("failed to skip field")
+		}
+		pos += l
+	}
+
+	return found, nil
+}
+
+// temporality returns the aggregation_temporality (field 2) of a Sum or
+// Histogram message, or 0 if it is unset.
+func temporality(agg []byte) (uint64, error) {
+	var t uint64
+	pos := 0
+	for pos < len(agg) {
+		n, typ, tagLen := protowire.ConsumeTag(agg[pos:])
+		if tagLen < 0 {
+			return 0, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+		if n == 2 {
+			if typ != protowire.VarintType {
+				return 0, errors.New("wrong wire type for aggregation temporality")
+			}
+			v, l := protowire.ConsumeVarint(agg[pos:])
+			if l < 0 {
+				return 0, errors.New("invalid aggregation temporality")
+			}
+			t = v
+		}
+		l := protowire.ConsumeFieldValue(n, typ, agg[pos:])
+		if l < 0 {
+			return 0, errors.New("failed to skip field")
+		}
+		pos += l
+	}
+	return t, nil
+}
+
+// appendFixed64s decodes a repeated fixed64 field value, packed or not.
+func appendFixed64s(dst []uint64, typ protowire.Type, value []byte) ([]uint64, error) {
+	switch typ {
+	case protowire.Fixed64Type:
+		return append(dst, binary.LittleEndian.Uint64(value)), nil
+	case protowire.BytesType:
+		packed, n := protowire.ConsumeBytes(value)
+		if n < 0 || len(packed)%8 != 0 {
+			return nil, errors.New("invalid packed fixed64 field")
+		}
+		for i := 0; i < len(packed); i += 8 {
+			dst = append(dst, binary.LittleEndian.Uint64(packed[i:]))
+		}
+		return dst, nil
+	default:
+		return nil, errors.New("wrong wire type for field")
+	}
+}
+
+func appendFixed64(dst []byte, num protowire.Number, v uint64) []byte {
+	dst = protowire.AppendTag(dst, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(dst, v)
+}
@@ -0,0 +1,253 @@
+package deltatocumulative
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+func marshal(t *testing.T, md pmetric.Metrics) otlpwire.ExportMetricsServiceRequest {
+	t.Helper()
+	marshaler := &pmetric.ProtoMarshaler{}
+	data, err := marshaler.MarshalMetrics(md)
+	require.NoError(t, err)
+	return data
+}
+
+func unmarshal(t *testing.T, data otlpwire.ExportMetricsServiceRequest) pmetric.Metrics {
+	t.Helper()
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	md, err := unmarshaler.UnmarshalMetrics(data)
+	require.NoError(t, err)
+	return md
+}
+
+// deltaSum builds a request with one delta int sum point per host.
+func deltaSum(t *testing.T, start, ts pcommon.Timestamp, values map[string]int64) otlpwire.ExportMetricsServiceRequest {
+	t.Helper()
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "svc")
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("requests")
+	sum := m.SetEmptySum()
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	sum.SetIsMonotonic(true)
+	for host, v := range values {
+		dp := sum.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("host", host)
+		dp.SetStartTimestamp(start)
+		dp.SetTimestamp(ts)
+		dp.SetIntValue(v)
+	}
+	return marshal(t, md)
+}
+
+// sumPoints returns the cumulative value per host and checks the shared
+// shape of the converted sum.
+func sumPoints(t *testing.T, data otlpwire.ExportMetricsServiceRequest) map[string]int64 {
+	t.Helper()
+	md := unmarshal(t, data)
+	got := map[string]int64{}
+	if md.ResourceMetrics().Len() == 0 {
+		return got
+	}
+	sum := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum()
+	require.Equal(t, pmetric.AggregationTemporalityCumulative, sum.AggregationTemporality())
+	require.True(t, sum.IsMonotonic())
+	for i := 0; i < sum.DataPoints().Len(); i++ {
+		dp := sum.DataPoints().At(i)
+		host, _ := dp.Attributes().Get("host")
+		require.Equal(t, pcommon.Timestamp(100), dp.StartTimestamp())
+		got[host.Str()] = dp.IntValue()
+	}
+	return got
+}
+
+func TestConverter_Sum(t *testing.T) {
+	c := New(Options{})
+
+	out, err := c.Convert(deltaSum(t, 100, 200, map[string]int64{"a": 1, "b": 10}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 1, "b": 10}, sumPoints(t, out))
+
+	out, err = c.Convert(deltaSum(t, 200, 300, map[string]int64{"a": 2, "b": 20}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 3, "b": 30}, sumPoints(t, out))
+
+	// A duplicate or older point is dropped.
+	out, err = c.Convert(deltaSum(t, 200, 300, map[string]int64{"a": 5}))
+	require.NoError(t, err)
+	require.Empty(t, sumPoints(t, out))
+	require.Equal(t, Stats{Streams: 2, DroppedOutOfOrder: 1}, c.Stats())
+}
+
+func TestConverter_DoubleSum(t *testing.T) {
+	c := New(Options{})
+	for i, want := range []float64{0.5, 1.75} {
+		md := pmetric.NewMetrics()
+		sum := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptySum()
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		dp := sum.DataPoints().AppendEmpty()
+		dp.SetTimestamp(pcommon.Timestamp(10 * (i + 1)))
+		dp.SetDoubleValue([]float64{0.5, 1.25}[i])
+
+		out, err := c.Convert(marshal(t, md))
+		require.NoError(t, err)
+		got := unmarshal(t, out).ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints().At(0)
+		require.Equal(t, want, got.DoubleValue())
+		// Without a start time, the series starts at its first point.
+		require.Equal(t, pcommon.Timestamp(10), got.StartTimestamp())
+	}
+}
+
+func TestConverter_Histogram(t *testing.T) {
+	build := func(ts pcommon.Timestamp, bounds []float64, counts []uint64, minV, maxV float64) otlpwire.ExportMetricsServiceRequest {
+		md := pmetric.NewMetrics()
+		m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("latency")
+		m.SetUnit("ms")
+		hist := m.SetEmptyHistogram()
+		hist.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		dp := hist.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(ts - 10)
+		dp.SetTimestamp(ts)
+		dp.ExplicitBounds().FromRaw(bounds)
+		dp.BucketCounts().FromRaw(counts)
+		var total uint64
+		for _, n := range counts {
+			total += n
+		}
+		dp.SetCount(total)
+		dp.SetSum(float64(total))
+		dp.SetMin(minV)
+		dp.SetMax(maxV)
+		return marshal(t, md)
+	}
+	point := func(data otlpwire.ExportMetricsServiceRequest) pmetric.HistogramDataPoint {
+		m := unmarshal(t, data).ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+		require.Equal(t, "ms", m.Unit())
+		require.Equal(t, pmetric.AggregationTemporalityCumulative, m.Histogram().AggregationTemporality())
+		return m.Histogram().DataPoints().At(0)
+	}
+
+	c := New(Options{})
+	_, err := c.Convert(build(20, []float64{1, 5}, []uint64{1, 2, 3}, 0.5, 9))
+	require.NoError(t, err)
+	out, err := c.Convert(build(30, []float64{1, 5}, []uint64{4, 0, 1}, 0.1, 7))
+	require.NoError(t, err)
+
+	dp := point(out)
+	require.Equal(t, pcommon.Timestamp(10), dp.StartTimestamp())
+	require.Equal(t, pcommon.Timestamp(30), dp.Timestamp())
+	require.Equal(t, []uint64{5, 2, 4}, dp.BucketCounts().AsRaw())
+	require.Equal(t, []float64{1, 5}, dp.ExplicitBounds().AsRaw())
+	require.Equal(t, uint64(11), dp.Count())
+	require.Equal(t, 11.0, dp.Sum())
+	require.Equal(t, 0.1, dp.Min())
+	require.Equal(t, 9.0, dp.Max())
+
+	// New bucket boundaries restart the series.
+	out, err = c.Convert(build(40, []float64{2}, []uint64{1, 1}, 1, 3))
+	require.NoError(t, err)
+	dp = point(out)
+	require.Equal(t, pcommon.Timestamp(30), dp.StartTimestamp())
+	require.Equal(t, uint64(2), dp.Count())
+	require.Equal(t, 1, c.Stats().Streams)
+}
+
+func TestConverter_PassThrough(t *testing.T) {
+	md := pmetric.NewMetrics()
+	ms := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	ms.AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	cumulative := ms.AppendEmpty().SetEmptySum()
+	cumulative.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	cumulative.DataPoints().AppendEmpty().SetIntValue(2)
+	exp := ms.AppendEmpty().SetEmptyExponentialHistogram()
+	exp.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	exp.DataPoints().AppendEmpty().SetCount(3)
+	data := marshal(t, md)
+
+	c := New(Options{})
+	out, err := c.Convert(data)
+	require.NoError(t, err)
+	require.Equal(t, data, out)
+	require.Zero(t, c.Stats().Streams)
+}
+
+func TestConverter_MaxStreams(t *testing.T) {
+	c := New(Options{MaxStreams: 1})
+	out, err := c.Convert(deltaSum(t, 100, 200, map[string]int64{"a": 1}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 1}, sumPoints(t, out))
+
+	out, err = c.Convert(deltaSum(t, 200, 300, map[string]int64{"b": 1}))
+	require.NoError(t, err)
+	require.Empty(t, sumPoints(t, out))
+
+	out, err = c.Convert(deltaSum(t, 200, 300, map[string]int64{"a": 1}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 2}, sumPoints(t, out))
+	require.Equal(t, Stats{Streams: 1, DroppedOverLimit: 1}, c.Stats())
+}
+
+func TestConverter_MaxStale(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := New(Options{MaxStale: time.Minute, Now: func() time.Time { return now }})
+	_, err := c.Convert(deltaSum(t, 100, 200, map[string]int64{"a": 1}))
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	out, err := c.Convert(deltaSum(t, 100, 300, map[string]int64{"a": 5}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 5}, sumPoints(t, out))
+	require.Equal(t, Stats{Streams: 1, Evicted: 1}, c.Stats())
+}
+
+func TestConverter_MalformedLeavesStateUnchanged(t *testing.T) {
+	c := New(Options{})
+	valid := deltaSum(t, 100, 200, map[string]int64{"a": 1})
+	// The valid request followed by a truncated ResourceMetrics field.
+	malformed := append(append(otlpwire.ExportMetricsServiceRequest{}, valid...), 0x0a, 0x05)
+	_, err := c.Convert(malformed)
+	require.Error(t, err)
+	require.Zero(t, c.Stats().Streams)
+
+	out, err := c.Convert(valid)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 1}, sumPoints(t, out))
+}
+
+func TestConverter_MalformedTemporality(t *testing.T) {
+	for name, body := range map[string][]byte{
+		"truncated varint": {0x10},
+		"wrong wire type":  {0x12, 0x00},
+		"bad tag":          {0x80},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, field := range []protowire.Number{7, 9} {
+				var metric []byte
+				metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+				metric = protowire.AppendString(metric, "requests")
+				metric = protowire.AppendTag(metric, field, protowire.BytesType)
+				metric = protowire.AppendBytes(metric, body)
+				var scope, resource, req []byte
+				scope = protowire.AppendTag(scope, 2, protowire.BytesType)
+				scope = protowire.AppendBytes(scope, metric)
+				resource = protowire.AppendTag(resource, 2, protowire.BytesType)
+				resource = protowire.AppendBytes(resource, scope)
+				req = protowire.AppendTag(req, 1, protowire.BytesType)
+				req = protowire.AppendBytes(req, resource)
+
+				_, err := New(Options{}).Convert(req)
+				require.Error(t, err, "field %d", field)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package otlpwire
+
+import (
+	"errors"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// derivedScopeName is the instrumentation scope name of metrics derived by
+// this package.
+const derivedScopeName = "go.olly.garden/otlp-wire"
+
+// DefaultSpanMetricsBuckets are the default latency histogram boundaries of
+// SpanMetrics, in milliseconds. They match the OpenTelemetry Collector's
+// spanmetrics connector.
+var DefaultSpanMetricsBuckets = []float64{2, 4, 6, 8, 10, 50, 100, 200, 400, 800, 1000, 1400, 2000, 5000, 10000, 15000}
+
+// SpanMetricsConfig configures SpanMetrics.
+type SpanMetricsConfig struct {
+	// Buckets are the explicit boundaries of the latency histogram, in
+	// milliseconds, strictly increasing and free of NaN. Nil means
+	// DefaultSpanMetricsBuckets.
+	Buckets []float64
+	// StartTime and Timestamp bound the aggregation window and become the
+	// start and sample time of every data point. Timestamp defaults to the
+	// current time and StartTime to Timestamp.
+	StartTime, Timestamp time.Time
+}
+
+var (
+	spanKindNames   = []string{"SPAN_KIND_UNSPECIFIED", "SPAN_KIND_INTERNAL", "SPAN_KIND_SERVER", "SPAN_KIND_CLIENT", "SPAN_KIND_PRODUCER", "SPAN_KIND_CONSUMER"}
+	statusCodeNames = []string{"STATUS_CODE_UNSET", "STATUS_CODE_OK", "STATUS_CODE_ERROR"}
+)
+
+// enumName returns names[v], or the decimal value if v is out of range.
+func enumName(names []string, v uint64) string {
+	if v < uint64(len(names)) {
+		return names[v]
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+// SpanMetrics derives request, error and duration (RED) metrics from the
+// spans of the request and returns them as a metrics request with one
+// resource per service.name. Each resource holds two delta metrics whose data
+// points carry the span.name, span.kind and status.code attributes, named as
+// in the OpenTelemetry Collector's spanmetrics connector:
+//
+//   - traces.span.metrics.calls, a monotonic sum of spans; error counts are
+//     the points with status.code STATUS_CODE_ERROR.
+//   - traces.span.metrics.duration, a histogram of span durations in ms.
+//
+// Services and series appear in order of first appearance.
+func (t ExportTracesServiceRequest) SpanMetrics(cfg SpanMetricsConfig) (ExportMetricsServiceRequest, error) {
+	bounds := cfg.Buckets
+	if bounds == nil {
+		bounds = DefaultSpanMetricsBuckets
+	}
+	for i, b := range bounds {
+		if math.IsNaN(b) || (i > 0 && b <= bounds[i-1]) {
+			return nil, errors.New("buckets must be strictly increasing and not NaN")
+		}
+	}
+	window := newDerivedWindow(cfg.StartTime, cfg.Timestamp)
+
+	type service struct {
+		calls     []counterPoint
+		durations []histogramPoint
+		byKey     map[string]int
+	}
+	services := newDerivedResources[service]()
+
+	err := forEachMessage([]byte(t), 1, func(rs []byte) error {
+		resource, err := extractBytesField(rs, 1)
+		if err != nil {
+			return err
+		}
+		name, err := serviceName(resource)
+		if err != nil {
+			return err
+		}
+		svc := services.get(name, func() *service { return &service{byKey: make(map[string]int)} })
+
+		return forEachMessage(rs, 2, func(ss []byte) error {
+			return forEachMessage(ss, 2, func(raw []byte) error {
+				span := Span(raw)
+				spanName, err := extractBytesField(span, 5)
+				if err != nil {
+					return err
+				}
+				kind, err := extractVarintField(span, 6)
+				if err != nil {
+					return err
+				}
+				var code uint64
+				if status, err := extractBytesField(span, 15); err != nil {
+					return err
+				} else if code, err = extractVarintField(status, 3); err != nil {
+					return err
+				}
+				start, end, err := spanInterval(span)
+				if err != nil {
+					return err
+				}
+
+				attrs := [][2]string{
+					{"span.name", string(spanName)},
+					{"span.kind", enumName(spanKindNames, kind)},
+					{"status.code", enumName(statusCodeNames, code)},
+				}
+				key := attrs[0][1] + "\x00" + attrs[1][1] + "\x00" + attrs[2][1]
+				i, ok := svc.byKey[key]
+				if !ok {
+					i = len(svc.calls)
+					svc.byKey[key] = i
+					svc.calls = append(svc.calls, counterPoint{attrs: attrs})
+					svc.durations = append(svc.durations, histogramPoint{attrs: attrs, buckets: make([]uint64, len(bounds)+1)})
+				}
+				svc.calls[i].value++
+				svc.durations[i].observe(bounds, float64(end-start)/float64(time.Millisecond))
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	for name, svc := range services.all() {
+		out = appendDerivedResource(out, name, func(b []byte) []byte {
+			b = appendSumMetric(b, "traces.span.metrics.calls", "{call}", window, svc.calls)
+			return appendHistogramMetric(b, "traces.span.metrics.duration", "ms", window, bounds, svc.durations)
+		})
+	}
+	return ExportMetricsServiceRequest(out), nil
+}
+
+// derivedResources keeps per-service aggregation state in order of first
+// appearance.
+type derivedResources[T any] struct {
+	names []string
+	state map[string]*T
+}
+
+func newDerivedResources[T any]() *derivedResources[T] {
+	return &derivedResources[T]{state: make(map[string]*T)}
+}
+
+// get returns the state of service name, creating it with create.
+func (r *derivedResources[T]) get(name string, create func() *T) *T {
+	s, ok := r.state[name]
+	if !ok {
+		s = create()
+		r.state[name] = s
+		r.names = append(r.names, name)
+	}
+	return s
+}
+
+// all iterates over the services in order of first appearance.
+func (r *derivedResources[T]) all() iter.Seq2[string, *T] {
+	return func(yield func(string, *T) bool) {
+		for _, name := range r.names {
+			if !yield(name, r.state[name]) {
+				return
+			}
+		}
+	}
+}
+
+// derivedWindow is the start and sample time of derived data points, in Unix
+// nanoseconds.
+type derivedWindow struct{ start, time uint64 }
+
+func newDerivedWindow(start, ts time.Time) derivedWindow {
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	if start.IsZero() {
+		start = ts
+	}
+	return derivedWindow{start: uint64(start.UnixNano()), time: uint64(ts.UnixNano())}
+}
+
+// counterPoint is one data point of a derived monotonic delta sum.
+type counterPoint struct {
+	attrs [][2]string
+	value int64
+}
+
+// histogramPoint is one data point of a derived delta histogram.
+type histogramPoint struct {
+	attrs    [][2]string
+	count    uint64
+	sum      float64
+	min, max float64
+	buckets  []uint64
+}
+
+func (p *histogramPoint) observe(bounds []float64, v float64) {
+	if p.count == 0 || v < p.min {
+		p.min = v
+	}
+	if p.count == 0 || v > p.max {
+		p.max = v
+	}
+	p.count++
+	p.sum += v
+	i, _ := slices.BinarySearch(bounds, v)
+	p.buckets[i]++
+}
+
+// appendDerivedResource appends a ResourceMetrics field (field 1 of
+// ExportMetricsServiceRequest) whose resource carries service.name, unless it
+// is empty, and whose single scope holds the metrics appended by
+// appendMetrics.
+func appendDerivedResource(dst []byte, service string, appendMetrics func([]byte) []byte) []byte {
+	return appendMessage(dst, 1, func(b []byte) []byte {
+		b = appendMessage(b, 1, func(b []byte) []byte {
+			if service == "" {
+				return b
+			}
+			return appendStringKeyValue(b, 1, "service.name", service)
+		})
+		return appendMessage(b, 2, func(b []byte) []byte {
+			b = appendMessage(b, 1, func(b []byte) []byte {
+				b = protowire.AppendTag(b, 1, protowire.BytesType)
+				return protowire.AppendString(b, derivedScopeName)
+			})
+			return appendMetrics(b)
+		})
+	})
+}
+
+// appendSumMetric appends a Metric field (field 2 of ScopeMetrics) holding a
+// monotonic delta sum of integer points.
+func appendSumMetric(dst []byte, name, unit string, w derivedWindow, points []counterPoint) []byte {
+	return appendMetricHeader(dst, name, unit, MetricTypeSum, func(b []byte) []byte {
+		for _, p := range points {
+			b = appendMessage(b, 1, func(b []byte) []byte {
+				for _, kv := range p.attrs {
+					b = appendStringKeyValue(b, 7, kv[0], kv[1])
+				}
+				b = appendFixed64Field(b, 2, w.start)
+				b = appendFixed64Field(b, 3, w.time)
+				return appendFixed64Field(b, 6, uint64(p.value))
+			})
+		}
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, temporalityDelta)
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		return protowire.AppendVarint(b, 1) // is_monotonic
+	})
+}
+
+// appendHistogramMetric appends a Metric field (field 2 of ScopeMetrics)
+// holding a delta explicit-bucket histogram.
+func appendHistogramMetric(dst []byte, name, unit string, w derivedWindow, bounds []float64, points []histogramPoint) []byte {
+	return appendMetricHeader(dst, name, unit, MetricTypeHistogram, func(b []byte) []byte {
+		for _, p := range points {
+			b = appendMessage(b, 1, func(b []byte) []byte {
+				b = appendFixed64Field(b, 2, w.start)
+				b = appendFixed64Field(b, 3, w.time)
+				b = appendFixed64Field(b, 4, p.count)
+				b = appendFixed64Field(b, 5, math.Float64bits(p.sum))
+				b = appendPackedFixed64(b, 6, p.buckets)
+				b = appendPackedDouble(b, 7, bounds)
+				for _, kv := range p.attrs {
+					b = appendStringKeyValue(b, 9, kv[0], kv[1])
+				}
+				b = appendFixed64Field(b, 11, math.Float64bits(p.min))
+				return appendFixed64Field(b, 12, math.Float64bits(p.max))
+			})
+		}
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		return protowire.AppendVarint(b, temporalityDelta)
+	})
+}
+
+// appendMetricHeader appends a Metric field with name, unit and a body of
+// type typ built by appendBody.
+func appendMetricHeader(dst []byte, name, unit string, typ MetricType, appendBody func([]byte) []byte) []byte {
+	return appendMessage(dst, 2, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, unit)
+		return appendMessage(b, protowire.Number(typ), appendBody)
+	})
+}
+
+// appendStringKeyValue appends a KeyValue field num with a string value.
+func appendStringKeyValue(dst []byte, num protowire.Number, key, value string) []byte {
+	return appendMessage(dst, num, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+		return appendMessage(b, 2, func(b []byte) []byte {
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			return protowire.AppendString(b, value)
+		})
+	})
+}
+
+func appendFixed64Field(dst []byte, num protowire.Number, v uint64) []byte {
+	dst = protowire.AppendTag(dst, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(dst, v)
+}
+
+// appendPackedFixed64 appends a packed repeated fixed64 field.
+func appendPackedFixed64(dst []byte, num protowire.Number, values []uint64) []byte {
+	dst = protowire.AppendTag(dst, num, protowire.BytesType)
+	dst = protowire.AppendVarint(dst, uint64(8*len(values)))
+	for _, v := range values {
+		dst = protowire.AppendFixed64(dst, v)
+	}
+	return dst
+}
+
+// appendPackedDouble appends a packed repeated double field, or nothing if
+// values is empty.
+func appendPackedDouble(dst []byte, num protowire.Number, values []float64) []byte {
+	if len(values) == 0 {
+		return dst
+	}
+	dst = protowire.AppendTag(dst, num, protowire.BytesType)
+	dst = protowire.AppendVarint(dst, uint64(8*len(values)))
+	for _, v := range values {
+		dst = protowire.AppendFixed64(dst, math.Float64bits(v))
+	}
+	return dst
+}
+
+// appendMessage is appendMessageField for builders that cannot fail.
+func appendMessage(dst []byte, num protowire.Number, build func([]byte) []byte) []byte {
+	dst, _ = appendMessageField(dst, num, func(b []byte) ([]byte, error) {
+		return build(b), nil
+	})
+	return dst
+}
+
+// LogMetricsConfig configures LogMetrics.
+type LogMetricsConfig struct {
+	// Dimensions are log record attribute keys whose string values are added
+	// as data point attributes. A record without a string value for a key
+	// gets no attribute for it.
+	Dimensions []string
+	// StartTime and Timestamp bound the aggregation window and become the
+	// start and sample time of every data point. Timestamp defaults to the
+	// current time and StartTime to Timestamp.
+	StartTime, Timestamp time.Time
+}
+
+// severityName returns the short name of the range an OTLP SeverityNumber
+// falls in, such as "INFO" for 9 to 12, or "UNSPECIFIED".
+func severityName(n uint64) string {
+	if n == 0 || n > 24 {
+		return "UNSPECIFIED"
+	}
+	return [...]string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}[(n-1)/4]
+}
+
+// LogMetrics counts the log records of the request by severity and returns
+// the counts as a metrics request with one resource per service.name. Each
+// resource holds a monotonic delta sum named log.record.count, as emitted by
+// the OpenTelemetry Collector's count connector, whose data points carry a
+// severity attribute (TRACE, DEBUG, INFO, WARN, ERROR, FATAL or UNSPECIFIED,
+// from severity_number) followed by the configured dimensions. Services and
+// series appear in order of first appearance.
+func (l ExportLogsServiceRequest) LogMetrics(cfg LogMetricsConfig) (ExportMetricsServiceRequest, error) {
+	window := newDerivedWindow(cfg.StartTime, cfg.Timestamp)
+
+	type service struct {
+		counts []counterPoint
+		byKey  map[string]int
+	}
+	services := newDerivedResources[service]()
+
+	err := forEachMessage([]byte(l), 1, func(rl []byte) error {
+		resource, err := extractBytesField(rl, 1)
+		if err != nil {
+			return err
+		}
+		name, err := serviceName(resource)
+		if err != nil {
+			return err
+		}
+		svc := services.get(name, func() *service { return &service{byKey: make(map[string]int)} })
+
+		return forEachMessage(rl, 2, func(sl []byte) error {
+			return forEachMessage(sl, 2, func(record []byte) error {
+				severity, err := extractVarintField(record, 2)
+				if err != nil {
+					return err
+				}
+				attrs := [][2]string{{"severity", severityName(severity)}}
+				for _, key := range cfg.Dimensions {
+					value, ok, err := stringAttribute(record, 6, key)
+					if err != nil {
+						return err
+					}
+					if ok {
+						attrs = append(attrs, [2]string{key, value})
+					}
+				}
+
+				var seriesKey []byte
+				for _, kv := range attrs {
+					seriesKey = protowire.AppendString(seriesKey, kv[0])
+					seriesKey = protowire.AppendString(seriesKey, kv[1])
+				}
+				i, ok := svc.byKey[string(seriesKey)]
+				if !ok {
+					i = len(svc.counts)
+					svc.byKey[string(seriesKey)] = i
+					svc.counts = append(svc.counts, counterPoint{attrs: attrs})
+				}
+				svc.counts[i].value++
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	for name, svc := range services.all() {
+		out = appendDerivedResource(out, name, func(b []byte) []byte {
+			return appendSumMetric(b, "log.record.count", "{record}", window, svc.counts)
+		})
+	}
+	return ExportMetricsServiceRequest(out), nil
+}
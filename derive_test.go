@@ -0,0 +1,183 @@
+package otlpwire
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestSpanMetrics(t *testing.T) {
+	traces := ptrace.NewTraces()
+	addSpan := func(service, name string, kind ptrace.SpanKind, failed bool, duration time.Duration) {
+		rs := traces.ResourceSpans().AppendEmpty()
+		if service != "" {
+			rs.Resource().Attributes().PutStr("service.name", service)
+		}
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetName(name)
+		span.SetKind(kind)
+		span.SetStartTimestamp(1000)
+		span.SetEndTimestamp(pcommon.Timestamp(1000 + duration))
+		if failed {
+			span.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+	addSpan("api", "GET /", ptrace.SpanKindServer, false, 3*time.Millisecond)
+	addSpan("db", "SELECT", ptrace.SpanKindClient, false, 1*time.Millisecond)
+	addSpan("api", "GET /", ptrace.SpanKindServer, false, 7*time.Millisecond)
+	addSpan("api", "GET /", ptrace.SpanKindServer, true, 20*time.Millisecond)
+	addSpan("", "orphan", ptrace.SpanKindInternal, false, 0)
+
+	start, end := time.Unix(10, 0), time.Unix(70, 0)
+	out, err := ExportTracesServiceRequest(marshalTraces(t, traces)).SpanMetrics(SpanMetricsConfig{
+		Buckets:   []float64{5, 10},
+		StartTime: start,
+		Timestamp: end,
+	})
+	require.NoError(t, err)
+
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	require.Equal(t, 3, got.ResourceMetrics().Len())
+
+	rm := got.ResourceMetrics().At(0)
+	svc, _ := rm.Resource().Attributes().Get("service.name")
+	require.Equal(t, "api", svc.Str())
+	sm := rm.ScopeMetrics().At(0)
+	require.Equal(t, "go.olly.garden/otlp-wire", sm.Scope().Name())
+
+	calls := sm.Metrics().At(0)
+	require.Equal(t, "traces.span.metrics.calls", calls.Name())
+	require.True(t, calls.Sum().IsMonotonic())
+	require.Equal(t, pmetric.AggregationTemporalityDelta, calls.Sum().AggregationTemporality())
+	require.Equal(t, 2, calls.Sum().DataPoints().Len())
+	ok := calls.Sum().DataPoints().At(0)
+	require.Equal(t, int64(2), ok.IntValue())
+	require.Equal(t, map[string]any{"span.name": "GET /", "span.kind": "SPAN_KIND_SERVER", "status.code": "STATUS_CODE_UNSET"}, ok.Attributes().AsRaw())
+	require.Equal(t, pcommon.NewTimestampFromTime(start), ok.StartTimestamp())
+	require.Equal(t, pcommon.NewTimestampFromTime(end), ok.Timestamp())
+	failed := calls.Sum().DataPoints().At(1)
+	require.Equal(t, int64(1), failed.IntValue())
+	status, _ := failed.Attributes().Get("status.code")
+	require.Equal(t, "STATUS_CODE_ERROR", status.Str())
+
+	duration := sm.Metrics().At(1)
+	require.Equal(t, "traces.span.metrics.duration", duration.Name())
+	require.Equal(t, "ms", duration.Unit())
+	hist := duration.Histogram().DataPoints().At(0)
+	require.Equal(t, uint64(2), hist.Count())
+	require.Equal(t, 10.0, hist.Sum())
+	require.Equal(t, 3.0, hist.Min())
+	require.Equal(t, 7.0, hist.Max())
+	require.Equal(t, []float64{5, 10}, hist.ExplicitBounds().AsRaw())
+	require.Equal(t, []uint64{1, 1, 0}, hist.BucketCounts().AsRaw())
+	require.Equal(t, []uint64{0, 0, 1}, duration.Histogram().DataPoints().At(1).BucketCounts().AsRaw())
+
+	// A resource without service.name yields a resource without attributes.
+	require.Zero(t, got.ResourceMetrics().At(2).Resource().Attributes().Len())
+
+	count, err := out.DataPointCount()
+	require.NoError(t, err)
+	require.Equal(t, 8, count)
+}
+
+func TestSpanMetrics_DefaultBuckets(t *testing.T) {
+	out, err := ExportTracesServiceRequest(marshalTraces(t, createBenchTraces())).SpanMetrics(SpanMetricsConfig{})
+	require.NoError(t, err)
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	hist := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(1).Histogram().DataPoints().At(0)
+	require.Equal(t, DefaultSpanMetricsBuckets, hist.ExplicitBounds().AsRaw())
+	require.NotZero(t, hist.Timestamp())
+	require.Equal(t, hist.Timestamp(), hist.StartTimestamp())
+}
+
+func TestSpanMetrics_InvalidBuckets(t *testing.T) {
+	data := ExportTracesServiceRequest(marshalTraces(t, createBenchTraces()))
+	for name, buckets := range map[string][]float64{
+		"unsorted":  {10, 2, 50},
+		"duplicate": {2, 10, 10},
+		"NaN":       {2, math.NaN()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := data.SpanMetrics(SpanMetricsConfig{Buckets: buckets})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSpanMetrics_Malformed(t *testing.T) {
+	// Span whose name is encoded as varint.
+	span := protowire.AppendTag(nil, 5, protowire.VarintType)
+	span = protowire.AppendVarint(span, 1)
+	_, err := ExportTracesServiceRequest(wrapRecord(span)).SpanMetrics(SpanMetricsConfig{})
+	require.Error(t, err)
+}
+
+func TestLogMetrics(t *testing.T) {
+	logs := plog.NewLogs()
+	addRecord := func(service string, severity plog.SeverityNumber, attrs map[string]any) {
+		rl := logs.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("service.name", service)
+		record := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		record.SetSeverityNumber(severity)
+		require.NoError(t, record.Attributes().FromRaw(attrs))
+	}
+	addRecord("api", plog.SeverityNumberInfo, map[string]any{"region": "eu"})
+	addRecord("api", plog.SeverityNumberInfo4, map[string]any{"region": "eu", "other": "x"})
+	addRecord("api", plog.SeverityNumberError, map[string]any{"region": "us"})
+	addRecord("worker", plog.SeverityNumberUnspecified, map[string]any{"region": 1}) // non-string dimension
+	addRecord("api", plog.SeverityNumberInfo, map[string]any{"region": "us"})
+
+	out, err := ExportLogsServiceRequest(marshalLogs(t, logs)).LogMetrics(LogMetricsConfig{
+		Dimensions: []string{"region"},
+		Timestamp:  time.Unix(60, 0),
+	})
+	require.NoError(t, err)
+
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+
+	type count struct {
+		service string
+		attrs   map[string]any
+		value   int64
+	}
+	var counts []count
+	for i := 0; i < got.ResourceMetrics().Len(); i++ {
+		rm := got.ResourceMetrics().At(i)
+		svc, _ := rm.Resource().Attributes().Get("service.name")
+		m := rm.ScopeMetrics().At(0).Metrics().At(0)
+		require.Equal(t, "log.record.count", m.Name())
+		require.True(t, m.Sum().IsMonotonic())
+		for j := 0; j < m.Sum().DataPoints().Len(); j++ {
+			dp := m.Sum().DataPoints().At(j)
+			require.Equal(t, pcommon.NewTimestampFromTime(time.Unix(60, 0)), dp.Timestamp())
+			counts = append(counts, count{svc.Str(), dp.Attributes().AsRaw(), dp.IntValue()})
+		}
+	}
+	require.Equal(t, []count{
+		{"api", map[string]any{"severity": "INFO", "region": "eu"}, 2},
+		{"api", map[string]any{"severity": "ERROR", "region": "us"}, 1},
+		{"api", map[string]any{"severity": "INFO", "region": "us"}, 1},
+		{"worker", map[string]any{"severity": "UNSPECIFIED"}, 1},
+	}, counts)
+}
+
+func TestLogMetrics_Malformed(t *testing.T) {
+	// Log record whose severity_number is encoded as fixed64.
+	record := protowire.AppendTag(nil, 2, protowire.Fixed64Type)
+	record = protowire.AppendFixed64(record, 9)
+	_, err := ExportLogsServiceRequest(wrapRecord(record)).LogMetrics(LogMetricsConfig{})
+	require.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package otlpwire
+
+import (
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ElasticsearchBulkConfig configures AppendElasticsearchBulk.
+type ElasticsearchBulkConfig struct {
+	// Index is the target index or data stream of every action. Empty
+	// leaves it to the index in the _bulk request path.
+	Index string
+}
+
+// AppendElasticsearchBulk appends the log records of the request to dst as
+// Elasticsearch _bulk NDJSON, a create action line followed by a document
+// line per record, and returns the extended buffer and the number of
+// documents. create works for both indices and data streams. Documents
+// follow the OpenTelemetry field names:
+//
+//	{"@timestamp":"2024-05-06T07:08:09.123456789Z",
+//	 "observed_timestamp":"2024-05-06T07:08:09.2Z",
+//	 "severity_text":"ERROR","severity_number":17,
+//	 "body":{"text":"payment failed"},
+//	 "attributes":{...},
+//	 "resource":{"attributes":{...}},
+//	 "scope":{"name":"...","version":"...","attributes":{...}},
+//	 "trace_id":"...","span_id":"..."}
+//
+// @timestamp is time_unix_nano, or observed_time_unix_nano when unset.
+// A map body is written as body.structured and any other body as
+// body.text, so that differently typed bodies do not conflict in the
+// mapping. Unset fields and empty objects are omitted. On error the
+// returned buffer holds the documents appended so far.
+func (l ExportLogsServiceRequest) AppendElasticsearchBulk(dst []byte, cfg ElasticsearchBulkConfig) ([]byte, int, error) {
+	var action []byte
+	if cfg.Index == "" {
+		action = []byte(`{"create":{}}` + "\n")
+	} else {
+		action = append([]byte(`{"create":{"_index":`), appendJSONString(nil, []byte(cfg.Index))...)
+		action = append(action, "}}\n"...)
+	}
+
+	count := 0
+	err := forEachLogRecord([]byte(l), func(resource, scope, record []byte) error {
+		start := len(dst)
+		dst = append(dst, action...)
+		var err error
+		dst, err = appendElasticsearchDocument(dst, resource, scope, record)
+		if err != nil {
+			dst = dst[:start]
+			return err
+		}
+		dst = append(dst, '\n')
+		count++
+		return nil
+	})
+	return dst, count, err
+}
+
+func appendElasticsearchDocument(dst, resource, scope, record []byte) ([]byte, error) {
+	start := len(dst)
+
+	ts, err := logRecordTime(record)
+	if err != nil {
+		return dst, err
+	}
+	if ts != 0 {
+		dst = appendJSONMemberKey(dst, "@timestamp")
+		dst = appendJSONTime(dst, ts)
+	}
+	observed, err := extractFixed64Field(record, 11)
+	if err != nil {
+		return dst, err
+	}
+	if observed != 0 {
+		dst = appendJSONMemberKey(dst, "observed_timestamp")
+		dst = appendJSONTime(dst, observed)
+	}
+
+	severityText, err := extractBytesField(record, 3)
+	if err != nil {
+		return dst, err
+	}
+	if len(severityText) > 0 {
+		dst = appendJSONMemberKey(dst, "severity_text")
+		dst = appendJSONString(dst, severityText)
+	}
+	severity, err := extractVarintField(record, 2)
+	if err != nil {
+		return dst, err
+	}
+	if severity != 0 {
+		dst = appendJSONMemberKey(dst, "severity_number")
+		dst = strconv.AppendUint(dst, severity, 10)
+	}
+
+	body, err := extractBytesField(record, 5)
+	if err != nil {
+		return dst, err
+	}
+	typ, err := anyValueType(body)
+	if err != nil {
+		return dst, err
+	}
+	switch typ {
+	case ValueTypeEmpty:
+	case ValueTypeKvlist:
+		dst = appendJSONMemberKey(dst, "body")
+		dst = append(dst, `{"structured":`...)
+		if dst, err = appendAnyValueJSON(dst, body, 0); err != nil {
+			return dst, err
+		}
+		dst = append(dst, '}')
+	default:
+		dst = appendJSONMemberKey(dst, "body")
+		dst = append(dst, `{"text":`...)
+		text, err := appendAnyValueText(nil, body)
+		if err != nil {
+			return dst, err
+		}
+		dst = appendJSONString(dst, text)
+		dst = append(dst, '}')
+	}
+
+	if dst, err = appendAttributesMember(dst, record, 6); err != nil {
+		return dst, err
+	}
+
+	mark := len(dst)
+	dst = appendJSONMemberKey(dst, "resource")
+	inner := len(dst)
+	if dst, err = appendAttributesMember(dst, resource, 1); err != nil {
+		return dst, err
+	}
+	dst = closeNestedJSONObject(dst, mark, inner)
+
+	mark = len(dst)
+	dst = appendJSONMemberKey(dst, "scope")
+	inner = len(dst)
+	for _, f := range []struct {
+		key string
+		num protowire.Number
+	}{{"name", 1}, {"version", 2}} {
+		s, err := extractBytesField(scope, f.num)
+		if err != nil {
+			return dst, err
+		}
+		if len(s) > 0 {
+			dst = appendJSONMemberKey(dst, f.key)
+			dst = appendJSONString(dst, s)
+		}
+	}
+	if dst, err = appendAttributesMember(dst, scope, 3); err != nil {
+		return dst, err
+	}
+	dst = closeNestedJSONObject(dst, mark, inner)
+
+	for _, id := range []struct {
+		key string
+		num protowire.Number
+	}{{"trace_id", 9}, {"span_id", 10}} {
+		mark := len(dst)
+		dst = appendJSONMemberKey(dst, id.key)
+		dst = append(dst, '"')
+		var ok bool
+		if dst, ok, err = appendHexField(dst, record, id.num); err != nil {
+			return dst, err
+		}
+		if !ok {
+			dst = dst[:mark]
+			continue
+		}
+		dst = append(dst, '"')
+	}
+
+	return closeJSONObject(dst, start), nil
+}
+
+// appendAttributesMember appends the repeated KeyValue field num of msg as
+// an "attributes" object member, unless there are no attributes.
+func appendAttributesMember(dst, msg []byte, num protowire.Number) ([]byte, error) {
+	count, err := countOccurrences(msg, num)
+	if err != nil || count == 0 {
+		return dst, err
+	}
+	dst = appendJSONMemberKey(dst, "attributes")
+	return appendKeyValuesJSON(dst, msg, num, 0)
+}
+
+// appendJSONTime appends a Unix nanosecond timestamp as an RFC 3339 JSON
+// string in UTC.
+func appendJSONTime(dst []byte, ns uint64) []byte {
+	dst = append(dst, '"')
+	dst = time.Unix(0, int64(ns)).UTC().AppendFormat(dst, time.RFC3339Nano)
+	return append(dst, '"')
+}
+
+// appendJSONMemberKey appends the name of an object member preceded by a
+// comma. Objects are written without their opening brace, which
+// closeJSONObject puts in place of the first comma.
+func appendJSONMemberKey(dst []byte, name string) []byte {
+	dst = append(dst, ',')
+	dst = appendJSONString(dst, []byte(name))
+	return append(dst, ':')
+}
+
+// closeJSONObject closes an object whose members were written from start
+// with appendJSONMemberKey.
+func closeJSONObject(dst []byte, start int) []byte {
+	if len(dst) == start {
+		return append(dst, '{', '}')
+	}
+	dst[start] = '{'
+	return append(dst, '}')
+}
+
+// closeNestedJSONObject closes an object member whose name was written at
+// mark and whose members start at inner, or removes the member if the
+// object is empty.
+func closeNestedJSONObject(dst []byte, mark, inner int) []byte {
+	if len(dst) == inner {
+		return dst[:mark]
+	}
+	return closeJSONObject(dst, inner)
+}
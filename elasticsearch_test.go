@@ -0,0 +1,78 @@
+package otlpwire
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestAppendElasticsearchBulk(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.Scope().SetName("app/logger")
+	sl.Scope().SetVersion("1.2.0")
+	records := sl.LogRecords()
+
+	lr := records.AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)))
+	lr.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Date(2024, 5, 6, 7, 8, 9, 200000000, time.UTC)))
+	lr.SetSeverityText("ERROR")
+	lr.SetSeverityNumber(plog.SeverityNumberError)
+	lr.SetTraceID(pcommon.TraceID{0x01})
+	lr.Attributes().PutInt("attempt", 2)
+	lr.Body().SetStr("payment failed")
+
+	lr = records.AppendEmpty()
+	lr.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)))
+	lr.Body().SetEmptyMap().PutBool("ok", false)
+
+	// A record without a resource, scope or any field set.
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+
+	data := ExportLogsServiceRequest(marshalLogs(t, logs))
+	out, n, err := data.AppendElasticsearchBulk(nil, ElasticsearchBulkConfig{Index: "logs-app-default"})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	const action = `{"create":{"_index":"logs-app-default"}}`
+	const resourceScope = `"resource":{"attributes":{"service.name":"checkout"}},"scope":{"name":"app/logger","version":"1.2.0"}`
+	lines := strings.Split(string(out), "\n")
+	require.Equal(t, []string{
+		action,
+		`{"@timestamp":"2024-05-06T07:08:09.123456789Z","observed_timestamp":"2024-05-06T07:08:09.2Z",` +
+			`"severity_text":"ERROR","severity_number":17,"body":{"text":"payment failed"},"attributes":{"attempt":2},` +
+			resourceScope + `,"trace_id":"01000000000000000000000000000000"}`,
+		action,
+		`{"@timestamp":"2024-05-06T00:00:00Z","observed_timestamp":"2024-05-06T00:00:00Z","body":{"structured":{"ok":false}},` + resourceScope + `}`,
+		action,
+		`{}`,
+		"",
+	}, lines)
+	for _, line := range lines[:6] {
+		require.True(t, json.Valid([]byte(line)), line)
+	}
+}
+
+func TestAppendElasticsearchBulk_DefaultIndex(t *testing.T) {
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetInt(7)
+
+	out, n, err := ExportLogsServiceRequest(marshalLogs(t, logs)).AppendElasticsearchBulk(nil, ElasticsearchBulkConfig{})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, `{"create":{}}`+"\n"+`{"body":{"text":"7"}}`+"\n", string(out))
+}
+
+func TestAppendElasticsearchBulk_Malformed(t *testing.T) {
+	out, n, err := ExportLogsServiceRequest([]byte{0x0a, 0x05}).AppendElasticsearchBulk([]byte("keep"), ElasticsearchBulkConfig{})
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.Equal(t, "keep", string(out))
+}
@@ -0,0 +1,183 @@
+package otlpwire
+
+import (
+	"errors"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Signal identifies the OTLP signal carried by a payload.
+type Signal uint8
+
+// Signal kinds.
+const (
+	SignalUnspecified Signal = iota
+	SignalTraces
+	SignalMetrics
+	SignalLogs
+)
+
+// String returns the lower-case signal name.
+func (s Signal) String() string {
+	switch s {
+	case SignalTraces:
+		return "traces"
+	case SignalMetrics:
+		return "metrics"
+	case SignalLogs:
+		return "logs"
+	default:
+		return "unspecified"
+	}
+}
+
+// Envelope bundles an OTLP export request of any signal with optional
+// metadata, so heterogeneous traffic can share one queue and be re-typed by
+// the consumer.
+//
+// The binary encoding is itself a protobuf message:
+//
+//	Envelope
+//	  ├─ field 1: signal (varint)
+//	  ├─ field 2: payload (bytes, the export request)
+//	  ├─ field 3: tenant (string)
+//	  └─ field 4: received_at (fixed64, Unix nanoseconds)
+//
+// Absent optional fields are omitted. Unknown fields are skipped on decode.
+type Envelope struct {
+	Signal     Signal
+	Payload    []byte
+	Tenant     string
+	ReceivedAt time.Time // zero if unknown
+}
+
+// AppendBinary appends the encoded envelope to dst and returns the extended
+// buffer.
+func (e Envelope) AppendBinary(dst []byte) ([]byte, error) {
+	if e.Signal == SignalUnspecified {
+		return dst, errors.New("envelope signal is unspecified")
+	}
+	dst = protowire.AppendTag(dst, 1, protowire.VarintType)
+	dst = protowire.AppendVarint(dst, uint64(e.Signal))
+	dst = protowire.AppendTag(dst, 2, protowire.BytesType)
+	dst = protowire.AppendBytes(dst, e.Payload)
+	if e.Tenant != "" {
+		dst = protowire.AppendTag(dst, 3, protowire.BytesType)
+		dst = protowire.AppendString(dst, e.Tenant)
+	}
+	if !e.ReceivedAt.IsZero() {
+		dst = protowire.AppendTag(dst, 4, protowire.Fixed64Type)
+		dst = protowire.AppendFixed64(dst, uint64(e.ReceivedAt.UnixNano()))
+	}
+	return dst, nil
+}
+
+// MarshalBinary encodes the envelope.
+// Implements encoding.BinaryMarshaler.
+func (e Envelope) MarshalBinary() ([]byte, error) {
+	return e.AppendBinary(make([]byte, 0, len(e.Payload)+len(e.Tenant)+24))
+}
+
+// UnmarshalBinary decodes an envelope produced by MarshalBinary. Payload
+// aliases data; no copy is made.
+// Implements encoding.BinaryUnmarshaler.
+func (e *Envelope) UnmarshalBinary(data []byte) error {
+	var out Envelope
+	pos := 0
+
+	for pos < len(data) {
+		num, wireType, tagLen := consumeTag(data[pos:])
+		if tagLen < 0 {
+			return errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		switch {
+		case num == 1 && wireType == protowire.VarintType:
+			v, n := consumeVarint(data[pos:])
+			if n < 0 {
+				return errors.New("invalid varint in envelope signal")
+			}
+			pos += n
+			out.Signal = Signal(v)
+		case num == 2 && wireType == protowire.BytesType:
+			v, n := consumeBytes(data[pos:])
+			if n < 0 {
+				return errors.New("invalid bytes in envelope payload")
+			}
+			pos += n
+			out.Payload = v
+		case num == 3 && wireType == protowire.BytesType:
+			v, n := consumeBytes(data[pos:])
+			if n < 0 {
+				return errors.New("invalid bytes in envelope tenant")
+			}
+			pos += n
+			out.Tenant = string(v)
+		case num == 4 && wireType == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data[pos:])
+			if n < 0 {
+				return errors.New("invalid fixed64 in envelope received_at")
+			}
+			pos += n
+			out.ReceivedAt = time.Unix(0, int64(v))
+		case num >= 1 && num <= 4:
+			return errors.New("wrong wire type for envelope field")
+		default:
+			n := skipField(data[pos:], wireType)
+			if n < 0 {
+				return errors.New("failed to skip field")
+			}
+			pos += n
+		}
+	}
+
+	if out.Signal < SignalTraces || out.Signal > SignalLogs {
+		return errors.New("envelope has unknown signal")
+	}
+	*e = out
+	return nil
+}
+
+// Traces returns the payload as an ExportTracesServiceRequest. The bool
+// result is false if the envelope carries a different signal.
+func (e Envelope) Traces() (ExportTracesServiceRequest, bool) {
+	if e.Signal != SignalTraces {
+		return nil, false
+	}
+	return ExportTracesServiceRequest(e.Payload), true
+}
+
+// Metrics returns the payload as an ExportMetricsServiceRequest. The bool
+// result is false if the envelope carries a different signal.
+func (e Envelope) Metrics() (ExportMetricsServiceRequest, bool) {
+	if e.Signal != SignalMetrics {
+		return nil, false
+	}
+	return ExportMetricsServiceRequest(e.Payload), true
+}
+
+// Logs returns the payload as an ExportLogsServiceRequest. The bool result
+// is false if the envelope carries a different signal.
+func (e Envelope) Logs() (ExportLogsServiceRequest, bool) {
+	if e.Signal != SignalLogs {
+		return nil, false
+	}
+	return ExportLogsServiceRequest(e.Payload), true
+}
+
+// ItemCount returns the number of spans, data points, or log records in the
+// payload, depending on the signal.
+func (e Envelope) ItemCount() (int, error) {
+	switch e.Signal {
+	case SignalTraces:
+		return countSpans(e.Payload)
+	case SignalMetrics:
+		return countMetricDataPoints(e.Payload)
+	case SignalLogs:
+		return countLogRecords(e.Payload)
+	default:
+		return 0, errors.New("envelope has unknown signal")
+	}
+}
@@ -0,0 +1,86 @@
+package otlpwire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	payloads := map[Signal][]byte{
+		SignalTraces:  marshalTraces(t, createBenchTraces()),
+		SignalMetrics: marshalMetrics(t, createBenchMetrics()),
+		SignalLogs:    marshalLogs(t, createBenchLogs()),
+	}
+	received := time.Unix(1700000000, 123)
+
+	for signal, payload := range payloads {
+		t.Run(signal.String(), func(t *testing.T) {
+			in := Envelope{Signal: signal, Payload: payload, Tenant: "acme", ReceivedAt: received}
+			data, err := in.MarshalBinary()
+			require.NoError(t, err)
+
+			var out Envelope
+			require.NoError(t, out.UnmarshalBinary(data))
+			require.Equal(t, signal, out.Signal)
+			require.Equal(t, payload, out.Payload)
+			require.Equal(t, "acme", out.Tenant)
+			require.True(t, received.Equal(out.ReceivedAt))
+
+			count, err := out.ItemCount()
+			require.NoError(t, err)
+			require.Equal(t, 500, count)
+
+			_, isTraces := out.Traces()
+			_, isMetrics := out.Metrics()
+			_, isLogs := out.Logs()
+			require.Equal(t, signal == SignalTraces, isTraces)
+			require.Equal(t, signal == SignalMetrics, isMetrics)
+			require.Equal(t, signal == SignalLogs, isLogs)
+		})
+	}
+}
+
+func TestEnvelope_OptionalFieldsOmitted(t *testing.T) {
+	data, err := Envelope{Signal: SignalLogs, Payload: []byte{}}.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x08, 0x03, 0x12, 0x00}, data)
+
+	var out Envelope
+	require.NoError(t, out.UnmarshalBinary(data))
+	require.Empty(t, out.Tenant)
+	require.True(t, out.ReceivedAt.IsZero())
+}
+
+func TestEnvelope_UnknownFieldSkipped(t *testing.T) {
+	data, err := Envelope{Signal: SignalTraces, Payload: []byte{0x01}}.MarshalBinary()
+	require.NoError(t, err)
+	data = protowire.AppendTag(data, 99, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte("future"))
+
+	var out Envelope
+	require.NoError(t, out.UnmarshalBinary(data))
+	require.Equal(t, []byte{0x01}, out.Payload)
+}
+
+func TestEnvelope_Errors(t *testing.T) {
+	_, err := Envelope{Payload: []byte{0x01}}.MarshalBinary()
+	require.Error(t, err)
+
+	tests := map[string][]byte{
+		"truncated tag":      {0x80},
+		"truncated payload":  {0x08, 0x01, 0x12, 0x05},
+		"wrong wire type":    {0x0a, 0x00},
+		"unknown signal":     {0x08, 0x09},
+		"missing signal":     {0x12, 0x00},
+		"truncated received": {0x08, 0x01, 0x21, 0x01},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out Envelope
+			require.Error(t, out.UnmarshalBinary(data))
+		})
+	}
+}
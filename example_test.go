@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"time"
 
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	"go.olly.garden/otlp-wire"
 )
@@ -136,6 +140,283 @@ func ExampleMetric_DataPoints() {
 	// Output: request.duration ts=1000000000 attr=method
 }
 
+// ExampleExportTracesServiceRequest_SplitBySize demonstrates splitting a
+// batch into requests under an exporter's size limit.
+func ExampleExportTracesServiceRequest_SplitBySize() {
+	marshaler := &ptrace.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalTraces(createSampleTraces())
+	req := otlpwire.ExportTracesServiceRequest(otlpBytes)
+
+	parts, getErr := req.SplitBySize(len(req) / 2)
+	for part := range parts {
+		spans, _ := part.SpanCount()
+		fmt.Printf("%d spans, fits: %t\n", spans, len(part) <= len(req)/2)
+	}
+	if err := getErr(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	// Output:
+	// 3 spans, fits: true
+	// 2 spans, fits: true
+	// 1 spans, fits: true
+}
+
+// ExampleExportTracesServiceRequest_SplitByAttribute demonstrates routing a
+// batch to per-tenant pipelines by a resource attribute.
+func ExampleExportTracesServiceRequest_SplitByAttribute() {
+	marshaler := &ptrace.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalTraces(createSampleTraces())
+	req := otlpwire.ExportTracesServiceRequest(otlpBytes)
+
+	parts, getErr := req.SplitByAttribute("service.name")
+	for service, part := range parts {
+		spans, _ := part.SpanCount()
+		fmt.Printf("%s: %d spans\n", service, spans)
+	}
+	if err := getErr(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	// Output:
+	// frontend: 3 spans
+	// backend: 2 spans
+	// database: 1 spans
+}
+
+// ExampleExportTracesServiceRequest_SplitByTraceIDHash demonstrates
+// trace-aware load balancing: every span of a trace goes to the same
+// backend.
+func ExampleExportTracesServiceRequest_SplitByTraceIDHash() {
+	marshaler := &ptrace.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalTraces(createSampleTraces())
+	req := otlpwire.ExportTracesServiceRequest(otlpBytes)
+
+	total := 0
+	parts, getErr := req.SplitByTraceIDHash(4)
+	for bucket, part := range parts {
+		spans, _ := part.SpanCount()
+		total += spans
+		_ = bucket // send part to backends[bucket]
+	}
+	if err := getErr(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("%d spans routed\n", total)
+
+	// Output: 6 spans routed
+}
+
+// ExampleSplitterFunc demonstrates a custom partitioning: here log records
+// are keyed by whether they carry an error severity.
+func ExampleSplitterFunc() {
+	logs := plog.NewLogs()
+	records := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for _, sev := range []plog.SeverityNumber{plog.SeverityNumberInfo, plog.SeverityNumberError, plog.SeverityNumberWarn} {
+		records.AppendEmpty().SetSeverityNumber(sev)
+	}
+	marshaler := &plog.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalLogs(logs)
+
+	bySeverity := otlpwire.SplitterFunc(func(level otlpwire.SplitLevel, record []byte) (string, bool, error) {
+		if level != otlpwire.SplitRecord {
+			return "", false, nil
+		}
+		sev, err := otlpwire.LogRecord(record).SeverityNumber()
+		if err != nil {
+			return "", false, err
+		}
+		if sev >= int32(plog.SeverityNumberError) {
+			return "errors", true, nil
+		}
+		return "other", true, nil
+	})
+	parts, getErr := otlpwire.ExportLogsServiceRequest(otlpBytes).Split(bySeverity)
+	for key, part := range parts {
+		n, _ := part.LogRecordCount()
+		fmt.Printf("%s: %d\n", key, n)
+	}
+	if err := getErr(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	// Output:
+	// other: 2
+	// errors: 1
+}
+
+// ExampleExportMetricsServiceRequest_FilterMetricNames demonstrates dropping
+// unwanted metrics before export.
+func ExampleExportMetricsServiceRequest_FilterMetricNames() {
+	metrics := pmetric.NewMetrics()
+	ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range []string{"http.server.duration", "jvm.gc.duration", "jvm.memory.used"} {
+		m := ms.AppendEmpty()
+		m.SetName(name)
+		m.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	}
+	marshaler := &pmetric.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalMetrics(metrics)
+
+	filter := otlpwire.NameFilter{DenyPrefixes: []string{"jvm.gc."}}
+	out, dropped, err := otlpwire.ExportMetricsServiceRequest(otlpBytes).FilterMetricNames(filter)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	count, _ := out.DataPointCount()
+	fmt.Printf("dropped %d metrics, %d data points left\n", dropped, count)
+
+	// Output: dropped 1 metrics, 2 data points left
+}
+
+// ExampleExportTracesServiceRequest_FilterScopeNames demonstrates dropping
+// the spans of a noisy instrumentation library.
+func ExampleExportTracesServiceRequest_FilterScopeNames() {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	for _, scope := range []string{"io.opentelemetry.netty-4.1", "app"} {
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(scope)
+		ss.Spans().AppendEmpty().SetName(scope + " span")
+	}
+	marshaler := &ptrace.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalTraces(traces)
+
+	filter := otlpwire.NameFilter{DenyPrefixes: []string{"io.opentelemetry.netty"}}
+	out, dropped, err := otlpwire.ExportTracesServiceRequest(otlpBytes).FilterScopeNames(filter)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	count, _ := out.SpanCount()
+	fmt.Printf("dropped %d spans, kept %d\n", dropped, count)
+
+	// Output: dropped 1 spans, kept 1
+}
+
+// ExampleExportTracesServiceRequest_DropResourcesWhere demonstrates dropping
+// the telemetry of synthetic checks by a resource attribute.
+func ExampleExportTracesServiceRequest_DropResourcesWhere() {
+	marshaler := &ptrace.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalTraces(createSampleTraces())
+
+	out, dropped, err := otlpwire.ExportTracesServiceRequest(otlpBytes).DropResourcesWhere("service.name", "database")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	count, _ := out.SpanCount()
+	fmt.Printf("dropped %d resources, %d spans left\n", dropped, count)
+
+	// Output: dropped 1 resources, 5 spans left
+}
+
+// ExampleExportTracesServiceRequest_KeepErrorSpans demonstrates errors-only
+// retention that keeps the local root of each failing span for context.
+func ExampleExportTracesServiceRequest_KeepErrorSpans() {
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	traceID := pcommon.TraceID{1}
+	root := spans.AppendEmpty()
+	root.SetName("GET /checkout")
+	root.SetTraceID(traceID)
+	root.SetSpanID(pcommon.SpanID{1})
+	for i, name := range []string{"load cart", "charge card"} {
+		child := spans.AppendEmpty()
+		child.SetName(name)
+		child.SetTraceID(traceID)
+		child.SetSpanID(pcommon.SpanID{byte(i + 2)})
+		child.SetParentSpanID(root.SpanID())
+	}
+	spans.At(2).Status().SetCode(ptrace.StatusCodeError)
+	marshaler := &ptrace.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalTraces(traces)
+
+	out, dropped, err := otlpwire.ExportTracesServiceRequest(otlpBytes).KeepErrorSpans(true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	kept, _ := out.SpanCount()
+	fmt.Printf("kept %d spans, dropped %d\n", kept, dropped)
+
+	// Output: kept 2 spans, dropped 1
+}
+
+// ExampleExportLogsServiceRequest_BackfillObservedTimestamps demonstrates
+// stamping records that arrive without an observed time at the receiver.
+func ExampleExportLogsServiceRequest_BackfillObservedTimestamps() {
+	logs := plog.NewLogs()
+	records := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	records.AppendEmpty().Body().SetStr("no observed time")
+	records.AppendEmpty().SetObservedTimestamp(1)
+	marshaler := &plog.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalLogs(logs)
+
+	_, updated, err := otlpwire.ExportLogsServiceRequest(otlpBytes).BackfillObservedTimestamps(time.Unix(1700000000, 0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("updated %d records\n", updated)
+
+	// Output: updated 1 records
+}
+
+// ExampleExportMetricsServiceRequest_IntValuesToDouble demonstrates
+// normalizing number data points for a backend that stores only doubles.
+func ExampleExportMetricsServiceRequest_IntValuesToDouble() {
+	marshaler := &pmetric.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalMetrics(createSampleMetrics(3))
+
+	_, converted, err := otlpwire.ExportMetricsServiceRequest(otlpBytes).IntValuesToDouble()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("converted %d data points\n", converted)
+
+	// Output: converted 3 data points
+}
+
+// ExampleRebatchTraces demonstrates turning many small requests into a few
+// requests of a target size.
+func ExampleRebatchTraces() {
+	marshaler := &ptrace.ProtoMarshaler{}
+	otlpBytes, _ := marshaler.MarshalTraces(createSampleTraces())
+
+	reqs := [][]byte{otlpBytes, otlpBytes, otlpBytes}
+	out, err := otlpwire.RebatchTraces(reqs, 2*len(otlpBytes))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	for _, req := range out {
+		spans, _ := req.SpanCount()
+		fmt.Printf("%d spans\n", spans)
+	}
+
+	// Output:
+	// 15 spans
+	// 3 spans
+}
+
+// ExampleBundle demonstrates carrying all signals of one tenant through a
+// pipeline stage as a single value.
+func ExampleBundle() {
+	tracesBytes, _ := (&ptrace.ProtoMarshaler{}).MarshalTraces(createSampleTraces())
+	metricsBytes, _ := (&pmetric.ProtoMarshaler{}).MarshalMetrics(createSampleMetrics(5))
+
+	b := otlpwire.Bundle{Metrics: metricsBytes, Tenant: "acme"}
+	if err := b.Add(otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesBytes}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	stats, _ := b.Stats()
+	fmt.Printf("%d spans, %d data points, %d items\n", stats.Spans, stats.DataPoints, stats.Items())
+	for _, e := range b.Envelopes() {
+		fmt.Printf("%s for %s\n", e.Signal, e.Tenant)
+	}
+
+	// Output:
+	// 6 spans, 5 data points, 11 items
+	// traces for acme
+	// metrics for acme
+}
+
 // Helper functions
 
 func createSampleMetrics(dataPoints int) pmetric.Metrics {
@@ -178,6 +459,24 @@ func createMultiServiceMetrics() pmetric.Metrics {
 	return metrics
 }
 
+// createSampleTraces returns three services with three, two and one spans,
+// each span in a trace of its own.
+func createSampleTraces() ptrace.Traces {
+	traces := ptrace.NewTraces()
+	for i, svc := range []string{"frontend", "backend", "database"} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", svc)
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+		for j := 0; j < 3-i; j++ {
+			span := spans.AppendEmpty()
+			span.SetName("operation")
+			span.SetTraceID(pcommon.TraceID{byte(i), byte(j), 1})
+			span.SetSpanID(pcommon.SpanID{byte(i), byte(j), 1})
+		}
+	}
+	return traces
+}
+
 func hashBytes(data []byte) uint64 {
 	h := fnv.New64a()
 	_, _ = h.Write(data)
@@ -0,0 +1,328 @@
+package otlpwire
+
+import (
+	"bytes"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// resourcePath selects the resource entries of an export request.
+var resourcePath = []protowire.Number{1}
+
+// FilterResources returns a copy of the request holding only the
+// ResourceSpans for which keep returns true, copied verbatim and in order,
+// and the number of entries dropped.
+func (t ExportTracesServiceRequest) FilterResources(keep func(ResourceSpans) bool) (ExportTracesServiceRequest, int, error) {
+	out, dropped, err := filterResources(t, func(entry []byte) (bool, error) {
+		return keep(ResourceSpans(entry)), nil
+	})
+	return ExportTracesServiceRequest(out), dropped, err
+}
+
+// FilterResources returns a copy of the request holding only the
+// ResourceMetrics for which keep returns true, as
+// ExportTracesServiceRequest.FilterResources.
+func (m ExportMetricsServiceRequest) FilterResources(keep func(ResourceMetrics) bool) (ExportMetricsServiceRequest, int, error) {
+	out, dropped, err := filterResources(m, func(entry []byte) (bool, error) {
+		return keep(ResourceMetrics(entry)), nil
+	})
+	return ExportMetricsServiceRequest(out), dropped, err
+}
+
+// FilterResources returns a copy of the request holding only the
+// ResourceLogs for which keep returns true, as
+// ExportTracesServiceRequest.FilterResources.
+func (l ExportLogsServiceRequest) FilterResources(keep func(ResourceLogs) bool) (ExportLogsServiceRequest, int, error) {
+	out, dropped, err := filterResources(l, func(entry []byte) (bool, error) {
+		return keep(ResourceLogs(entry)), nil
+	})
+	return ExportLogsServiceRequest(out), dropped, err
+}
+
+// DropResourcesWhere returns a copy of the request without the
+// ResourceSpans whose resource attribute key equals value, and the number
+// of entries dropped. Values are compared in their AnyValue.AppendText
+// form, so an int attribute 42 matches "42"; resources without the
+// attribute are kept.
+func (t ExportTracesServiceRequest) DropResourcesWhere(key, value string) (ExportTracesServiceRequest, int, error) {
+	out, dropped, err := dropResourcesWhere(t, key, func(v string) bool { return v == value })
+	return ExportTracesServiceRequest(out), dropped, err
+}
+
+// DropResourcesWithPrefix is DropResourcesWhere matching the values that
+// start with prefix.
+func (t ExportTracesServiceRequest) DropResourcesWithPrefix(key, prefix string) (ExportTracesServiceRequest, int, error) {
+	out, dropped, err := dropResourcesWhere(t, key, func(v string) bool { return strings.HasPrefix(v, prefix) })
+	return ExportTracesServiceRequest(out), dropped, err
+}
+
+// DropResourcesWhere returns a copy of the request without the
+// ResourceMetrics whose resource attribute key equals value, as
+// ExportTracesServiceRequest.DropResourcesWhere.
+func (m ExportMetricsServiceRequest) DropResourcesWhere(key, value string) (ExportMetricsServiceRequest, int, error) {
+	out, dropped, err := dropResourcesWhere(m, key, func(v string) bool { return v == value })
+	return ExportMetricsServiceRequest(out), dropped, err
+}
+
+// DropResourcesWithPrefix is DropResourcesWhere matching the values that
+// start with prefix.
+func (m ExportMetricsServiceRequest) DropResourcesWithPrefix(key, prefix string) (ExportMetricsServiceRequest, int, error) {
+	out, dropped, err := dropResourcesWhere(m, key, func(v string) bool { return strings.HasPrefix(v, prefix) })
+	return ExportMetricsServiceRequest(out), dropped, err
+}
+
+// DropResourcesWhere returns a copy of the request without the
+// ResourceLogs whose resource attribute key equals value, as
+// ExportTracesServiceRequest.DropResourcesWhere.
+func (l ExportLogsServiceRequest) DropResourcesWhere(key, value string) (ExportLogsServiceRequest, int, error) {
+	out, dropped, err := dropResourcesWhere(l, key, func(v string) bool { return v == value })
+	return ExportLogsServiceRequest(out), dropped, err
+}
+
+// DropResourcesWithPrefix is DropResourcesWhere matching the values that
+// start with prefix.
+func (l ExportLogsServiceRequest) DropResourcesWithPrefix(key, prefix string) (ExportLogsServiceRequest, int, error) {
+	out, dropped, err := dropResourcesWhere(l, key, func(v string) bool { return strings.HasPrefix(v, prefix) })
+	return ExportLogsServiceRequest(out), dropped, err
+}
+
+// dropResourcesWhere drops the resource entries of data whose attribute key
+// has a text form matching match.
+func dropResourcesWhere(data []byte, key string, match func(string) bool) ([]byte, int, error) {
+	var text []byte
+	return filterResources(data, func(entry []byte) (bool, error) {
+		resource, err := extractBytesField(entry, 1)
+		if err != nil {
+			return false, err
+		}
+		value, ok, err := Resource(resource).Attribute(key)
+		if err != nil || !ok {
+			return true, err
+		}
+		text, err = value.AppendText(text[:0])
+		return !match(string(text)), err
+	})
+}
+
+// KeepErrorSpans returns a copy of the request holding only the spans
+// whose status code is StatusCodeError, for errors-only retention, and the
+// number of spans dropped. With withLocalRoots, the local root of every
+// error span is kept too: the topmost ancestor reached through parents
+// that are in the request and share the span's Resource, which usually
+// names the failed operation as the service received it. Scopes and
+// resources left without spans are dropped.
+func (t ExportTracesServiceRequest) KeepErrorSpans(withLocalRoots bool) (ExportTracesServiceRequest, int, error) {
+	type spanKey struct {
+		traceID [16]byte
+		spanID  [8]byte
+	}
+	roots := make(map[spanKey]bool)
+	if withLocalRoots {
+		x := NewTraceIndex()
+		if err := x.Add(t); err != nil {
+			return nil, 0, err
+		}
+		for trace := range x.Traces() {
+			for _, s := range trace.Spans {
+				code, err := s.Span.StatusCode()
+				if err != nil {
+					return nil, 0, err
+				}
+				if code != StatusCodeError {
+					continue
+				}
+				root := s
+				for root.Parent >= 0 && bytes.Equal(trace.Spans[root.Parent].Resource, s.Resource) {
+					root = trace.Spans[root.Parent]
+				}
+				roots[spanKey{trace.TraceID, root.SpanID}] = true
+			}
+		}
+	}
+
+	total := 0
+	out, err := filterRecords(t, spanPath, func(raw []byte) (bool, error) {
+		total++
+		span := Span(raw)
+		code, err := span.StatusCode()
+		if err != nil || code == StatusCodeError || len(roots) == 0 {
+			return code == StatusCodeError, err
+		}
+		traceID, err := span.TraceID()
+		if err != nil {
+			return false, err
+		}
+		spanID, err := span.SpanID()
+		return roots[spanKey{traceID, spanID}], err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	kept, err := countSpans(out)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportTracesServiceRequest(out), total - kept, nil
+}
+
+// NameFilter selects names, such as metric or scope names, by allow and
+// deny lists of exact names and prefixes. A name is kept unless it matches
+// a deny rule; when any allow rule is set, it must also match one. The
+// zero NameFilter keeps everything.
+type NameFilter struct {
+	Allow         []string
+	AllowPrefixes []string
+	Deny          []string
+	DenyPrefixes  []string
+}
+
+// Keep reports whether f keeps name.
+func (f NameFilter) Keep(name string) bool {
+	return f.compile().keep([]byte(name))
+}
+
+// nameMatcher is a NameFilter prepared for matching many names.
+type nameMatcher struct {
+	allow, deny                 map[string]bool
+	allowPrefixes, denyPrefixes []string
+	hasAllow                    bool
+}
+
+func (f NameFilter) compile() *nameMatcher {
+	set := func(names []string) map[string]bool {
+		m := make(map[string]bool, len(names))
+		for _, n := range names {
+			m[n] = true
+		}
+		return m
+	}
+	return &nameMatcher{
+		allow:         set(f.Allow),
+		deny:          set(f.Deny),
+		allowPrefixes: f.AllowPrefixes,
+		denyPrefixes:  f.DenyPrefixes,
+		hasAllow:      len(f.Allow) > 0 || len(f.AllowPrefixes) > 0,
+	}
+}
+
+func (m *nameMatcher) keep(name []byte) bool {
+	if m.deny[string(name)] || hasAnyPrefix(name, m.denyPrefixes) {
+		return false
+	}
+	return !m.hasAllow || m.allow[string(name)] || hasAnyPrefix(name, m.allowPrefixes)
+}
+
+func hasAnyPrefix(name []byte, prefixes []string) bool {
+	for _, p := range prefixes {
+		if bytes.HasPrefix(name, []byte(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterMetricNames returns the request without the metrics whose name f
+// does not keep, and the number of metrics dropped. Scopes and resources
+// left without metrics are dropped. Names are checked in a first pass that
+// copies nothing; if every metric is kept, m itself is returned.
+func (m ExportMetricsServiceRequest) FilterMetricNames(f NameFilter) (ExportMetricsServiceRequest, int, error) {
+	matcher := f.compile()
+	keep := func(metric []byte) (bool, error) {
+		name, err := extractBytesField(metric, 1)
+		return err == nil && matcher.keep(name), err
+	}
+	dropped := 0
+	var keepErr error
+	err := forEachNested(m, metricPath, func(metric []byte) bool {
+		ok, err := keep(metric)
+		if err != nil {
+			keepErr = err
+			return false
+		}
+		if !ok {
+			dropped++
+		}
+		return true
+	})
+	if err == nil {
+		err = keepErr
+	}
+	if err != nil || dropped == 0 {
+		return m, 0, err
+	}
+	out, err := filterRecords(m, metricPath, keep)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportMetricsServiceRequest(out), dropped, nil
+}
+
+// FilterScopeNames returns the request without the scopes whose
+// instrumentation scope name f does not keep, and the number of spans
+// dropped with them. Resources left without scopes are dropped.
+func (t ExportTracesServiceRequest) FilterScopeNames(f NameFilter) (ExportTracesServiceRequest, int, error) {
+	out, dropped, err := filterScopeNames(t, f, countInScopeSpans)
+	return ExportTracesServiceRequest(out), dropped, err
+}
+
+// FilterScopeNames returns the request without the scopes whose
+// instrumentation scope name f does not keep, and the number of data
+// points dropped with them. Resources left without scopes are dropped.
+func (m ExportMetricsServiceRequest) FilterScopeNames(f NameFilter) (ExportMetricsServiceRequest, int, error) {
+	out, dropped, err := filterScopeNames(m, f, countInScopeMetrics)
+	return ExportMetricsServiceRequest(out), dropped, err
+}
+
+// FilterScopeNames returns the request without the scopes whose
+// instrumentation scope name f does not keep, and the number of log
+// records dropped with them. Resources left without scopes are dropped.
+func (l ExportLogsServiceRequest) FilterScopeNames(f NameFilter) (ExportLogsServiceRequest, int, error) {
+	out, dropped, err := filterScopeNames(l, f, countInScopeLogs)
+	return ExportLogsServiceRequest(out), dropped, err
+}
+
+// filterScopeNames drops the scope entries of data whose scope name the
+// filter rejects and returns the number of items they held, as counted by
+// count. A scope entry without a scope has the empty name.
+func filterScopeNames(data []byte, f NameFilter, count func([]byte) (int, error)) ([]byte, int, error) {
+	matcher := f.compile()
+	dropped := 0
+	out, err := filterRecords(data, scopePath, func(entry []byte) (bool, error) {
+		scope, err := extractBytesField(entry, 1)
+		if err != nil {
+			return false, err
+		}
+		name, err := extractBytesField(scope, 1)
+		if err != nil {
+			return false, err
+		}
+		if matcher.keep(name) {
+			return true, nil
+		}
+		n, err := count(entry)
+		dropped += n
+		return false, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, dropped, nil
+}
+
+// filterResources keeps the resource entries of data for which keep returns
+// true and returns the number dropped.
+func filterResources(data []byte, keep func([]byte) (bool, error)) ([]byte, int, error) {
+	dropped := 0
+	out, err := filterRecords(data, resourcePath, func(entry []byte) (bool, error) {
+		ok, err := keep(entry)
+		if err == nil && !ok {
+			dropped++
+		}
+		return ok, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, dropped, nil
+}
@@ -0,0 +1,291 @@
+package otlpwire
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestFilterResources(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for _, service := range []string{"api", "synthetic-probe", "db", "synthetic-check"} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	}
+	out, dropped, err := ExportTracesServiceRequest(marshalTraces(t, traces)).FilterResources(func(rs ResourceSpans) bool {
+		service, err := rs.ServiceName()
+		return err == nil && !strings.HasPrefix(service, "synthetic-")
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, dropped)
+	td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	var services []string
+	for _, rs := range td.ResourceSpans().All() {
+		service, _ := rs.Resource().Attributes().Get("service.name")
+		services = append(services, service.Str())
+	}
+	require.Equal(t, []string{"api", "db"}, services)
+
+	metrics := ExportMetricsServiceRequest(buildScopedMetrics(t, 3, 1, 1))
+	mout, dropped, err := metrics.FilterResources(func(ResourceMetrics) bool { return true })
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+	require.Equal(t, metrics, mout)
+
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lout, dropped, err := ExportLogsServiceRequest(marshalLogs(t, logs)).FilterResources(func(ResourceLogs) bool { return false })
+	require.NoError(t, err)
+	require.Equal(t, 1, dropped)
+	require.Empty(t, lout)
+}
+
+func TestDropResourcesWhere(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for _, r := range []struct {
+		service   string
+		synthetic bool
+	}{{"api", false}, {"probe-eu", true}, {"probe-us", true}, {"db", false}} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", r.service)
+		rs.Resource().Attributes().PutBool("synthetic", r.synthetic)
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	}
+	// A resource without the attribute is kept.
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	data := ExportTracesServiceRequest(marshalTraces(t, traces))
+
+	services := func(req ExportTracesServiceRequest) []string {
+		var out []string
+		seq, errFn := req.ResourceSpans()
+		for rs := range seq {
+			service, err := rs.ServiceName()
+			require.NoError(t, err)
+			out = append(out, service)
+		}
+		require.NoError(t, errFn())
+		return out
+	}
+
+	out, dropped, err := data.DropResourcesWhere("synthetic", "true")
+	require.NoError(t, err)
+	require.Equal(t, 2, dropped)
+	require.Equal(t, []string{"api", "db", ""}, services(out))
+
+	out, dropped, err = data.DropResourcesWithPrefix("service.name", "probe-")
+	require.NoError(t, err)
+	require.Equal(t, 2, dropped)
+	require.Equal(t, []string{"api", "db", ""}, services(out))
+
+	out, dropped, err = data.DropResourcesWhere("service.name", "probe")
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+	require.Equal(t, data, out)
+
+	metrics := ExportMetricsServiceRequest(buildScopedMetrics(t, 3, 1, 1))
+	mout, dropped, err := metrics.DropResourcesWhere("service.name", "service-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, dropped)
+	n, err := mout.DataPointCount()
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	_, dropped, err = metrics.DropResourcesWithPrefix("service.name", "service-")
+	require.NoError(t, err)
+	require.Equal(t, 3, dropped)
+
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutInt("shard", 42)
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	_, dropped, err = ExportLogsServiceRequest(marshalLogs(t, logs)).DropResourcesWhere("shard", "42")
+	require.NoError(t, err)
+	require.Equal(t, 1, dropped)
+	_, dropped, err = ExportLogsServiceRequest(marshalLogs(t, logs)).DropResourcesWithPrefix("shard", "5")
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+}
+
+func TestKeepErrorSpans(t *testing.T) {
+	traces := ptrace.NewTraces()
+	add := func(service string, spans ...testSpan) {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		ss := rs.ScopeSpans().AppendEmpty()
+		for _, s := range spans {
+			span := ss.Spans().AppendEmpty()
+			span.SetTraceID(pcommon.TraceID(traceID(s.trace)))
+			span.SetSpanID(pcommon.SpanID(spanID(s.id)))
+			if s.parent != 0 {
+				span.SetParentSpanID(pcommon.SpanID(spanID(s.parent)))
+			}
+			span.SetName(s.name)
+			if strings.HasSuffix(s.name, "!") {
+				span.Status().SetCode(ptrace.StatusCodeError)
+			}
+		}
+	}
+	// The frontend calls the backend, which fails in a nested span; the
+	// frontend also fails in a span of its own.
+	add("frontend",
+		testSpan{trace: 1, id: 1, name: "GET /checkout"},
+		testSpan{trace: 1, id: 2, parent: 1, name: "call backend"},
+		testSpan{trace: 1, id: 5, parent: 2, name: "render!"},
+	)
+	add("backend",
+		testSpan{trace: 1, id: 3, parent: 2, name: "POST /pay"},
+		testSpan{trace: 1, id: 4, parent: 3, name: "charge card!"},
+		testSpan{trace: 1, id: 6, parent: 3, name: "audit"},
+	)
+	add("idle", testSpan{trace: 2, id: 1, name: "tick"})
+	data := ExportTracesServiceRequest(marshalTraces(t, traces))
+
+	names := func(req ExportTracesServiceRequest) []string {
+		var out []string
+		seq, errFn := req.AllSpans()
+		for s := range seq {
+			name, err := s.Span.Name()
+			require.NoError(t, err)
+			out = append(out, string(name))
+		}
+		require.NoError(t, errFn())
+		return out
+	}
+
+	out, dropped, err := data.KeepErrorSpans(false)
+	require.NoError(t, err)
+	require.Equal(t, 5, dropped)
+	require.Equal(t, []string{"render!", "charge card!"}, names(out))
+	td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	require.Equal(t, 2, td.ResourceSpans().Len())
+
+	out, dropped, err = data.KeepErrorSpans(true)
+	require.NoError(t, err)
+	require.Equal(t, 3, dropped)
+	require.Equal(t, []string{"GET /checkout", "render!", "POST /pay", "charge card!"}, names(out))
+}
+
+func TestNameFilter(t *testing.T) {
+	for _, tt := range []struct {
+		filter NameFilter
+		kept   []string
+	}{
+		{NameFilter{}, []string{"http.server.duration", "jvm.gc.duration", "jvm.memory.used", "rpc.calls"}},
+		{NameFilter{Deny: []string{"rpc.calls"}, DenyPrefixes: []string{"jvm.gc."}}, []string{"http.server.duration", "jvm.memory.used"}},
+		{NameFilter{Allow: []string{"rpc.calls"}, AllowPrefixes: []string{"jvm."}}, []string{"jvm.gc.duration", "jvm.memory.used", "rpc.calls"}},
+		// Deny wins over allow.
+		{NameFilter{AllowPrefixes: []string{"jvm."}, Deny: []string{"jvm.memory.used"}}, []string{"jvm.gc.duration"}},
+	} {
+		var kept []string
+		for _, name := range []string{"http.server.duration", "jvm.gc.duration", "jvm.memory.used", "rpc.calls"} {
+			if tt.filter.Keep(name) {
+				kept = append(kept, name)
+			}
+		}
+		require.Equal(t, tt.kept, kept, "%+v", tt.filter)
+	}
+}
+
+func TestFilterMetricNames(t *testing.T) {
+	// metric.<scope>.<n> for 2 resources, 2 scopes, 3 metrics per scope.
+	data := ExportMetricsServiceRequest(buildScopedMetrics(t, 2, 2, 3))
+
+	out, dropped, err := data.FilterMetricNames(NameFilter{Deny: []string{"metric.0.1"}, DenyPrefixes: []string{"metric.1."}})
+	require.NoError(t, err)
+	require.Equal(t, 8, dropped)
+	md, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(out)
+	require.NoError(t, err)
+	require.Equal(t, 2, md.ResourceMetrics().Len())
+	var names []string
+	for _, rm := range md.ResourceMetrics().All() {
+		// The scope left without metrics is gone.
+		require.Equal(t, 1, rm.ScopeMetrics().Len())
+		for _, m := range rm.ScopeMetrics().At(0).Metrics().All() {
+			names = append(names, m.Name())
+		}
+	}
+	require.Equal(t, []string{"metric.0.0", "metric.0.2", "metric.0.0", "metric.0.2"}, names)
+
+	// Nothing dropped returns the request as is.
+	out, dropped, err = data.FilterMetricNames(NameFilter{AllowPrefixes: []string{"metric."}})
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+	require.Equal(t, data, out)
+
+	out, dropped, err = data.FilterMetricNames(NameFilter{Allow: []string{"none"}})
+	require.NoError(t, err)
+	require.Equal(t, 12, dropped)
+	require.Empty(t, out)
+}
+
+func TestFilterScopeNames(t *testing.T) {
+	td := ptrace.NewTraces()
+	for r := range 2 {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("svc-%d", r))
+		for _, name := range []string{"io.opentelemetry.netty-4.1", "app", ""} {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(name)
+			for range 2 {
+				ss.Spans().AppendEmpty().SetName(name + ".op")
+			}
+		}
+	}
+	data := ExportTracesServiceRequest(marshalTraces(t, td))
+
+	out, dropped, err := data.FilterScopeNames(NameFilter{DenyPrefixes: []string{"io.opentelemetry.netty"}})
+	require.NoError(t, err)
+	require.Equal(t, 4, dropped)
+	got, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	var names []string
+	for _, rs := range got.ResourceSpans().All() {
+		for _, ss := range rs.ScopeSpans().All() {
+			names = append(names, ss.Scope().Name())
+		}
+	}
+	require.Equal(t, []string{"app", "", "app", ""}, names)
+
+	// An allow list drops unnamed scopes too; empty resources go away.
+	out, dropped, err = data.FilterScopeNames(NameFilter{Allow: []string{"none"}})
+	require.NoError(t, err)
+	require.Equal(t, 12, dropped)
+	require.Empty(t, out)
+
+	_, _, err = ExportTracesServiceRequest{0x0a, 0x05, 0x12}.FilterScopeNames(NameFilter{})
+	require.Error(t, err)
+}
+
+func TestFilterScopeNamesMetricsLogs(t *testing.T) {
+	// Scopes are named scope-<n>, with 3 metrics of one data point each.
+	m := ExportMetricsServiceRequest(buildScopedMetrics(t, 1, 3, 3))
+	out, dropped, err := m.FilterScopeNames(NameFilter{Deny: []string{"scope-1"}})
+	require.NoError(t, err)
+	require.Equal(t, 3, dropped)
+	n, err := out.DataPointCount()
+	require.NoError(t, err)
+	require.Equal(t, 6, n)
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	for _, name := range []string{"keep", "drop"} {
+		sl := rl.ScopeLogs().AppendEmpty()
+		sl.Scope().SetName(name)
+		sl.LogRecords().AppendEmpty()
+	}
+	l := ExportLogsServiceRequest(marshalLogs(t, ld))
+	outLogs, dropped, err := l.FilterScopeNames(NameFilter{Allow: []string{"keep"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, dropped)
+	n, err = outLogs.LogRecordCount()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
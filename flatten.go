@@ -0,0 +1,211 @@
+package otlpwire
+
+import (
+	"bytes"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// scopeTagger appends a copy of record to dst with the name and version of
+// the scope it came from added as attributes.
+type scopeTagger func(dst, record, name, version []byte) ([]byte, error)
+
+// FlattenScopes returns a copy of the request in which the ScopeSpans of
+// each ResourceSpans are merged into a single entry holding all of its
+// spans, in their original order, for downstreams that charge per envelope.
+// It also returns the number of scope entries removed.
+//
+// If all scopes of a resource have the same InstrumentationScope and
+// schema URL, the merged entry keeps them. Otherwise scope identity is
+// lost: the merged entry has neither, and if tagScope is set, every span
+// gets the name and version of its original scope as the otel.scope.name
+// and otel.scope.version attributes, unless it already has them.
+func (t ExportTracesServiceRequest) FlattenScopes(tagScope bool) (ExportTracesServiceRequest, int, error) {
+	var tag scopeTagger
+	if tagScope {
+		tag = attributeScopeTagger(9)
+	}
+	out, removed, err := flattenScopes([]byte(t), tag)
+	return ExportTracesServiceRequest(out), removed, err
+}
+
+// FlattenScopes returns a copy of the request in which the ScopeMetrics of
+// each ResourceMetrics are merged into a single entry, as
+// ExportTracesServiceRequest.FlattenScopes. Scope attributes are added to
+// data points.
+func (m ExportMetricsServiceRequest) FlattenScopes(tagScope bool) (ExportMetricsServiceRequest, int, error) {
+	var tag scopeTagger
+	if tagScope {
+		tag = tagMetricScope
+	}
+	out, removed, err := flattenScopes([]byte(m), tag)
+	return ExportMetricsServiceRequest(out), removed, err
+}
+
+// FlattenScopes returns a copy of the request in which the ScopeLogs of each
+// ResourceLogs are merged into a single entry, as
+// ExportTracesServiceRequest.FlattenScopes.
+func (l ExportLogsServiceRequest) FlattenScopes(tagScope bool) (ExportLogsServiceRequest, int, error) {
+	var tag scopeTagger
+	if tagScope {
+		tag = attributeScopeTagger(6)
+	}
+	out, removed, err := flattenScopes([]byte(l), tag)
+	return ExportLogsServiceRequest(out), removed, err
+}
+
+func flattenScopes(data []byte, tag scopeTagger) ([]byte, int, error) {
+	removed := 0
+	out, _, err := appendRewritten(make([]byte, 0, len(data)), data, []protowire.Number{1}, func(dst, resource []byte) ([]byte, bool, error) {
+		var n int
+		var err error
+		dst, n, err = appendFlattenedResource(dst, resource, tag)
+		removed += n
+		return dst, true, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, removed, nil
+}
+
+// appendFlattenedResource appends a copy of a Resource* message with its
+// scope entries merged and returns the number of entries removed.
+func appendFlattenedResource(dst, resource []byte, tag scopeTagger) ([]byte, int, error) {
+	var scopes [][]byte
+	err := forEachMessage(resource, 2, func(scope []byte) error {
+		scopes = append(scopes, scope)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(scopes) < 2 {
+		return append(dst, resource...), 0, nil
+	}
+
+	// Scope identity survives if every entry has the same scope and schema
+	// URL.
+	first, err := extractBytesField(scopes[0], 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	firstSchema, err := extractBytesField(scopes[0], 3)
+	if err != nil {
+		return nil, 0, err
+	}
+	same := true
+	for _, s := range scopes[1:] {
+		scope, err := extractBytesField(s, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		schema, err := extractBytesField(s, 3)
+		if err != nil {
+			return nil, 0, err
+		}
+		same = same && bytes.Equal(scope, first) && bytes.Equal(schema, firstSchema)
+	}
+
+	dst, err = appendFieldsExcept(dst, resource, 2)
+	if err != nil {
+		return nil, 0, err
+	}
+	dst, err = appendMessageField(dst, 2, func(b []byte) ([]byte, error) {
+		if same {
+			if first != nil {
+				b = protowire.AppendTag(b, 1, protowire.BytesType)
+				b = protowire.AppendBytes(b, first)
+			}
+			if len(firstSchema) > 0 {
+				b = protowire.AppendTag(b, 3, protowire.BytesType)
+				b = protowire.AppendBytes(b, firstSchema)
+			}
+		}
+		for _, s := range scopes {
+			var name, version []byte
+			if !same && tag != nil {
+				scope, err := extractBytesField(s, 1)
+				if err != nil {
+					return nil, err
+				}
+				if name, err = extractBytesField(scope, 1); err != nil {
+					return nil, err
+				}
+				if version, err = extractBytesField(scope, 2); err != nil {
+					return nil, err
+				}
+			}
+			err := forEachMessage(s, 2, func(record []byte) error {
+				if same || tag == nil {
+					b = protowire.AppendTag(b, 2, protowire.BytesType)
+					b = protowire.AppendBytes(b, record)
+					return nil
+				}
+				var err error
+				b, err = appendMessageField(b, 2, func(b []byte) ([]byte, error) {
+					return tag(b, record, name, version)
+				})
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return dst, len(scopes) - 1, nil
+}
+
+// attributeScopeTagger returns a scopeTagger for records whose attributes
+// are the repeated KeyValue field num.
+func attributeScopeTagger(num protowire.Number) scopeTagger {
+	return func(dst, record, name, version []byte) ([]byte, error) {
+		dst = append(dst, record...)
+		for _, attr := range [...]struct {
+			key   string
+			value []byte
+		}{{"otel.scope.name", name}, {"otel.scope.version", version}} {
+			if len(attr.value) == 0 {
+				continue
+			}
+			found, err := hasAttribute(record, num, []byte(attr.key))
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				dst = appendStringKeyValue(dst, num, attr.key, string(attr.value))
+			}
+		}
+		return dst, nil
+	}
+}
+
+// tagMetricScope is the scopeTagger of metrics, which tags every data point
+// of the metric.
+func tagMetricScope(dst, metric, name, version []byte) ([]byte, error) {
+	typ, err := metricBodyType(metric)
+	if err != nil {
+		return nil, err
+	}
+	if typ == 0 {
+		return append(dst, metric...), nil
+	}
+	tag := attributeScopeTagger(DataPoint{typ: typ}.attributesFieldNum())
+	mark := len(dst)
+	dst, kept, err := appendRewritten(dst, metric, []protowire.Number{protowire.Number(typ), 1}, func(dst, dp []byte) ([]byte, bool, error) {
+		dst, err := tag(dst, dp, name, version)
+		return dst, true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if kept == 0 {
+		// A body without data points would have been dropped.
+		return append(dst[:mark], metric...), nil
+	}
+	return dst, nil
+}
@@ -0,0 +1,128 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestFlattenScopes_Traces(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "api")
+	rs.SetSchemaUrl("resource-schema")
+	for i, name := range []string{"http", "db", "http"} {
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(name)
+		ss.Scope().SetVersion("1.0")
+		for j := range 2 {
+			span := ss.Spans().AppendEmpty()
+			span.SetName(name)
+			span.Attributes().PutInt("i", int64(i*2+j))
+		}
+	}
+	ss := rs.ScopeSpans().At(1)
+	ss.Spans().At(0).Attributes().PutStr("otel.scope.name", "custom")
+	// A second resource with a single scope is left alone.
+	single := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	single.Scope().SetName("only")
+	single.Spans().AppendEmpty().SetName("s")
+
+	data := marshalTraces(t, traces)
+	for _, tagScope := range []bool{false, true} {
+		out, removed, err := ExportTracesServiceRequest(data).FlattenScopes(tagScope)
+		require.NoError(t, err)
+		require.Equal(t, 2, removed)
+
+		got, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+		require.NoError(t, err)
+		require.Equal(t, 7, got.SpanCount())
+		rs := got.ResourceSpans().At(0)
+		require.Equal(t, "resource-schema", rs.SchemaUrl())
+		require.Equal(t, 1, rs.ScopeSpans().Len())
+		merged := rs.ScopeSpans().At(0)
+		require.Empty(t, merged.Scope().Name())
+		require.Equal(t, 6, merged.Spans().Len())
+		for i := range 6 {
+			span := merged.Spans().At(i)
+			idx, _ := span.Attributes().Get("i")
+			require.Equal(t, int64(i), idx.Int())
+			name, ok := span.Attributes().Get("otel.scope.name")
+			switch {
+			case i == 2:
+				require.Equal(t, "custom", name.Str())
+			case tagScope:
+				require.Equal(t, span.Name(), name.Str())
+			default:
+				require.False(t, ok)
+			}
+			_, ok = span.Attributes().Get("otel.scope.version")
+			require.Equal(t, tagScope, ok)
+		}
+		require.Equal(t, "only", got.ResourceSpans().At(1).ScopeSpans().At(0).Scope().Name())
+	}
+}
+
+func TestFlattenScopes_IdenticalScopes(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	for range 3 {
+		sl := rl.ScopeLogs().AppendEmpty()
+		sl.SetSchemaUrl("scope-schema")
+		sl.Scope().SetName("app")
+		sl.LogRecords().AppendEmpty().Body().SetStr("line")
+	}
+
+	out, removed, err := ExportLogsServiceRequest(marshalLogs(t, logs)).FlattenScopes(true)
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+	got, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(out)
+	require.NoError(t, err)
+	sls := got.ResourceLogs().At(0).ScopeLogs()
+	require.Equal(t, 1, sls.Len())
+	require.Equal(t, "app", sls.At(0).Scope().Name())
+	require.Equal(t, "scope-schema", sls.At(0).SchemaUrl())
+	require.Equal(t, 3, sls.At(0).LogRecords().Len())
+	require.Zero(t, sls.At(0).LogRecords().At(0).Attributes().Len())
+}
+
+func TestFlattenScopes_MetricsTagDataPoints(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("a")
+	hist := sm.Metrics().AppendEmpty()
+	hist.SetName("latency")
+	hist.SetEmptyHistogram().DataPoints().AppendEmpty().SetCount(1)
+	empty := sm.Metrics().AppendEmpty()
+	empty.SetName("empty")
+	empty.SetEmptyGauge()
+	sm = rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("b")
+	exp := sm.Metrics().AppendEmpty()
+	exp.SetName("sizes")
+	exp.SetEmptyExponentialHistogram().DataPoints().AppendEmpty().SetCount(2)
+
+	out, removed, err := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).FlattenScopes(true)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	got, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(out)
+	require.NoError(t, err)
+	ms := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 3, ms.Len())
+	name, _ := ms.At(0).Histogram().DataPoints().At(0).Attributes().Get("otel.scope.name")
+	require.Equal(t, "a", name.Str())
+	require.Equal(t, pmetric.MetricTypeGauge, ms.At(1).Type())
+	name, _ = ms.At(2).ExponentialHistogram().DataPoints().At(0).Attributes().Get("otel.scope.name")
+	require.Equal(t, "b", name.Str())
+}
+
+func TestFlattenScopes_Malformed(t *testing.T) {
+	_, _, err := ExportTracesServiceRequest([]byte{0x0a, 0x02, 0x12, 0x05}).FlattenScopes(false)
+	require.Error(t, err)
+	_, _, err = ExportTracesServiceRequest([]byte{0x0a, 0x04, 0x12, 0x00, 0x12, 0x05}).FlattenScopes(false)
+	require.Error(t, err)
+}
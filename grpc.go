@@ -0,0 +1,142 @@
+package otlpwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"iter"
+	"math"
+	"net/http"
+)
+
+// GRPCFrameHeaderSize is the size of the prefix of a gRPC message frame: a
+// flags byte and a big-endian 4-byte message length.
+const GRPCFrameHeaderSize = 5
+
+// gRPC frame flag bits. The trailer bit is only used by gRPC-Web.
+const (
+	grpcFlagCompressed = 0x01
+	grpcFlagTrailer    = 0x80
+)
+
+// GRPCFrame is one length-prefixed frame of a gRPC or gRPC-Web body.
+type GRPCFrame struct {
+	// Compressed reports that Data is compressed with the message encoding
+	// named by the grpc-encoding header, typically gzip. It must be
+	// decompressed before it is used as a request.
+	Compressed bool
+	// Trailer reports a gRPC-Web trailer frame, whose Data is an HTTP/1
+	// header block rather than a message. See GRPCWebTrailer.
+	Trailer bool
+	// Data is the frame payload, aliasing the body. For a message frame of
+	// an OTLP export call it is the request, for example
+	// ExportTracesServiceRequest(f.Data).
+	Data []byte
+}
+
+// GRPCFrames iterates over the frames of a gRPC or gRPC-Web body, as
+// captured from a proxy or traffic mirror. Bodies of the
+// application/grpc-web-text content type must be base64-decoded first. The
+// returned function should be called after iteration to check for errors.
+func GRPCFrames(body []byte) (iter.Seq[GRPCFrame], func() error) {
+	var iterErr error
+	seq := func(yield func(GRPCFrame) bool) {
+		iterErr = nil
+		data := body
+		for len(data) > 0 {
+			f, n, err := nextGRPCFrame(data)
+			if err != nil {
+				iterErr = err
+				return
+			}
+			data = data[n:]
+			if !yield(f) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return iterErr }
+}
+
+// nextGRPCFrame decodes the frame at the start of b and returns it together
+// with its encoded length.
+func nextGRPCFrame(b []byte) (GRPCFrame, int, error) {
+	if len(b) < GRPCFrameHeaderSize {
+		return GRPCFrame{}, 0, errors.New("truncated gRPC frame header")
+	}
+	flags := b[0]
+	if flags&^(grpcFlagCompressed|grpcFlagTrailer) != 0 {
+		return GRPCFrame{}, 0, errors.New("invalid gRPC frame flags")
+	}
+	size := binary.BigEndian.Uint32(b[1:GRPCFrameHeaderSize])
+	if uint64(size) > uint64(len(b)-GRPCFrameHeaderSize) {
+		return GRPCFrame{}, 0, errors.New("truncated gRPC frame")
+	}
+	end := GRPCFrameHeaderSize + int(size)
+	return GRPCFrame{
+		Compressed: flags&grpcFlagCompressed != 0,
+		Trailer:    flags&grpcFlagTrailer != 0,
+		Data:       b[GRPCFrameHeaderSize:end],
+	}, end, nil
+}
+
+// UnwrapGRPCMessage returns the message of a unary gRPC request body, such as
+// the body of an OTLP Export call: exactly one uncompressed message frame,
+// optionally followed by gRPC-Web trailer frames. The message aliases body.
+func UnwrapGRPCMessage(body []byte) ([]byte, error) {
+	var msg []byte
+	found := false
+	seq, errFn := GRPCFrames(body)
+	for f := range seq {
+		switch {
+		case f.Trailer:
+			continue
+		case found:
+			return nil, errors.New("more than one gRPC message frame")
+		case f.Compressed:
+			return nil, errors.New("gRPC message is compressed")
+		}
+		msg, found = f.Data, true
+	}
+	if err := errFn(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("no gRPC message frame")
+	}
+	return msg, nil
+}
+
+// AppendGRPCFrame appends msg as a gRPC message frame. compressed must be
+// set if msg is already compressed with the call's message encoding.
+func AppendGRPCFrame(dst, msg []byte, compressed bool) ([]byte, error) {
+	if len(msg) > math.MaxUint32 {
+		return dst, errors.New("message too large for gRPC frame")
+	}
+	var flags byte
+	if compressed {
+		flags = grpcFlagCompressed
+	}
+	dst = append(dst, flags)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(msg)))
+	return append(dst, msg...), nil
+}
+
+// GRPCWebTrailer parses the Data of a gRPC-Web trailer frame, "key: value"
+// lines separated by CRLF, for example to read grpc-status and
+// grpc-message. Keys are canonicalized as by http.Header.
+func GRPCWebTrailer(data []byte) (http.Header, error) {
+	h := make(http.Header)
+	for line := range bytes.Lines(data) {
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok || len(bytes.TrimSpace(key)) == 0 {
+			return nil, errors.New("malformed gRPC-Web trailer line")
+		}
+		h.Add(string(bytes.TrimSpace(key)), string(bytes.TrimSpace(value)))
+	}
+	return h, nil
+}
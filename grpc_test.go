@@ -0,0 +1,101 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCFrames(t *testing.T) {
+	traces := marshalTraces(t, createBenchTraces())
+
+	body, err := AppendGRPCFrame(nil, traces, false)
+	require.NoError(t, err)
+	require.Equal(t, byte(0), body[0])
+	require.Len(t, body, GRPCFrameHeaderSize+len(traces))
+	body, err = AppendGRPCFrame(body, []byte("gz"), true)
+	require.NoError(t, err)
+	trailer := "grpc-status: 0\r\ngrpc-message: OK\r\n"
+	body = append(body, 0x80, 0, 0, 0, byte(len(trailer)))
+	body = append(body, trailer...)
+
+	seq, errFn := GRPCFrames(body)
+	var frames []GRPCFrame
+	for f := range seq {
+		frames = append(frames, f)
+	}
+	require.NoError(t, errFn())
+	require.Len(t, frames, 3)
+	require.Equal(t, GRPCFrame{Data: traces}, frames[0])
+	require.Equal(t, GRPCFrame{Compressed: true, Data: []byte("gz")}, frames[1])
+	require.True(t, frames[2].Trailer)
+
+	count, err := ExportTracesServiceRequest(frames[0].Data).SpanCount()
+	require.NoError(t, err)
+	require.Equal(t, 500, count)
+
+	h, err := GRPCWebTrailer(frames[2].Data)
+	require.NoError(t, err)
+	require.Equal(t, "0", h.Get("Grpc-Status"))
+	require.Equal(t, "OK", h.Get("grpc-message"))
+
+	// Early break.
+	seq, errFn = GRPCFrames(body)
+	n := 0
+	for range seq {
+		n++
+		break
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 1, n)
+
+	// The sequence can be ranged again from the start.
+	n = 0
+	for range seq {
+		n++
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 3, n)
+}
+
+func TestGRPCFrames_Malformed(t *testing.T) {
+	for name, body := range map[string][]byte{
+		"truncated header": {0, 0, 0},
+		"truncated data":   {0, 0, 0, 0, 5, 'a'},
+		"invalid flags":    {0x02, 0, 0, 0, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			seq, errFn := GRPCFrames(body)
+			for range seq {
+				t.Fatal("unexpected frame")
+			}
+			require.Error(t, errFn())
+		})
+	}
+
+	_, err := GRPCWebTrailer([]byte("grpc-status 0\r\n"))
+	require.Error(t, err)
+}
+
+func TestUnwrapGRPCMessage(t *testing.T) {
+	msg, err := UnwrapGRPCMessage([]byte{0, 0, 0, 0, 2, 0x0a, 0x00, 0x80, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x0a, 0x00}, msg)
+
+	msg, err = UnwrapGRPCMessage([]byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.Empty(t, msg)
+
+	for name, body := range map[string][]byte{
+		"empty":      nil,
+		"trailer":    {0x80, 0, 0, 0, 0},
+		"two":        {0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		"compressed": {0x01, 0, 0, 0, 0},
+		"malformed":  {0, 0, 0, 0, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := UnwrapGRPCMessage(body)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package otlpwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// HeaderSize is the encoded size of a Header in bytes.
+const HeaderSize = 22
+
+// headerMagic marks the start of an encoded Header.
+var headerMagic = [2]byte{'o', 'w'}
+
+// headerVersion is the current Header layout version.
+const headerVersion = 1
+
+// Header is a fixed-size metadata header that can be prepended to a wire
+// payload before it is enqueued, so consumers can route on signal, size and
+// fingerprint without touching the OTLP bytes.
+//
+// Layout (big-endian, 22 bytes):
+//
+//	offset  size  field
+//	0       2     magic "ow"
+//	2       1     version
+//	3       1     signal
+//	4       2     schema hint
+//	6       4     item count
+//	10      4     byte size of the payload
+//	14      8     fingerprint
+type Header struct {
+	Signal Signal
+	// SchemaHint is an application-defined tag, for example an OTLP or
+	// semantic-conventions version the producer targets.
+	SchemaHint uint16
+	// ItemCount is the number of spans, data points or log records.
+	ItemCount uint32
+	// ByteSize is the length of the payload that follows the header.
+	ByteSize uint32
+	// Fingerprint is an application-defined routing hash, for example of the
+	// payload's Resource bytes. The package does not pick a hash function.
+	Fingerprint uint64
+}
+
+// NewHeader returns a Header for payload with Signal, ItemCount and ByteSize
+// filled in. SchemaHint and Fingerprint are left for the caller to set.
+func NewHeader(signal Signal, payload []byte) (Header, error) {
+	count, err := Envelope{Signal: signal, Payload: payload}.ItemCount()
+	if err != nil {
+		return Header{}, err
+	}
+	if len(payload) > math.MaxUint32 || count > math.MaxUint32 {
+		return Header{}, errors.New("payload too large for header")
+	}
+	return Header{
+		Signal:    signal,
+		ItemCount: uint32(count),
+		ByteSize:  uint32(len(payload)),
+	}, nil
+}
+
+// AppendTo appends the encoded header to dst and returns the extended buffer.
+func (h Header) AppendTo(dst []byte) []byte {
+	dst = append(dst, headerMagic[0], headerMagic[1], headerVersion, byte(h.Signal))
+	dst = binary.BigEndian.AppendUint16(dst, h.SchemaHint)
+	dst = binary.BigEndian.AppendUint32(dst, h.ItemCount)
+	dst = binary.BigEndian.AppendUint32(dst, h.ByteSize)
+	dst = binary.BigEndian.AppendUint64(dst, h.Fingerprint)
+	return dst
+}
+
+// ParseHeader decodes the Header at the start of data and returns it along
+// with the payload that follows. The payload aliases data and is exactly
+// ByteSize bytes long; any bytes after it are reported as an error.
+func ParseHeader(data []byte) (Header, []byte, error) {
+	h, payload, rest, err := ParseHeaderPrefix(data)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	if len(rest) > 0 {
+		return Header{}, nil, errors.New("header byte size does not match payload")
+	}
+	return h, payload, nil
+}
+
+// ParseHeaderPrefix decodes the Header at the start of data and returns it
+// along with the payload that follows and the bytes after that payload,
+// such as further framed payloads in a stream. payload and rest alias data.
+func ParseHeaderPrefix(data []byte) (h Header, payload, rest []byte, err error) {
+	if len(data) < HeaderSize {
+		return Header{}, nil, nil, errors.New("header truncated")
+	}
+	if data[0] != headerMagic[0] || data[1] != headerMagic[1] {
+		return Header{}, nil, nil, errors.New("header magic mismatch")
+	}
+	if data[2] != headerVersion {
+		return Header{}, nil, nil, errors.New("unsupported header version")
+	}
+	h = Header{
+		Signal:      Signal(data[3]),
+		SchemaHint:  binary.BigEndian.Uint16(data[4:]),
+		ItemCount:   binary.BigEndian.Uint32(data[6:]),
+		ByteSize:    binary.BigEndian.Uint32(data[10:]),
+		Fingerprint: binary.BigEndian.Uint64(data[14:]),
+	}
+	if h.Signal < SignalTraces || h.Signal > SignalLogs {
+		return Header{}, nil, nil, errors.New("header has unknown signal")
+	}
+	data = data[HeaderSize:]
+	if uint64(len(data)) < uint64(h.ByteSize) {
+		return Header{}, nil, nil, errors.New("header byte size does not match payload")
+	}
+	return h, data[:h.ByteSize], data[h.ByteSize:], nil
+}
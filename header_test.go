@@ -0,0 +1,85 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeader_RoundTrip(t *testing.T) {
+	payload := marshalLogs(t, createBenchLogs())
+
+	h, err := NewHeader(SignalLogs, payload)
+	require.NoError(t, err)
+	require.Equal(t, uint32(500), h.ItemCount)
+	require.Equal(t, uint32(len(payload)), h.ByteSize)
+	h.SchemaHint = 7
+	h.Fingerprint = 0xdeadbeefcafef00d
+
+	data := h.AppendTo(nil)
+	require.Len(t, data, HeaderSize)
+	data = append(data, payload...)
+
+	got, rest, err := ParseHeader(data)
+	require.NoError(t, err)
+	require.Equal(t, h, got)
+	require.Equal(t, payload, rest)
+}
+
+func TestNewHeader_Errors(t *testing.T) {
+	_, err := NewHeader(SignalUnspecified, nil)
+	require.Error(t, err)
+
+	_, err = NewHeader(SignalTraces, []byte{0x0a, 0x05})
+	require.Error(t, err)
+}
+
+func TestParseHeader_Errors(t *testing.T) {
+	valid := Header{Signal: SignalTraces, ByteSize: 2}.AppendTo(nil)
+	valid = append(valid, 0x0a, 0x00)
+	_, _, err := ParseHeader(valid)
+	require.NoError(t, err)
+
+	corrupt := func(i int, b byte) []byte {
+		c := append([]byte(nil), valid...)
+		c[i] = b
+		return c
+	}
+	tests := map[string][]byte{
+		"truncated":      valid[:HeaderSize-1],
+		"bad magic":      corrupt(0, 'x'),
+		"bad version":    corrupt(2, 9),
+		"unknown signal": corrupt(3, 0),
+		"short payload":  valid[:len(valid)-1],
+		"trailing bytes": append(append([]byte(nil), valid...), 0x00),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ParseHeader(data)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParseHeaderPrefix(t *testing.T) {
+	first := append(Header{Signal: SignalTraces, ByteSize: 2}.AppendTo(nil), 0x0a, 0x00)
+	second := Header{Signal: SignalLogs}.AppendTo(nil)
+	stream := append(append([]byte(nil), first...), second...)
+
+	h, payload, rest, err := ParseHeaderPrefix(stream)
+	require.NoError(t, err)
+	require.Equal(t, SignalTraces, h.Signal)
+	require.Equal(t, []byte{0x0a, 0x00}, payload)
+	require.Equal(t, second, rest)
+
+	h, payload, rest, err = ParseHeaderPrefix(rest)
+	require.NoError(t, err)
+	require.Equal(t, SignalLogs, h.Signal)
+	require.Empty(t, payload)
+	require.Empty(t, rest)
+
+	_, _, _, err = ParseHeaderPrefix(first[:len(first)-1])
+	require.Error(t, err)
+	_, _, _, err = ParseHeaderPrefix(first[:HeaderSize-1])
+	require.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package otlpwire
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// LineageConfig configures StampLineage.
+type LineageConfig struct {
+	// Attributes are string resource attributes to set, for example
+	// collector.pipeline or collector.instance.id.
+	Attributes map[string]string
+	// ProcessedAtKey, if set, is a resource attribute set to the current
+	// time as an RFC 3339 string with nanoseconds.
+	ProcessedAtKey string
+	// HopCountKey, if set, is an int resource attribute incremented on
+	// every stamp, starting from 1 for resources without it.
+	HopCountKey string
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// StampLineage returns a copy of the request with the lineage attributes of
+// cfg set on the Resource of every ResourceSpans, so that multi-hop
+// topologies can tell where a batch has been. Existing attributes with the
+// same keys are replaced; a resource without a Resource message gets one.
+// It also returns the number of resources stamped.
+func (t ExportTracesServiceRequest) StampLineage(cfg LineageConfig) (ExportTracesServiceRequest, int, error) {
+	out, n, err := stampLineage([]byte(t), cfg)
+	return ExportTracesServiceRequest(out), n, err
+}
+
+// StampLineage returns a copy of the request with the lineage attributes of
+// cfg set on every resource, as ExportTracesServiceRequest.StampLineage.
+func (m ExportMetricsServiceRequest) StampLineage(cfg LineageConfig) (ExportMetricsServiceRequest, int, error) {
+	out, n, err := stampLineage([]byte(m), cfg)
+	return ExportMetricsServiceRequest(out), n, err
+}
+
+// StampLineage returns a copy of the request with the lineage attributes of
+// cfg set on every resource, as ExportTracesServiceRequest.StampLineage.
+func (l ExportLogsServiceRequest) StampLineage(cfg LineageConfig) (ExportLogsServiceRequest, int, error) {
+	out, n, err := stampLineage([]byte(l), cfg)
+	return ExportLogsServiceRequest(out), n, err
+}
+
+func stampLineage(data []byte, cfg LineageConfig) ([]byte, int, error) {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	keys := make([]string, 0, len(cfg.Attributes))
+	for k := range cfg.Attributes {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var processedAt string
+	if cfg.ProcessedAtKey != "" {
+		processedAt = cfg.Now().UTC().Format(time.RFC3339Nano)
+	}
+	replaced := slices.Clip(keys)
+	for _, k := range []string{cfg.ProcessedAtKey, cfg.HopCountKey} {
+		if k != "" {
+			replaced = append(replaced, k)
+		}
+	}
+
+	stamped := 0
+	out, _, err := appendRewritten(make([]byte, 0, len(data)), data, []protowire.Number{1}, func(dst, rs []byte) ([]byte, bool, error) {
+		// The Resource field is optional; a missing one reads as empty.
+		resource, err := extractBytesField(rs, 1)
+		if err != nil {
+			return nil, false, err
+		}
+		hops, err := lineageHopCount(resource, cfg.HopCountKey)
+		if err != nil {
+			return nil, false, err
+		}
+
+		dst, err = appendMessageField(dst, 1, func(b []byte) ([]byte, error) {
+			b, err := appendAttributesExcept(b, resource, 1, replaced)
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				b = appendStringKeyValue(b, 1, k, cfg.Attributes[k])
+			}
+			if cfg.ProcessedAtKey != "" {
+				b = appendStringKeyValue(b, 1, cfg.ProcessedAtKey, processedAt)
+			}
+			if cfg.HopCountKey != "" {
+				b = appendIntKeyValue(b, 1, cfg.HopCountKey, hops+1)
+			}
+			return b, nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		dst, err = appendFieldsExcept(dst, rs, 1)
+		stamped++
+		return dst, true, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, stamped, nil
+}
+
+// lineageHopCount returns the int value of the attribute key of a Resource
+// message, or 0 if key is empty or the attribute is absent or not an int.
+func lineageHopCount(resource []byte, key string) (int64, error) {
+	if key == "" {
+		return 0, nil
+	}
+	var hops int64
+	err := forEachMessage(resource, 1, func(kv []byte) error {
+		k, err := extractBytesField(kv, 1)
+		if err != nil || string(k) != key {
+			return err
+		}
+		value, err := extractBytesField(kv, 2)
+		if err != nil {
+			return err
+		}
+		v, err := parseAnyValue(value)
+		if err == nil && v.typ == ValueTypeInt {
+			hops = int64(v.num)
+		}
+		return err
+	})
+	return hops, err
+}
+
+// appendAttributesExcept appends every field of msg to dst except the
+// KeyValue entries of the repeated field num whose key is listed in skip.
+func appendAttributesExcept(dst, msg []byte, num protowire.Number, skip []string) ([]byte, error) {
+	pos := 0
+
+	for pos < len(msg) {
+		fieldStart := pos
+		fieldNum, wireType, tagLen := consumeTag(msg[pos:])
+		if tagLen < 0 {
+			return nil, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		n := skipField(msg[pos:], wireType)
+		if n < 0 {
+			return nil, errors.New("failed to skip field")
+		}
+		pos += n
+
+		if fieldNum == num {
+			if wireType != protowire.BytesType {
+				return nil, errors.New("wrong wire type for field")
+			}
+			kv, _ := consumeBytes(msg[fieldStart+tagLen : pos])
+			key, err := extractBytesField(kv, 1)
+			if err != nil {
+				return nil, err
+			}
+			if slices.Contains(skip, string(key)) {
+				continue
+			}
+		}
+		dst = append(dst, msg[fieldStart:pos]...)
+	}
+
+	return dst, nil
+}
+
+// appendIntKeyValue appends a KeyValue field num with an int value.
+func appendIntKeyValue(dst []byte, num protowire.Number, key string, value int64) []byte {
+	return appendMessage(dst, num, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+		return appendMessage(b, 2, func(b []byte) []byte {
+			b = protowire.AppendTag(b, 3, protowire.VarintType)
+			return protowire.AppendVarint(b, uint64(value))
+		})
+	})
+}
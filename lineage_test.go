@@ -0,0 +1,106 @@
+package otlpwire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestStampLineage(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("schema")
+	rs.Resource().Attributes().PutStr("service.name", "api")
+	rs.Resource().Attributes().PutStr("collector.pipeline", "old")
+	rs.Resource().Attributes().PutInt("otlp.hops", 2)
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("a")
+	// A ResourceSpans without a Resource message.
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("b")
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 5, time.FixedZone("CEST", 2*3600))
+	cfg := LineageConfig{
+		Attributes:     map[string]string{"collector.pipeline": "traces/edge", "collector.region": "eu"},
+		ProcessedAtKey: "processed.at",
+		HopCountKey:    "otlp.hops",
+		Now:            func() time.Time { return now },
+	}
+	out, stamped, err := ExportTracesServiceRequest(marshalTraces(t, traces)).StampLineage(cfg)
+	require.NoError(t, err)
+	require.Equal(t, 2, stamped)
+
+	got, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	require.Equal(t, 2, got.SpanCount())
+	first := got.ResourceSpans().At(0)
+	require.Equal(t, "schema", first.SchemaUrl())
+	require.Equal(t, map[string]any{
+		"service.name":       "api",
+		"collector.pipeline": "traces/edge",
+		"collector.region":   "eu",
+		"processed.at":       "2024-05-01T10:00:00.000000005Z",
+		"otlp.hops":          int64(3),
+	}, first.Resource().Attributes().AsRaw())
+	require.Equal(t, int64(1), got.ResourceSpans().At(1).Resource().Attributes().AsRaw()["otlp.hops"])
+
+	// Stamping again increments the hop count without duplicating keys.
+	out, _, err = out.StampLineage(cfg)
+	require.NoError(t, err)
+	got, err = (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	attrs := got.ResourceSpans().At(0).Resource().Attributes()
+	require.Equal(t, 5, attrs.Len())
+	require.Equal(t, int64(4), attrs.AsRaw()["otlp.hops"])
+}
+
+func TestStampLineage_AttributesOnly(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("", "empty key")
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+
+	out, stamped, err := ExportLogsServiceRequest(marshalLogs(t, logs)).StampLineage(LineageConfig{
+		Attributes: map[string]string{"collector.pipeline": "logs"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, stamped)
+	got, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(out)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"": "empty key", "collector.pipeline": "logs"},
+		got.ResourceLogs().At(0).Resource().Attributes().AsRaw())
+}
+
+func TestStampLineage_ResourceFieldAbsent(t *testing.T) {
+	// A ResourceSpans that omits the optional Resource field, as protobuf
+	// encoders do for a nil Resource.
+	span := protowire.AppendTag(nil, 5, protowire.BytesType)
+	span = protowire.AppendString(span, "b")
+	scopeSpans := protowire.AppendTag(nil, 2, protowire.BytesType)
+	scopeSpans = protowire.AppendBytes(scopeSpans, span)
+	rs := protowire.AppendTag(nil, 2, protowire.BytesType)
+	rs = protowire.AppendBytes(rs, scopeSpans)
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendBytes(req, rs)
+
+	out, stamped, err := ExportTracesServiceRequest(req).StampLineage(LineageConfig{
+		Attributes:  map[string]string{"collector.pipeline": "traces"},
+		HopCountKey: "otlp.hops",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, stamped)
+	got, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	require.Equal(t, 1, got.SpanCount())
+	require.Equal(t, map[string]any{"collector.pipeline": "traces", "otlp.hops": int64(1)},
+		got.ResourceSpans().At(0).Resource().Attributes().AsRaw())
+}
+
+func TestStampLineage_Malformed(t *testing.T) {
+	_, _, err := ExportMetricsServiceRequest([]byte{0x0a, 0x02, 0x0a, 0x05}).StampLineage(LineageConfig{})
+	require.Error(t, err)
+	_, _, err = ExportMetricsServiceRequest([]byte{0x0a, 0x04, 0x0a, 0x02, 0x08, 0x01}).StampLineage(LineageConfig{})
+	require.ErrorContains(t, err, "wrong wire type")
+}
@@ -0,0 +1,275 @@
+package otlpwire
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// forEachLogRecord calls fn for every log record of a logs export request
+// together with its Resource and InstrumentationScope messages, which are
+// nil when absent.
+func forEachLogRecord(data []byte, fn func(resource, scope, record []byte) error) error {
+	return forEachMessage(data, 1, func(rl []byte) error {
+		resource, err := extractBytesField(rl, 1)
+		if err != nil {
+			return err
+		}
+		return forEachMessage(rl, 2, func(sl []byte) error {
+			scope, err := extractBytesField(sl, 1)
+			if err != nil {
+				return err
+			}
+			return forEachMessage(sl, 2, func(record []byte) error {
+				return fn(resource, scope, record)
+			})
+		})
+	})
+}
+
+// logRecordTime returns the time of a log record, falling back to its
+// observed time when time_unix_nano is unset. Zero means neither is set.
+func logRecordTime(record []byte) (uint64, error) {
+	ts, err := extractFixed64Field(record, 1)
+	if err != nil || ts != 0 {
+		return ts, err
+	}
+	return extractFixed64Field(record, 11)
+}
+
+// appendHexField appends the lower-case hex encoding of the bytes field num
+// of msg and reports whether the field was present and not all zeros, as
+// for trace and span IDs.
+func appendHexField(dst, msg []byte, num protowire.Number) ([]byte, bool, error) {
+	id, err := extractBytesField(msg, num)
+	if err != nil {
+		return dst, false, err
+	}
+	for _, b := range id {
+		if b != 0 {
+			return hex.AppendEncode(dst, id), true, nil
+		}
+	}
+	return dst, false, nil
+}
+
+// appendUnixSeconds appends a Unix nanosecond timestamp as decimal seconds
+// with microsecond precision.
+func appendUnixSeconds(dst []byte, ns uint64) []byte {
+	dst = strconv.AppendUint(dst, ns/1e9, 10)
+	frac := ns % 1e9 / 1e3
+	dst = append(dst, '.')
+	for div := uint64(1e5); div > 0; div /= 10 {
+		dst = append(dst, byte('0'+frac/div%10))
+	}
+	return dst
+}
+
+// anyValue is a decoded AnyValue oneof. bytes holds the payload of the
+// string, bytes, array and kvlist variants; num holds the bool and int
+// values and the bits of a double.
+type anyValue struct {
+	typ   ValueType
+	bytes []byte
+	num   uint64
+}
+
+// parseAnyValue decodes an AnyValue message. As with anyValueType, the last
+// oneof field present wins.
+func parseAnyValue(value []byte) (anyValue, error) {
+	var v anyValue
+	pos := 0
+
+	for pos < len(value) {
+		fieldNum, wireType, tagLen := consumeTag(value[pos:])
+		if tagLen < 0 {
+			return anyValue{}, errors.New("malformed protobuf tag in AnyValue")
+		}
+		pos += tagLen
+
+		typ := ValueType(fieldNum)
+		switch {
+		case fieldNum < 1 || fieldNum > 7:
+			n := skipField(value[pos:], wireType)
+			if n < 0 {
+				return anyValue{}, errors.New("failed to skip field")
+			}
+			pos += n
+		case typ == ValueTypeBool || typ == ValueTypeInt:
+			if wireType != protowire.VarintType {
+				return anyValue{}, errors.New("wrong wire type for AnyValue field")
+			}
+			x, n := consumeVarint(value[pos:])
+			if n < 0 {
+				return anyValue{}, errors.New("invalid varint in AnyValue")
+			}
+			v = anyValue{typ: typ, num: x}
+			pos += n
+		case typ == ValueTypeDouble:
+			if wireType != protowire.Fixed64Type {
+				return anyValue{}, errors.New("wrong wire type for AnyValue field")
+			}
+			x, n := protowire.ConsumeFixed64(value[pos:])
+			if n < 0 {
+				return anyValue{}, errors.New("invalid fixed64 in AnyValue")
+			}
+			v = anyValue{typ: typ, num: x}
+			pos += n
+		default:
+			if wireType != protowire.BytesType {
+				return anyValue{}, errors.New("wrong wire type for AnyValue field")
+			}
+			b, n := consumeBytes(value[pos:])
+			if n < 0 {
+				return anyValue{}, errors.New("invalid bytes in AnyValue")
+			}
+			v = anyValue{typ: typ, bytes: b}
+			pos += n
+		}
+	}
+
+	return v, nil
+}
+
+// appendAnyValueText appends an AnyValue as plain text: strings verbatim,
+// scalars in their Go formatting, bytes as standard base64, and arrays and
+// key-value lists as JSON. An empty value appends nothing.
+func appendAnyValueText(dst, value []byte) ([]byte, error) {
+	v, err := parseAnyValue(value)
+	if err != nil {
+		return dst, err
+	}
+	switch v.typ {
+	case ValueTypeString:
+		return append(dst, v.bytes...), nil
+	case ValueTypeBool:
+		return strconv.AppendBool(dst, v.num != 0), nil
+	case ValueTypeInt:
+		return strconv.AppendInt(dst, int64(v.num), 10), nil
+	case ValueTypeDouble:
+		return strconv.AppendFloat(dst, math.Float64frombits(v.num), 'g', -1, 64), nil
+	case ValueTypeBytes:
+		return base64.StdEncoding.AppendEncode(dst, v.bytes), nil
+	case ValueTypeArray, ValueTypeKvlist:
+		return appendAnyValueJSON(dst, value, 0)
+	default:
+		return dst, nil
+	}
+}
+
+// appendAnyValueJSON appends an AnyValue nested depth levels deep as JSON.
+// Bytes are encoded as a base64 string, non-finite doubles as the strings
+// "NaN", "+Inf" and "-Inf", and an empty value as null. Values nested more
+// than maxNestingDepth levels deep are an error.
+func appendAnyValueJSON(dst, value []byte, depth int) ([]byte, error) {
+	if depth > maxNestingDepth {
+		return dst, errors.New("values nested too deeply")
+	}
+	v, err := parseAnyValue(value)
+	if err != nil {
+		return dst, err
+	}
+	switch v.typ {
+	case ValueTypeString:
+		return appendJSONString(dst, v.bytes), nil
+	case ValueTypeBool:
+		return strconv.AppendBool(dst, v.num != 0), nil
+	case ValueTypeInt:
+		return strconv.AppendInt(dst, int64(v.num), 10), nil
+	case ValueTypeDouble:
+		f := math.Float64frombits(v.num)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.AppendQuote(dst, strconv.FormatFloat(f, 'g', -1, 64)), nil
+		}
+		return strconv.AppendFloat(dst, f, 'g', -1, 64), nil
+	case ValueTypeBytes:
+		dst = append(dst, '"')
+		dst = base64.StdEncoding.AppendEncode(dst, v.bytes)
+		return append(dst, '"'), nil
+	case ValueTypeArray:
+		dst = append(dst, '[')
+		first := true
+		err := forEachMessage(v.bytes, 1, func(elem []byte) error {
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			var err error
+			dst, err = appendAnyValueJSON(dst, elem, depth+1)
+			return err
+		})
+		return append(dst, ']'), err
+	case ValueTypeKvlist:
+		return appendKeyValuesJSON(dst, v.bytes, 1, depth+1)
+	default:
+		return append(dst, "null"...), nil
+	}
+}
+
+// appendKeyValuesJSON appends the repeated KeyValue field num of msg as a
+// JSON object whose values are nested depth levels deep. Keys are written in
+// wire order; OTLP requires them to be unique.
+func appendKeyValuesJSON(dst, msg []byte, num protowire.Number, depth int) ([]byte, error) {
+	dst = append(dst, '{')
+	first := true
+	err := forEachMessage(msg, num, func(kv []byte) error {
+		key, err := extractBytesField(kv, 1)
+		if err != nil {
+			return err
+		}
+		value, err := extractBytesField(kv, 2)
+		if err != nil {
+			return err
+		}
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = appendJSONString(dst, key)
+		dst = append(dst, ':')
+		dst, err = appendAnyValueJSON(dst, value, depth)
+		return err
+	})
+	return append(dst, '}'), err
+}
+
+// appendJSONString appends s as a quoted JSON string. Invalid UTF-8 is
+// replaced with U+FFFD.
+func appendJSONString(dst, s []byte) []byte {
+	const hexDigits = "0123456789abcdef"
+	dst = append(dst, '"')
+	for len(s) > 0 {
+		c := s[0]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < 0x20:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			s = s[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(s)
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, "\ufffd"...)
+		} else {
+			dst = append(dst, s[:size]...)
+		}
+		s = s[size:]
+	}
+	return append(dst, '"')
+}
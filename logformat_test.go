@@ -0,0 +1,100 @@
+package otlpwire
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// marshalAnyValue returns the AnyValue wire bytes of v, taken from the body
+// of a marshaled log record.
+func marshalAnyValue(t *testing.T, set func(pcommon.Value)) []byte {
+	t.Helper()
+	logs := plog.NewLogs()
+	set(logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body())
+	var body []byte
+	err := forEachLogRecord(marshalLogs(t, logs), func(_, _, record []byte) error {
+		var err error
+		body, err = extractBytesField(record, 5)
+		return err
+	})
+	require.NoError(t, err)
+	return body
+}
+
+func TestAppendAnyValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		set        func(pcommon.Value)
+		text, json string
+	}{
+		{"empty", func(pcommon.Value) {}, "", "null"},
+		{"string", func(v pcommon.Value) { v.SetStr("a\"b\n\x01\xff") }, "a\"b\n\x01\xff", `"a\"b\n\u0001` + "\ufffd" + `"`},
+		{"bool", func(v pcommon.Value) { v.SetBool(true) }, "true", "true"},
+		{"int", func(v pcommon.Value) { v.SetInt(-42) }, "-42", "-42"},
+		{"double", func(v pcommon.Value) { v.SetDouble(1.5) }, "1.5", "1.5"},
+		{"nan", func(v pcommon.Value) { v.SetDouble(math.NaN()) }, "NaN", `"NaN"`},
+		{"inf", func(v pcommon.Value) { v.SetDouble(math.Inf(-1)) }, "-Inf", `"-Inf"`},
+		{"bytes", func(v pcommon.Value) { v.SetEmptyBytes().FromRaw([]byte{0xff, 0x00}) }, "/wA=", `"/wA="`},
+		{"array", func(v pcommon.Value) {
+			s := v.SetEmptySlice()
+			s.AppendEmpty().SetStr("x")
+			s.AppendEmpty().SetInt(1)
+			s.AppendEmpty()
+		}, `["x",1,null]`, `["x",1,null]`},
+		{"kvlist", func(v pcommon.Value) {
+			m := v.SetEmptyMap()
+			m.PutStr("k", "v")
+			m.PutEmptySlice("list")
+		}, `{"k":"v","list":[]}`, `{"k":"v","list":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := marshalAnyValue(t, tt.set)
+			text, err := appendAnyValueText(nil, value)
+			require.NoError(t, err)
+			require.Equal(t, tt.text, string(text))
+			json, err := appendAnyValueJSON(nil, value, 0)
+			require.NoError(t, err)
+			require.Equal(t, tt.json, string(json))
+		})
+	}
+}
+
+func TestAppendAnyValueJSON_Nesting(t *testing.T) {
+	json, err := appendAnyValueJSON(nil, nestedArrayValue(maxNestingDepth), 0)
+	require.NoError(t, err)
+	require.Len(t, json, 2*maxNestingDepth+4) // brackets around null
+	_, err = appendAnyValueJSON(nil, nestedArrayValue(maxNestingDepth+1), 0)
+	require.ErrorContains(t, err, "nested too deeply")
+
+	// Key-value lists count alike.
+	value := []byte{}
+	for range maxNestingDepth + 1 {
+		kv := protowire.AppendTag(nil, 1, protowire.BytesType)
+		kv = protowire.AppendString(kv, "k")
+		kv = protowire.AppendTag(kv, 2, protowire.BytesType)
+		kv = protowire.AppendBytes(kv, value)
+		list := protowire.AppendTag(nil, 1, protowire.BytesType)
+		list = protowire.AppendBytes(list, kv)
+		value = protowire.AppendTag(nil, 6, protowire.BytesType)
+		value = protowire.AppendBytes(value, list)
+	}
+	_, err = appendAnyValueJSON(nil, value, 0)
+	require.ErrorContains(t, err, "nested too deeply")
+
+	record := protowire.AppendTag(nil, 5, protowire.BytesType)
+	record = protowire.AppendBytes(record, nestedArrayValue(5000))
+	_, _, err = ExportLogsServiceRequest(wrapRecord(record)).AppendSplunkHEC(nil, SplunkHECConfig{})
+	require.ErrorContains(t, err, "nested too deeply")
+}
+
+func TestParseAnyValue_WrongWireType(t *testing.T) {
+	// int_value (field 3) encoded as a length-delimited field.
+	_, err := parseAnyValue([]byte{0x1a, 0x00})
+	require.Error(t, err)
+}
@@ -0,0 +1,318 @@
+package otlpwire
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// AppendResourceSpans appends each rs to the request as a field 1 entry,
+// verbatim, and returns the extended request. As with the built-in append,
+// the result may share the backing array of t, so accumulating requests
+// this way copies each entry only once and re-encodes nothing.
+func (t ExportTracesServiceRequest) AppendResourceSpans(rs ...ResourceSpans) ExportTracesServiceRequest {
+	return appendResourceEntries(t, rs)
+}
+
+// AppendResourceMetrics appends each rm to the request, as
+// ExportTracesServiceRequest.AppendResourceSpans.
+func (m ExportMetricsServiceRequest) AppendResourceMetrics(rm ...ResourceMetrics) ExportMetricsServiceRequest {
+	return appendResourceEntries(m, rm)
+}
+
+// AppendResourceLogs appends each rl to the request, as
+// ExportTracesServiceRequest.AppendResourceSpans.
+func (l ExportLogsServiceRequest) AppendResourceLogs(rl ...ResourceLogs) ExportLogsServiceRequest {
+	return appendResourceEntries(l, rl)
+}
+
+func appendResourceEntries[R, E ~[]byte](dst R, entries []E) R {
+	for _, e := range entries {
+		dst = protowire.AppendTag(dst, 1, protowire.BytesType)
+		dst = protowire.AppendBytes(dst, e)
+	}
+	return dst
+}
+
+// MergeTracesDedup combines the ResourceSpans of reqs into one request, in
+// order, coalescing entries whose resources are semantically equal into a
+// single ResourceSpans that holds the ScopeSpans of all of them. This turns
+// fragmented agent traffic, where each request carries a few spans of the
+// same services, into dense batches. Resources are equal when they have the
+// same attributes in any order, the same other Resource fields and the same
+// schema URL. The coalesced entry takes the position of the first one; its
+// scopes are not merged. It also returns the number of entries coalesced
+// away.
+func MergeTracesDedup(reqs ...ExportTracesServiceRequest) (ExportTracesServiceRequest, int, error) {
+	out, merged, err := mergeDedup(len(reqs), func(i int) []byte { return reqs[i] })
+	return ExportTracesServiceRequest(out), merged, err
+}
+
+// MergeMetricsDedup combines the ResourceMetrics of reqs into one request,
+// coalescing entries with equal resources, as MergeTracesDedup.
+func MergeMetricsDedup(reqs ...ExportMetricsServiceRequest) (ExportMetricsServiceRequest, int, error) {
+	out, merged, err := mergeDedup(len(reqs), func(i int) []byte { return reqs[i] })
+	return ExportMetricsServiceRequest(out), merged, err
+}
+
+// MergeLogsDedup combines the ResourceLogs of reqs into one request,
+// coalescing entries with equal resources, as MergeTracesDedup.
+func MergeLogsDedup(reqs ...ExportLogsServiceRequest) (ExportLogsServiceRequest, int, error) {
+	out, merged, err := mergeDedup(len(reqs), func(i int) []byte { return reqs[i] })
+	return ExportLogsServiceRequest(out), merged, err
+}
+
+func mergeDedup(n int, req func(int) []byte) ([]byte, int, error) {
+	groups := make(map[string]int)
+	var order [][][]byte // Resource* messages by group
+	size := 0
+	for i := range n {
+		data := req(i)
+		size += len(data)
+		err := forEachMessage(data, 1, func(entry []byte) error {
+			key, err := resourceIdentityKey(entry)
+			if err != nil {
+				return err
+			}
+			g, ok := groups[key]
+			if !ok {
+				g = len(order)
+				groups[key] = g
+				order = append(order, nil)
+			}
+			order[g] = append(order[g], entry)
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	merged := 0
+	out := make([]byte, 0, size)
+	for _, members := range order {
+		if len(members) == 1 {
+			out = protowire.AppendTag(out, 1, protowire.BytesType)
+			out = protowire.AppendBytes(out, members[0])
+			continue
+		}
+		merged += len(members) - 1
+		var err error
+		out, err = appendMessageField(out, 1, func(b []byte) ([]byte, error) {
+			b, err := appendFieldsExcept(b, members[0], 2)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range members {
+				err := forEachMessage(m, 2, func(scope []byte) error {
+					b = protowire.AppendTag(b, 2, protowire.BytesType)
+					b = protowire.AppendBytes(b, scope)
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+			return b, nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return out, merged, nil
+}
+
+// MergeScopes returns a copy of the request in which, within every
+// ResourceSpans, ScopeSpans entries with byte-identical
+// InstrumentationScope messages and the same schema URL are combined into
+// one that holds the spans of all of them, in order, at the position of the
+// first. It also returns the number of entries combined away. Resources
+// without duplicate scopes are copied verbatim. Use it after
+// MergeTracesDedup, which leaves the scopes of coalesced entries as they
+// are.
+func (t ExportTracesServiceRequest) MergeScopes() (ExportTracesServiceRequest, int, error) {
+	out, merged, err := mergeScopes(t)
+	return ExportTracesServiceRequest(out), merged, err
+}
+
+// MergeScopes returns a copy of the request with duplicate ScopeMetrics
+// combined, as ExportTracesServiceRequest.MergeScopes. Metrics of the same
+// name in combined scopes are not merged.
+func (m ExportMetricsServiceRequest) MergeScopes() (ExportMetricsServiceRequest, int, error) {
+	out, merged, err := mergeScopes(m)
+	return ExportMetricsServiceRequest(out), merged, err
+}
+
+// MergeScopes returns a copy of the request with duplicate ScopeLogs
+// combined, as ExportTracesServiceRequest.MergeScopes.
+func (l ExportLogsServiceRequest) MergeScopes() (ExportLogsServiceRequest, int, error) {
+	out, merged, err := mergeScopes(l)
+	return ExportLogsServiceRequest(out), merged, err
+}
+
+func mergeScopes(data []byte) ([]byte, int, error) {
+	out, err := appendFieldsExcept(make([]byte, 0, len(data)), data, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	merged := 0
+	err = forEachMessage(data, 1, func(entry []byte) error {
+		groups := make(map[string]int)
+		var order [][][]byte // Scope* messages by group
+		dup := false
+		err := forEachMessage(entry, 2, func(scope []byte) error {
+			key, err := scopeIdentityKey(scope)
+			if err != nil {
+				return err
+			}
+			g, ok := groups[key]
+			if !ok {
+				g = len(order)
+				groups[key] = g
+				order = append(order, nil)
+			}
+			dup = dup || ok
+			order[g] = append(order[g], scope)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !dup {
+			out = protowire.AppendTag(out, 1, protowire.BytesType)
+			out = protowire.AppendBytes(out, entry)
+			return nil
+		}
+
+		out, err = appendMessageField(out, 1, func(b []byte) ([]byte, error) {
+			b, err := appendFieldsExcept(b, entry, 2)
+			if err != nil {
+				return nil, err
+			}
+			for _, members := range order {
+				if len(members) == 1 {
+					b = protowire.AppendTag(b, 2, protowire.BytesType)
+					b = protowire.AppendBytes(b, members[0])
+					continue
+				}
+				merged += len(members) - 1
+				b, err = appendMessageField(b, 2, func(b []byte) ([]byte, error) {
+					b, err := appendFieldsExcept(b, members[0], 2)
+					if err != nil {
+						return nil, err
+					}
+					for _, m := range members {
+						err := forEachMessage(m, 2, func(record []byte) error {
+							b = protowire.AppendTag(b, 2, protowire.BytesType)
+							b = protowire.AppendBytes(b, record)
+							return nil
+						})
+						if err != nil {
+							return nil, err
+						}
+					}
+					return b, nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+			return b, nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, merged, nil
+}
+
+// scopeIdentityKey returns a key that is equal for two Scope* messages
+// exactly when their InstrumentationScope messages are byte-identical and
+// their schema URLs are equal.
+func scopeIdentityKey(entry []byte) (string, error) {
+	scope, err := extractBytesField(entry, 1)
+	if err != nil {
+		return "", err
+	}
+	schemaURL, err := extractBytesField(entry, 3)
+	if err != nil {
+		return "", err
+	}
+	key := protowire.AppendBytes(nil, schemaURL)
+	return string(append(key, scope...)), nil
+}
+
+// DropDuplicateLogRecords returns a copy of the request without the log
+// records that repeat an earlier record of the request, such as those
+// re-sent by an agent retrying an export, and the number dropped. Records
+// are duplicates when they have the same body, the same attributes in any
+// order and the same time (time_unix_nano, or observed_time_unix_nano when
+// unset), and belong to semantically equal resources. Scopes and resources
+// left without records are dropped. To deduplicate across requests, apply it
+// to the output of MergeLogsDedup.
+func (l ExportLogsServiceRequest) DropDuplicateLogRecords() (ExportLogsServiceRequest, int, error) {
+	seen := make(map[string]struct{})
+	total, kept := 0, 0
+	var key []byte
+	out, _, err := appendRewritten(make([]byte, 0, len(l)), l, resourcePath, func(dst, entry []byte) ([]byte, bool, error) {
+		resourceKey, err := resourceIdentityKey(entry)
+		if err != nil {
+			return dst, false, err
+		}
+		dst, n, err := appendRewritten(dst, entry, logRecordPath[1:], func(dst, record []byte) ([]byte, bool, error) {
+			total++
+			key, err = appendLogRecordKey(protowire.AppendString(key[:0], resourceKey), record)
+			if err != nil {
+				return dst, false, err
+			}
+			if _, dup := seen[string(key)]; dup {
+				return dst, false, nil
+			}
+			seen[string(key)] = struct{}{}
+			return append(dst, record...), true, nil
+		})
+		kept += n
+		return dst, n > 0, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportLogsServiceRequest(out), total - kept, nil
+}
+
+// appendLogRecordKey appends the identity of a log record for
+// DropDuplicateLogRecords: its time, body and sorted attributes.
+func appendLogRecordKey(dst, record []byte) ([]byte, error) {
+	ts, err := logRecordTime(record)
+	if err != nil {
+		return nil, err
+	}
+	body, err := extractBytesField(record, 5)
+	if err != nil {
+		return nil, err
+	}
+	dst = protowire.AppendFixed64(dst, ts)
+	dst = protowire.AppendBytes(dst, body)
+	return appendAttributesKey(dst, record, 6)
+}
+
+// resourceIdentityKey returns a key that is equal for two Resource*
+// messages exactly when their resources are semantically equal: the same
+// attribute KeyValues in any order, the same other Resource fields and the
+// same schema URL. A missing Resource keys as the empty resource.
+func resourceIdentityKey(entry []byte) (string, error) {
+	resource, err := extractBytesField(entry, 1)
+	if err != nil {
+		return "", err
+	}
+	schemaURL, err := extractBytesField(entry, 3)
+	if err != nil {
+		return "", err
+	}
+
+	key := protowire.AppendBytes(nil, schemaURL)
+	key, err = appendResourceKey(key, resource)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
@@ -0,0 +1,289 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMergeTracesDedup(t *testing.T) {
+	request := func(service, host, span string) ExportTracesServiceRequest {
+		traces := ptrace.NewTraces()
+		rs := traces.ResourceSpans().AppendEmpty()
+		// Attribute order differs between requests.
+		if span == "b" {
+			rs.Resource().Attributes().PutStr("host.name", host)
+			rs.Resource().Attributes().PutStr("service.name", service)
+		} else {
+			rs.Resource().Attributes().PutStr("service.name", service)
+			rs.Resource().Attributes().PutStr("host.name", host)
+		}
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(span)
+		ss.Spans().AppendEmpty().SetName(span)
+		return ExportTracesServiceRequest(marshalTraces(t, traces))
+	}
+
+	out, merged, err := MergeTracesDedup(
+		request("api", "h1", "a"),
+		request("db", "h1", "x"),
+		request("api", "h1", "b"),
+		nil,
+		request("api", "h2", "c"),
+		request("api", "h1", "d"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, merged)
+
+	got, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	require.Equal(t, 3, got.ResourceSpans().Len())
+	var layout [][]string
+	for i := range got.ResourceSpans().Len() {
+		var names []string
+		sss := got.ResourceSpans().At(i).ScopeSpans()
+		for j := range sss.Len() {
+			names = append(names, sss.At(j).Spans().At(0).Name())
+		}
+		layout = append(layout, names)
+	}
+	require.Equal(t, [][]string{{"a", "b", "d"}, {"x"}, {"c"}}, layout)
+
+	empty, merged, err := MergeTracesDedup()
+	require.NoError(t, err)
+	require.Zero(t, merged)
+	require.Empty(t, empty)
+}
+
+func TestMergeDedup_SchemaURL(t *testing.T) {
+	request := func(schema string) ExportLogsServiceRequest {
+		logs := plog.NewLogs()
+		rl := logs.ResourceLogs().AppendEmpty()
+		rl.SetSchemaUrl(schema)
+		rl.Resource().Attributes().PutStr("service.name", "api")
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		return ExportLogsServiceRequest(marshalLogs(t, logs))
+	}
+	out, merged, err := MergeLogsDedup(request("v1"), request("v2"), request("v1"))
+	require.NoError(t, err)
+	require.Equal(t, 1, merged)
+	count, err := out.LogRecordCount()
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	metrics := ExportMetricsServiceRequest(marshalMetrics(t, createBenchMetrics()))
+	outMetrics, merged, err := MergeMetricsDedup(metrics, metrics)
+	require.NoError(t, err)
+	before, err := metrics.DataPointCount()
+	require.NoError(t, err)
+	after, err := outMetrics.DataPointCount()
+	require.NoError(t, err)
+	require.Equal(t, 2*before, after)
+	resources, err := countOccurrences(metrics, 1)
+	require.NoError(t, err)
+	require.Equal(t, resources, merged)
+}
+
+func TestMergeDedup_ResourceFieldAbsent(t *testing.T) {
+	// ResourceLogs entries with and without an empty Resource message
+	// describe the same resource.
+	record := protowire.AppendTag(nil, 3, protowire.BytesType)
+	record = protowire.AppendString(record, "INFO")
+	scopeLogs := protowire.AppendTag(nil, 2, protowire.BytesType)
+	scopeLogs = protowire.AppendBytes(scopeLogs, record)
+	bare := protowire.AppendTag(nil, 2, protowire.BytesType)
+	bare = protowire.AppendBytes(bare, scopeLogs)
+	withResource := append([]byte{0x0a, 0x00}, bare...)
+	request := func(rl []byte) ExportLogsServiceRequest {
+		return protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), rl)
+	}
+
+	out, merged, err := MergeLogsDedup(request(bare), request(withResource), request(bare))
+	require.NoError(t, err)
+	require.Equal(t, 2, merged)
+	got, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(out)
+	require.NoError(t, err)
+	require.Equal(t, 1, got.ResourceLogs().Len())
+	require.Equal(t, 3, got.LogRecordCount())
+
+	out, dropped, err := request(bare).DropDuplicateLogRecords()
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+	require.Equal(t, request(bare), out)
+
+	batches, err := RebatchLogs([][]byte{request(bare), request(bare)}, 1<<20)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+}
+
+func TestMergeDedup_Malformed(t *testing.T) {
+	_, _, err := MergeLogsDedup(ExportLogsServiceRequest{0x0a, 0x05})
+	require.Error(t, err)
+	_, _, err = MergeLogsDedup(ExportLogsServiceRequest{0x0a, 0x02, 0x0a, 0x05})
+	require.Error(t, err)
+}
+
+func TestAppendResourceSpans(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for _, service := range []string{"api", "db"} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName(service + "-op")
+	}
+	data := ExportTracesServiceRequest(marshalTraces(t, traces))
+
+	var entries []ResourceSpans
+	seq, errFn := data.ResourceSpans()
+	for rs := range seq {
+		entries = append(entries, rs)
+	}
+	require.NoError(t, errFn())
+
+	var out ExportTracesServiceRequest
+	for _, rs := range entries {
+		out = out.AppendResourceSpans(rs)
+	}
+	require.Equal(t, data, out)
+	out = out.AppendResourceSpans(entries...)
+	n, err := out.SpanCount()
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	metrics := ExportMetricsServiceRequest(buildScopedMetrics(t, 2, 1, 1))
+	var rms []ResourceMetrics
+	mseq, errFn := metrics.ResourceMetrics()
+	for rm := range mseq {
+		rms = append(rms, rm)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, metrics, ExportMetricsServiceRequest(nil).AppendResourceMetrics(rms...))
+
+	logs := ExportLogsServiceRequest(nil).AppendResourceLogs(ResourceLogs{})
+	ld, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(logs)
+	require.NoError(t, err)
+	require.Equal(t, 1, ld.ResourceLogs().Len())
+}
+
+func TestMergeScopes(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "api")
+	for _, s := range []struct{ scope, schema, span string }{
+		{"http", "", "a"}, {"db", "", "b"}, {"http", "", "c"}, {"http", "v2", "d"}, {"db", "", "e"},
+	} {
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(s.scope)
+		ss.SetSchemaUrl(s.schema)
+		ss.Spans().AppendEmpty().SetName(s.span)
+	}
+	// A resource without duplicates is copied as is.
+	other := traces.ResourceSpans().AppendEmpty()
+	other.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("f")
+
+	out, merged, err := ExportTracesServiceRequest(marshalTraces(t, traces)).MergeScopes()
+	require.NoError(t, err)
+	require.Equal(t, 2, merged)
+
+	got, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(out)
+	require.NoError(t, err)
+	require.Equal(t, 2, got.ResourceSpans().Len())
+	type scope struct {
+		name, schema string
+		spans        []string
+	}
+	var scopes []scope
+	for _, ss := range got.ResourceSpans().At(0).ScopeSpans().All() {
+		s := scope{name: ss.Scope().Name(), schema: ss.SchemaUrl()}
+		for _, span := range ss.Spans().All() {
+			s.spans = append(s.spans, span.Name())
+		}
+		scopes = append(scopes, s)
+	}
+	require.Equal(t, []scope{
+		{"http", "", []string{"a", "c"}},
+		{"db", "", []string{"b", "e"}},
+		{"http", "v2", []string{"d"}},
+	}, scopes)
+	require.Equal(t, "api", got.ResourceSpans().At(0).Resource().Attributes().AsRaw()["service.name"])
+	require.Equal(t, 6, got.SpanCount())
+
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	for range 3 {
+		sl := rl.ScopeLogs().AppendEmpty()
+		sl.Scope().SetName("app")
+		sl.LogRecords().AppendEmpty()
+	}
+	lout, merged, err := ExportLogsServiceRequest(marshalLogs(t, logs)).MergeScopes()
+	require.NoError(t, err)
+	require.Equal(t, 2, merged)
+	ld, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(lout)
+	require.NoError(t, err)
+	require.Equal(t, 1, ld.ResourceLogs().At(0).ScopeLogs().Len())
+	require.Equal(t, 3, ld.LogRecordCount())
+
+	metrics := ExportMetricsServiceRequest(buildScopedMetrics(t, 2, 2, 1))
+	mout, merged, err := metrics.MergeScopes()
+	require.NoError(t, err)
+	require.Zero(t, merged)
+	require.Equal(t, metrics, mout)
+}
+
+func TestDropDuplicateLogRecords(t *testing.T) {
+	request := func(service string, records ...string) ExportLogsServiceRequest {
+		logs := plog.NewLogs()
+		rl := logs.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("service.name", service)
+		lrs := rl.ScopeLogs().AppendEmpty().LogRecords()
+		for i, body := range records {
+			r := lrs.AppendEmpty()
+			r.Body().SetStr(body)
+			r.SetTimestamp(pcommon.Timestamp(1000))
+			// Attribute order differs between records.
+			if i%2 == 0 {
+				r.Attributes().PutStr("a", "1")
+				r.Attributes().PutStr("b", "2")
+			} else {
+				r.Attributes().PutStr("b", "2")
+				r.Attributes().PutStr("a", "1")
+			}
+		}
+		return ExportLogsServiceRequest(marshalLogs(t, logs))
+	}
+
+	merged, _, err := MergeLogsDedup(
+		request("api", "x", "y"),
+		request("api", "x", "z"), // a retry repeating x
+		request("db", "x"),       // same record, other service
+		request("db", "only-y"),
+	)
+	require.NoError(t, err)
+	out, dropped, err := merged.DropDuplicateLogRecords()
+	require.NoError(t, err)
+	require.Equal(t, 1, dropped)
+
+	ld, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(out)
+	require.NoError(t, err)
+	var bodies []string
+	for _, rl := range ld.ResourceLogs().All() {
+		service, _ := rl.Resource().Attributes().Get("service.name")
+		for _, sl := range rl.ScopeLogs().All() {
+			for _, r := range sl.LogRecords().All() {
+				bodies = append(bodies, service.Str()+"/"+r.Body().Str())
+			}
+		}
+	}
+	require.Equal(t, []string{"api/x", "api/y", "api/z", "db/x", "db/only-y"}, bodies)
+
+	// A scope left empty is dropped.
+	out, dropped, err = ExportLogsServiceRequest(append(request("api", "x"), request("api", "x")...)).DropDuplicateLogRecords()
+	require.NoError(t, err)
+	require.Equal(t, 1, dropped)
+	ld, err = (&plog.ProtoUnmarshaler{}).UnmarshalLogs(out)
+	require.NoError(t, err)
+	require.Equal(t, 1, ld.ResourceLogs().Len())
+}
@@ -0,0 +1,312 @@
+// Package otlpslog provides a log/slog Handler that encodes records directly
+// as OTLP log wire bytes and batches them into ExportLogsServiceRequests, so
+// a Go service can emit OTLP logs without the OpenTelemetry SDK.
+//
+// Records are mapped as by the OpenTelemetry slog bridge: the message becomes
+// the body, the level the severity (slog.LevelInfo is INFO, severity 9), and
+// attributes become log record attributes. Attributes inside groups, from
+// WithGroup or from group-valued attributes, are flattened into dotted keys
+// such as "http.method".
+package otlpslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// Options configures a Handler.
+type Options struct {
+	// Level is the minimum level of records to export. Defaults to
+	// slog.LevelInfo.
+	Level slog.Leveler
+	// AddSource records the source location of the log call as the
+	// code.filepath, code.lineno and code.function attributes.
+	AddSource bool
+	// Resource holds the resource attributes of every record, typically
+	// service.name.
+	Resource []slog.Attr
+	// ScopeName and ScopeVersion identify the instrumentation scope.
+	// ScopeName defaults to the import path of this package.
+	ScopeName, ScopeVersion string
+	// SpanContext, if set, returns the trace and span of the log call, for
+	// example from the OpenTelemetry trace API, to correlate records with
+	// traces without this package depending on it.
+	SpanContext func(ctx context.Context) (traceID [16]byte, spanID [8]byte, ok bool)
+	// Batch configures the batching of records into requests.
+	Batch otlpwire.BatchConfig
+}
+
+// Handler is a slog.Handler that batches records into OTLP log requests. It
+// is safe for concurrent use. The records of a flushed request share one
+// ResourceLogs and one ScopeLogs. Options.Batch.MaxBytes bounds the batch
+// before they are combined, so requests come out smaller than it.
+type Handler struct {
+	core   *core
+	attrs  []byte // encoded KeyValue fields (6) from WithAttrs
+	prefix string // dotted group prefix from WithGroup
+}
+
+// core is the state shared by a Handler and the handlers derived from it.
+type core struct {
+	level       slog.Leveler
+	addSource   bool
+	spanContext func(context.Context) ([16]byte, [8]byte, bool)
+	// resource and scope are the encoded resource field of every
+	// ResourceLogs and scope field of every ScopeLogs.
+	resource, scope []byte
+	bufs            otlpwire.BufferPool
+
+	mu      sync.Mutex
+	batcher *otlpwire.LogsBatcher
+}
+
+// NewHandler returns a Handler that calls flush with each completed request,
+// as otlpwire.NewLogsBatcher. flush runs while the handler is locked and must
+// not log through it. The request is a fresh copy even when
+// Options.Batch.Pool is set; the handler returns the batch buffer itself.
+func NewHandler(flush func(otlpwire.ExportLogsServiceRequest) error, opts Options) *Handler {
+	c := &core{
+		level:       opts.Level,
+		addSource:   opts.AddSource,
+		spanContext: opts.SpanContext,
+	}
+	c.batcher = otlpwire.NewLogsBatcher(opts.Batch, func(req otlpwire.ExportLogsServiceRequest) error {
+		merged, err := mergeBatch(req)
+		if opts.Batch.Pool != nil {
+			opts.Batch.Pool.Put(req)
+		}
+		if err != nil {
+			return err
+		}
+		return flush(merged)
+	})
+	if c.level == nil {
+		c.level = slog.LevelInfo
+	}
+
+	var attrs []byte
+	for _, a := range opts.Resource {
+		attrs = appendAttr(attrs, 1, "", a)
+	}
+	c.resource = appendMessage(nil, 1, attrs)
+
+	scopeName := opts.ScopeName
+	if scopeName == "" {
+		scopeName = "go.olly.garden/otlp-wire/otlpslog"
+	}
+	var scope []byte
+	scope = appendString(scope, 1, scopeName)
+	if opts.ScopeVersion != "" {
+		scope = appendString(scope, 2, opts.ScopeVersion)
+	}
+	c.scope = appendMessage(nil, 1, scope)
+
+	return &Handler{core: c}
+}
+
+// Enabled reports whether level is at least the configured minimum level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.core.level.Level()
+}
+
+// Handle encodes r and adds it to the pending batch. It returns the error of
+// a flush the record triggered.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	c := h.core
+	rec := c.bufs.Get(256)
+	defer func() { c.bufs.Put(rec) }()
+	rec = h.appendRecord(ctx, rec, r)
+
+	out := c.bufs.Get(len(c.resource) + len(c.scope) + len(rec) + 16)
+	defer func() { c.bufs.Put(out) }()
+	out = append(out, c.resource...)
+	out = protowire.AppendTag(out, 2, protowire.BytesType)
+	out = protowire.AppendVarint(out, uint64(len(c.scope)+protowire.SizeTag(2)+protowire.SizeBytes(len(rec))))
+	out = append(out, c.scope...)
+	out = appendMessage(out, 2, rec)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batcher.Add(otlpwire.ResourceLogs(out))
+}
+
+// WithAttrs returns a handler that adds attrs to every record.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append([]byte(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, 6, h.prefix, a)
+	}
+	return &h2
+}
+
+// WithGroup returns a handler that prefixes the keys of later attributes
+// with name and a dot.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// Flush flushes the pending batch, if any. Call it before the process exits.
+func (h *Handler) Flush() error {
+	h.core.mu.Lock()
+	defer h.core.mu.Unlock()
+	return h.core.batcher.Flush()
+}
+
+// FlushExpired flushes the pending batch if it is older than
+// Options.Batch.MaxAge. Call it periodically so that records are not held
+// back while the service logs little.
+func (h *Handler) FlushExpired() error {
+	h.core.mu.Lock()
+	defer h.core.mu.Unlock()
+	return h.core.batcher.FlushExpired()
+}
+
+// mergeBatch combines the ResourceLogs of a batch, one per record and all
+// with the same resource and scope, into one ResourceLogs with one
+// ScopeLogs.
+func mergeBatch(req otlpwire.ExportLogsServiceRequest) (otlpwire.ExportLogsServiceRequest, error) {
+	merged, _, err := otlpwire.MergeLogsDedup(req)
+	if err != nil {
+		return nil, err
+	}
+	merged, _, err = merged.MergeScopes()
+	return merged, err
+}
+
+// appendRecord appends the fields of the LogRecord for r.
+func (h *Handler) appendRecord(ctx context.Context, dst []byte, r slog.Record) []byte {
+	if !r.Time.IsZero() {
+		dst = appendFixed64(dst, 1, uint64(r.Time.UnixNano()))
+	}
+	dst = protowire.AppendTag(dst, 2, protowire.VarintType)
+	dst = protowire.AppendVarint(dst, uint64(severity(r.Level)))
+	dst = appendString(dst, 3, r.Level.String())
+	dst = protowire.AppendTag(dst, 5, protowire.BytesType)
+	dst = protowire.AppendVarint(dst, uint64(protowire.SizeTag(1)+protowire.SizeBytes(len(r.Message))))
+	dst = appendString(dst, 1, r.Message)
+
+	dst = append(dst, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		dst = appendAttr(dst, 6, h.prefix, a)
+		return true
+	})
+	if h.core.addSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		dst = appendAttr(dst, 6, "", slog.String("code.filepath", frame.File))
+		dst = appendAttr(dst, 6, "", slog.Int("code.lineno", frame.Line))
+		dst = appendAttr(dst, 6, "", slog.String("code.function", frame.Function))
+	}
+
+	if h.core.spanContext != nil {
+		if traceID, spanID, ok := h.core.spanContext(ctx); ok {
+			dst = protowire.AppendTag(dst, 9, protowire.BytesType)
+			dst = protowire.AppendBytes(dst, traceID[:])
+			dst = protowire.AppendTag(dst, 10, protowire.BytesType)
+			dst = protowire.AppendBytes(dst, spanID[:])
+		}
+	}
+	return appendFixed64(dst, 11, uint64(time.Now().UnixNano()))
+}
+
+// severity maps a slog level to an OTLP severity number: slog.LevelDebug,
+// LevelInfo, LevelWarn and LevelError become DEBUG, INFO, WARN and ERROR,
+// with levels in between mapped to the numbers in between.
+func severity(level slog.Level) int {
+	return min(max(int(level)+9, 1), 24)
+}
+
+// appendAttr appends a as KeyValue fields num, with group attributes
+// flattened into dotted keys. Empty attributes and empty groups are
+// omitted, as slog handlers should.
+func appendAttr(dst []byte, num protowire.Number, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return dst
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			dst = appendAttr(dst, num, prefix, ga)
+		}
+		return dst
+	}
+
+	var value []byte
+	switch v := a.Value; v.Kind() {
+	case slog.KindString:
+		value = appendString(nil, 1, v.String())
+	case slog.KindBool:
+		value = protowire.AppendTag(nil, 2, protowire.VarintType)
+		value = protowire.AppendVarint(value, protowire.EncodeBool(v.Bool()))
+	case slog.KindInt64:
+		value = appendInt(nil, v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			value = appendInt(nil, int64(u))
+		} else {
+			value = appendString(nil, 1, strconv.FormatUint(u, 10))
+		}
+	case slog.KindFloat64:
+		value = appendFixed64(nil, 4, math.Float64bits(v.Float64()))
+	case slog.KindDuration:
+		value = appendInt(nil, int64(v.Duration()))
+	case slog.KindTime:
+		value = appendInt(nil, v.Time().UnixNano())
+	default:
+		switch x := v.Any().(type) {
+		case []byte:
+			value = protowire.AppendTag(nil, 7, protowire.BytesType)
+			value = protowire.AppendBytes(value, x)
+		case error:
+			value = appendString(nil, 1, x.Error())
+		default:
+			value = appendString(nil, 1, fmt.Sprint(x))
+		}
+	}
+
+	var kv []byte
+	kv = appendString(kv, 1, prefix+a.Key)
+	kv = appendMessage(kv, 2, value)
+	return appendMessage(dst, num, kv)
+}
+
+func appendInt(dst []byte, v int64) []byte {
+	dst = protowire.AppendTag(dst, 3, protowire.VarintType)
+	return protowire.AppendVarint(dst, uint64(v))
+}
+
+func appendString(dst []byte, num protowire.Number, s string) []byte {
+	dst = protowire.AppendTag(dst, num, protowire.BytesType)
+	return protowire.AppendString(dst, s)
+}
+
+func appendFixed64(dst []byte, num protowire.Number, v uint64) []byte {
+	dst = protowire.AppendTag(dst, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(dst, v)
+}
+
+func appendMessage(dst []byte, num protowire.Number, msg []byte) []byte {
+	dst = protowire.AppendTag(dst, num, protowire.BytesType)
+	return protowire.AppendBytes(dst, msg)
+}
@@ -0,0 +1,154 @@
+package otlpslog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// collect returns a handler whose flushed requests are unmarshaled into the
+// returned slice.
+func collect(t *testing.T, opts Options) (*Handler, *[]plog.Logs) {
+	t.Helper()
+	var got []plog.Logs
+	h := NewHandler(func(req otlpwire.ExportLogsServiceRequest) error {
+		logs, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(req)
+		require.NoError(t, err)
+		got = append(got, logs)
+		return nil
+	}, opts)
+	return h, &got
+}
+
+type tracedKey struct{}
+
+func TestHandler(t *testing.T) {
+	traceID := [16]byte{1, 2, 3}
+	spanID := [8]byte{4, 5}
+	h, got := collect(t, Options{
+		Level:        slog.LevelDebug,
+		Resource:     []slog.Attr{slog.String("service.name", "checkout")},
+		ScopeVersion: "1.0.0",
+		SpanContext: func(ctx context.Context) ([16]byte, [8]byte, bool) {
+			return traceID, spanID, ctx.Value(tracedKey{}) != nil
+		},
+	})
+	logger := slog.New(h).With("tenant", "acme").WithGroup("http")
+
+	ctx := context.WithValue(context.Background(), tracedKey{}, true)
+	logger.DebugContext(ctx, "request",
+		"method", "GET",
+		slog.Int("status", 200),
+		slog.Float64("ratio", 0.5),
+		slog.Bool("cached", true),
+		slog.Duration("took", 3*time.Millisecond),
+		slog.Uint64("big", 1<<63),
+		slog.Any("raw", []byte{0xff}),
+		slog.Any("err", errors.New("boom")),
+		slog.Group("peer", "addr", "10.0.0.1"),
+		slog.Group("empty"),
+	)
+	logger.Warn("slow")
+	require.NoError(t, h.Flush())
+
+	require.Len(t, *got, 1)
+	logs := (*got)[0]
+	require.Equal(t, 2, logs.LogRecordCount())
+
+	require.Equal(t, 1, logs.ResourceLogs().Len())
+	rl := logs.ResourceLogs().At(0)
+	require.Equal(t, 1, rl.ScopeLogs().Len())
+	svc, _ := rl.Resource().Attributes().Get("service.name")
+	require.Equal(t, "checkout", svc.Str())
+	sl := rl.ScopeLogs().At(0)
+	require.Equal(t, "go.olly.garden/otlp-wire/otlpslog", sl.Scope().Name())
+	require.Equal(t, "1.0.0", sl.Scope().Version())
+
+	lr := sl.LogRecords().At(0)
+	require.Equal(t, "request", lr.Body().Str())
+	require.Equal(t, plog.SeverityNumberDebug, lr.SeverityNumber())
+	require.Equal(t, "DEBUG", lr.SeverityText())
+	require.NotZero(t, lr.Timestamp())
+	require.NotZero(t, lr.ObservedTimestamp())
+	require.Equal(t, pcommon.TraceID(traceID), lr.TraceID())
+	require.Equal(t, pcommon.SpanID(spanID), lr.SpanID())
+	require.Equal(t, map[string]any{
+		"tenant":         "acme",
+		"http.method":    "GET",
+		"http.status":    int64(200),
+		"http.ratio":     0.5,
+		"http.cached":    true,
+		"http.took":      int64(3 * time.Millisecond),
+		"http.big":       "9223372036854775808",
+		"http.raw":       []byte{0xff},
+		"http.err":       "boom",
+		"http.peer.addr": "10.0.0.1",
+	}, lr.Attributes().AsRaw())
+
+	lr = sl.LogRecords().At(1)
+	require.Equal(t, "slow", lr.Body().Str())
+	require.Equal(t, plog.SeverityNumberWarn, lr.SeverityNumber())
+	require.True(t, lr.TraceID().IsEmpty())
+}
+
+func TestHandler_LevelAndBatching(t *testing.T) {
+	h, got := collect(t, Options{AddSource: true, Batch: otlpwire.BatchConfig{MaxItems: 2}})
+	logger := slog.New(h)
+
+	require.False(t, h.Enabled(context.Background(), slog.LevelDebug))
+	logger.Debug("dropped")
+	for range 5 {
+		logger.Error("failed")
+	}
+	require.Len(t, *got, 2)
+	require.NoError(t, h.Flush())
+	require.Len(t, *got, 3)
+
+	lr := (*got)[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	require.Equal(t, plog.SeverityNumberError, lr.SeverityNumber())
+	line, ok := lr.Attributes().Get("code.lineno")
+	require.True(t, ok)
+	require.Positive(t, line.Int())
+	file, _ := lr.Attributes().Get("code.filepath")
+	require.Contains(t, file.Str(), "otlpslog_test.go")
+}
+
+func TestSeverity(t *testing.T) {
+	require.Equal(t, 1, severity(slog.Level(-100)))
+	require.Equal(t, 5, severity(slog.LevelDebug))
+	require.Equal(t, 10, severity(slog.LevelInfo+1))
+	require.Equal(t, 17, severity(slog.LevelError))
+	require.Equal(t, 24, severity(slog.Level(100)))
+}
+
+func TestHandler_Concurrent(t *testing.T) {
+	var records atomic.Int64
+	h := NewHandler(func(req otlpwire.ExportLogsServiceRequest) error {
+		n, err := req.LogRecordCount()
+		records.Add(int64(n))
+		return err
+	}, Options{Batch: otlpwire.BatchConfig{MaxItems: 10, Pool: &otlpwire.BufferPool{}}})
+	logger := slog.New(h).With("worker", true)
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Go(func() {
+			for i := range 100 {
+				logger.Info("tick", "w", w, "i", i)
+			}
+		})
+	}
+	wg.Wait()
+	require.NoError(t, h.Flush())
+	require.Equal(t, int64(800), records.Load())
+}
@@ -7,6 +7,8 @@ import (
 	"iter"
 
 	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.olly.garden/otlp-wire/protoscan"
 )
 
 // ExportMetricsServiceRequest represents an OTLP ExportMetricsServiceRequest message.
@@ -27,6 +29,9 @@ type ResourceLogs []byte
 // ResourceSpans represents a single ResourceSpans message.
 type ResourceSpans []byte
 
+// Resource represents a single Resource message (raw wire bytes).
+type Resource []byte
+
 // ScopeSpans represents a single ScopeSpans message (raw wire bytes).
 type ScopeSpans []byte
 
@@ -39,6 +44,12 @@ type ScopeMetrics []byte
 // Metric represents a single Metric message (raw wire bytes).
 type Metric []byte
 
+// ScopeLogs represents a single ScopeLogs message (raw wire bytes).
+type ScopeLogs []byte
+
+// LogRecord represents a single LogRecord message (raw wire bytes).
+type LogRecord []byte
+
 // MetricType identifies which oneof body a DataPoint came from.
 type MetricType int
 
@@ -51,6 +62,37 @@ const (
 	MetricTypeSummary              MetricType = 11
 )
 
+// SpanKind is the OTLP Span.SpanKind enum.
+type SpanKind int32
+
+// Span kinds.
+const (
+	SpanKindUnspecified SpanKind = iota
+	SpanKindInternal
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+// String returns the OTLP name of the kind, such as SPAN_KIND_SERVER, or
+// the decimal value of an unknown kind.
+func (k SpanKind) String() string { return enumName(spanKindNames, uint64(k)) }
+
+// StatusCode is the OTLP Status.StatusCode enum.
+type StatusCode int32
+
+// Span status codes.
+const (
+	StatusCodeUnset StatusCode = iota
+	StatusCodeOk
+	StatusCodeError
+)
+
+// String returns the OTLP name of the code, such as STATUS_CODE_ERROR, or
+// the decimal value of an unknown code.
+func (c StatusCode) String() string { return enumName(statusCodeNames, uint64(c)) }
+
 // DataPoint represents a single datapoint message (raw wire bytes) together
 // with the metric type it came from. The type is needed because the
 // attributes field number differs between datapoint message types.
@@ -185,6 +227,46 @@ func (r ResourceMetrics) WriteTo(w io.Writer) (int64, error) {
 	return writeResourceMessage(w, []byte(r))
 }
 
+// SizeOfAsExportRequest returns the exact encoded size of the ResourceMetrics
+// as an ExportMetricsServiceRequest, the number of bytes WriteTo writes,
+// without building it.
+func (r ResourceMetrics) SizeOfAsExportRequest() int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(len(r))
+}
+
+// AppendAsExportRequest appends the ResourceMetrics as an
+// ExportMetricsServiceRequest to dst and returns the extended buffer, the
+// bytes WriteTo writes. Pass a pooled buffer to avoid allocating.
+func (r ResourceMetrics) AppendAsExportRequest(dst []byte) []byte {
+	return ExportMetricsServiceRequest(dst).AppendResourceMetrics(r)
+}
+
+// SchemaURL returns the schema_url of this ResourceMetrics (field 3), or "" if
+// the field is not present.
+func (r ResourceMetrics) SchemaURL() (string, error) {
+	url, err := extractBytesField([]byte(r), 3)
+	return string(url), err
+}
+
+// Metrics returns an iterator over the Metrics of every ScopeMetrics in this
+// ResourceMetrics, in wire order.
+// The returned function should be called after iteration to check for errors.
+func (r ResourceMetrics) Metrics() (iter.Seq[Metric], func() error) {
+	var iterErr error
+
+	seq := func(yield func(Metric) bool) {
+		iterErr = forEachNested([]byte(r), metricPath[1:], func(b []byte) bool {
+			return yield(Metric(b))
+		})
+	}
+
+	errFunc := func() error {
+		return iterErr
+	}
+
+	return seq, errFunc
+}
+
 // ScopeMetrics returns an iterator over ScopeMetrics in this ResourceMetrics.
 // Field 2 in the ResourceMetrics protobuf message.
 // The returned function should be called after iteration to check for errors.
@@ -208,6 +290,25 @@ func (r ResourceMetrics) ScopeMetrics() (iter.Seq[ScopeMetrics], func() error) {
 	return seq, errFunc
 }
 
+// Scope returns the raw InstrumentationScope message bytes (field 1), or nil
+// if the field is not present.
+func (s ScopeMetrics) Scope() ([]byte, error) {
+	return extractBytesField([]byte(s), 1)
+}
+
+// SchemaURL returns the schema_url of this ScopeMetrics (field 3), or "" if
+// the field is not present.
+func (s ScopeMetrics) SchemaURL() (string, error) {
+	url, err := extractBytesField([]byte(s), 3)
+	return string(url), err
+}
+
+// DataPointCount returns the number of metric data points in this
+// ScopeMetrics.
+func (s ScopeMetrics) DataPointCount() (int, error) {
+	return countInScopeMetrics([]byte(s))
+}
+
 // Metrics returns an iterator over Metrics in this ScopeMetrics.
 // Field 2 in the ScopeMetrics protobuf message.
 // The returned function should be called after iteration to check for errors.
@@ -231,12 +332,36 @@ func (s ScopeMetrics) Metrics() (iter.Seq[Metric], func() error) {
 	return seq, errFunc
 }
 
+// DataPointCount returns the number of data points in this Metric.
+func (m Metric) DataPointCount() (int, error) {
+	return countInMetric([]byte(m))
+}
+
 // Name returns the metric name (field 1) as a view into the underlying
 // buffer. Returns nil if the field is not present.
 func (m Metric) Name() ([]byte, error) {
 	return extractBytesField([]byte(m), 1)
 }
 
+// Description returns the metric description (field 2) as a view into the
+// underlying buffer. Returns nil if the field is not present.
+func (m Metric) Description() ([]byte, error) {
+	return extractBytesField([]byte(m), 2)
+}
+
+// Unit returns the metric unit (field 3) as a view into the underlying
+// buffer. Returns nil if the field is not present.
+func (m Metric) Unit() ([]byte, error) {
+	return extractBytesField([]byte(m), 3)
+}
+
+// Type returns the type of the metric, derived from which oneof body is
+// present. Returns 0 if there is none; if a malformed metric carries more
+// than one, the last wins, as in protobuf.
+func (m Metric) Type() (MetricType, error) {
+	return metricBodyType([]byte(m))
+}
+
 // DataPoints returns an iterator over datapoints in this Metric, descending
 // whichever oneof body is present (gauge 5, sum 7, histogram 9,
 // exponential_histogram 10, summary 11). Each body holds its datapoints in
@@ -281,7 +406,7 @@ func (m Metric) DataPointsSeq(yield func(DataPoint, error) bool) {
 	pos := 0
 
 	for pos < len(data) {
-		fieldNum, wireType, tagLen := protowire.ConsumeTag(data[pos:])
+		fieldNum, wireType, tagLen := consumeTag(data[pos:])
 		if tagLen < 0 {
 			yield(DataPoint{}, errors.New("malformed protobuf tag in metric"))
 			return
@@ -297,7 +422,7 @@ func (m Metric) DataPointsSeq(yield func(DataPoint, error) bool) {
 			return
 		}
 		if isBody {
-			body, n := protowire.ConsumeBytes(data[pos:])
+			body, n := consumeBytes(data[pos:])
 			if n < 0 {
 				yield(DataPoint{}, errors.New("invalid bytes in metric data"))
 				return
@@ -374,6 +499,162 @@ func (r ResourceLogs) WriteTo(w io.Writer) (int64, error) {
 	return writeResourceMessage(w, []byte(r))
 }
 
+// SizeOfAsExportRequest returns the exact encoded size of the ResourceLogs
+// as an ExportLogsServiceRequest, the number of bytes WriteTo writes,
+// without building it.
+func (r ResourceLogs) SizeOfAsExportRequest() int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(len(r))
+}
+
+// AppendAsExportRequest appends the ResourceLogs as an
+// ExportLogsServiceRequest to dst and returns the extended buffer, the
+// bytes WriteTo writes. Pass a pooled buffer to avoid allocating.
+func (r ResourceLogs) AppendAsExportRequest(dst []byte) []byte {
+	return ExportLogsServiceRequest(dst).AppendResourceLogs(r)
+}
+
+// SchemaURL returns the schema_url of this ResourceLogs (field 3), or "" if
+// the field is not present.
+func (r ResourceLogs) SchemaURL() (string, error) {
+	url, err := extractBytesField([]byte(r), 3)
+	return string(url), err
+}
+
+// ScopeLogs returns an iterator over ScopeLogs in this ResourceLogs.
+// Field 2 in the ResourceLogs protobuf message.
+// The returned function should be called after iteration to check for errors.
+func (r ResourceLogs) ScopeLogs() (iter.Seq[ScopeLogs], func() error) {
+	var iterErr error
+
+	seq := func(yield func(ScopeLogs) bool) {
+		forEachRepeatedField([]byte(r), 2, func(rb []byte, err error) bool {
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			return yield(ScopeLogs(rb))
+		})
+	}
+
+	errFunc := func() error {
+		return iterErr
+	}
+
+	return seq, errFunc
+}
+
+// LogRecords returns an iterator over the LogRecords of every ScopeLogs in
+// this ResourceLogs, in wire order.
+// The returned function should be called after iteration to check for errors.
+func (r ResourceLogs) LogRecords() (iter.Seq[LogRecord], func() error) {
+	var iterErr error
+
+	seq := func(yield func(LogRecord) bool) {
+		iterErr = forEachNested([]byte(r), logRecordPath[1:], func(b []byte) bool {
+			return yield(LogRecord(b))
+		})
+	}
+
+	errFunc := func() error {
+		return iterErr
+	}
+
+	return seq, errFunc
+}
+
+// Scope returns the raw InstrumentationScope message bytes (field 1), or nil
+// if the field is not present.
+func (s ScopeLogs) Scope() ([]byte, error) {
+	return extractBytesField([]byte(s), 1)
+}
+
+// SchemaURL returns the schema_url of this ScopeLogs (field 3), or "" if
+// the field is not present.
+func (s ScopeLogs) SchemaURL() (string, error) {
+	url, err := extractBytesField([]byte(s), 3)
+	return string(url), err
+}
+
+// LogRecordCount returns the number of log records in this ScopeLogs.
+func (s ScopeLogs) LogRecordCount() (int, error) {
+	return countInScopeLogs([]byte(s))
+}
+
+// LogRecords returns an iterator over LogRecords in this ScopeLogs.
+// Field 2 in the ScopeLogs protobuf message.
+// The returned function should be called after iteration to check for errors.
+func (s ScopeLogs) LogRecords() (iter.Seq[LogRecord], func() error) {
+	var iterErr error
+
+	seq := func(yield func(LogRecord) bool) {
+		forEachRepeatedField([]byte(s), 2, func(rb []byte, err error) bool {
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			return yield(LogRecord(rb))
+		})
+	}
+
+	errFunc := func() error {
+		return iterErr
+	}
+
+	return seq, errFunc
+}
+
+// Timestamp returns the record's time_unix_nano (field 1, fixed64).
+// Returns 0 if the field is not present.
+func (r LogRecord) Timestamp() (uint64, error) {
+	return extractFixed64Field([]byte(r), 1)
+}
+
+// ObservedTimestamp returns the record's observed_time_unix_nano (field 11,
+// fixed64). Returns 0 if the field is not present.
+func (r LogRecord) ObservedTimestamp() (uint64, error) {
+	return extractFixed64Field([]byte(r), 11)
+}
+
+// SeverityNumber returns the record's severity_number (field 2), from 1
+// (TRACE) to 24 (FATAL4). Returns 0 (unspecified) if the field is not
+// present.
+func (r LogRecord) SeverityNumber() (int32, error) {
+	severity, err := extractVarintField([]byte(r), 2)
+	return int32(severity), err
+}
+
+// SeverityText returns the record's severity_text (field 3) as a view into
+// the underlying buffer. Returns nil if the field is not present.
+func (r LogRecord) SeverityText() ([]byte, error) {
+	return extractBytesField([]byte(r), 3)
+}
+
+// TraceID extracts the trace ID from the LogRecord.
+// Returns the raw 16 bytes from field 9.
+// Returns zero value if the field is not present.
+func (r LogRecord) TraceID() ([16]byte, error) {
+	raw, err := extractFixedBytesField([]byte(r), 9, 16)
+	if err != nil {
+		return [16]byte{}, err
+	}
+	var id [16]byte
+	copy(id[:], raw)
+	return id, nil
+}
+
+// SpanID extracts the span ID from the LogRecord.
+// Returns the raw 8 bytes from field 10.
+// Returns zero value if the field is not present.
+func (r LogRecord) SpanID() ([8]byte, error) {
+	raw, err := extractFixedBytesField([]byte(r), 10, 8)
+	if err != nil {
+		return [8]byte{}, err
+	}
+	var id [8]byte
+	copy(id[:], raw)
+	return id, nil
+}
+
 // SpanCount returns the total number of spans in the batch.
 func (t ExportTracesServiceRequest) SpanCount() (int, error) {
 	return countSpans([]byte(t))
@@ -417,6 +698,46 @@ func (r ResourceSpans) WriteTo(w io.Writer) (int64, error) {
 	return writeResourceMessage(w, []byte(r))
 }
 
+// SizeOfAsExportRequest returns the exact encoded size of the ResourceSpans
+// as an ExportTracesServiceRequest, the number of bytes WriteTo writes,
+// without building it.
+func (r ResourceSpans) SizeOfAsExportRequest() int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(len(r))
+}
+
+// AppendAsExportRequest appends the ResourceSpans as an
+// ExportTracesServiceRequest to dst and returns the extended buffer, the
+// bytes WriteTo writes. Pass a pooled buffer to avoid allocating.
+func (r ResourceSpans) AppendAsExportRequest(dst []byte) []byte {
+	return ExportTracesServiceRequest(dst).AppendResourceSpans(r)
+}
+
+// SchemaURL returns the schema_url of this ResourceSpans (field 3), or "" if
+// the field is not present.
+func (r ResourceSpans) SchemaURL() (string, error) {
+	url, err := extractBytesField([]byte(r), 3)
+	return string(url), err
+}
+
+// Spans returns an iterator over the Spans of every ScopeSpans in this
+// ResourceSpans, in wire order.
+// The returned function should be called after iteration to check for errors.
+func (r ResourceSpans) Spans() (iter.Seq[Span], func() error) {
+	var iterErr error
+
+	seq := func(yield func(Span) bool) {
+		iterErr = forEachNested([]byte(r), spanPath[1:], func(b []byte) bool {
+			return yield(Span(b))
+		})
+	}
+
+	errFunc := func() error {
+		return iterErr
+	}
+
+	return seq, errFunc
+}
+
 // ScopeSpans returns an iterator over ScopeSpans in this ResourceSpans.
 // Field 2 in the ResourceSpans protobuf message.
 // The returned function should be called after iteration to check for errors.
@@ -440,6 +761,19 @@ func (r ResourceSpans) ScopeSpans() (iter.Seq[ScopeSpans], func() error) {
 	return seq, errFunc
 }
 
+// Scope returns the raw InstrumentationScope message bytes (field 1), or nil
+// if the field is not present.
+func (s ScopeSpans) Scope() ([]byte, error) {
+	return extractBytesField([]byte(s), 1)
+}
+
+// SchemaURL returns the schema_url of this ScopeSpans (field 3), or "" if
+// the field is not present.
+func (s ScopeSpans) SchemaURL() (string, error) {
+	url, err := extractBytesField([]byte(s), 3)
+	return string(url), err
+}
+
 // SpanCount returns the number of spans in this ScopeSpans.
 func (s ScopeSpans) SpanCount() (int, error) {
 	return countOccurrences([]byte(s), 2)
@@ -507,6 +841,60 @@ func (s Span) ParentSpanID() ([8]byte, error) {
 	return id, nil
 }
 
+// StartTime returns the span's start_time_unix_nano (field 7, fixed64).
+// Returns 0 if the field is not present.
+func (s Span) StartTime() (uint64, error) {
+	return extractFixed64Field([]byte(s), 7)
+}
+
+// EndTime returns the span's end_time_unix_nano (field 8, fixed64).
+// Returns 0 if the field is not present.
+func (s Span) EndTime() (uint64, error) {
+	return extractFixed64Field([]byte(s), 8)
+}
+
+// Name returns the span name (field 5) as a view into the underlying
+// buffer. Returns nil if the field is not present.
+func (s Span) Name() ([]byte, error) {
+	return extractBytesField([]byte(s), 5)
+}
+
+// Kind returns the span kind (field 6).
+// Returns SpanKindUnspecified if the field is not present.
+func (s Span) Kind() (SpanKind, error) {
+	kind, err := extractVarintField([]byte(s), 6)
+	return SpanKind(kind), err
+}
+
+// StatusCode returns the code of the span status (field 15, code 3).
+// Returns StatusCodeUnset if the status or its code is not present.
+func (s Span) StatusCode() (StatusCode, error) {
+	status, err := extractBytesField([]byte(s), 15)
+	if err != nil || status == nil {
+		return StatusCodeUnset, err
+	}
+	code, err := extractVarintField(status, 3)
+	return StatusCode(code), err
+}
+
+// StatusMessage returns the message of the span status (field 15, message
+// 2) as a view into the underlying buffer. Returns nil if the status or its
+// message is not present.
+func (s Span) StatusMessage() ([]byte, error) {
+	status, err := extractBytesField([]byte(s), 15)
+	if err != nil || status == nil {
+		return nil, err
+	}
+	return extractBytesField(status, 2)
+}
+
+// Flags returns the span's flags (field 16, fixed32). Bits 0-7 hold the W3C
+// trace flags; bit 0 is the sampled flag.
+// Returns 0 if the field is not present.
+func (s Span) Flags() (uint32, error) {
+	return extractFixed32Field([]byte(s), 16)
+}
+
 // countMetricDataPoints counts the number of metric data points in an OTLP
 // ExportMetricsServiceRequest protobuf message without unmarshaling it.
 //
@@ -577,7 +965,7 @@ func countInMetric(data []byte) (int, error) {
 	pos := 0
 
 	for pos < len(data) {
-		fieldNum, wireType, tagLen := protowire.ConsumeTag(data[pos:])
+		fieldNum, wireType, tagLen := consumeTag(data[pos:])
 		if tagLen < 0 {
 			return 0, errors.New("malformed protobuf tag in metric")
 		}
@@ -585,7 +973,7 @@ func countInMetric(data []byte) (int, error) {
 
 		// Metric types: field 5=Gauge, 7=Sum, 9=Histogram, 10=ExponentialHistogram, 11=Summary
 		if (fieldNum == 5 || fieldNum == 7 || fieldNum == 9 || fieldNum == 10 || fieldNum == 11) && wireType == protowire.BytesType {
-			msgBytes, n := protowire.ConsumeBytes(data[pos:])
+			msgBytes, n := consumeBytes(data[pos:])
 			if n < 0 {
 				return 0, errors.New("invalid bytes in metric data")
 			}
@@ -612,27 +1000,6 @@ func countDataPoints(data []byte) (int, error) {
 	return countOccurrences(data, 1)
 }
 
-// skipField skips a field based on its wire type.
-// Returns the number of bytes skipped. Returns negative value on error.
-func skipField(data []byte, wireType protowire.Type) int {
-	switch wireType {
-	case protowire.VarintType:
-		_, n := protowire.ConsumeVarint(data)
-		return n
-	case protowire.Fixed64Type:
-		_, n := protowire.ConsumeFixed64(data)
-		return n
-	case protowire.BytesType:
-		_, n := protowire.ConsumeBytes(data)
-		return n
-	case protowire.Fixed32Type:
-		_, n := protowire.ConsumeFixed32(data)
-		return n
-	default:
-		return -1
-	}
-}
-
 // countRepeatedField counts items in a repeated field by delegating to countFunc
 // for each occurrence of the specified field.
 func countRepeatedField(data []byte, fieldNum protowire.Number, countFunc func([]byte) (int, error)) (int, error) {
@@ -640,7 +1007,7 @@ func countRepeatedField(data []byte, fieldNum protowire.Number, countFunc func([
 	pos := 0
 
 	for pos < len(data) {
-		num, wireType, tagLen := protowire.ConsumeTag(data[pos:])
+		num, wireType, tagLen := consumeTag(data[pos:])
 		if tagLen < 0 {
 			return 0, errors.New("malformed protobuf tag")
 		}
@@ -650,7 +1017,7 @@ func countRepeatedField(data []byte, fieldNum protowire.Number, countFunc func([
 			if wireType != protowire.BytesType {
 				return 0, errors.New("wrong wire type for field")
 			}
-			msgBytes, n := protowire.ConsumeBytes(data[pos:])
+			msgBytes, n := consumeBytes(data[pos:])
 			if n < 0 {
 				return 0, errors.New("invalid bytes in repeated field")
 			}
@@ -679,7 +1046,7 @@ func countOccurrences(data []byte, fieldNum protowire.Number) (int, error) {
 	pos := 0
 
 	for pos < len(data) {
-		num, wireType, tagLen := protowire.ConsumeTag(data[pos:])
+		num, wireType, tagLen := consumeTag(data[pos:])
 		if tagLen < 0 {
 			return 0, errors.New("malformed protobuf tag")
 		}
@@ -689,7 +1056,7 @@ func countOccurrences(data []byte, fieldNum protowire.Number) (int, error) {
 			if wireType != protowire.BytesType {
 				return 0, errors.New("wrong wire type for field")
 			}
-			_, n := protowire.ConsumeBytes(data[pos:])
+			_, n := consumeBytes(data[pos:])
 			if n < 0 {
 				return 0, errors.New("invalid bytes in field")
 			}
@@ -713,7 +1080,7 @@ func forEachRepeatedField(data []byte, fieldNum protowire.Number, fn func([]byte
 	pos := 0
 
 	for pos < len(data) {
-		num, wireType, tagLen := protowire.ConsumeTag(data[pos:])
+		num, wireType, tagLen := consumeTag(data[pos:])
 		if tagLen < 0 {
 			fn(nil, errors.New("malformed protobuf tag"))
 			return
@@ -725,7 +1092,7 @@ func forEachRepeatedField(data []byte, fieldNum protowire.Number, fn func([]byte
 				fn(nil, errors.New("wrong wire type for field"))
 				return
 			}
-			msgBytes, n := protowire.ConsumeBytes(data[pos:])
+			msgBytes, n := consumeBytes(data[pos:])
 			if n < 0 {
 				fn(nil, errors.New("invalid bytes in repeated field"))
 				return
@@ -746,6 +1113,30 @@ func forEachRepeatedField(data []byte, fieldNum protowire.Number, fn func([]byte
 	}
 }
 
+// forEachNested walks a chain of repeated message fields, one field number
+// per nesting level, and calls fn for every message at the end of the chain.
+// For example, path {1, 2, 2} visits every Span of an
+// ExportTracesServiceRequest. It returns the first parse error encountered.
+// Return false from fn to stop the walk early.
+func forEachNested(data []byte, path []protowire.Number, fn func([]byte) bool) error {
+	return protoscan.Walk(data, path, fn)
+}
+
+// forEachMessage calls fn for every occurrence of the repeated message field
+// num in data. It stops at the first parse error or the first error returned
+// by fn, and returns it.
+func forEachMessage(data []byte, num protowire.Number, fn func([]byte) error) error {
+	return protoscan.ForEach(data, num, fn)
+}
+
+// Repeated-field paths from an export request down to its leaf records.
+var (
+	metricPath    = []protowire.Number{1, 2, 2} // ResourceMetrics → ScopeMetrics → Metric
+	logRecordPath = []protowire.Number{1, 2, 2} // ResourceLogs → ScopeLogs → LogRecord
+	spanPath      = []protowire.Number{1, 2, 2} // ResourceSpans → ScopeSpans → Span
+	scopePath     = []protowire.Number{1, 2}    // Resource* → Scope*, for all signals
+)
+
 // forEachResourceMetrics iterates over ResourceMetrics messages, calling fn for each.
 // The callback receives resource bytes or an error. Return false to stop iteration.
 func forEachResourceMetrics(data []byte, fn func([]byte, error) bool) {
@@ -770,7 +1161,7 @@ func extractResourceMessage(data []byte) ([]byte, error) {
 	pos := 0
 
 	for pos < len(data) {
-		fieldNum, wireType, tagLen := protowire.ConsumeTag(data[pos:])
+		fieldNum, wireType, tagLen := consumeTag(data[pos:])
 		if tagLen < 0 {
 			return nil, errors.New("malformed protobuf tag")
 		}
@@ -781,7 +1172,7 @@ func extractResourceMessage(data []byte) ([]byte, error) {
 			if wireType != protowire.BytesType {
 				return nil, errors.New("resource field has wrong wire type")
 			}
-			msgBytes, n := protowire.ConsumeBytes(data[pos:])
+			msgBytes, n := consumeBytes(data[pos:])
 			if n < 0 {
 				return nil, errors.New("invalid bytes in resource field")
 			}
@@ -803,67 +1194,56 @@ func extractResourceMessage(data []byte) ([]byte, error) {
 // field from protobuf data. Returns nil (not an error) if absent.
 // The returned slice aliases data; no copy is made.
 func extractBytesField(data []byte, fieldNum protowire.Number) ([]byte, error) {
-	pos := 0
-
-	for pos < len(data) {
-		num, wireType, tagLen := protowire.ConsumeTag(data[pos:])
-		if tagLen < 0 {
-			return nil, errors.New("malformed protobuf tag")
-		}
-		pos += tagLen
-
-		if num == fieldNum {
-			if wireType != protowire.BytesType {
-				return nil, errors.New("wrong wire type for field")
-			}
-			msgBytes, n := protowire.ConsumeBytes(data[pos:])
-			if n < 0 {
-				return nil, errors.New("invalid bytes in field")
-			}
-			return msgBytes, nil
-		}
-
-		n := skipField(data[pos:], wireType)
-		if n < 0 {
-			return nil, errors.New("failed to skip field")
-		}
-		pos += n
-	}
-
-	return nil, nil
+	return protoscan.Bytes(data, fieldNum)
 }
 
 // extractFixed64Field extracts the first occurrence of a fixed64 field from
 // protobuf data. Returns 0 (not an error) if absent.
 func extractFixed64Field(data []byte, fieldNum protowire.Number) (uint64, error) {
-	pos := 0
+	return protoscan.Fixed64(data, fieldNum)
+}
 
-	for pos < len(data) {
-		num, wireType, tagLen := protowire.ConsumeTag(data[pos:])
-		if tagLen < 0 {
-			return 0, errors.New("malformed protobuf tag")
-		}
-		pos += tagLen
+// extractFixed32Field extracts the first occurrence of a fixed32 field from
+// protobuf data. Returns 0 (not an error) if absent.
+func extractFixed32Field(data []byte, fieldNum protowire.Number) (uint32, error) {
+	return protoscan.Fixed32(data, fieldNum)
+}
 
-		if num == fieldNum {
-			if wireType != protowire.Fixed64Type {
-				return 0, errors.New("wrong wire type for field")
-			}
-			v, n := protowire.ConsumeFixed64(data[pos:])
-			if n < 0 {
-				return 0, errors.New("invalid fixed64 in field")
-			}
-			return v, nil
-		}
+// extractVarintField extracts the first occurrence of a varint field from
+// protobuf data. Returns 0 (not an error) if absent.
+func extractVarintField(data []byte, fieldNum protowire.Number) (uint64, error) {
+	return protoscan.Varint(data, fieldNum)
+}
 
-		n := skipField(data[pos:], wireType)
-		if n < 0 {
-			return 0, errors.New("failed to skip field")
+// stringAttribute looks up key in the repeated KeyValue field num of msg and
+// returns its value if it is a string. ok is false if the key is absent or
+// holds another type; the last occurrence of the key wins.
+func stringAttribute(msg []byte, num protowire.Number, key string) (value string, ok bool, err error) {
+	err = forEachMessage(msg, num, func(kv []byte) error {
+		k, err := extractBytesField(kv, 1)
+		if err != nil || string(k) != key {
+			return err
 		}
-		pos += n
+		anyValue, err := extractBytesField(kv, 2)
+		if err != nil {
+			return err
+		}
+		typ, err := anyValueType(anyValue)
+		if err != nil {
+			return err
+		}
+		if typ != ValueTypeString {
+			value, ok = "", false
+			return nil
+		}
+		s, err := extractBytesField(anyValue, 1)
+		value, ok = string(s), true
+		return err
+	})
+	if err != nil {
+		return "", false, err
 	}
-
-	return 0, nil
+	return value, ok, nil
 }
 
 // writeResourceMessage writes resource data as a valid OTLP export request message.
@@ -888,7 +1268,7 @@ func extractFixedBytesField(data []byte, fieldNum protowire.Number, size int) ([
 	pos := 0
 
 	for pos < len(data) {
-		num, wireType, tagLen := protowire.ConsumeTag(data[pos:])
+		num, wireType, tagLen := consumeTag(data[pos:])
 		if tagLen < 0 {
 			return nil, errors.New("malformed protobuf tag")
 		}
@@ -898,7 +1278,7 @@ func extractFixedBytesField(data []byte, fieldNum protowire.Number, size int) ([
 			if wireType != protowire.BytesType {
 				return nil, errors.New("wrong wire type for field")
 			}
-			msgBytes, n := protowire.ConsumeBytes(data[pos:])
+			msgBytes, n := consumeBytes(data[pos:])
 			if n < 0 {
 				return nil, errors.New("invalid bytes in field")
 			}
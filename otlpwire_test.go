@@ -672,6 +672,325 @@ func TestScopeSpans_Spans(t *testing.T) {
 	require.NoError(t, rsErr())
 }
 
+func TestScope(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	for _, name := range []string{"lib-a", "lib-a", ""} {
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(name)
+		ss.Spans().AppendEmpty().SetName("op")
+	}
+	var scopes [][]byte
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range seq {
+		ssSeq, ssErr := rs.ScopeSpans()
+		for ss := range ssSeq {
+			scope, err := ss.Scope()
+			require.NoError(t, err)
+			scopes = append(scopes, scope)
+		}
+		require.NoError(t, ssErr())
+	}
+	require.NoError(t, errFn())
+	require.Len(t, scopes, 3)
+	require.Equal(t, scopes[0], scopes[1], "equal scopes have equal bytes")
+	name, err := extractBytesField(scopes[0], 1)
+	require.NoError(t, err)
+	require.Equal(t, "lib-a", string(name))
+	require.Empty(t, scopes[2])
+
+	metrics := buildScopedMetrics(t, 1, 2, 1)
+	rmSeq, rmErr := ExportMetricsServiceRequest(metrics).ResourceMetrics()
+	for rm := range rmSeq {
+		smSeq, smErr := rm.ScopeMetrics()
+		i := 0
+		for sm := range smSeq {
+			scope, err := sm.Scope()
+			require.NoError(t, err)
+			name, err := extractBytesField(scope, 1)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("scope-%d", i), string(name))
+			i++
+		}
+		require.NoError(t, smErr())
+		require.Equal(t, 2, i)
+	}
+	require.NoError(t, rmErr())
+
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.ScopeLogs().AppendEmpty().Scope().SetVersion("1.0")
+	rl.ScopeLogs().AppendEmpty()
+	rlSeq, rlErr := ExportLogsServiceRequest(marshalLogs(t, logs)).ResourceLogs()
+	for rl := range rlSeq {
+		var got [][]byte
+		slSeq, slErr := rl.ScopeLogs()
+		for sl := range slSeq {
+			scope, err := sl.Scope()
+			require.NoError(t, err)
+			got = append(got, scope)
+		}
+		require.NoError(t, slErr())
+		require.Len(t, got, 2)
+		version, err := extractBytesField(got[0], 2)
+		require.NoError(t, err)
+		require.Equal(t, "1.0", string(version))
+		require.Empty(t, got[1])
+	}
+	require.NoError(t, rlErr())
+
+	_, err = ScopeLogs{0x08, 0x01}.Scope()
+	require.Error(t, err)
+}
+
+func TestSchemaURL(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("https://opentelemetry.io/schemas/1.26.0")
+	rs.ScopeSpans().AppendEmpty().SetSchemaUrl("https://opentelemetry.io/schemas/1.21.0")
+	rs.ScopeSpans().AppendEmpty()
+	rsSeq, rsErr := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range rsSeq {
+		url, err := rs.SchemaURL()
+		require.NoError(t, err)
+		require.Equal(t, "https://opentelemetry.io/schemas/1.26.0", url)
+		var scopeURLs []string
+		ssSeq, ssErr := rs.ScopeSpans()
+		for ss := range ssSeq {
+			url, err := ss.SchemaURL()
+			require.NoError(t, err)
+			scopeURLs = append(scopeURLs, url)
+		}
+		require.NoError(t, ssErr())
+		require.Equal(t, []string{"https://opentelemetry.io/schemas/1.21.0", ""}, scopeURLs)
+	}
+	require.NoError(t, rsErr())
+
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.SetSchemaUrl("m")
+	rm.ScopeMetrics().AppendEmpty().SetSchemaUrl("ms")
+	rmSeq, rmErr := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).ResourceMetrics()
+	for rm := range rmSeq {
+		url, err := rm.SchemaURL()
+		require.NoError(t, err)
+		require.Equal(t, "m", url)
+		smSeq, smErr := rm.ScopeMetrics()
+		for sm := range smSeq {
+			url, err := sm.SchemaURL()
+			require.NoError(t, err)
+			require.Equal(t, "ms", url)
+		}
+		require.NoError(t, smErr())
+	}
+	require.NoError(t, rmErr())
+
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.SetSchemaUrl("l")
+	rl.ScopeLogs().AppendEmpty().SetSchemaUrl("ls")
+	rlSeq, rlErr := ExportLogsServiceRequest(marshalLogs(t, logs)).ResourceLogs()
+	for rl := range rlSeq {
+		url, err := rl.SchemaURL()
+		require.NoError(t, err)
+		require.Equal(t, "l", url)
+		slSeq, slErr := rl.ScopeLogs()
+		for sl := range slSeq {
+			url, err := sl.SchemaURL()
+			require.NoError(t, err)
+			require.Equal(t, "ls", url)
+		}
+		require.NoError(t, slErr())
+	}
+	require.NoError(t, rlErr())
+
+	_, err := ResourceSpans{0x18, 0x01}.SchemaURL()
+	require.Error(t, err)
+}
+
+func TestScopeCounts(t *testing.T) {
+	metrics := buildAllTypesMetrics(t)
+	total, err := ExportMetricsServiceRequest(metrics).DataPointCount()
+	require.NoError(t, err)
+	sum, metricSum := 0, 0
+	rmSeq, rmErr := ExportMetricsServiceRequest(metrics).ResourceMetrics()
+	for rm := range rmSeq {
+		smSeq, smErr := rm.ScopeMetrics()
+		for sm := range smSeq {
+			n, err := sm.DataPointCount()
+			require.NoError(t, err)
+			sum += n
+			mSeq, mErr := sm.Metrics()
+			for m := range mSeq {
+				n, err := m.DataPointCount()
+				require.NoError(t, err)
+				metricSum += n
+			}
+			require.NoError(t, mErr())
+		}
+		require.NoError(t, smErr())
+	}
+	require.NoError(t, rmErr())
+	require.Positive(t, total)
+	require.Equal(t, total, sum)
+	require.Equal(t, total, metricSum)
+
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.LogRecords().AppendEmpty()
+	sl.LogRecords().AppendEmpty()
+	var counts []int
+	rlSeq, rlErr := ExportLogsServiceRequest(marshalLogs(t, logs)).ResourceLogs()
+	for rl := range rlSeq {
+		slSeq, slErr := rl.ScopeLogs()
+		for sl := range slSeq {
+			n, err := sl.LogRecordCount()
+			require.NoError(t, err)
+			counts = append(counts, n)
+		}
+		require.NoError(t, slErr())
+	}
+	require.NoError(t, rlErr())
+	require.Equal(t, []int{1, 2}, counts)
+}
+
+func TestResourceSpans_Spans(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetSpanID(pcommon.SpanID{1})
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Spans().AppendEmpty().SetSpanID(pcommon.SpanID{2})
+	ss.Spans().AppendEmpty().SetSpanID(pcommon.SpanID{3})
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetSpanID(pcommon.SpanID{4})
+
+	var ids []byte
+	rsSeq, rsErr := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range rsSeq {
+		spans, spanErr := rs.Spans()
+		for span := range spans {
+			id, err := span.SpanID()
+			require.NoError(t, err)
+			ids = append(ids, id[0])
+			if id[0] == 2 {
+				break // stops the spans of this resource only
+			}
+		}
+		require.NoError(t, spanErr())
+	}
+	require.NoError(t, rsErr())
+	require.Equal(t, []byte{1, 2, 4}, ids)
+
+	spans, spanErr := ResourceSpans{0x12, 0x02, 0x12, 0x05}.Spans()
+	for range spans {
+		t.Fatal("no span expected")
+	}
+	require.Error(t, spanErr())
+}
+
+func TestLogRecords(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("a")
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.LogRecords().AppendEmpty().Body().SetStr("b")
+	sl.LogRecords().AppendEmpty().Body().SetStr("c")
+
+	body := func(record LogRecord) string {
+		value, err := extractBytesField(record, 5)
+		require.NoError(t, err)
+		text, err := appendAnyValueText(nil, value)
+		require.NoError(t, err)
+		return string(text)
+	}
+
+	var fromScopes, fromResource []string
+	rlSeq, rlErr := ExportLogsServiceRequest(marshalLogs(t, logs)).ResourceLogs()
+	for rl := range rlSeq {
+		slSeq, slErr := rl.ScopeLogs()
+		for sl := range slSeq {
+			records, recErr := sl.LogRecords()
+			for record := range records {
+				fromScopes = append(fromScopes, body(record))
+			}
+			require.NoError(t, recErr())
+		}
+		require.NoError(t, slErr())
+
+		records, recErr := rl.LogRecords()
+		for record := range records {
+			fromResource = append(fromResource, body(record))
+			if len(fromResource) == 2 {
+				break
+			}
+		}
+		require.NoError(t, recErr())
+	}
+	require.NoError(t, rlErr())
+	require.Equal(t, []string{"a", "b", "c"}, fromScopes)
+	require.Equal(t, []string{"a", "b"}, fromResource)
+
+	records, recErr := ScopeLogs{0x12, 0x05}.LogRecords()
+	for range records {
+		t.Fatal("no record expected")
+	}
+	require.Error(t, recErr())
+}
+
+func TestLogRecordFieldAccessors(t *testing.T) {
+	logs := plog.NewLogs()
+	sl := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
+	record := sl.LogRecords().AppendEmpty()
+	record.SetTimestamp(1000)
+	record.SetObservedTimestamp(2000)
+	record.SetSeverityNumber(plog.SeverityNumberWarn)
+	record.SetSeverityText("WARN")
+	record.SetTraceID(pcommon.TraceID{1, 2, 3})
+	record.SetSpanID(pcommon.SpanID{4, 5})
+	sl.LogRecords().AppendEmpty()
+
+	var records []LogRecord
+	seq, errFn := ExportLogsServiceRequest(marshalLogs(t, logs)).AllLogRecords()
+	for r := range seq {
+		records = append(records, r.LogRecord)
+	}
+	require.NoError(t, errFn())
+	require.Len(t, records, 2)
+
+	ts, err := records[0].Timestamp()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), ts)
+	observed, err := records[0].ObservedTimestamp()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2000), observed)
+	severity, err := records[0].SeverityNumber()
+	require.NoError(t, err)
+	require.Equal(t, int32(plog.SeverityNumberWarn), severity)
+	text, err := records[0].SeverityText()
+	require.NoError(t, err)
+	require.Equal(t, "WARN", string(text))
+	traceID, err := records[0].TraceID()
+	require.NoError(t, err)
+	require.Equal(t, [16]byte{1, 2, 3}, traceID)
+	spanID, err := records[0].SpanID()
+	require.NoError(t, err)
+	require.Equal(t, [8]byte{4, 5}, spanID)
+
+	ts, err = records[1].Timestamp()
+	require.NoError(t, err)
+	require.Zero(t, ts)
+	severity, err = records[1].SeverityNumber()
+	require.NoError(t, err)
+	require.Zero(t, severity)
+	traceID, err = records[1].TraceID()
+	require.NoError(t, err)
+	require.Zero(t, traceID)
+
+	_, err = LogRecord{0x4a, 0x01, 0x00}.TraceID()
+	require.Error(t, err, "trace ID of the wrong length")
+}
+
 func TestSpanFieldAccessors(t *testing.T) {
 	traces := ptrace.NewTraces()
 	rs := traces.ResourceSpans().AppendEmpty()
@@ -717,6 +1036,57 @@ func TestSpanFieldAccessors(t *testing.T) {
 	require.NoError(t, rsErr())
 }
 
+func TestSpanNameKindStatus(t *testing.T) {
+	traces := ptrace.NewTraces()
+	ss := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	span := ss.Spans().AppendEmpty()
+	span.SetName("GET /users")
+	span.SetKind(ptrace.SpanKindServer)
+	span.Status().SetCode(ptrace.StatusCodeError)
+	span.Status().SetMessage("timeout")
+	ss.Spans().AppendEmpty()
+
+	var spans []Span
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).AllSpans()
+	for s := range seq {
+		spans = append(spans, s.Span)
+	}
+	require.NoError(t, errFn())
+	require.Len(t, spans, 2)
+
+	name, err := spans[0].Name()
+	require.NoError(t, err)
+	require.Equal(t, "GET /users", string(name))
+	kind, err := spans[0].Kind()
+	require.NoError(t, err)
+	require.Equal(t, SpanKindServer, kind)
+	require.Equal(t, "SPAN_KIND_SERVER", kind.String())
+	code, err := spans[0].StatusCode()
+	require.NoError(t, err)
+	require.Equal(t, StatusCodeError, code)
+	require.Equal(t, "STATUS_CODE_ERROR", code.String())
+	message, err := spans[0].StatusMessage()
+	require.NoError(t, err)
+	require.Equal(t, "timeout", string(message))
+
+	name, err = spans[1].Name()
+	require.NoError(t, err)
+	require.Nil(t, name)
+	kind, err = spans[1].Kind()
+	require.NoError(t, err)
+	require.Equal(t, SpanKindUnspecified, kind)
+	code, err = spans[1].StatusCode()
+	require.NoError(t, err)
+	require.Equal(t, StatusCodeUnset, code)
+	message, err = spans[1].StatusMessage()
+	require.NoError(t, err)
+	require.Empty(t, message)
+	require.Equal(t, "7", SpanKind(7).String())
+
+	_, err = Span{0x7a, 0x02, 0x18}.StatusCode()
+	require.Error(t, err)
+}
+
 func TestSpanFieldAccessors_RootSpan(t *testing.T) {
 	traces := ptrace.NewTraces()
 	rs := traces.ResourceSpans().AppendEmpty()
@@ -1439,6 +1809,26 @@ func TestScopeMetricsIteration(t *testing.T) {
 	require.Equal(t, 24, totalMetrics) // 6 scopes × 4 metrics
 }
 
+func TestResourceMetrics_Metrics(t *testing.T) {
+	req := ExportMetricsServiceRequest(buildScopedMetrics(t, 2, 2, 2))
+	var names []string
+	resources, resErr := req.ResourceMetrics()
+	for rm := range resources {
+		metrics, metricErr := rm.Metrics()
+		for m := range metrics {
+			name, err := m.Name()
+			require.NoError(t, err)
+			names = append(names, string(name))
+		}
+		require.NoError(t, metricErr())
+	}
+	require.NoError(t, resErr())
+	require.Equal(t, []string{
+		"metric.0.0", "metric.0.1", "metric.1.0", "metric.1.1",
+		"metric.0.0", "metric.0.1", "metric.1.0", "metric.1.1",
+	}, names)
+}
+
 func TestScopeMetricsIteration_Malformed(t *testing.T) {
 	// Field 2 (scope_metrics) with wrong wire type: varint instead of bytes.
 	bad := ResourceMetrics{0x10, 0x01}
@@ -1472,6 +1862,43 @@ func TestMetricName(t *testing.T) {
 	require.Equal(t, []string{"metric.0.0", "metric.0.1"}, names)
 }
 
+func TestMetricFieldAccessors(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	m := ms.AppendEmpty()
+	m.SetName("http.server.request.duration")
+	m.SetDescription("Duration of HTTP server requests.")
+	m.SetUnit("s")
+	m.SetEmptyHistogram().DataPoints().AppendEmpty()
+	ms.AppendEmpty().SetEmptySum()
+	ms.AppendEmpty().SetName("no-data")
+
+	type metric struct {
+		description, unit string
+		typ               MetricType
+	}
+	var got []metric
+	seq, errFn := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).AllMetrics()
+	for m := range seq {
+		description, err := m.Metric.Description()
+		require.NoError(t, err)
+		unit, err := m.Metric.Unit()
+		require.NoError(t, err)
+		typ, err := m.Metric.Type()
+		require.NoError(t, err)
+		got = append(got, metric{string(description), string(unit), typ})
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []metric{
+		{"Duration of HTTP server requests.", "s", MetricTypeHistogram},
+		{"", "", MetricTypeSum},
+		{"", "", 0},
+	}, got)
+
+	_, err := Metric{0x2a, 0x05}.Type()
+	require.Error(t, err)
+}
+
 func TestMetricName_Absent(t *testing.T) {
 	// A metric message with only a unit (field 3), no name.
 	var m Metric
@@ -1909,3 +2336,29 @@ func TestDataPointsSeq_ZeroAlloc(t *testing.T) {
 	})
 	require.Zero(t, allocs, "DataPointsSeq/AttributesSeq must not allocate")
 }
+
+// ========== Shared fixture helpers ==========
+
+func marshalTraces(t testing.TB, traces ptrace.Traces) []byte {
+	t.Helper()
+	marshaler := &ptrace.ProtoMarshaler{}
+	data, err := marshaler.MarshalTraces(traces)
+	require.NoError(t, err)
+	return data
+}
+
+func marshalMetrics(t testing.TB, metrics pmetric.Metrics) []byte {
+	t.Helper()
+	marshaler := &pmetric.ProtoMarshaler{}
+	data, err := marshaler.MarshalMetrics(metrics)
+	require.NoError(t, err)
+	return data
+}
+
+func marshalLogs(t testing.TB, logs plog.Logs) []byte {
+	t.Helper()
+	marshaler := &plog.ProtoMarshaler{}
+	data, err := marshaler.MarshalLogs(logs)
+	require.NoError(t, err)
+	return data
+}
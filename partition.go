@@ -0,0 +1,363 @@
+package otlpwire
+
+import (
+	"bytes"
+	"errors"
+	"hash/fnv"
+	"iter"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SplitLevel is the nesting level of a message passed to a Splitter.
+type SplitLevel int
+
+const (
+	// SplitResource is a resource entry: a ResourceSpans, ResourceMetrics
+	// or ResourceLogs.
+	SplitResource SplitLevel = 1
+	// SplitScope is a ScopeSpans, ScopeMetrics or ScopeLogs.
+	SplitScope SplitLevel = 2
+	// SplitRecord is a Span, Metric or LogRecord.
+	SplitRecord SplitLevel = 3
+	// SplitDataPoint is a DataPoint of any metric type.
+	SplitDataPoint SplitLevel = 5
+)
+
+// Splitter decides the partitions of a request for Split. SplitKey is
+// called for a message at every level, outermost first; ok is false if
+// the partition is decided at a lower level, and the message's children
+// are asked in turn. Records or data points left undecided are dropped.
+type Splitter interface {
+	SplitKey(level SplitLevel, msg []byte) (key string, ok bool, err error)
+}
+
+// SplitterFunc adapts a function to a Splitter.
+type SplitterFunc func(level SplitLevel, msg []byte) (key string, ok bool, err error)
+
+// SplitKey returns f(level, msg).
+func (f SplitterFunc) SplitKey(level SplitLevel, msg []byte) (string, bool, error) {
+	return f(level, msg)
+}
+
+// Split returns an iterator over one request per partition key of s, each
+// holding the messages of t assigned to it in their original order. The
+// Resource, scope and other envelope fields of a message are repeated in
+// every request that holds part of it, and a message is copied verbatim
+// once its partition is decided. Requests are yielded in the order their
+// key first appears in t, once the whole of t has been partitioned.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) Split(s Splitter) (iter.Seq2[string, ExportTracesServiceRequest], func() error) {
+	return partitionSeq[ExportTracesServiceRequest](t, &tracesChunking, splitterKey(s))
+}
+
+// Split returns an iterator over one request per partition key of s, as
+// ExportTracesServiceRequest.Split.
+func (m ExportMetricsServiceRequest) Split(s Splitter) (iter.Seq2[string, ExportMetricsServiceRequest], func() error) {
+	return partitionSeq[ExportMetricsServiceRequest](m, &metricsChunking, splitterKey(s))
+}
+
+// Split returns an iterator over one request per partition key of s, as
+// ExportTracesServiceRequest.Split.
+func (l ExportLogsServiceRequest) Split(s Splitter) (iter.Seq2[string, ExportLogsServiceRequest], func() error) {
+	return partitionSeq[ExportLogsServiceRequest](l, &logsChunking, splitterKey(s))
+}
+
+// splitterKey adapts s to partition. The metric body between a Metric and
+// its data points is not a level of its own.
+func splitterKey(s Splitter) partitionKeyFunc[string] {
+	return func(depth int, msg []byte) (string, bool, error) {
+		if depth == 4 {
+			return "", false, nil
+		}
+		return s.SplitKey(SplitLevel(depth), msg)
+	}
+}
+
+// SplitByAttribute returns an iterator over one request per distinct value
+// of the resource attribute key, such as a tenant ID, each holding the
+// resources with that value in their original order. Values are compared
+// in their AnyValue.AppendText form; resources without the attribute are
+// yielded under "". Requests are yielded in the order their value first
+// appears in t, once the whole of t has been partitioned.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) SplitByAttribute(key string) (iter.Seq2[string, ExportTracesServiceRequest], func() error) {
+	return partitionSeq[ExportTracesServiceRequest](t, &tracesChunking, resourceAttributeKey(key))
+}
+
+// SplitByAttribute returns an iterator over one request per distinct value
+// of the resource attribute key, as ExportTracesServiceRequest.SplitByAttribute.
+func (m ExportMetricsServiceRequest) SplitByAttribute(key string) (iter.Seq2[string, ExportMetricsServiceRequest], func() error) {
+	return partitionSeq[ExportMetricsServiceRequest](m, &metricsChunking, resourceAttributeKey(key))
+}
+
+// SplitByAttribute returns an iterator over one request per distinct value
+// of the resource attribute key, as ExportTracesServiceRequest.SplitByAttribute.
+func (l ExportLogsServiceRequest) SplitByAttribute(key string) (iter.Seq2[string, ExportLogsServiceRequest], func() error) {
+	return partitionSeq[ExportLogsServiceRequest](l, &logsChunking, resourceAttributeKey(key))
+}
+
+// SplitByTraceID returns an iterator over one request per trace ID, each
+// holding the spans of that trace in their original order with their
+// resource and scope envelopes, so that every request carries complete
+// traces of t. Requests are yielded in the order their trace first appears
+// in t, once the whole of t has been partitioned.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) SplitByTraceID() (iter.Seq2[[16]byte, ExportTracesServiceRequest], func() error) {
+	return partitionSeq[ExportTracesServiceRequest](t, &tracesChunking, func(depth int, span []byte) ([16]byte, bool, error) {
+		if depth != 3 {
+			return [16]byte{}, false, nil
+		}
+		id, err := Span(span).TraceID()
+		return id, true, err
+	})
+}
+
+// SplitByTraceIDHash returns an iterator over up to buckets requests keyed
+// by bucket number, as SplitByTraceID but with the traces grouped by the
+// 32-bit FNV-1a hash of their trace ID modulo buckets. Every span of a trace
+// lands in the same bucket in every request and process, so that collectors
+// behind a load balancer each receive complete traces. Buckets without spans
+// are not yielded.
+func (t ExportTracesServiceRequest) SplitByTraceIDHash(buckets int) (iter.Seq2[int, ExportTracesServiceRequest], func() error) {
+	if buckets <= 0 {
+		return func(func(int, ExportTracesServiceRequest) bool) {},
+			func() error { return errors.New("buckets must be positive") }
+	}
+	return partitionSeq[ExportTracesServiceRequest](t, &tracesChunking, func(depth int, span []byte) (int, bool, error) {
+		if depth != 3 {
+			return 0, false, nil
+		}
+		id, err := Span(span).TraceID()
+		if err != nil {
+			return 0, false, err
+		}
+		h := fnv.New32a()
+		h.Write(id[:])
+		return int(h.Sum32() % uint32(buckets)), true, nil
+	})
+}
+
+// SplitByMetricPrefix returns an iterator over one request per metric name
+// prefix, such as "jvm." or "http.", each holding the metrics whose name
+// starts with that prefix, with their resource and scope envelopes. A
+// metric matching several prefixes goes to the longest; metrics matching
+// none are yielded under "". Requests are yielded in the order their prefix
+// first appears in m, once the whole of m has been partitioned.
+// The returned function should be called after iteration to check for errors.
+func (m ExportMetricsServiceRequest) SplitByMetricPrefix(prefixes ...string) (iter.Seq2[string, ExportMetricsServiceRequest], func() error) {
+	return partitionSeq[ExportMetricsServiceRequest](m, &metricsChunking, func(depth int, metric []byte) (string, bool, error) {
+		if depth != 3 {
+			return "", false, nil
+		}
+		name, err := Metric(metric).Name()
+		if err != nil {
+			return "", false, err
+		}
+		match := ""
+		for _, prefix := range prefixes {
+			if len(prefix) > len(match) && bytes.HasPrefix(name, []byte(prefix)) {
+				match = prefix
+			}
+		}
+		return match, true, nil
+	})
+}
+
+// SplitBySeverity splits the request into len(thresholds)+1 requests by
+// severity number band, such as DEBUG and INFO against WARN and above:
+// band 0 holds the log records below thresholds[0], band i those from
+// thresholds[i-1] up to thresholds[i], and the last band the rest. Records
+// without a severity number count as 0. Resource and scope envelopes are
+// preserved; a band without records is nil. thresholds must be ascending.
+func (l ExportLogsServiceRequest) SplitBySeverity(thresholds ...int32) ([]ExportLogsServiceRequest, error) {
+	if !slices.IsSorted(thresholds) {
+		return nil, errors.New("severity thresholds must be ascending")
+	}
+	keys, parts, err := partition(l, &logsChunking, func(depth int, record []byte) (int, bool, error) {
+		if depth != 3 {
+			return 0, false, nil
+		}
+		severity, err := LogRecord(record).SeverityNumber()
+		if err != nil {
+			return 0, false, err
+		}
+		band, _ := slices.BinarySearch(thresholds, severity+1)
+		return band, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	bands := make([]ExportLogsServiceRequest, len(thresholds)+1)
+	for i, band := range keys {
+		bands[band] = parts[i]
+	}
+	return bands, nil
+}
+
+// resourceAttributeKey returns the partition key function of SplitByAttribute.
+func resourceAttributeKey(key string) partitionKeyFunc[string] {
+	return func(depth int, entry []byte) (string, bool, error) {
+		if depth != 1 {
+			return "", false, nil
+		}
+		resource, err := extractBytesField(entry, 1)
+		if err != nil {
+			return "", false, err
+		}
+		value, ok, err := Resource(resource).Attribute(key)
+		if err != nil || !ok {
+			return "", true, err
+		}
+		text, err := value.AppendText(nil)
+		return string(text), true, err
+	}
+}
+
+// partitionKeyFunc returns the partition of a message at depth of a
+// chunkSchema: a resource entry, a scope entry and so on down to the items.
+// ok is false if the partition is decided further down; items left
+// undecided are dropped.
+type partitionKeyFunc[K comparable] func(depth int, msg []byte) (key K, ok bool, err error)
+
+// partitionSeq adapts partition to the iterator convention of the package.
+func partitionSeq[R ~[]byte, K comparable](data []byte, schema *chunkSchema, keyOf partitionKeyFunc[K]) (iter.Seq2[K, R], func() error) {
+	var iterErr error
+	seq := func(yield func(K, R) bool) {
+		keys, parts, err := partition(data, schema, keyOf)
+		iterErr = err
+		if err != nil {
+			return
+		}
+		for i, key := range keys {
+			if !yield(key, R(parts[i])) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return iterErr }
+}
+
+// partition splits the export request data by keyOf in a single pass and
+// returns the keys in order of first appearance with the request of each.
+// The envelopes of the messages a partition holds part of are repeated in
+// it; messages are copied verbatim once their partition is decided.
+func partition[K comparable](data []byte, schema *chunkSchema, keyOf partitionKeyFunc[K]) ([]K, [][]byte, error) {
+	child, err := schema.child(0, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	hdr, err := appendFieldsExcept(nil, data, child)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &partitioner[K]{schema: schema, keyOf: keyOf, rootHdr: hdr, index: make(map[K]int)}
+	err = forEachMessage(data, child, func(msg []byte) error {
+		return p.visit(msg, 1, child)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parts := make([][]byte, len(p.parts))
+	for i := range p.parts {
+		b := &p.parts[i]
+		b.closeTo(0)
+		root := b.frames[0]
+		parts[i] = append(append(make([]byte, 0, len(root.hdr)+len(root.children)), root.hdr...), root.children...)
+	}
+	return p.keys, parts, nil
+}
+
+// partitioner holds the state of partition. stack is the chain of
+// undecided messages enclosing the one being visited; each partition keeps
+// its own open copy of the part of the chain it has added to.
+type partitioner[K comparable] struct {
+	schema  *chunkSchema
+	keyOf   partitionKeyFunc[K]
+	rootHdr []byte
+	stack   []partFrame
+	nextID  int
+	keys    []K
+	parts   []partBuilder
+	index   map[K]int
+}
+
+// partFrame is an undecided message on the stack of a partitioner.
+type partFrame struct {
+	id  int
+	num protowire.Number
+	hdr []byte
+}
+
+// partBuilder is the request of one partition under construction. ids
+// identifies the stack frame each of frames[1:] copies.
+type partBuilder struct {
+	frames []chunkFrame
+	ids    []int
+}
+
+func (p *partitioner[K]) visit(msg []byte, depth int, num protowire.Number) error {
+	key, ok, err := p.keyOf(depth, msg)
+	if err != nil {
+		return err
+	}
+	if ok {
+		p.add(key, num, msg)
+		return nil
+	}
+	child, err := p.schema.child(depth, msg)
+	if err != nil || child == 0 {
+		return err
+	}
+	hdr, err := appendFieldsExcept(nil, msg, child)
+	if err != nil {
+		return err
+	}
+	p.stack = append(p.stack, partFrame{id: p.nextID, num: num, hdr: hdr})
+	p.nextID++
+	err = forEachMessage(msg, child, func(ch []byte) error {
+		return p.visit(ch, depth+1, child)
+	})
+	p.stack = p.stack[:len(p.stack)-1]
+	return err
+}
+
+// add appends msg as field num to partition key, inside copies of the
+// envelopes on the stack.
+func (p *partitioner[K]) add(key K, num protowire.Number, msg []byte) {
+	i, ok := p.index[key]
+	if !ok {
+		i = len(p.parts)
+		p.index[key] = i
+		p.keys = append(p.keys, key)
+		p.parts = append(p.parts, partBuilder{frames: []chunkFrame{{hdr: p.rootHdr}}})
+	}
+	b := &p.parts[i]
+
+	n := 0
+	for n < len(b.ids) && n < len(p.stack) && b.ids[n] == p.stack[n].id {
+		n++
+	}
+	b.closeTo(n)
+	for _, f := range p.stack[n:] {
+		b.frames = append(b.frames, chunkFrame{num: f.num, hdr: f.hdr})
+		b.ids = append(b.ids, f.id)
+	}
+	f := &b.frames[len(b.frames)-1]
+	f.children = protowire.AppendTag(f.children, num, protowire.BytesType)
+	f.children = protowire.AppendBytes(f.children, msg)
+}
+
+// closeTo closes the open frames of b beyond the first n stack frames into
+// their parents.
+func (b *partBuilder) closeTo(n int) {
+	for len(b.ids) > n {
+		f := b.frames[len(b.frames)-1]
+		b.frames = b.frames[:len(b.frames)-1]
+		b.ids = b.ids[:len(b.ids)-1]
+		parent := &b.frames[len(b.frames)-1]
+		parent.children = appendFrame(parent.children, f, nil)
+	}
+}
@@ -0,0 +1,280 @@
+package otlpwire
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestSplitByAttribute(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for _, r := range []struct{ tenant, service string }{
+		{"a", "api"}, {"b", "api"}, {"a", "worker"}, {"", "cron"},
+	} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		if r.tenant != "" {
+			rs.Resource().Attributes().PutStr("tenant.id", r.tenant)
+		}
+		rs.Resource().Attributes().PutStr("service.name", r.service)
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName(r.service + "-op")
+	}
+
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).SplitByAttribute("tenant.id")
+	got := map[string][]string{}
+	var keys []string
+	for tenant, req := range seq {
+		keys = append(keys, tenant)
+		entries, entryErr := req.ResourceSpans()
+		for rs := range entries {
+			service, err := rs.ServiceName()
+			require.NoError(t, err)
+			got[tenant] = append(got[tenant], service)
+		}
+		require.NoError(t, entryErr())
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{"a", "b", ""}, keys)
+	require.Equal(t, map[string][]string{
+		"a": {"api", "worker"},
+		"b": {"api"},
+		"":  {"cron"},
+	}, got)
+
+	// Non-string values are compared as text.
+	metrics := pmetric.NewMetrics()
+	for _, shard := range []int64{1, 2, 1} {
+		rm := metrics.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutInt("shard", shard)
+		rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty()
+	}
+	mseq, errFn := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).SplitByAttribute("shard")
+	counts := map[string]int{}
+	for shard, req := range mseq {
+		n, err := req.DataPointCount()
+		require.NoError(t, err)
+		counts[shard] = n
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, map[string]int{"1": 2, "2": 1}, counts)
+
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lseq, errFn := ExportLogsServiceRequest(marshalLogs(t, logs)).SplitByAttribute("tenant.id")
+	n := 0
+	for tenant, req := range lseq {
+		require.Empty(t, tenant)
+		count, err := req.LogRecordCount()
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+		n++
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 1, n)
+}
+
+func TestSplitByTraceID(t *testing.T) {
+	traces := ptrace.NewTraces()
+	ids := []pcommon.TraceID{{1}, {2}, {3}}
+	for r := range 2 {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("service-%d", r))
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName("scope")
+		for i, id := range ids {
+			span := ss.Spans().AppendEmpty()
+			span.SetTraceID(id)
+			span.SetName(fmt.Sprintf("op-%d-%d", r, i))
+		}
+	}
+	data := marshalTraces(t, traces)
+
+	seq, errFn := ExportTracesServiceRequest(data).SplitByTraceID()
+	var keys [][16]byte
+	for id, req := range seq {
+		keys = append(keys, id)
+		td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(req)
+		require.NoError(t, err)
+		require.Equal(t, 2, td.ResourceSpans().Len())
+		for _, rs := range td.ResourceSpans().All() {
+			ss := rs.ScopeSpans().At(0)
+			require.Equal(t, "scope", ss.Scope().Name())
+			require.Equal(t, 1, ss.Spans().Len())
+			require.Equal(t, pcommon.TraceID(id), ss.Spans().At(0).TraceID())
+		}
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, [][16]byte{{1}, {2}, {3}}, keys)
+
+	hseq, errFn := ExportTracesServiceRequest(data).SplitByTraceIDHash(2)
+	total := 0
+	seen := map[[16]byte]int{}
+	for bucket, req := range hseq {
+		require.GreaterOrEqual(t, bucket, 0)
+		require.Less(t, bucket, 2)
+		spans, spanErr := req.AllSpans()
+		for s := range spans {
+			id, err := s.Span.TraceID()
+			require.NoError(t, err)
+			if b, ok := seen[id]; ok {
+				require.Equal(t, b, bucket)
+			}
+			seen[id] = bucket
+			total++
+		}
+		require.NoError(t, spanErr())
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 6, total)
+
+	hseq, errFn = ExportTracesServiceRequest(data).SplitByTraceIDHash(0)
+	for range hseq {
+		t.Fatal("unexpected request")
+	}
+	require.Error(t, errFn())
+}
+
+func TestSplitByMetricPrefix(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "api")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("runtime")
+	for _, name := range []string{"http.server.duration", "jvm.memory.used", "jvm.gc.duration", "process.cpu", "http.client.duration"} {
+		m := sm.Metrics().AppendEmpty()
+		m.SetName(name)
+		m.SetEmptyGauge().DataPoints().AppendEmpty()
+	}
+
+	seq, errFn := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).SplitByMetricPrefix("http.", "jvm.", "jvm.gc.")
+	got := map[string][]string{}
+	var keys []string
+	for prefix, req := range seq {
+		keys = append(keys, prefix)
+		md, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(req)
+		require.NoError(t, err)
+		require.Equal(t, 1, md.ResourceMetrics().Len())
+		service, _ := md.ResourceMetrics().At(0).Resource().Attributes().Get("service.name")
+		require.Equal(t, "api", service.Str())
+		sm := md.ResourceMetrics().At(0).ScopeMetrics().At(0)
+		require.Equal(t, "runtime", sm.Scope().Name())
+		for _, m := range sm.Metrics().All() {
+			got[prefix] = append(got[prefix], m.Name())
+		}
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{"http.", "jvm.", "jvm.gc.", ""}, keys)
+	require.Equal(t, map[string][]string{
+		"http.":   {"http.server.duration", "http.client.duration"},
+		"jvm.":    {"jvm.memory.used"},
+		"jvm.gc.": {"jvm.gc.duration"},
+		"":        {"process.cpu"},
+	}, got)
+}
+
+func TestSplitBySeverity(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "api")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	for _, sev := range []plog.SeverityNumber{
+		plog.SeverityNumberDebug, plog.SeverityNumberWarn, plog.SeverityNumberUnspecified,
+		plog.SeverityNumberInfo, plog.SeverityNumberError, plog.SeverityNumberFatal,
+	} {
+		records.AppendEmpty().SetSeverityNumber(sev)
+	}
+	data := ExportLogsServiceRequest(marshalLogs(t, logs))
+
+	bands, err := data.SplitBySeverity(int32(plog.SeverityNumberWarn), int32(plog.SeverityNumberFatal))
+	require.NoError(t, err)
+	require.Len(t, bands, 3)
+	var got [][]plog.SeverityNumber
+	for _, band := range bands {
+		ld, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(band)
+		require.NoError(t, err)
+		var sevs []plog.SeverityNumber
+		for _, rl := range ld.ResourceLogs().All() {
+			service, _ := rl.Resource().Attributes().Get("service.name")
+			require.Equal(t, "api", service.Str())
+			for _, r := range rl.ScopeLogs().At(0).LogRecords().All() {
+				sevs = append(sevs, r.SeverityNumber())
+			}
+		}
+		got = append(got, sevs)
+	}
+	require.Equal(t, [][]plog.SeverityNumber{
+		{plog.SeverityNumberDebug, plog.SeverityNumberUnspecified, plog.SeverityNumberInfo},
+		{plog.SeverityNumberWarn, plog.SeverityNumberError},
+		{plog.SeverityNumberFatal},
+	}, got)
+
+	bands, err = data.SplitBySeverity(100)
+	require.NoError(t, err)
+	require.Nil(t, bands[1])
+
+	_, err = data.SplitBySeverity(17, 9)
+	require.Error(t, err)
+}
+
+func TestSplit(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for _, service := range []string{"api", "internal-db"} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+		for _, name := range []string{"GET /", "health", "POST /"} {
+			spans.AppendEmpty().SetName(name)
+		}
+	}
+	// Internal services go to one partition whole; other spans are split
+	// by name, and health checks are dropped.
+	splitter := SplitterFunc(func(level SplitLevel, msg []byte) (string, bool, error) {
+		switch level {
+		case SplitResource:
+			service, err := ResourceSpans(msg).ServiceName()
+			if err != nil || strings.HasPrefix(service, "internal-") {
+				return "internal", true, err
+			}
+		case SplitRecord:
+			name, err := Span(msg).Name()
+			if err != nil || string(name) == "health" {
+				return "", false, err
+			}
+			return strings.Fields(string(name))[0], true, nil
+		}
+		return "", false, nil
+	})
+
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).Split(splitter)
+	got := map[string]int{}
+	var keys []string
+	for key, req := range seq {
+		keys = append(keys, key)
+		n, err := req.SpanCount()
+		require.NoError(t, err)
+		got[key] = n
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{"GET", "POST", "internal"}, keys)
+	require.Equal(t, map[string]int{"GET": 1, "POST": 1, "internal": 3}, got)
+
+	// Data points are a level of their own.
+	mseq, errFn := ExportMetricsServiceRequest(buildAllTypesMetrics(t)).Split(SplitterFunc(func(level SplitLevel, msg []byte) (string, bool, error) {
+		if level != SplitDataPoint {
+			return "", false, nil
+		}
+		return "all", true, nil
+	}))
+	for key, req := range mseq {
+		require.Equal(t, "all", key)
+		n, err := req.DataPointCount()
+		require.NoError(t, err)
+		require.Equal(t, 10, n)
+	}
+	require.NoError(t, errFn())
+}
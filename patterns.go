@@ -0,0 +1,180 @@
+package otlpwire
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+// logPatternWildcard replaces the variable tokens of a log pattern.
+const logPatternWildcard = "<*>"
+
+var logPatternWildcardBytes = []byte(logPatternWildcard)
+
+// LogPatternConfig configures a LogPatternMiner.
+type LogPatternConfig struct {
+	// Similarity is the fraction of tokens a body must share with a
+	// pattern, position by position, to join it. Defaults to 0.5.
+	Similarity float64
+	// MaxPatterns bounds the number of patterns tracked. Bodies that match
+	// no pattern once the limit is reached are counted as unmatched.
+	// Defaults to 1000.
+	MaxPatterns int
+}
+
+// LogPattern is a template of log bodies: the body tokens, separated by
+// single spaces, with the tokens that vary between bodies replaced by "<*>".
+type LogPattern struct {
+	Template string
+	Count    int
+}
+
+// LogPatternMiner clusters log record bodies into patterns, the way the
+// Drain algorithm does, to show what is flooding a pipeline before it
+// reaches storage. Bodies are split into whitespace-separated tokens, and
+// tokens containing digits are treated as variable up front. A body joins
+// the most similar pattern with the same number of tokens and the same
+// first token, which turns the tokens where they differ into "<*>", or
+// starts a new pattern. Bodies that are not strings are mined in their text
+// form; empty bodies are skipped. A LogPatternMiner is not safe for
+// concurrent use.
+type LogPatternMiner struct {
+	cfg       LogPatternConfig
+	groups    map[string][]*logPattern // by first token
+	patterns  []*logPattern            // in order of creation
+	unmatched int
+	tokens    [][]byte
+	text      []byte
+}
+
+type logPattern struct {
+	tokens []string
+	count  int
+}
+
+// NewLogPatternMiner returns an empty miner.
+func NewLogPatternMiner(cfg LogPatternConfig) *LogPatternMiner {
+	if cfg.Similarity <= 0 {
+		cfg.Similarity = 0.5
+	}
+	if cfg.MaxPatterns <= 0 {
+		cfg.MaxPatterns = 1000
+	}
+	return &LogPatternMiner{cfg: cfg, groups: make(map[string][]*logPattern)}
+}
+
+// Add mines the bodies of every log record of req.
+func (m *LogPatternMiner) Add(req ExportLogsServiceRequest) error {
+	return forEachLogRecord(req, func(_, _, record []byte) error {
+		body, err := extractBytesField(record, 5)
+		if err != nil {
+			return err
+		}
+		m.text, err = appendAnyValueText(m.text[:0], body)
+		if err != nil {
+			return err
+		}
+		m.addBody(m.text)
+		return nil
+	})
+}
+
+func (m *LogPatternMiner) addBody(body []byte) {
+	m.tokens = m.tokens[:0]
+	for tok := range bytes.FieldsSeq(body) {
+		if bytes.ContainsAny(tok, "0123456789") {
+			tok = logPatternWildcardBytes
+		}
+		m.tokens = append(m.tokens, tok)
+	}
+	if len(m.tokens) == 0 {
+		return
+	}
+
+	var best *logPattern
+	bestSimilarity := 0.0
+	for _, p := range m.groups[string(m.tokens[0])] {
+		if len(p.tokens) != len(m.tokens) {
+			continue
+		}
+		if s := p.similarity(m.tokens); s > bestSimilarity {
+			best, bestSimilarity = p, s
+		}
+	}
+	if best != nil && bestSimilarity >= m.cfg.Similarity {
+		best.merge(m.tokens)
+		best.count++
+		return
+	}
+
+	if len(m.patterns) >= m.cfg.MaxPatterns {
+		m.unmatched++
+		return
+	}
+	p := &logPattern{tokens: make([]string, len(m.tokens)), count: 1}
+	for i, tok := range m.tokens {
+		p.tokens[i] = string(tok)
+	}
+	m.groups[p.tokens[0]] = append(m.groups[p.tokens[0]], p)
+	m.patterns = append(m.patterns, p)
+}
+
+// similarity returns the fraction of tokens equal to the pattern's. A
+// wildcard matches only a wildcard, so that bodies do not collapse into an
+// all-wildcard pattern.
+func (p *logPattern) similarity(tokens [][]byte) float64 {
+	same := 0
+	for i, tok := range tokens {
+		if p.tokens[i] == string(tok) {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens))
+}
+
+// merge turns the tokens where the pattern and tokens differ into
+// wildcards.
+func (p *logPattern) merge(tokens [][]byte) {
+	for i, tok := range tokens {
+		if p.tokens[i] != string(tok) {
+			p.tokens[i] = logPatternWildcard
+		}
+	}
+}
+
+// Top returns the n patterns with the highest counts, or all patterns if n
+// is not positive. Patterns with equal counts are in order of creation.
+func (m *LogPatternMiner) Top(n int) []LogPattern {
+	out := make([]LogPattern, len(m.patterns))
+	for i, p := range m.patterns {
+		out[i] = LogPattern{Template: strings.Join(p.tokens, " "), Count: p.count}
+	}
+	slices.SortStableFunc(out, func(a, b LogPattern) int { return b.Count - a.Count })
+	if n > 0 && n < len(out) {
+		out = out[:n]
+	}
+	return out
+}
+
+// Unmatched returns the number of bodies that matched no pattern after
+// MaxPatterns was reached.
+func (m *LogPatternMiner) Unmatched() int { return m.unmatched }
+
+// Reset forgets all patterns, for example at the start of a reporting
+// window.
+func (m *LogPatternMiner) Reset() {
+	clear(m.groups)
+	m.patterns = m.patterns[:0]
+	m.unmatched = 0
+}
+
+// LogPatterns mines the log bodies of the request with a default
+// LogPatternMiner and returns its n top patterns, or all if n is not
+// positive.
+func (l ExportLogsServiceRequest) LogPatterns(n int) ([]LogPattern, error) {
+	m := NewLogPatternMiner(LogPatternConfig{})
+	if err := m.Add(l); err != nil {
+		return nil, err
+	}
+	return m.Top(n), nil
+}
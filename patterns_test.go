@@ -0,0 +1,84 @@
+package otlpwire
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func logsWithBodies(t testing.TB, bodies ...string) ExportLogsServiceRequest {
+	logs := plog.NewLogs()
+	records := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for _, b := range bodies {
+		records.AppendEmpty().Body().SetStr(b)
+	}
+	return ExportLogsServiceRequest(marshalLogs(t, logs))
+}
+
+func TestLogPatterns(t *testing.T) {
+	req := logsWithBodies(t,
+		"user alice logged in from 10.0.0.1",
+		"connection reset by peer",
+		"user bob logged in from 10.0.0.2",
+		"user carol logged in from 192.168.1.1",
+		"cache miss for key=42",
+		"connection reset by peer",
+		"user dave logged out",
+		"",
+	)
+
+	patterns, err := req.LogPatterns(0)
+	require.NoError(t, err)
+	require.Equal(t, []LogPattern{
+		{Template: "user <*> logged in from <*>", Count: 3},
+		{Template: "connection reset by peer", Count: 2},
+		{Template: "cache miss for <*>", Count: 1},
+		{Template: "user dave logged out", Count: 1},
+	}, patterns)
+
+	top, err := req.LogPatterns(1)
+	require.NoError(t, err)
+	require.Equal(t, patterns[:1], top)
+}
+
+func TestLogPatternMiner(t *testing.T) {
+	m := NewLogPatternMiner(LogPatternConfig{MaxPatterns: 2})
+
+	// Patterns accumulate across requests.
+	require.NoError(t, m.Add(logsWithBodies(t, "GET /a took 3ms", "GET /b took 5ms")))
+	require.NoError(t, m.Add(logsWithBodies(t, "disk full", "GET /c took 1ms")))
+	require.NoError(t, m.Add(logsWithBodies(t, "shutting down now", "disk full")))
+	require.Equal(t, []LogPattern{
+		{Template: "GET <*> took <*>", Count: 3},
+		{Template: "disk full", Count: 2},
+	}, m.Top(0))
+	require.Equal(t, 1, m.Unmatched())
+
+	// Non-string bodies are mined in their text form.
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetBool(true)
+	m.Reset()
+	require.Empty(t, m.Top(0))
+	require.Zero(t, m.Unmatched())
+	require.NoError(t, m.Add(ExportLogsServiceRequest(marshalLogs(t, logs))))
+	require.Equal(t, []LogPattern{{Template: "true", Count: 1}}, m.Top(0))
+
+	require.Error(t, m.Add(ExportLogsServiceRequest{0x0a, 0x05}))
+}
+
+func BenchmarkLogPatternMiner(b *testing.B) {
+	bodies := make([]string, 1000)
+	for i := range bodies {
+		bodies[i] = fmt.Sprintf("request %d from user-%c served in %dms", i, 'a'+i%5, i%100)
+	}
+	req := logsWithBodies(b, bodies...)
+	m := NewLogPatternMiner(LogPatternConfig{})
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := m.Add(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
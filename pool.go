@@ -0,0 +1,67 @@
+package otlpwire
+
+import "sync"
+
+// defaultMaxPooledCap is the default BufferPool.MaxCap.
+const defaultMaxPooledCap = 4 << 20
+
+// BufferPool recycles the buffers of encoded requests, so that a gateway
+// forwarding every request it receives does not leave each one to the
+// garbage collector. It serves these outputs:
+//
+//   - batchers configured with BatchConfig.Pool accumulate requests in
+//     buffers from the pool;
+//   - as an Allocator, it backs SplitBySizeWith, SplitByResourceWith and
+//     PartitionBySampledWith;
+//   - Get with SizeOfAsExportRequest sizes a buffer for
+//     AppendAsExportRequest or AppendWithResource, which encode a resource
+//     or a wrapped scope without allocating.
+//
+// Other operations, notably the transforms and filters that rewrite a
+// request, allocate their results from the heap.
+//
+// A buffer from the pool belongs to whoever received it: the flush
+// function of a batcher or the consumer of a split. It is released by
+// passing it to Put once nothing refers to it any more, including requests,
+// resources or records sliced from it; using it after Put is a data race
+// with the next Get. Releasing is optional, and buffers that are never put
+// back are collected as usual. Heap-allocated requests may be put too. The
+// zero value is ready to use and a BufferPool is safe for concurrent use.
+type BufferPool struct {
+	// MaxCap bounds the capacity of buffers kept for reuse; larger buffers
+	// are dropped by Put so that one outsized request does not pin its
+	// memory. Zero means 4 MiB.
+	MaxCap int
+
+	pool sync.Pool
+}
+
+// Get returns an empty buffer with capacity for at least size bytes.
+func (p *BufferPool) Get(size int) []byte {
+	if b, ok := p.pool.Get().(*[]byte); ok {
+		if cap(*b) >= size {
+			return (*b)[:0]
+		}
+		p.pool.Put(b)
+	}
+	return make([]byte, 0, size)
+}
+
+// Alloc returns Get(size), making the pool an Allocator.
+func (p *BufferPool) Alloc(size int) []byte {
+	return p.Get(size)
+}
+
+// Put releases b for reuse by a later Get. Buffers with a capacity above
+// MaxCap are dropped.
+func (p *BufferPool) Put(b []byte) {
+	maxCap := p.MaxCap
+	if maxCap == 0 {
+		maxCap = defaultMaxPooledCap
+	}
+	if cap(b) == 0 || cap(b) > maxCap {
+		return
+	}
+	b = b[:0]
+	p.pool.Put(&b)
+}
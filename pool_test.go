@@ -0,0 +1,146 @@
+package otlpwire
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	var p BufferPool
+	b := p.Get(64)
+	require.Empty(t, b)
+	require.GreaterOrEqual(t, cap(b), 64)
+
+	b = append(b, "request"...)
+	p.Put(b)
+	// Whether or not the buffer is reused, Get returns an empty buffer of
+	// the requested capacity.
+	b = p.Get(32)
+	require.Empty(t, b)
+	require.GreaterOrEqual(t, cap(b), 32)
+	b = p.Get(1024)
+	require.GreaterOrEqual(t, cap(b), 1024)
+
+	// Oversized and empty buffers are not kept; Put must not panic on them.
+	small := BufferPool{MaxCap: 16}
+	small.Put(make([]byte, 0, 32))
+	small.Put(nil)
+	require.GreaterOrEqual(t, cap(small.Get(8)), 8)
+}
+
+func TestTracesBatcher_Pool(t *testing.T) {
+	pool := &BufferPool{}
+	req := tracesWithResources(t, 1, 1, 1)
+	var flushed []ExportTracesServiceRequest
+	b := NewTracesBatcher(BatchConfig{MaxItems: 2, MaxBytes: 1 << 10, Pool: pool}, func(req ExportTracesServiceRequest) error {
+		flushed = append(flushed, append(ExportTracesServiceRequest{}, req...))
+		require.GreaterOrEqual(t, cap(req), 1<<10)
+		pool.Put(req)
+		return nil
+	})
+	for range 3 {
+		require.NoError(t, b.AddRequest(req))
+	}
+	require.NoError(t, b.Flush())
+
+	total := 0
+	for _, r := range flushed {
+		n, err := r.SpanCount()
+		require.NoError(t, err)
+		total += n
+	}
+	require.Equal(t, 9, total)
+}
+
+func TestSplitBySizeWith_Pool(t *testing.T) {
+	req := tracesWithResources(t, 10, 10, 10, 10)
+	pool := &BufferPool{}
+	for range 2 { // the second round reuses the released buffers
+		seq, errFn := req.SplitBySize(len(req) / 3)
+		want := slices.Collect(seq)
+		require.NoError(t, errFn())
+
+		pooled, errFn := req.SplitBySizeWith(len(req)/3, pool)
+		var got []ExportTracesServiceRequest
+		for out := range pooled {
+			got = append(got, append(ExportTracesServiceRequest{}, out...))
+			pool.Put(out)
+		}
+		require.NoError(t, errFn())
+		require.Equal(t, want, got)
+	}
+
+	seq, errFn := req.SplitByResourceWith(pool)
+	n := 0
+	for out := range seq {
+		count, err := out.SpanCount()
+		require.NoError(t, err)
+		require.Equal(t, 10, count)
+		pool.Put(out)
+		n++
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 4, n)
+}
+
+func TestBufferPool_AppendAsExportRequest(t *testing.T) {
+	req := tracesWithResources(t, 3)
+	rs, err := req0(req)
+	require.NoError(t, err)
+	var pool BufferPool
+	out := rs.AppendAsExportRequest(pool.Get(rs.SizeOfAsExportRequest()))
+	require.Equal(t, req, ExportTracesServiceRequest(out))
+	pool.Put(out)
+}
+
+func BenchmarkTracesBatcher(b *testing.B) {
+	req := tracesWithResources(b, 10, 10, 10, 10)
+	for _, tc := range []struct {
+		name string
+		pool *BufferPool
+	}{{"NoPool", nil}, {"Pool", &BufferPool{}}} {
+		b.Run(tc.name, func(b *testing.B) {
+			batcher := NewTracesBatcher(BatchConfig{MaxItems: 100, Pool: tc.pool}, func(req ExportTracesServiceRequest) error {
+				if tc.pool != nil {
+					tc.pool.Put(req)
+				}
+				return nil
+			})
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := batcher.AddRequest(req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTracesSplitBySizeWith(b *testing.B) {
+	req := benchTraces(b)
+	for _, tc := range []struct {
+		name string
+		pool *BufferPool
+	}{{"NoPool", nil}, {"Pool", &BufferPool{}}} {
+		var alloc Allocator // a nil *BufferPool is not a nil Allocator
+		if tc.pool != nil {
+			alloc = tc.pool
+		}
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				seq, errFn := req.SplitBySizeWith(len(req)/8, alloc)
+				for out := range seq {
+					if tc.pool != nil {
+						tc.pool.Put(out)
+					}
+				}
+				if err := errFn(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
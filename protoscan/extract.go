@@ -0,0 +1,101 @@
+package protoscan
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Extractor runs any number of field extractions over a message in a
+// single pass. Each extraction is registered with a path: the chain of
+// message fields leading to the field, followed by the field number itself.
+// Run reads every field of the message at most once, descending only into
+// the message fields that some registered path passes through, so adding
+// extractions adds callbacks rather than passes over the payload.
+//
+// The zero value is ready to use. Register must not be called concurrently
+// with Run; Run itself may be called concurrently.
+type Extractor struct {
+	root extractNode
+}
+
+// extractNode is one step of the registered paths: the callbacks of paths
+// ending at this field and the fields below it that paths continue to.
+type extractNode struct {
+	fns      []func(Field) error
+	children []extractEdge
+}
+
+type extractEdge struct {
+	num  protowire.Number
+	node *extractNode
+}
+
+// Register calls fn for every occurrence of the field at path during Run.
+// For example, on an OTLP ExportTracesServiceRequest, path {1, 2, 2, 5}
+// visits the name of every span. Callbacks run in wire order; the callback
+// of a message field runs before those of the fields inside it, so a
+// callback registered on an enclosing message, such as {1} for each
+// ResourceSpans, can record context for the callbacks below it. Register
+// panics if path is empty.
+func (e *Extractor) Register(path []protowire.Number, fn func(Field) error) {
+	if len(path) == 0 {
+		panic("protoscan: empty extractor path")
+	}
+	n := &e.root
+	for _, num := range path {
+		n = n.child(num, true)
+	}
+	n.fns = append(n.fns, fn)
+}
+
+// child returns the child node for num, adding it if create is set.
+func (n *extractNode) child(num protowire.Number, create bool) *extractNode {
+	for _, e := range n.children {
+		if e.num == num {
+			return e.node
+		}
+	}
+	if !create {
+		return nil
+	}
+	c := &extractNode{}
+	n.children = append(n.children, extractEdge{num: num, node: c})
+	return c
+}
+
+// Run scans data and calls the registered callbacks. It stops at the first
+// parse error or callback error and returns it.
+func (e *Extractor) Run(data []byte) error {
+	return e.root.run(data)
+}
+
+func (n *extractNode) run(data []byte) error {
+	for pos := 0; pos < len(data); {
+		f, size, err := Next(data[pos:])
+		if err != nil {
+			return err
+		}
+		pos += size
+
+		c := n.child(f.Number, false)
+		if c == nil {
+			continue
+		}
+		for _, fn := range c.fns {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		if len(c.children) == 0 {
+			continue
+		}
+		if f.Type != protowire.BytesType {
+			return errors.New("wrong wire type for field")
+		}
+		if err := c.run(f.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
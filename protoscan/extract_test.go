@@ -0,0 +1,93 @@
+package protoscan
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestExtractor(t *testing.T) {
+	// A request-like tree: resources (1) with a name (5) and scopes (2)
+	// holding records (2) with a name (5) and a count (3).
+	record := func(name string, count uint64) []byte { return message(5, name, 3, count) }
+	res1 := message(5, "svc-a", 2, message(2, record("r1", 1), 2, record("r2", 2)))
+	res2 := message(2, message(2, record("r3", 3)), 5, "svc-b")
+	data := message(1, res1, 9, "ignored", 1, res2)
+
+	var e Extractor
+	var resources, events []string
+	resource := ""
+	e.Register([]protowire.Number{1}, func(f Field) error {
+		// Resource names follow the scopes in res2, so read them here.
+		name, err := Bytes(f.Value, 5)
+		resource = string(name)
+		resources = append(resources, resource)
+		return err
+	})
+	e.Register([]protowire.Number{1, 2, 2, 5}, func(f Field) error {
+		events = append(events, resource+"/"+string(f.Value))
+		return nil
+	})
+	var total uint64
+	e.Register([]protowire.Number{1, 2, 2, 3}, func(f Field) error {
+		total += f.Uint64()
+		return nil
+	})
+	e.Register([]protowire.Number{1, 2, 2, 5}, func(f Field) error {
+		events = append(events, fmt.Sprintf("len=%d", len(f.Value)))
+		return nil
+	})
+
+	require.NoError(t, e.Run(data))
+	require.Equal(t, []string{"svc-a", "svc-b"}, resources)
+	require.Equal(t, []string{"svc-a/r1", "len=2", "svc-a/r2", "len=2", "svc-b/r3", "len=2"}, events)
+	require.Equal(t, uint64(6), total)
+}
+
+func TestExtractor_Errors(t *testing.T) {
+	var e Extractor
+	errStop := errors.New("stop")
+	calls := 0
+	e.Register([]protowire.Number{1, 2}, func(Field) error {
+		calls++
+		return errStop
+	})
+	data := message(1, message(2, "a", 2, "b"))
+	require.ErrorIs(t, e.Run(data), errStop)
+	require.Equal(t, 1, calls)
+
+	// A path through a varint field.
+	require.ErrorContains(t, e.Run(message(1, uint64(5))), "wrong wire type")
+	require.Error(t, e.Run([]byte{0x0a, 0x05}))
+	require.Panics(t, func() { e.Register(nil, func(Field) error { return nil }) })
+}
+
+func BenchmarkExtractor(b *testing.B) {
+	var records [][]byte
+	for i := range 100 {
+		records = append(records, message(5, fmt.Sprintf("record-%d", i), 3, uint64(i), 7, "payload payload payload"))
+	}
+	var scope []byte
+	for _, r := range records {
+		scope = append(scope, message(2, r)...)
+	}
+	data := message(1, message(5, "svc", 2, scope))
+
+	var e Extractor
+	var n uint64
+	for _, num := range []protowire.Number{5, 3} {
+		e.Register([]protowire.Number{1, 2, 2, num}, func(f Field) error {
+			n += uint64(len(f.Value))
+			return nil
+		})
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := e.Run(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package protoscan
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Rule is a condition on a message: a field path, as for Extractor, and
+// optionally the value the field must have.
+type Rule struct {
+	Path []protowire.Number
+	// Value, if not nil, must equal the Value of some occurrence of the
+	// field: the payload of a length-delimited field or the encoded value of
+	// a scalar field, such as protowire.AppendVarint(nil, 2). A nil Value
+	// matches any occurrence.
+	Value []byte
+}
+
+// Matcher evaluates a fixed set of rules against messages. Compile merges
+// the paths of all rules into a single tree and indexes the expected values
+// of each field, so Match reads each message once however many rules there
+// are, descends only into fields some rule passes through, and stops as soon
+// as every rule has matched. A Matcher is safe for concurrent use.
+type Matcher struct {
+	nodes []matchNode
+	rules int
+}
+
+// matchNode is one step of the compiled paths. Children with field numbers
+// below len(direct), the one-byte tags that dominate real messages, are
+// found by index; the others by scanning edges.
+type matchNode struct {
+	direct [16]int32 // child node index by field number, 0 if none
+	edges  []matchEdge
+	// any and byValue hold the rules whose path ends at this field,
+	// without and with a Value.
+	any     []int
+	byValue map[string][]int
+	inner   bool // some path continues below this field
+}
+
+type matchEdge struct {
+	num  protowire.Number
+	node int32
+}
+
+// Compile returns a Matcher for rules. Rule i is reported at index i by
+// Match. It returns an error if a rule has an empty path or a field number
+// that protobuf does not allow, such as zero or a negative number.
+func Compile(rules ...Rule) (*Matcher, error) {
+	m := &Matcher{nodes: make([]matchNode, 1), rules: len(rules)}
+	for i, r := range rules {
+		if len(r.Path) == 0 {
+			return nil, fmt.Errorf("rule %d has an empty path", i)
+		}
+		n := int32(0)
+		for _, num := range r.Path {
+			if !num.IsValid() {
+				return nil, fmt.Errorf("rule %d has invalid field number %d", i, num)
+			}
+			c := m.child(n, num)
+			if c == 0 {
+				m.nodes[n].inner = true
+				c = int32(len(m.nodes))
+				m.nodes = append(m.nodes, matchNode{})
+				if int(num) < len(m.nodes[n].direct) {
+					m.nodes[n].direct[num] = c
+				} else {
+					m.nodes[n].edges = append(m.nodes[n].edges, matchEdge{num: num, node: c})
+				}
+			}
+			n = c
+		}
+		node := &m.nodes[n]
+		if r.Value == nil {
+			node.any = append(node.any, i)
+			continue
+		}
+		if node.byValue == nil {
+			node.byValue = make(map[string][]int)
+		}
+		node.byValue[string(r.Value)] = append(node.byValue[string(r.Value)], i)
+	}
+	return m, nil
+}
+
+// child returns the index of the child of node n for field num, or 0 if
+// there is none. Index 0 is the root, which is nobody's child.
+func (m *Matcher) child(n int32, num protowire.Number) int32 {
+	node := &m.nodes[n]
+	if num >= 0 && int(num) < len(node.direct) {
+		return node.direct[num]
+	}
+	for _, e := range node.edges {
+		if e.num == num {
+			return e.node
+		}
+	}
+	return 0
+}
+
+// Match reports which rules match data, appending one result per rule to
+// dst[:0] so that a caller can reuse the slice across requests.
+func (m *Matcher) Match(data []byte, dst []bool) ([]bool, error) {
+	if cap(dst) < m.rules {
+		dst = make([]bool, m.rules)
+	} else {
+		dst = dst[:m.rules]
+		clear(dst)
+	}
+	remaining := m.rules
+	err := m.match(data, 0, dst, &remaining)
+	if err == errStop {
+		err = nil
+	}
+	return dst, err
+}
+
+func (m *Matcher) match(data []byte, n int32, matched []bool, remaining *int) error {
+	for pos := 0; pos < len(data); {
+		f, size, err := Next(data[pos:])
+		if err != nil {
+			return err
+		}
+		pos += size
+
+		c := m.child(n, f.Number)
+		if c == 0 {
+			continue
+		}
+		node := &m.nodes[c]
+		if markMatched(matched, node.any, remaining) || markMatched(matched, node.byValue[string(f.Value)], remaining) {
+			return errStop
+		}
+		if !node.inner {
+			continue
+		}
+		if f.Type != protowire.BytesType {
+			return errors.New("wrong wire type for field")
+		}
+		if err := m.match(f.Value, c, matched, remaining); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markMatched marks rules as matched and reports whether that was the last
+// of the remaining rules.
+func markMatched(matched []bool, rules []int, remaining *int) bool {
+	for _, i := range rules {
+		if matched[i] {
+			continue
+		}
+		matched[i] = true
+		if *remaining--; *remaining == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package protoscan
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMatcher(t *testing.T) {
+	span := func(name string, kind uint64) []byte { return message(5, name, 6, kind) }
+	data := message(
+		1, message(1, message(1, "attrs"), 2, message(2, span("GET", 2), 2, span("query", 3))),
+		1, message(2, message(1, message(1, "db"), 2, span("commit", 3))),
+	)
+
+	m, err := Compile(
+		Rule{Path: []protowire.Number{1, 2, 2, 5}, Value: []byte("query")},
+		Rule{Path: []protowire.Number{1, 2, 2, 6}, Value: protowire.AppendVarint(nil, 2)},
+		Rule{Path: []protowire.Number{1, 2, 2, 6}, Value: protowire.AppendVarint(nil, 4)},
+		Rule{Path: []protowire.Number{1, 2, 1, 1}, Value: []byte("db")},
+		Rule{Path: []protowire.Number{1, 1}},
+		Rule{Path: []protowire.Number{1, 3}},
+		Rule{Path: []protowire.Number{1, 2, 2, 1000}},
+	)
+	require.NoError(t, err)
+
+	got, err := m.Match(data, nil)
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, false, true, true, false, false}, got)
+
+	// The result slice is reused and cleared.
+	got2, err := m.Match(message(1, message(2, message(2, message(1000, uint64(1))))), got[:0])
+	require.NoError(t, err)
+	require.Equal(t, &got[0], &got2[0])
+	require.Equal(t, []bool{false, false, false, false, false, false, true}, got2)
+}
+
+func TestMatcher_Errors(t *testing.T) {
+	_, err := Compile(Rule{Path: []protowire.Number{1}}, Rule{})
+	require.ErrorContains(t, err, "rule 1 has an empty path")
+	for _, num := range []protowire.Number{0, -1, protowire.MaxValidNumber + 1} {
+		_, err = Compile(Rule{Path: []protowire.Number{1, num}})
+		require.ErrorContains(t, err, "rule 0 has invalid field number")
+	}
+
+	m, err := Compile(Rule{Path: []protowire.Number{1, 2}})
+	require.NoError(t, err)
+	_, err = m.Match(message(1, uint64(5)), nil)
+	require.ErrorContains(t, err, "wrong wire type")
+	_, err = m.Match([]byte{0x0a, 0x05}, nil)
+	require.Error(t, err)
+
+	// Matching stops once every rule has matched, before the malformed tail.
+	got, err := m.Match(append(message(1, message(2, "x")), 0x0a, 0x05), nil)
+	require.NoError(t, err)
+	require.Equal(t, []bool{true}, got)
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	var scope []byte
+	for i := range 100 {
+		scope = append(scope, message(2, message(5, fmt.Sprintf("span-%d", i), 6, uint64(i%5)))...)
+	}
+	data := message(1, message(1, message(1, "attrs"), 2, scope))
+
+	var rules []Rule
+	for i := range 32 {
+		rules = append(rules, Rule{Path: []protowire.Number{1, 2, 2, 5}, Value: fmt.Appendf(nil, "route-%d", i)})
+	}
+	m, err := Compile(rules...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var dst []bool
+	b.ReportAllocs()
+	for b.Loop() {
+		if dst, err = m.Match(data, dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package protoscan provides the low-level protobuf wire scanning that
+// otlp-wire is built on, for extracting fields its high-level API does not
+// cover yet. It works on any protobuf message, OTLP or not, without a
+// schema: callers name fields by number and interpret wire types themselves.
+//
+// The Consume functions are drop-in replacements for the protowire functions
+// of the same names with inline fast paths for the short encodings that
+// dominate real payloads. The lookup functions return the first occurrence of
+// a field, and a zero value without error when it is absent. Returned slices
+// alias the input; nothing is copied.
+package protoscan
+
+import (
+	"errors"
+	"iter"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ConsumeTag is protowire.ConsumeTag with a fast path for one-byte tags,
+// which cover field numbers 1 to 15.
+func ConsumeTag(b []byte) (protowire.Number, protowire.Type, int) {
+	if len(b) > 0 {
+		if c := b[0]; c < 0x80 && c >= 1<<3 {
+			return protowire.Number(c >> 3), protowire.Type(c & 7), 1
+		}
+	}
+	return protowire.ConsumeTag(b)
+}
+
+// ConsumeVarint is protowire.ConsumeVarint with a fast path for one- and
+// two-byte varints.
+func ConsumeVarint(b []byte) (uint64, int) {
+	if len(b) > 1 {
+		c0, c1 := b[0], b[1]
+		if c0 < 0x80 {
+			return uint64(c0), 1
+		}
+		if c1 < 0x80 {
+			return uint64(c0&0x7f) | uint64(c1)<<7, 2
+		}
+	} else if len(b) == 1 && b[0] < 0x80 {
+		return uint64(b[0]), 1
+	}
+	return protowire.ConsumeVarint(b)
+}
+
+// ConsumeBytes is protowire.ConsumeBytes with a fast path for values whose
+// length prefix takes one or two bytes, that is values shorter than 16 KiB.
+func ConsumeBytes(b []byte) ([]byte, int) {
+	if len(b) > 0 {
+		if c0 := b[0]; c0 < 0x80 {
+			if end := 1 + int(c0); end <= len(b) {
+				return b[1:end], end
+			}
+		} else if len(b) > 1 && b[1] < 0x80 {
+			if end := 2 + (int(c0&0x7f) | int(b[1])<<7); end <= len(b) {
+				return b[2:end], end
+			}
+		}
+	}
+	return protowire.ConsumeBytes(b)
+}
+
+// SkipValue returns the length of the field value of wire type typ at the
+// start of b, or a negative protowire error code. Groups are not supported
+// and yield -1, as OTLP does not use them.
+func SkipValue(b []byte, typ protowire.Type) int {
+	switch typ {
+	case protowire.VarintType:
+		_, n := ConsumeVarint(b)
+		return n
+	case protowire.Fixed64Type:
+		if len(b) >= 8 {
+			return 8
+		}
+		_, n := protowire.ConsumeFixed64(b)
+		return n
+	case protowire.BytesType:
+		_, n := ConsumeBytes(b)
+		return n
+	case protowire.Fixed32Type:
+		if len(b) >= 4 {
+			return 4
+		}
+		_, n := protowire.ConsumeFixed32(b)
+		return n
+	default:
+		return -1
+	}
+}
+
+// Field is one field of a message.
+type Field struct {
+	Number protowire.Number
+	Type   protowire.Type
+	// Value is the payload of a length-delimited field, or the encoded
+	// value of a varint, fixed32 or fixed64 field.
+	Value []byte
+}
+
+// Uint64 decodes the value of a varint, fixed32 or fixed64 field. It
+// returns 0 for length-delimited fields.
+func (f Field) Uint64() uint64 {
+	switch f.Type {
+	case protowire.VarintType:
+		v, _ := ConsumeVarint(f.Value)
+		return v
+	case protowire.Fixed64Type:
+		v, _ := protowire.ConsumeFixed64(f.Value)
+		return v
+	case protowire.Fixed32Type:
+		v, _ := protowire.ConsumeFixed32(f.Value)
+		return uint64(v)
+	default:
+		return 0
+	}
+}
+
+// Next decodes the field at the start of b and returns it together with
+// its encoded length.
+func Next(b []byte) (Field, int, error) {
+	num, typ, tagLen := ConsumeTag(b)
+	if tagLen < 0 {
+		return Field{}, 0, errors.New("malformed protobuf tag")
+	}
+	if typ == protowire.BytesType {
+		v, n := ConsumeBytes(b[tagLen:])
+		if n < 0 {
+			return Field{}, 0, errors.New("invalid bytes in field")
+		}
+		return Field{Number: num, Type: typ, Value: v}, tagLen + n, nil
+	}
+	n := SkipValue(b[tagLen:], typ)
+	if n < 0 {
+		return Field{}, 0, errors.New("failed to skip field")
+	}
+	return Field{Number: num, Type: typ, Value: b[tagLen : tagLen+n]}, tagLen + n, nil
+}
+
+// Fields iterates over the top-level fields of a message in wire order.
+// The returned function should be called after iteration to check for
+// errors.
+func Fields(data []byte) (iter.Seq[Field], func() error) {
+	var iterErr error
+	seq := func(yield func(Field) bool) {
+		iterErr = nil
+		for pos := 0; pos < len(data); {
+			f, n, err := Next(data[pos:])
+			if err != nil {
+				iterErr = err
+				return
+			}
+			pos += n
+			if !yield(f) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return iterErr }
+}
+
+// ForEach calls fn for every occurrence of the message (or other
+// length-delimited) field num of data, in wire order. It stops at the first
+// parse error or the first error returned by fn, and returns it.
+func ForEach(data []byte, num protowire.Number, fn func([]byte) error) error {
+	pos := 0
+
+	for pos < len(data) {
+		fieldNum, wireType, tagLen := ConsumeTag(data[pos:])
+		if tagLen < 0 {
+			return errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if fieldNum != num {
+			n := SkipValue(data[pos:], wireType)
+			if n < 0 {
+				return errors.New("failed to skip field")
+			}
+			pos += n
+			continue
+		}
+
+		if wireType != protowire.BytesType {
+			return errors.New("wrong wire type for field")
+		}
+		msg, n := ConsumeBytes(data[pos:])
+		if n < 0 {
+			return errors.New("invalid bytes in repeated field")
+		}
+		pos += n
+
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Walk follows a chain of repeated message fields, one field number per
+// nesting level, and calls fn for every message at the end of the chain.
+// For example, path {1, 2, 2} visits every Span of an OTLP
+// ExportTracesServiceRequest. Return false from fn to stop the walk early.
+// Walk returns the first parse error encountered.
+func Walk(data []byte, path []protowire.Number, fn func([]byte) bool) error {
+	_, err := walk(data, path, fn)
+	return err
+}
+
+// errStop ends a walk early without reporting an error.
+var errStop = errors.New("stop")
+
+// walk implements Walk. The returned bool reports whether the walk should
+// continue.
+func walk(data []byte, path []protowire.Number, fn func([]byte) bool) (bool, error) {
+	if len(path) == 0 {
+		return fn(data), nil
+	}
+	err := ForEach(data, path[0], func(msg []byte) error {
+		cont, err := walk(msg, path[1:], fn)
+		if err == nil && !cont {
+			err = errStop
+		}
+		return err
+	})
+	if err == errStop {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Count returns the number of occurrences of the length-delimited field num
+// in data.
+func Count(data []byte, num protowire.Number) (int, error) {
+	count := 0
+	err := ForEach(data, num, func([]byte) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// find returns the first occurrence of field num in data, checking its
+// wire type. ok is false if the field is absent.
+func find(data []byte, num protowire.Number, typ protowire.Type) (f Field, ok bool, err error) {
+	for pos := 0; pos < len(data); {
+		f, n, err := Next(data[pos:])
+		if err != nil {
+			return Field{}, false, err
+		}
+		if f.Number == num {
+			if f.Type != typ {
+				return Field{}, false, errors.New("wrong wire type for field")
+			}
+			return f, true, nil
+		}
+		pos += n
+	}
+	return Field{}, false, nil
+}
+
+// Bytes returns the payload of the first occurrence of the length-delimited
+// field num, or nil if it is absent.
+func Bytes(data []byte, num protowire.Number) ([]byte, error) {
+	f, _, err := find(data, num, protowire.BytesType)
+	return f.Value, err
+}
+
+// Varint returns the first occurrence of the varint field num, or 0 if it
+// is absent.
+func Varint(data []byte, num protowire.Number) (uint64, error) {
+	f, _, err := find(data, num, protowire.VarintType)
+	return f.Uint64(), err
+}
+
+// Fixed64 returns the first occurrence of the fixed64 field num, or 0 if it
+// is absent.
+func Fixed64(data []byte, num protowire.Number) (uint64, error) {
+	f, _, err := find(data, num, protowire.Fixed64Type)
+	return f.Uint64(), err
+}
+
+// Fixed32 returns the first occurrence of the fixed32 field num, or 0 if it
+// is absent.
+func Fixed32(data []byte, num protowire.Number) (uint32, error) {
+	f, _, err := find(data, num, protowire.Fixed32Type)
+	return uint32(f.Uint64()), err
+}
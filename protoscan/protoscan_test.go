@@ -0,0 +1,174 @@
+package protoscan
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// scanInputs returns encodings around the fast-path boundaries, each also
+// truncated at every length.
+func scanInputs() [][]byte {
+	var full [][]byte
+	for _, v := range []uint64{0, 1, 0x7f, 0x80, 0x3fff, 0x4000, 1 << 35, ^uint64(0)} {
+		full = append(full, protowire.AppendVarint(nil, v))
+	}
+	for _, num := range []protowire.Number{1, 2, 15, 16, 2047, protowire.MaxValidNumber} {
+		for _, typ := range []protowire.Type{protowire.VarintType, protowire.Fixed64Type, protowire.BytesType, protowire.Fixed32Type, protowire.StartGroupType} {
+			full = append(full, protowire.AppendTag(nil, num, typ))
+		}
+	}
+	for _, n := range []int{0, 1, 127, 128, 16383, 16384} {
+		full = append(full, protowire.AppendBytes(nil, make([]byte, n)))
+	}
+	// Field number 0, non-minimal and overlong varints.
+	full = append(full, []byte{0x00}, []byte{0x07}, []byte{0x80, 0x00}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+
+	var inputs [][]byte
+	for _, b := range full {
+		for i := 0; i <= len(b); i++ {
+			inputs = append(inputs, b[:i])
+		}
+		inputs = append(inputs, append(append([]byte{}, b...), 0x01, 0x02))
+	}
+	return inputs
+}
+
+func TestConsumeMatchesProtowire(t *testing.T) {
+	for _, b := range scanInputs() {
+		num, typ, n := ConsumeTag(b)
+		wantNum, wantTyp, wantN := protowire.ConsumeTag(b)
+		require.Equal(t, wantN, n, "tag %x", b)
+		if n >= 0 {
+			require.Equal(t, wantNum, num, "tag %x", b)
+			require.Equal(t, wantTyp, typ, "tag %x", b)
+		}
+
+		v, n := ConsumeVarint(b)
+		wantV, wantN := protowire.ConsumeVarint(b)
+		require.Equal(t, wantN, n, "varint %x", b)
+		require.Equal(t, wantV, v, "varint %x", b)
+
+		bs, n := ConsumeBytes(b)
+		wantBs, wantN := protowire.ConsumeBytes(b)
+		require.Equal(t, wantN, n, "bytes %x", b)
+		require.Equal(t, wantBs, bs, "bytes %x", b)
+
+		for _, typ := range []protowire.Type{protowire.VarintType, protowire.Fixed64Type, protowire.BytesType, protowire.Fixed32Type} {
+			require.Equal(t, protowire.ConsumeFieldValue(1, typ, b), SkipValue(b, typ), "skip %v %x", typ, b)
+		}
+	}
+}
+
+// message builds a message from alternating field numbers and values: a
+// uint64 is encoded as a varint, a []byte or string as a length-delimited
+// field.
+func message(fields ...any) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		num := protowire.Number(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case uint64:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		}
+	}
+	return b
+}
+
+func TestFields(t *testing.T) {
+	data := message(1, "a", 2, uint64(300))
+	data = protowire.AppendTag(data, 3, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 7)
+	data = protowire.AppendTag(data, 4, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 9)
+
+	var got []uint64
+	seq, errFn := Fields(data)
+	for f := range seq {
+		if f.Type == protowire.BytesType {
+			require.Equal(t, "a", string(f.Value))
+			continue
+		}
+		got = append(got, uint64(f.Number)*1000+f.Uint64())
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []uint64{2300, 3007, 4009}, got)
+
+	seq, errFn = Fields(append(data, 0x0a, 0x05))
+	for range seq {
+	}
+	require.Error(t, errFn())
+}
+
+func TestForEachAndWalk(t *testing.T) {
+	// Two outer messages (field 1) holding inner messages (field 2).
+	outer1 := message(2, message(9, "x"), 5, uint64(1), 2, message(9, "y"))
+	outer2 := message(2, message(9, "z"))
+	data := message(1, outer1, 3, "other", 1, outer2)
+
+	var names []string
+	err := Walk(data, []protowire.Number{1, 2}, func(msg []byte) bool {
+		name, err := Bytes(msg, 9)
+		require.NoError(t, err)
+		names = append(names, string(name))
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"x", "y", "z"}, names)
+
+	// Stopping early is not an error.
+	names = nil
+	err = Walk(data, []protowire.Number{1, 2}, func(msg []byte) bool {
+		names = append(names, "")
+		return len(names) < 2
+	})
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+
+	n, err := Count(data, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	errFn := errors.New("fn")
+	require.ErrorIs(t, ForEach(data, 1, func([]byte) error { return errFn }), errFn)
+	// A varint field read as a message.
+	require.ErrorContains(t, ForEach(outer1, 5, func([]byte) error { return nil }), "wrong wire type")
+	require.Error(t, Walk(append(data, 0x0a, 0x05), []protowire.Number{1, 2}, func([]byte) bool { return true }))
+}
+
+func TestLookups(t *testing.T) {
+	data := message(1, "first", 1, "second", 2, uint64(42))
+	data = protowire.AppendTag(data, 3, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 1<<40)
+	data = protowire.AppendTag(data, 4, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 7)
+
+	b, err := Bytes(data, 1)
+	require.NoError(t, err)
+	require.Equal(t, "first", string(b))
+	v, err := Varint(data, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), v)
+	f64, err := Fixed64(data, 3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1<<40), f64)
+	f32, err := Fixed32(data, 4)
+	require.NoError(t, err)
+	require.Equal(t, uint32(7), f32)
+
+	// Absent fields are zero without error; wrong wire types are errors.
+	b, err = Bytes(data, 15)
+	require.NoError(t, err)
+	require.Nil(t, b)
+	_, err = Varint(data, 1)
+	require.ErrorContains(t, err, "wrong wire type")
+}
@@ -0,0 +1,53 @@
+package protoscan
+
+import (
+	"errors"
+	"iter"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Split splits a message by its repeated message field num. It yields one
+// message per occurrence of num, holding that occurrence and a copy of every
+// other top-level field, so that each output is a valid message of the same
+// type with a single entry. This is how an OTLP export request splits into
+// one request per resource, for any protobuf message. A message without num
+// yields nothing. Each yielded message is newly allocated and owned by the
+// caller. The returned function should be called after iteration to check
+// for errors.
+func Split(data []byte, num protowire.Number) (iter.Seq[[]byte], func() error) {
+	var iterErr error
+	seq := func(yield func([]byte) bool) {
+		iterErr = nil
+		var common []byte
+		for pos := 0; pos < len(data); {
+			f, n, err := Next(data[pos:])
+			if err != nil {
+				iterErr = err
+				return
+			}
+			if f.Number != num {
+				common = append(common, data[pos:pos+n]...)
+			} else if f.Type != protowire.BytesType {
+				iterErr = errors.New("wrong wire type for field")
+				return
+			}
+			pos += n
+		}
+
+		for pos := 0; pos < len(data); {
+			f, n, _ := Next(data[pos:])
+			entry := data[pos : pos+n]
+			pos += n
+			if f.Number != num {
+				continue
+			}
+			out := make([]byte, 0, len(common)+len(entry))
+			out = append(out, common...)
+			if !yield(append(out, entry...)) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return iterErr }
+}
@@ -0,0 +1,59 @@
+package protoscan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	data := message(1, "a", 5, "header", 1, "b", 6, uint64(7), 1, "c")
+
+	seq, errFn := Split(data, 1)
+	var got [][]byte
+	for msg := range seq {
+		got = append(got, msg)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, [][]byte{
+		message(5, "header", 6, uint64(7), 1, "a"),
+		message(5, "header", 6, uint64(7), 1, "b"),
+		message(5, "header", 6, uint64(7), 1, "c"),
+	}, got)
+
+	// Outputs do not alias the input or each other.
+	got[0][len(got[0])-1] = 'x'
+	require.Equal(t, message(5, "header", 6, uint64(7), 1, "b"), got[1])
+	require.Equal(t, message(1, "a", 5, "header", 1, "b", 6, uint64(7), 1, "c"), data)
+
+	// Early break.
+	seq, errFn = Split(data, 1)
+	n := 0
+	for range seq {
+		n++
+		break
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 1, n)
+
+	seq, errFn = Split(message(5, "header"), 1)
+	for range seq {
+		t.Fatal("unexpected message")
+	}
+	require.NoError(t, errFn())
+}
+
+func TestSplit_Errors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"wire type": message(1, "a", 1, uint64(2)),
+		"malformed": append(message(1, "a"), 0x0a, 0x05),
+	} {
+		t.Run(name, func(t *testing.T) {
+			seq, errFn := Split(data, 1)
+			for range seq {
+				t.Fatal("yielded before validating the message")
+			}
+			require.Error(t, errFn())
+		})
+	}
+}
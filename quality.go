@@ -0,0 +1,268 @@
+package otlpwire
+
+import (
+	"errors"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ValueType identifies which oneof field of an AnyValue message is set. The
+// constant values are the AnyValue field numbers.
+type ValueType int
+
+// AnyValue oneof field numbers.
+const (
+	ValueTypeEmpty  ValueType = 0
+	ValueTypeString ValueType = 1
+	ValueTypeBool   ValueType = 2
+	ValueTypeInt    ValueType = 3
+	ValueTypeDouble ValueType = 4
+	ValueTypeArray  ValueType = 5
+	ValueTypeKvlist ValueType = 6
+	ValueTypeBytes  ValueType = 7
+)
+
+// String returns the lower-case name of the value type.
+func (t ValueType) String() string {
+	switch t {
+	case ValueTypeEmpty:
+		return "empty"
+	case ValueTypeString:
+		return "string"
+	case ValueTypeBool:
+		return "bool"
+	case ValueTypeInt:
+		return "int"
+	case ValueTypeDouble:
+		return "double"
+	case ValueTypeArray:
+		return "array"
+	case ValueTypeKvlist:
+		return "kvlist"
+	case ValueTypeBytes:
+		return "bytes"
+	default:
+		return "unknown"
+	}
+}
+
+// ValueType returns the type of the attribute's value. A KeyValue without a
+// value, or with an AnyValue that has no field set, is ValueTypeEmpty.
+func (kv KeyValue) ValueType() (ValueType, error) {
+	value, err := kv.ValueRaw()
+	if err != nil {
+		return 0, err
+	}
+	return anyValueType(value)
+}
+
+// anyValueType returns the type of an AnyValue message: the last oneof field
+// present, as protobuf parsers resolve repeated oneof members.
+func anyValueType(value []byte) (ValueType, error) {
+	typ := ValueTypeEmpty
+	pos := 0
+
+	for pos < len(value) {
+		fieldNum, wireType, tagLen := consumeTag(value[pos:])
+		if tagLen < 0 {
+			return 0, errors.New("malformed protobuf tag in AnyValue")
+		}
+		pos += tagLen
+
+		if fieldNum >= 1 && fieldNum <= 7 {
+			typ = ValueType(fieldNum)
+		}
+
+		n := skipField(value[pos:], wireType)
+		if n < 0 {
+			return 0, errors.New("failed to skip field")
+		}
+		pos += n
+	}
+
+	return typ, nil
+}
+
+// AttributeTypeConflict reports an attribute key that appears with more than
+// one value type within a request.
+type AttributeTypeConflict struct {
+	Key string
+	// Types lists the distinct value types seen, in order of first
+	// appearance.
+	Types []ValueType
+}
+
+// AttributeTypeConflicts returns the attribute keys that appear with more
+// than one value type across the resource, scope and data point attributes
+// of the request, in order of first appearance of the key.
+func (m ExportMetricsServiceRequest) AttributeTypeConflicts() ([]AttributeTypeConflict, error) {
+	return attributeTypeConflicts([]byte(m), func(metric []byte, add func(KeyValue) error) error {
+		for dp, err := range Metric(metric).DataPointsSeq {
+			if err != nil {
+				return err
+			}
+			for kv, err := range dp.AttributesSeq {
+				if err != nil {
+					return err
+				}
+				if err := add(kv); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// AttributeTypeConflicts returns the attribute keys that appear with more
+// than one value type across the resource, scope and log record attributes
+// of the request, in order of first appearance of the key.
+func (l ExportLogsServiceRequest) AttributeTypeConflicts() ([]AttributeTypeConflict, error) {
+	return attributeTypeConflicts([]byte(l), repeatedAttributes(6))
+}
+
+// AttributeTypeConflicts returns the attribute keys that appear with more
+// than one value type across the resource, scope and span attributes of the
+// request, in order of first appearance of the key. Span event and link
+// attributes are not inspected.
+func (t ExportTracesServiceRequest) AttributeTypeConflicts() ([]AttributeTypeConflict, error) {
+	return attributeTypeConflicts([]byte(t), repeatedAttributes(9))
+}
+
+// repeatedAttributes returns a record attribute visitor for records whose
+// attributes are the repeated KeyValue field num.
+func repeatedAttributes(num protowire.Number) func([]byte, func(KeyValue) error) error {
+	return func(record []byte, add func(KeyValue) error) error {
+		return forEachMessage(record, num, func(kv []byte) error {
+			return add(KeyValue(kv))
+		})
+	}
+}
+
+// attributeTypeConflicts walks the resource, scope and record attributes of
+// an export request and reports keys seen with more than one value type.
+// recordAttributes calls add for every attribute of one leaf record.
+func attributeTypeConflicts(data []byte, recordAttributes func(record []byte, add func(KeyValue) error) error) ([]AttributeTypeConflict, error) {
+	types := make(map[string][]ValueType)
+	var keys []string
+	add := func(kv KeyValue) error {
+		key, err := kv.Key()
+		if err != nil {
+			return err
+		}
+		typ, err := kv.ValueType()
+		if err != nil {
+			return err
+		}
+		seen, ok := types[string(key)]
+		if !ok {
+			keys = append(keys, string(key))
+		}
+		if !slices.Contains(seen, typ) {
+			types[string(key)] = append(seen, typ)
+		}
+		return nil
+	}
+	// addFrom adds the repeated KeyValue field num of the sub-message field
+	// msgNum of parent, if present.
+	addFrom := func(parent []byte, msgNum, num protowire.Number) error {
+		msg, err := extractBytesField(parent, msgNum)
+		if err != nil || msg == nil {
+			return err
+		}
+		return repeatedAttributes(num)(msg, add)
+	}
+
+	err := forEachMessage(data, 1, func(resource []byte) error {
+		if err := addFrom(resource, 1, 1); err != nil {
+			return err
+		}
+		return forEachMessage(resource, 2, func(scope []byte) error {
+			if err := addFrom(scope, 1, 3); err != nil {
+				return err
+			}
+			return forEachMessage(scope, 2, func(record []byte) error {
+				return recordAttributes(record, add)
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []AttributeTypeConflict
+	for _, key := range keys {
+		if len(types[key]) > 1 {
+			conflicts = append(conflicts, AttributeTypeConflict{Key: key, Types: types[key]})
+		}
+	}
+	return conflicts, nil
+}
+
+// OTLP AggregationTemporality values.
+const (
+	temporalityDelta      = 1
+	temporalityCumulative = 2
+)
+
+// MixedTemporalityMetrics returns the names of metrics that appear in the
+// request both with delta and with cumulative aggregation temporality, in
+// order of first appearance. Only sums, histograms and exponential
+// histograms carry a temporality; gauges and summaries are ignored.
+func (m ExportMetricsServiceRequest) MixedTemporalityMetrics() ([]string, error) {
+	const sawDelta, sawCumulative = 1, 2
+	seen := make(map[string]int)
+	var order []string
+
+	err := forEachMessage([]byte(m), 1, func(rm []byte) error {
+		return forEachMessage(rm, 2, func(sm []byte) error {
+			return forEachMessage(sm, 2, func(metric []byte) error {
+				typ, err := metricBodyType(metric)
+				if err != nil {
+					return err
+				}
+				if typ != MetricTypeSum && typ != MetricTypeHistogram && typ != MetricTypeExponentialHistogram {
+					return nil
+				}
+				body, err := extractBytesField(metric, protowire.Number(typ))
+				if err != nil {
+					return err
+				}
+				temporality, err := extractVarintField(body, 2)
+				if err != nil {
+					return err
+				}
+				var mark int
+				switch temporality {
+				case temporalityDelta:
+					mark = sawDelta
+				case temporalityCumulative:
+					mark = sawCumulative
+				default:
+					return nil
+				}
+				name, err := extractBytesField(metric, 1)
+				if err != nil {
+					return err
+				}
+				before, ok := seen[string(name)]
+				if !ok {
+					order = append(order, string(name))
+				}
+				seen[string(name)] = before | mark
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range order {
+		if seen[name] == sawDelta|sawCumulative {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
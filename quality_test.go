@@ -0,0 +1,145 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestKeyValue_ValueType(t *testing.T) {
+	logs := plog.NewLogs()
+	attrs := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes()
+	attrs.PutStr("s", "v")
+	attrs.PutBool("b", true)
+	attrs.PutInt("i", 1)
+	attrs.PutDouble("d", 1.5)
+	attrs.PutEmptySlice("a").AppendEmpty().SetStr("x")
+	attrs.PutEmptyMap("m").PutStr("k", "v")
+	attrs.PutEmptyBytes("y").FromRaw([]byte{1})
+	attrs.PutEmpty("e")
+	data := marshalLogs(t, logs)
+
+	var got []string
+	require.NoError(t, forEachNested(data, append(logRecordPath, 6), func(kv []byte) bool {
+		typ, err := KeyValue(kv).ValueType()
+		require.NoError(t, err)
+		got = append(got, typ.String())
+		return true
+	}))
+	require.Equal(t, []string{"string", "bool", "int", "double", "array", "kvlist", "bytes", "empty"}, got)
+}
+
+func TestAttributeTypeConflicts_Traces(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "svc")
+	rs.Resource().Attributes().PutStr("http.status_code", "200")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().Attributes().PutInt("service.name", 1)
+	span := ss.Spans().AppendEmpty()
+	span.Attributes().PutInt("http.status_code", 500)
+	span.Attributes().PutStr("consistent", "a")
+	span = ss.Spans().AppendEmpty()
+	span.Attributes().PutDouble("http.status_code", 404)
+	span.Attributes().PutStr("consistent", "b")
+
+	conflicts, err := ExportTracesServiceRequest(marshalTraces(t, traces)).AttributeTypeConflicts()
+	require.NoError(t, err)
+	require.Equal(t, []AttributeTypeConflict{
+		{Key: "service.name", Types: []ValueType{ValueTypeString, ValueTypeInt}},
+		{Key: "http.status_code", Types: []ValueType{ValueTypeString, ValueTypeInt, ValueTypeDouble}},
+	}, conflicts)
+}
+
+func TestAttributeTypeConflicts_LogsAndMetrics(t *testing.T) {
+	logs := plog.NewLogs()
+	records := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	records.AppendEmpty().Attributes().PutBool("flag", true)
+	records.AppendEmpty().Attributes().PutStr("flag", "true")
+	conflicts, err := ExportLogsServiceRequest(marshalLogs(t, logs)).AttributeTypeConflicts()
+	require.NoError(t, err)
+	require.Equal(t, []AttributeTypeConflict{{Key: "flag", Types: []ValueType{ValueTypeBool, ValueTypeString}}}, conflicts)
+
+	metrics := pmetric.NewMetrics()
+	ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	ms.AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().Attributes().PutInt("code", 1)
+	ms.AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty().Attributes().PutStr("code", "1")
+	ms.AppendEmpty().SetEmptyExponentialHistogram().DataPoints().AppendEmpty().Attributes().PutInt("code", 2)
+	conflicts, err = ExportMetricsServiceRequest(marshalMetrics(t, metrics)).AttributeTypeConflicts()
+	require.NoError(t, err)
+	require.Equal(t, []AttributeTypeConflict{{Key: "code", Types: []ValueType{ValueTypeInt, ValueTypeString}}}, conflicts)
+
+	conflicts, err = ExportMetricsServiceRequest(buildAllTypesMetrics(t)).AttributeTypeConflicts()
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+}
+
+func TestAttributeTypeConflicts_Malformed(t *testing.T) {
+	// Log record attribute whose AnyValue is truncated.
+	value := []byte{0x0a, 0x05}
+	kv := protowire.AppendTag(nil, 1, protowire.BytesType)
+	kv = protowire.AppendString(kv, "k")
+	kv = protowire.AppendTag(kv, 2, protowire.BytesType)
+	kv = protowire.AppendBytes(kv, value)
+	record := protowire.AppendTag(nil, 6, protowire.BytesType)
+	record = protowire.AppendBytes(record, kv)
+	_, err := ExportLogsServiceRequest(wrapRecord(record)).AttributeTypeConflicts()
+	require.Error(t, err)
+
+	_, err = ExportTracesServiceRequest([]byte{0x0a, 0x05}).AttributeTypeConflicts()
+	require.Error(t, err)
+}
+
+func TestMixedTemporalityMetrics(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	add := func(rm pmetric.ResourceMetrics, name string, typ pmetric.MetricType, temporality pmetric.AggregationTemporality) {
+		m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName(name)
+		switch typ {
+		case pmetric.MetricTypeSum:
+			m.SetEmptySum().SetAggregationTemporality(temporality)
+		case pmetric.MetricTypeHistogram:
+			m.SetEmptyHistogram().SetAggregationTemporality(temporality)
+		case pmetric.MetricTypeExponentialHistogram:
+			m.SetEmptyExponentialHistogram().SetAggregationTemporality(temporality)
+		case pmetric.MetricTypeGauge:
+			m.SetEmptyGauge()
+		}
+	}
+	delta, cumulative := pmetric.AggregationTemporalityDelta, pmetric.AggregationTemporalityCumulative
+	rm1 := metrics.ResourceMetrics().AppendEmpty()
+	rm2 := metrics.ResourceMetrics().AppendEmpty()
+	add(rm1, "requests", pmetric.MetricTypeSum, cumulative)
+	add(rm1, "latency", pmetric.MetricTypeHistogram, delta)
+	add(rm1, "steady", pmetric.MetricTypeSum, delta)
+	add(rm1, "queue", pmetric.MetricTypeGauge, 0)
+	add(rm2, "latency", pmetric.MetricTypeExponentialHistogram, cumulative)
+	add(rm2, "steady", pmetric.MetricTypeSum, delta)
+	add(rm2, "requests", pmetric.MetricTypeSum, delta)
+	add(rm2, "requests", pmetric.MetricTypeSum, cumulative)
+	add(rm2, "queue", pmetric.MetricTypeSum, delta)
+
+	names, err := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).MixedTemporalityMetrics()
+	require.NoError(t, err)
+	// "requests" appears first but only turns mixed after "latency" does;
+	// names are reported in order of first appearance.
+	require.Equal(t, []string{"requests", "latency"}, names)
+
+	names, err = ExportMetricsServiceRequest(buildAllTypesMetrics(t)).MixedTemporalityMetrics()
+	require.NoError(t, err)
+	require.Empty(t, names)
+}
+
+func TestMixedTemporalityMetrics_Malformed(t *testing.T) {
+	// Sum with aggregation_temporality encoded as fixed64.
+	sum := protowire.AppendTag(nil, 2, protowire.Fixed64Type)
+	sum = protowire.AppendFixed64(sum, 1)
+	metric := protowire.AppendTag(nil, 7, protowire.BytesType)
+	metric = protowire.AppendBytes(metric, sum)
+	_, err := ExportMetricsServiceRequest(wrapRecord(metric)).MixedTemporalityMetrics()
+	require.Error(t, err)
+}
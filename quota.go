@@ -0,0 +1,238 @@
+package otlpwire
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// QuotaUsage is an amount of telemetry: spans, data points or log records,
+// and encoded bytes.
+type QuotaUsage struct {
+	Items int64
+	Bytes int64
+}
+
+// QuotaConfig configures a QuotaMeter.
+type QuotaConfig struct {
+	// TenantKey is the resource attribute that names the tenant of a
+	// resource, for example tenant.id. A resource without a string value
+	// for it belongs to the tenant "".
+	TenantKey string
+	// Tenant, if set, names the tenant of a Resource message instead of
+	// TenantKey, for example from a fingerprint of its attributes. An entry
+	// without a Resource message passes an empty one.
+	Tenant func(resource []byte) (string, error)
+	// Window is the length of the rolling window usage is measured over.
+	// Defaults to one minute.
+	Window time.Duration
+	// Buckets is the number of intervals the window is divided into; usage
+	// expires one interval at a time. Defaults to 6.
+	Buckets int
+	// DefaultLimit is the usage a tenant may reach within the window. A zero
+	// field is unlimited.
+	DefaultLimit QuotaUsage
+	// Limits overrides DefaultLimit per tenant.
+	Limits map[string]QuotaUsage
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// QuotaMeter accounts the telemetry of requests to tenants over a rolling
+// window, so that admission control and billing can share one wire-level
+// count. Each resource is accounted to its tenant with its item count and
+// the encoded size of its Resource* entry. A QuotaMeter is safe for
+// concurrent use.
+type QuotaMeter struct {
+	cfg   QuotaConfig
+	width time.Duration
+
+	mu        sync.Mutex
+	tenants   map[string]*quotaWindow
+	lastPrune int64
+}
+
+// quotaWindow is a ring of per-interval usage. epochs holds the interval
+// number each slot was last written for.
+type quotaWindow struct {
+	usage  []QuotaUsage
+	epochs []int64
+}
+
+// NewQuotaMeter returns a QuotaMeter with no usage.
+func NewQuotaMeter(cfg QuotaConfig) *QuotaMeter {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = 6
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &QuotaMeter{
+		cfg:     cfg,
+		width:   max(cfg.Window/time.Duration(cfg.Buckets), 1),
+		tenants: make(map[string]*quotaWindow),
+	}
+}
+
+// AddTraces accounts the spans of req.
+func (m *QuotaMeter) AddTraces(req ExportTracesServiceRequest) error {
+	return m.add(req, countInResourceSpans)
+}
+
+// AddMetrics accounts the data points of req.
+func (m *QuotaMeter) AddMetrics(req ExportMetricsServiceRequest) error {
+	return m.add(req, countInResourceMetrics)
+}
+
+// AddLogs accounts the log records of req.
+func (m *QuotaMeter) AddLogs(req ExportLogsServiceRequest) error {
+	return m.add(req, countInResourceLogs)
+}
+
+// add parses req before taking the lock, so that a malformed request
+// accounts nothing.
+func (m *QuotaMeter) add(req []byte, count func([]byte) (int, error)) error {
+	type entry struct {
+		tenant string
+		usage  QuotaUsage
+	}
+	var entries []entry
+	err := forEachMessage(req, 1, func(rx []byte) error {
+		resource, err := extractBytesField(rx, 1)
+		if err != nil {
+			return err
+		}
+		tenant, err := m.tenant(resource)
+		if err != nil {
+			return err
+		}
+		items, err := count(rx)
+		if err != nil {
+			return err
+		}
+		size := protowire.SizeTag(1) + protowire.SizeBytes(len(rx))
+		entries = append(entries, entry{tenant, QuotaUsage{Items: int64(items), Bytes: int64(size)}})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	epoch := m.epoch()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range entries {
+		w := m.tenants[e.tenant]
+		if w == nil {
+			w = &quotaWindow{usage: make([]QuotaUsage, m.cfg.Buckets), epochs: make([]int64, m.cfg.Buckets)}
+			m.tenants[e.tenant] = w
+		}
+		slot := w.slot(epoch)
+		if w.epochs[slot] != epoch {
+			w.usage[slot], w.epochs[slot] = QuotaUsage{}, epoch
+		}
+		w.usage[slot].Items += e.usage.Items
+		w.usage[slot].Bytes += e.usage.Bytes
+	}
+	if epoch-m.lastPrune >= int64(m.cfg.Buckets) {
+		m.prune(epoch)
+	}
+	return nil
+}
+
+// tenant names the tenant of a Resource message.
+func (m *QuotaMeter) tenant(resource []byte) (string, error) {
+	if m.cfg.Tenant != nil {
+		return m.cfg.Tenant(resource)
+	}
+	if m.cfg.TenantKey == "" {
+		return "", nil
+	}
+	value, _, err := stringAttribute(resource, 1, m.cfg.TenantKey)
+	return value, err
+}
+
+// epoch returns the number of the current interval, counted from the Unix
+// epoch and rounded down, so that earlier times get negative numbers.
+func (m *QuotaMeter) epoch() int64 {
+	ns, width := m.cfg.Now().UnixNano(), int64(m.width)
+	e := ns / width
+	if ns%width < 0 {
+		e--
+	}
+	return e
+}
+
+// prune forgets the tenants without usage in the window. m.mu must be held.
+func (m *QuotaMeter) prune(epoch int64) {
+	for tenant, w := range m.tenants {
+		if w.sum(epoch) == (QuotaUsage{}) {
+			delete(m.tenants, tenant)
+		}
+	}
+	m.lastPrune = epoch
+}
+
+// slot returns the index of the interval epoch in the ring. Epochs before
+// the Unix epoch are negative and wrap around like the others.
+func (w *quotaWindow) slot(epoch int64) int {
+	n := int64(len(w.usage))
+	return int((epoch%n + n) % n)
+}
+
+// sum returns the usage of the intervals of the window ending at epoch.
+func (w *quotaWindow) sum(epoch int64) QuotaUsage {
+	var total QuotaUsage
+	for i, e := range w.epochs {
+		if e > epoch-int64(len(w.epochs)) && e <= epoch {
+			total.Items += w.usage[i].Items
+			total.Bytes += w.usage[i].Bytes
+		}
+	}
+	return total
+}
+
+// Usage returns the usage of tenant within the window.
+func (m *QuotaMeter) Usage(tenant string) QuotaUsage {
+	epoch := m.epoch()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w := m.tenants[tenant]; w != nil {
+		return w.sum(epoch)
+	}
+	return QuotaUsage{}
+}
+
+// Limit returns the limit of tenant: its entry in Limits, or DefaultLimit.
+func (m *QuotaMeter) Limit(tenant string) QuotaUsage {
+	if l, ok := m.cfg.Limits[tenant]; ok {
+		return l
+	}
+	return m.cfg.DefaultLimit
+}
+
+// OverQuota reports whether the usage of tenant within the window exceeds
+// either of its limits.
+func (m *QuotaMeter) OverQuota(tenant string) bool {
+	usage, limit := m.Usage(tenant), m.Limit(tenant)
+	return limit.Items > 0 && usage.Items > limit.Items ||
+		limit.Bytes > 0 && usage.Bytes > limit.Bytes
+}
+
+// Snapshot returns the usage within the window of every tenant that has
+// any.
+func (m *QuotaMeter) Snapshot() map[string]QuotaUsage {
+	epoch := m.epoch()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(epoch)
+	out := make(map[string]QuotaUsage, len(m.tenants))
+	for tenant, w := range m.tenants {
+		out[tenant] = w.sum(epoch)
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package otlpwire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestQuotaMeter(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for tenant, spans := range map[string]int{"a": 3, "b": 1} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("tenant.id", tenant)
+		ss := rs.ScopeSpans().AppendEmpty()
+		for range spans {
+			ss.Spans().AppendEmpty().SetName("op")
+		}
+	}
+	// A resource without the tenant attribute.
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	req := ExportTracesServiceRequest(marshalTraces(t, traces))
+
+	now := time.Unix(1000, 0)
+	m := NewQuotaMeter(QuotaConfig{
+		TenantKey:    "tenant.id",
+		Window:       time.Minute,
+		Buckets:      6,
+		DefaultLimit: QuotaUsage{Items: 4},
+		Limits:       map[string]QuotaUsage{"b": {Bytes: 1 << 20}},
+		Now:          func() time.Time { return now },
+	})
+	require.NoError(t, m.AddTraces(req))
+
+	a := m.Usage("a")
+	require.Equal(t, int64(3), a.Items)
+	require.Positive(t, a.Bytes)
+	require.Equal(t, int64(1), m.Usage("b").Items)
+	require.Equal(t, int64(1), m.Usage("").Items)
+	require.Zero(t, m.Usage("c"))
+	require.Equal(t, len(req), int(a.Bytes+m.Usage("b").Bytes+m.Usage("").Bytes))
+	require.False(t, m.OverQuota("a"))
+
+	now = now.Add(30 * time.Second)
+	require.NoError(t, m.AddTraces(req))
+	require.Equal(t, int64(6), m.Usage("a").Items)
+	require.True(t, m.OverQuota("a"))
+	require.False(t, m.OverQuota("b"), "b has no item limit")
+
+	// The first add leaves the window after a minute, the second after 90s.
+	now = now.Add(40 * time.Second)
+	require.Equal(t, int64(3), m.Usage("a").Items)
+	require.False(t, m.OverQuota("a"))
+	now = now.Add(30 * time.Second)
+	require.Empty(t, m.Snapshot())
+}
+
+func TestQuotaMeter_BeforeUnixEpoch(t *testing.T) {
+	traces := ptrace.NewTraces()
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	req := ExportTracesServiceRequest(marshalTraces(t, traces))
+
+	now := time.Unix(-95, 0)
+	m := NewQuotaMeter(QuotaConfig{Window: time.Minute, Buckets: 6, Now: func() time.Time { return now }})
+	require.NoError(t, m.AddTraces(req))
+	now = now.Add(20 * time.Second)
+	require.NoError(t, m.AddTraces(req))
+	require.Equal(t, int64(2), m.Usage("").Items)
+	now = now.Add(50 * time.Second)
+	require.Equal(t, int64(1), m.Usage("").Items)
+}
+
+func TestQuotaMeter_ResourceFieldAbsent(t *testing.T) {
+	// A ResourceSpans that omits the optional Resource field.
+	scopeSpans := protowire.AppendTag(nil, 2, protowire.BytesType)
+	scopeSpans = protowire.AppendBytes(scopeSpans, nil)
+	rs := protowire.AppendTag(nil, 2, protowire.BytesType)
+	rs = protowire.AppendBytes(rs, scopeSpans)
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendBytes(req, rs)
+
+	m := NewQuotaMeter(QuotaConfig{TenantKey: "tenant.id"})
+	require.NoError(t, m.AddTraces(req))
+	require.Equal(t, QuotaUsage{Items: 1, Bytes: int64(len(req))}, m.Usage(""))
+}
+
+func TestQuotaMeter_TenantFunc(t *testing.T) {
+	logs := plog.NewLogs()
+	for _, service := range []string{"api", "api", "db"} {
+		rl := logs.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("service.name", service)
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("x")
+	}
+
+	m := NewQuotaMeter(QuotaConfig{Tenant: func(resource []byte) (string, error) {
+		name, _, err := stringAttribute(resource, 1, "service.name")
+		return "svc/" + name, err
+	}})
+	require.NoError(t, m.AddLogs(ExportLogsServiceRequest(marshalLogs(t, logs))))
+	snap := m.Snapshot()
+	require.Len(t, snap, 2)
+	require.Equal(t, int64(2), snap["svc/api"].Items)
+	require.Equal(t, int64(1), snap["svc/db"].Items)
+
+	require.Error(t, m.AddMetrics(ExportMetricsServiceRequest{0x0a, 0x05}))
+	require.Len(t, m.Snapshot(), 2)
+}
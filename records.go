@@ -0,0 +1,90 @@
+package otlpwire
+
+import "iter"
+
+// ScopedSpan is a Span together with the ResourceSpans and ScopeSpans that
+// enclose it.
+type ScopedSpan struct {
+	Resource ResourceSpans
+	Scope    ScopeSpans
+	Span     Span
+}
+
+// AllSpans returns an iterator over every Span of the batch in wire order,
+// each with its enclosing resource and scope entries.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) AllSpans() (iter.Seq[ScopedSpan], func() error) {
+	var iterErr error
+	seq := func(yield func(ScopedSpan) bool) {
+		iterErr = forEachScopedItem(t, func(resource, scope, span []byte) bool {
+			return yield(ScopedSpan{ResourceSpans(resource), ScopeSpans(scope), Span(span)})
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// ScopedLogRecord is a LogRecord together with the ResourceLogs and
+// ScopeLogs that enclose it.
+type ScopedLogRecord struct {
+	Resource  ResourceLogs
+	Scope     ScopeLogs
+	LogRecord LogRecord
+}
+
+// AllLogRecords returns an iterator over every LogRecord of the batch in
+// wire order, each with its enclosing resource and scope entries, as
+// ExportTracesServiceRequest.AllSpans.
+func (l ExportLogsServiceRequest) AllLogRecords() (iter.Seq[ScopedLogRecord], func() error) {
+	var iterErr error
+	seq := func(yield func(ScopedLogRecord) bool) {
+		iterErr = forEachScopedItem(l, func(resource, scope, record []byte) bool {
+			return yield(ScopedLogRecord{ResourceLogs(resource), ScopeLogs(scope), LogRecord(record)})
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// ScopedMetric is a Metric together with the ResourceMetrics and
+// ScopeMetrics that enclose it.
+type ScopedMetric struct {
+	Resource ResourceMetrics
+	Scope    ScopeMetrics
+	Metric   Metric
+}
+
+// AllMetrics returns an iterator over every Metric of the batch in wire
+// order, each with its enclosing resource and scope entries, as
+// ExportTracesServiceRequest.AllSpans.
+func (m ExportMetricsServiceRequest) AllMetrics() (iter.Seq[ScopedMetric], func() error) {
+	var iterErr error
+	seq := func(yield func(ScopedMetric) bool) {
+		iterErr = forEachScopedItem(m, func(resource, scope, metric []byte) bool {
+			return yield(ScopedMetric{ResourceMetrics(resource), ScopeMetrics(scope), Metric(metric)})
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// forEachScopedItem calls fn with every item (field 2 of a scope entry) of
+// data together with its resource and scope entries, stopping when fn
+// returns false.
+func forEachScopedItem(data []byte, fn func(resource, scope, item []byte) bool) error {
+	var err error
+	scopeErr := forEachScope(data, func(resource, scope []byte) bool {
+		cont := true
+		forEachRepeatedField(scope, 2, func(item []byte, iterErr error) bool {
+			if iterErr != nil {
+				err = iterErr
+				cont = false
+				return false
+			}
+			cont = fn(resource, scope, item)
+			return cont
+		})
+		return cont
+	})
+	if scopeErr != nil {
+		return scopeErr
+	}
+	return err
+}
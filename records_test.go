@@ -0,0 +1,115 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestAllSpans(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for r, scopes := range [][]int{{2, 1}, {1}} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutInt("resource", int64(r))
+		for s, spans := range scopes {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(string(rune('a' + s)))
+			for range spans {
+				ss.Spans().AppendEmpty().SetSpanID(pcommon.SpanID{byte(traces.SpanCount())})
+			}
+		}
+	}
+
+	type seen struct {
+		resource int64
+		scope    string
+		span     byte
+	}
+	var got []seen
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).AllSpans()
+	for s := range seq {
+		resource, err := s.Resource.Resource()
+		require.NoError(t, err)
+		kv, err := extractBytesField(resource, 1)
+		require.NoError(t, err)
+		value, err := extractBytesField(kv, 2)
+		require.NoError(t, err)
+		r, err := extractVarintField(value, 3)
+		require.NoError(t, err)
+		scope, err := s.Scope.ScopeName()
+		require.NoError(t, err)
+		id, err := s.Span.SpanID()
+		require.NoError(t, err)
+		got = append(got, seen{int64(r), string(scope), id[0]})
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []seen{{0, "a", 1}, {0, "a", 2}, {0, "b", 3}, {1, "a", 4}}, got)
+
+	n := 0
+	for range seq {
+		if n++; n == 2 {
+			break
+		}
+	}
+	require.Equal(t, 2, n)
+	require.NoError(t, errFn())
+
+	seq, errFn = ExportTracesServiceRequest{0x0a, 0x04, 0x12, 0x02, 0x12, 0x05}.AllSpans()
+	for range seq {
+		t.Fatal("no span expected")
+	}
+	require.Error(t, errFn())
+}
+
+func TestAllLogRecords(t *testing.T) {
+	logs := plog.NewLogs()
+	for _, service := range []string{"api", "db"} {
+		rl := logs.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("service.name", service)
+		sl := rl.ScopeLogs().AppendEmpty()
+		sl.LogRecords().AppendEmpty().SetSeverityNumber(plog.SeverityNumberInfo)
+		sl.LogRecords().AppendEmpty().SetSeverityNumber(plog.SeverityNumberError)
+	}
+
+	var errorsByService []string
+	seq, errFn := ExportLogsServiceRequest(marshalLogs(t, logs)).AllLogRecords()
+	for r := range seq {
+		severity, err := extractVarintField(r.LogRecord, 2)
+		require.NoError(t, err)
+		if plog.SeverityNumber(severity) < plog.SeverityNumberError {
+			continue
+		}
+		resource, err := r.Resource.Resource()
+		require.NoError(t, err)
+		service, _, err := stringAttribute(resource, 1, "service.name")
+		require.NoError(t, err)
+		errorsByService = append(errorsByService, service)
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{"api", "db"}, errorsByService)
+}
+
+func TestAllMetrics(t *testing.T) {
+	req := ExportMetricsServiceRequest(buildScopedMetrics(t, 2, 2, 1))
+	var got []string
+	seq, errFn := req.AllMetrics()
+	for m := range seq {
+		resource, err := m.Resource.Resource()
+		require.NoError(t, err)
+		service, _, err := stringAttribute(resource, 1, "service.name")
+		require.NoError(t, err)
+		scope, err := m.Scope.ScopeName()
+		require.NoError(t, err)
+		name, err := m.Metric.Name()
+		require.NoError(t, err)
+		got = append(got, service+"/"+string(scope)+"/"+string(name))
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []string{
+		"service-0/scope-0/metric.0.0", "service-0/scope-1/metric.1.0",
+		"service-1/scope-0/metric.0.0", "service-1/scope-1/metric.1.0",
+	}, got)
+}
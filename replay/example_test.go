@@ -0,0 +1,48 @@
+package replay_test
+
+import (
+	"fmt"
+	"testing/fstest"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	otlpwire "go.olly.garden/otlp-wire"
+	"go.olly.garden/otlp-wire/replay"
+)
+
+// ExampleReplayer_Requests demonstrates reading stored requests back in
+// timestamp order: a raw traces file and a file of header-framed logs.
+func ExampleReplayer_Requests() {
+	traces := ptrace.NewTraces()
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	tracesBytes, _ := (&ptrace.ProtoMarshaler{}).MarshalTraces(traces)
+
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	logsBytes, _ := (&plog.ProtoMarshaler{}).MarshalLogs(logs)
+	var framed []byte
+	for range 2 {
+		h, _ := otlpwire.NewHeader(otlpwire.SignalLogs, logsBytes)
+		framed = append(h.AppendTo(framed), logsBytes...)
+	}
+
+	fsys := fstest.MapFS{
+		"traces/0001.pb": {Data: tracesBytes, ModTime: time.Unix(200, 0)},
+		"batch.owf":      {Data: framed, ModTime: time.Unix(100, 0)},
+	}
+	reqs, getErr := replay.New(fsys, replay.Options{}).Requests()
+	for req := range reqs {
+		n, _ := req.ItemCount()
+		fmt.Printf("%s: %s, %d items\n", req.Path, req.Signal, n)
+	}
+	if err := getErr(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	// Output:
+	// batch.owf: logs, 1 items
+	// batch.owf: logs, 1 items
+	// traces/0001.pb: traces, 1 items
+}
@@ -0,0 +1,237 @@
+// Package replay reads stored OTLP export requests back from a directory,
+// for example to backfill a backend after an outage. Files hold a raw
+// export request, a sequence of requests each framed by an otlpwire.Header,
+// or either of those compressed with gzip; the format is detected from the
+// content. Requests are replayed in timestamp order, optionally paced to
+// reproduce the original gaps between them.
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// SendFunc delivers one replayed request. It has the signature of
+// sender.SendFunc, so a sender.Sender's Send method can be used directly.
+type SendFunc func(ctx context.Context, e otlpwire.Envelope) error
+
+// Options configures a Replayer.
+type Options struct {
+	// Signal names the signal of a raw request from the path of its file.
+	// Defaults to the first word of the path that is "traces", "metrics"
+	// or "logs", as in traces/0001.pb or logs-2024-05-01.pb.gz. Framed
+	// requests carry their signal in the header.
+	Signal func(path string) otlpwire.Signal
+	// Time returns the timestamp of the requests of a file. Defaults to
+	// the modification time of the file.
+	Time func(path string, info fs.FileInfo) (time.Time, error)
+	// Speed paces Run: requests are sent with the gaps between their
+	// timestamps divided by Speed, so 1 replays in real time and 10 ten
+	// times faster. Zero sends as fast as possible.
+	Speed float64
+}
+
+// Request is one replayed export request. Envelope.ReceivedAt is the
+// timestamp of its file.
+type Request struct {
+	Path string
+	otlpwire.Envelope
+}
+
+// Replayer replays the files of a file system.
+type Replayer struct {
+	fsys fs.FS
+	opts Options
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New returns a Replayer for the files of fsys. Directories are walked
+// recursively; files and directories whose names start with a dot are
+// skipped.
+func New(fsys fs.FS, opts Options) *Replayer {
+	if opts.Signal == nil {
+		opts.Signal = SignalFromPath
+	}
+	if opts.Time == nil {
+		opts.Time = func(_ string, info fs.FileInfo) (time.Time, error) { return info.ModTime(), nil }
+	}
+	return &Replayer{fsys: fsys, opts: opts, now: time.Now, sleep: sleepContext}
+}
+
+// SignalFromPath returns the signal named by the first word of path that is
+// "traces", "metrics" or "logs", ignoring case, or SignalUnspecified if there
+// is none. Words are runs of letters.
+func SignalFromPath(path string) otlpwire.Signal {
+	words := strings.FieldsFunc(path, func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, w := range words {
+		for _, s := range []otlpwire.Signal{otlpwire.SignalTraces, otlpwire.SignalMetrics, otlpwire.SignalLogs} {
+			if strings.EqualFold(w, s.String()) {
+				return s
+			}
+		}
+	}
+	return otlpwire.SignalUnspecified
+}
+
+// file is a payload file to replay.
+type file struct {
+	path string
+	time time.Time
+}
+
+// Requests iterates over the requests of every file in timestamp order;
+// files with equal timestamps are in lexical order, and the requests of a
+// file in file order. Files are listed up front but read one at a time. The
+// returned function should be called after iteration to check for errors.
+func (r *Replayer) Requests() (iter.Seq[Request], func() error) {
+	var iterErr error
+	seq := func(yield func(Request) bool) {
+		iterErr = nil
+		files, err := r.list()
+		if err != nil {
+			iterErr = err
+			return
+		}
+		for _, f := range files {
+			reqs, err := r.read(f)
+			if err != nil {
+				iterErr = fmt.Errorf("replay: %s: %w", f.path, err)
+				return
+			}
+			for _, req := range reqs {
+				if !yield(req) {
+					return
+				}
+			}
+		}
+	}
+	return seq, func() error { return iterErr }
+}
+
+// Run sends every request with send, in the order of Requests and paced by
+// Options.Speed. It stops at the first error, which names the file of the
+// failed request, or when ctx is done.
+func (r *Replayer) Run(ctx context.Context, send SendFunc) error {
+	seq, errFn := r.Requests()
+	var start, first time.Time
+	for req := range seq {
+		if r.opts.Speed > 0 {
+			if start.IsZero() {
+				start, first = r.now(), req.ReceivedAt
+			}
+			due := start.Add(time.Duration(float64(req.ReceivedAt.Sub(first)) / r.opts.Speed))
+			if wait := due.Sub(r.now()); wait > 0 {
+				if err := r.sleep(ctx, wait); err != nil {
+					return err
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(ctx, req.Envelope); err != nil {
+			return fmt.Errorf("replay: %s: %w", req.Path, err)
+		}
+	}
+	return errFn()
+}
+
+// list returns the files of the file system sorted by timestamp.
+func (r *Replayer) list() ([]file, error) {
+	var files []file
+	err := fs.WalkDir(r.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		t, err := r.opts.Time(path, info)
+		if err != nil {
+			return fmt.Errorf("replay: %s: %w", path, err)
+		}
+		files = append(files, file{path: path, time: t})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// WalkDir visits files in lexical order, which the stable sort keeps
+	// for equal timestamps.
+	slices.SortStableFunc(files, func(a, b file) int { return a.time.Compare(b.time) })
+	return files, nil
+}
+
+// read reads and decodes the requests of f.
+func (r *Replayer) read(f file) ([]Request, error) {
+	data, err := fs.ReadFile(r.fsys, f.path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	// A raw export request cannot start with 'o': as a tag byte it would
+	// have wire type 7, which does not exist.
+	if !bytes.HasPrefix(data, []byte("ow")) {
+		signal := r.opts.Signal(f.path)
+		if signal == otlpwire.SignalUnspecified {
+			return nil, errors.New("cannot tell the signal of a raw request from its path")
+		}
+		return []Request{{Path: f.path, Envelope: otlpwire.Envelope{Signal: signal, Payload: data, ReceivedAt: f.time}}}, nil
+	}
+
+	var reqs []Request
+	for len(data) > 0 {
+		h, payload, rest, err := otlpwire.ParseHeaderPrefix(data)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, Request{Path: f.path, Envelope: otlpwire.Envelope{Signal: h.Signal, Payload: payload, ReceivedAt: f.time}})
+		data = rest
+	}
+	return reqs, nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
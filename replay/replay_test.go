@@ -0,0 +1,155 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+func payloads(t *testing.T) (traces, metrics, logs []byte) {
+	t.Helper()
+	td := ptrace.NewTraces()
+	td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("op")
+	traces, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(td)
+	require.NoError(t, err)
+
+	md := pmetric.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	metrics, err = (&pmetric.ProtoMarshaler{}).MarshalMetrics(md)
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("x")
+	logs, err = (&plog.ProtoMarshaler{}).MarshalLogs(ld)
+	require.NoError(t, err)
+	return traces, metrics, logs
+}
+
+func framed(t *testing.T, signal otlpwire.Signal, payloads ...[]byte) []byte {
+	t.Helper()
+	var out []byte
+	for _, p := range payloads {
+		h, err := otlpwire.NewHeader(signal, p)
+		require.NoError(t, err)
+		out = append(h.AppendTo(out), p...)
+	}
+	return out
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func testFS(t *testing.T) fstest.MapFS {
+	traces, metrics, logs := payloads(t)
+	t0 := time.Unix(1000, 0)
+	return fstest.MapFS{
+		"traces/b.pb":            {Data: traces, ModTime: t0.Add(2 * time.Second)},
+		"traces/a.pb":            {Data: traces, ModTime: t0.Add(2 * time.Second)},
+		"metrics-0001.pb.gz":     {Data: gzipped(t, metrics), ModTime: t0},
+		"spool/batch.bin":        {Data: framed(t, otlpwire.SignalLogs, logs, logs), ModTime: t0.Add(5 * time.Second)},
+		"spool/batch2.bin.gz":    {Data: gzipped(t, framed(t, otlpwire.SignalTraces, traces)), ModTime: t0.Add(6 * time.Second)},
+		".partial/logs-9.pb":     {Data: []byte("garbage"), ModTime: t0},
+		"traces/.in-progress.pb": {Data: []byte("garbage"), ModTime: t0},
+	}
+}
+
+type replayed struct {
+	path   string
+	signal otlpwire.Signal
+	items  int
+}
+
+func TestReplayer_Requests(t *testing.T) {
+	r := New(testFS(t), Options{})
+	seq, errFn := r.Requests()
+	var got []replayed
+	for req := range seq {
+		n, err := req.ItemCount()
+		require.NoError(t, err)
+		got = append(got, replayed{req.Path, req.Signal, n})
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []replayed{
+		{"metrics-0001.pb.gz", otlpwire.SignalMetrics, 1},
+		{"traces/a.pb", otlpwire.SignalTraces, 1},
+		{"traces/b.pb", otlpwire.SignalTraces, 1},
+		{"spool/batch.bin", otlpwire.SignalLogs, 1},
+		{"spool/batch.bin", otlpwire.SignalLogs, 1},
+		{"spool/batch2.bin.gz", otlpwire.SignalTraces, 1},
+	}, got)
+}
+
+func TestReplayer_Errors(t *testing.T) {
+	traces, _, logs := payloads(t)
+	for name, data := range map[string][]byte{
+		"unknown signal":   traces,
+		"truncated frame":  framed(t, otlpwire.SignalLogs, logs)[:otlpwire.HeaderSize+1],
+		"truncated header": []byte("ow\x01"),
+		"bad gzip":         {0x1f, 0x8b, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := New(fstest.MapFS{"data.bin": {Data: data}}, Options{})
+			seq, errFn := r.Requests()
+			for range seq {
+				t.Fatal("no request expected")
+			}
+			require.ErrorContains(t, errFn(), "data.bin")
+		})
+	}
+}
+
+func TestReplayer_Run(t *testing.T) {
+	r := New(testFS(t), Options{Speed: 2})
+	var waits []time.Duration
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	var sent []otlpwire.Signal
+	require.NoError(t, r.Run(context.Background(), func(_ context.Context, e otlpwire.Envelope) error {
+		sent = append(sent, e.Signal)
+		now = now.Add(100 * time.Millisecond) // time spent sending
+		return nil
+	}))
+	require.Len(t, sent, 6)
+	// The original gaps of 2s, 3s and 1s, halved, minus the time spent
+	// sending since the request was due.
+	require.Equal(t, []time.Duration{900 * time.Millisecond, 1300 * time.Millisecond, 300 * time.Millisecond}, waits)
+
+	boom := errors.New("boom")
+	err := r.Run(context.Background(), func(context.Context, otlpwire.Envelope) error { return boom })
+	require.ErrorIs(t, err, boom)
+	require.ErrorContains(t, err, "metrics-0001.pb.gz")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, r.Run(ctx, func(context.Context, otlpwire.Envelope) error { return nil }), context.Canceled)
+}
+
+func TestSignalFromPath(t *testing.T) {
+	require.Equal(t, otlpwire.SignalLogs, SignalFromPath("backfill/2024/LOGS_01.pb"))
+	require.Equal(t, otlpwire.SignalMetrics, SignalFromPath("metrics/traces.pb"))
+	require.Equal(t, otlpwire.SignalUnspecified, SignalFromPath("tracesdir/x.pb"))
+}
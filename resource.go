@@ -0,0 +1,131 @@
+package otlpwire
+
+import (
+	"bytes"
+	"hash/fnv"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Fingerprint returns a 64-bit FNV-1a hash of the resource that does not
+// depend on the order of its attributes, so that producers encoding the
+// same attributes in different orders shard alike. The hash is stable
+// across processes and releases; it can fill Header.Fingerprint.
+func (r Resource) Fingerprint() (uint64, error) {
+	key, err := appendResourceKey(nil, r)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write(key)
+	return h.Sum64(), nil
+}
+
+// ResourceEqual reports whether two Resource messages are semantically
+// equal: the same attribute KeyValues regardless of order, and the same
+// dropped_attributes_count and other fields.
+func ResourceEqual(a, b []byte) (bool, error) {
+	keyA, err := appendResourceKey(nil, a)
+	if err != nil {
+		return false, err
+	}
+	keyB, err := appendResourceKey(nil, b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(keyA, keyB), nil
+}
+
+// AttributeStats summarizes the attributes of a message.
+type AttributeStats struct {
+	// Count is the number of attributes.
+	Count int
+	// Bytes is the encoded size of the attributes, including their field
+	// tags and length prefixes.
+	Bytes int
+}
+
+// AttributeStats returns the number and encoded size of the attributes of
+// the resource.
+func (r Resource) AttributeStats() (AttributeStats, error) {
+	var stats AttributeStats
+	err := forEachMessage(r, 1, func(kv []byte) error {
+		stats.Count++
+		stats.Bytes += protowire.SizeTag(1) + protowire.SizeBytes(len(kv))
+		return nil
+	})
+	if err != nil {
+		return AttributeStats{}, err
+	}
+	return stats, nil
+}
+
+// ResourceAttributeStats returns the AttributeStats of the Resource of each
+// ResourceSpans, in order, to flag resources bloated by attributes before
+// they reach storage. A ResourceSpans without a Resource has zero stats.
+func (t ExportTracesServiceRequest) ResourceAttributeStats() ([]AttributeStats, error) {
+	return resourceAttributeStats(t)
+}
+
+// ResourceAttributeStats returns the AttributeStats of the Resource of each
+// ResourceMetrics, as ExportTracesServiceRequest.ResourceAttributeStats.
+func (m ExportMetricsServiceRequest) ResourceAttributeStats() ([]AttributeStats, error) {
+	return resourceAttributeStats(m)
+}
+
+// ResourceAttributeStats returns the AttributeStats of the Resource of each
+// ResourceLogs, as ExportTracesServiceRequest.ResourceAttributeStats.
+func (l ExportLogsServiceRequest) ResourceAttributeStats() ([]AttributeStats, error) {
+	return resourceAttributeStats(l)
+}
+
+func resourceAttributeStats(data []byte) ([]AttributeStats, error) {
+	var out []AttributeStats
+	err := forEachMessage(data, 1, func(entry []byte) error {
+		resource, err := extractBytesField(entry, 1)
+		if err != nil {
+			return err
+		}
+		stats, err := Resource(resource).AttributeStats()
+		out = append(out, stats)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// appendResourceKey appends a canonical encoding of a Resource message to
+// dst: its attribute KeyValues (field 1) sorted bytewise, then its other
+// fields verbatim. Two resources have equal keys exactly when they hold the
+// same attributes in any order and the same other fields.
+func appendResourceKey(dst, resource []byte) ([]byte, error) {
+	dst, err := appendAttributesKey(dst, resource, 1)
+	if err != nil {
+		return nil, err
+	}
+	return appendFieldsExcept(dst, resource, 1)
+}
+
+// appendAttributesKey appends the KeyValue fields num of msg sorted by their
+// encoding and length-prefixed, which is equal for two messages exactly
+// when they hold the same attributes in any order.
+func appendAttributesKey(dst, msg []byte, num protowire.Number) ([]byte, error) {
+	var attrs [][]byte
+	err := forEachMessage(msg, num, func(kv []byte) error {
+		attrs = append(attrs, kv)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(attrs, bytes.Compare)
+
+	dst = protowire.AppendVarint(dst, uint64(len(attrs)))
+	for _, kv := range attrs {
+		dst = protowire.AppendBytes(dst, kv)
+	}
+	return dst, nil
+}
@@ -0,0 +1,114 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// marshalResources returns the Resource messages of one ResourceSpans per
+// attribute map, with attributes inserted in the given order.
+func marshalResources(t *testing.T, attrs ...[][2]string) []Resource {
+	t.Helper()
+	traces := ptrace.NewTraces()
+	for _, kvs := range attrs {
+		m := traces.ResourceSpans().AppendEmpty().Resource().Attributes()
+		for _, kv := range kvs {
+			m.PutStr(kv[0], kv[1])
+		}
+	}
+	var out []Resource
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range seq {
+		r, err := rs.Resource()
+		require.NoError(t, err)
+		out = append(out, r)
+	}
+	require.NoError(t, errFn())
+	return out
+}
+
+func TestResource_Fingerprint(t *testing.T) {
+	rs := marshalResources(t,
+		[][2]string{{"service.name", "api"}, {"host.name", "a"}},
+		[][2]string{{"host.name", "a"}, {"service.name", "api"}},
+		[][2]string{{"service.name", "api"}, {"host.name", "b"}},
+		nil,
+	)
+	fp := make([]uint64, len(rs))
+	for i, r := range rs {
+		var err error
+		fp[i], err = r.Fingerprint()
+		require.NoError(t, err)
+	}
+	require.Equal(t, fp[0], fp[1], "attribute order does not matter")
+	require.NotEqual(t, fp[0], fp[2])
+	require.NotEqual(t, fp[0], fp[3])
+
+	// The dropped attributes count is part of the resource.
+	dropped := pcommon.NewResource()
+	dropped.SetDroppedAttributesCount(1)
+	traces := ptrace.NewTraces()
+	dropped.CopyTo(traces.ResourceSpans().AppendEmpty().Resource())
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for r := range seq {
+		raw, err := r.Resource()
+		require.NoError(t, err)
+		got, err := Resource(raw).Fingerprint()
+		require.NoError(t, err)
+		require.NotEqual(t, fp[3], got)
+	}
+	require.NoError(t, errFn())
+
+	_, err := Resource{0x0a, 0x05}.Fingerprint()
+	require.Error(t, err)
+}
+
+func TestResourceEqual(t *testing.T) {
+	rs := marshalResources(t,
+		[][2]string{{"service.name", "api"}, {"host.name", "a"}},
+		[][2]string{{"host.name", "a"}, {"service.name", "api"}},
+		[][2]string{{"service.name", "api"}},
+	)
+	equal, err := ResourceEqual(rs[0], rs[1])
+	require.NoError(t, err)
+	require.True(t, equal)
+	equal, err = ResourceEqual(rs[0], rs[2])
+	require.NoError(t, err)
+	require.False(t, equal)
+
+	withDropped := protowire.AppendVarint(protowire.AppendTag(append([]byte(nil), rs[2]...), 2, protowire.VarintType), 3)
+	equal, err = ResourceEqual(rs[2], withDropped)
+	require.NoError(t, err)
+	require.False(t, equal)
+
+	_, err = ResourceEqual(rs[0], []byte{0x0a, 0x05})
+	require.Error(t, err)
+}
+
+func TestResourceAttributeStats(t *testing.T) {
+	traces := ptrace.NewTraces()
+	attrs := traces.ResourceSpans().AppendEmpty().Resource().Attributes()
+	attrs.PutStr("service.name", "api")
+	attrs.PutStr("k8s.pod.annotation", string(make([]byte, 200)))
+	traces.ResourceSpans().AppendEmpty()
+
+	stats, err := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceAttributeStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	require.Equal(t, 2, stats[0].Count)
+	// Each KeyValue: tag and length, key, and AnyValue with a string.
+	kvSize := func(key string, value int) int {
+		anyValue := 1 + protowire.SizeBytes(value)
+		kv := 1 + protowire.SizeBytes(len(key)) + 1 + protowire.SizeBytes(anyValue)
+		return 1 + protowire.SizeBytes(kv)
+	}
+	require.Equal(t, kvSize("service.name", 3)+kvSize("k8s.pod.annotation", 200), stats[0].Bytes)
+	require.Zero(t, stats[1])
+
+	_, err = ExportLogsServiceRequest{0x0a, 0x02, 0x0a, 0x05}.ResourceAttributeStats()
+	require.Error(t, err)
+}
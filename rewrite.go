@@ -0,0 +1,255 @@
+package otlpwire
+
+import (
+	"errors"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// recordRewriter appends the rewritten form of record to dst. Returning
+// false drops the record; dst is then discarded by the caller.
+type recordRewriter func(dst, record []byte) ([]byte, bool, error)
+
+// filterRecords rebuilds an export request keeping only the leaf records at
+// the end of path (see forEachNested) for which keep returns true. It is
+// rewriteRecords with a rewriter that copies kept records unchanged, so the
+// output is never larger than data.
+func filterRecords(data []byte, path []protowire.Number, keep func([]byte) (bool, error)) ([]byte, error) {
+	return appendFiltered(make([]byte, 0, len(data)), data, path, keep)
+}
+
+// appendFiltered is filterRecords appending its output to dst.
+func appendFiltered(dst, data []byte, path []protowire.Number, keep func([]byte) (bool, error)) ([]byte, error) {
+	out, _, err := appendRewritten(dst, data, path, func(dst, record []byte) ([]byte, bool, error) {
+		ok, err := keep(record)
+		if err != nil || !ok {
+			return dst, false, err
+		}
+		return append(dst, record...), true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rewriteRecords rebuilds an export request, replacing every leaf record at
+// the end of path with the output of rewrite. All other fields are copied
+// verbatim and in their original order. Scope and resource entries left
+// without any record are dropped, so the output never carries empty
+// envelopes.
+func rewriteRecords(data []byte, path []protowire.Number, rewrite recordRewriter) ([]byte, error) {
+	out, _, err := appendRewritten(make([]byte, 0, len(data)), data, path, rewrite)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rewriteValues is rewriteRecords for rewrites that only change record
+// values: scope and resource entries without any record are copied through
+// instead of dropped, so the output keeps the structure of data.
+func rewriteValues(data []byte, path []protowire.Number, rewrite recordRewriter) ([]byte, error) {
+	out, _, err := appendRewrittenEntries(make([]byte, 0, len(data)), data, path, rewrite, true)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// appendRewritten appends a rewritten copy of msg to dst and returns the new
+// buffer along with the number of leaf records kept.
+func appendRewritten(dst, msg []byte, path []protowire.Number, rewrite recordRewriter) ([]byte, int, error) {
+	return appendRewrittenEntries(dst, msg, path, rewrite, false)
+}
+
+// appendRewrittenEntries is appendRewritten; keepEmpty keeps the entries
+// along path that end up without records instead of dropping them.
+func appendRewrittenEntries(dst, msg []byte, path []protowire.Number, rewrite recordRewriter, keepEmpty bool) ([]byte, int, error) {
+	kept := 0
+	pos := 0
+
+	for pos < len(msg) {
+		fieldStart := pos
+		num, wireType, tagLen := consumeTag(msg[pos:])
+		if tagLen < 0 {
+			return nil, 0, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if num != path[0] {
+			n := skipField(msg[pos:], wireType)
+			if n < 0 {
+				return nil, 0, errors.New("failed to skip field")
+			}
+			pos += n
+			dst = append(dst, msg[fieldStart:pos]...)
+			continue
+		}
+
+		if wireType != protowire.BytesType {
+			return nil, 0, errors.New("wrong wire type for field")
+		}
+		child, n := consumeBytes(msg[pos:])
+		if n < 0 {
+			return nil, 0, errors.New("invalid bytes in repeated field")
+		}
+		pos += n
+
+		mark := len(dst)
+		childKept := 0
+		var err error
+		dst, err = appendMessageField(dst, num, func(b []byte) ([]byte, error) {
+			if len(path) > 1 {
+				var err error
+				b, childKept, err = appendRewrittenEntries(b, child, path[1:], rewrite, keepEmpty)
+				return b, err
+			}
+			b, ok, err := rewrite(b, child)
+			if ok {
+				childKept = 1
+			}
+			return b, err
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if childKept == 0 && (!keepEmpty || len(path) == 1) {
+			dst = dst[:mark]
+			continue
+		}
+		kept += childKept
+	}
+
+	return dst, kept, nil
+}
+
+// appendMessageField appends a length-delimited field num to dst whose body
+// is produced by build. The length prefix is patched in place once the body
+// size is known, so no intermediate buffer is needed.
+func appendMessageField(dst []byte, num protowire.Number, build func([]byte) ([]byte, error)) ([]byte, error) {
+	dst = protowire.AppendTag(dst, num, protowire.BytesType)
+	lenPos := len(dst)
+	dst = append(dst, 0) // one-byte placeholder for the length varint
+	bodyStart := len(dst)
+
+	dst, err := build(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyLen := len(dst) - bodyStart
+	if extra := protowire.SizeVarint(uint64(bodyLen)) - 1; extra > 0 {
+		dst = append(dst, make([]byte, extra)...)
+		copy(dst[bodyStart+extra:], dst[bodyStart:bodyStart+bodyLen])
+	}
+	protowire.AppendVarint(dst[lenPos:lenPos], uint64(bodyLen))
+	return dst, nil
+}
+
+// appendFieldsExcept appends every field of msg to dst except those whose
+// number is listed in skip. Kept fields are copied verbatim.
+func appendFieldsExcept(dst, msg []byte, skip ...protowire.Number) ([]byte, error) {
+	return appendFieldsWhere(dst, msg, func(num protowire.Number) bool {
+		return !slices.Contains(skip, num)
+	})
+}
+
+// appendFieldsWhere appends every field of msg for which keep returns true to
+// dst, verbatim and in their original order.
+func appendFieldsWhere(dst, msg []byte, keep func(protowire.Number) bool) ([]byte, error) {
+	pos := 0
+
+	for pos < len(msg) {
+		fieldStart := pos
+		num, wireType, tagLen := consumeTag(msg[pos:])
+		if tagLen < 0 {
+			return nil, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		n := skipField(msg[pos:], wireType)
+		if n < 0 {
+			return nil, errors.New("failed to skip field")
+		}
+		pos += n
+
+		if keep(num) {
+			dst = append(dst, msg[fieldStart:pos]...)
+		}
+	}
+
+	return dst, nil
+}
+
+// dataPointRewriter is the recordRewriter of a single data point.
+type dataPointRewriter func(dst []byte, dp DataPoint) ([]byte, bool, error)
+
+// rewriteDataPoints rebuilds a metrics export request, calling forMetric once
+// per Metric and replacing each of its data points with the output of the
+// returned rewriter. A nil rewriter copies the metric unchanged. Metrics,
+// scopes and resources left without data points are dropped.
+func rewriteDataPoints(data []byte, forMetric func(Metric) (dataPointRewriter, error)) ([]byte, error) {
+	return rewriteRecords(data, metricPath, dataPointsRewriter(forMetric, false))
+}
+
+// rewriteDataPointValues is rewriteDataPoints for rewriters that only change
+// data point values: metrics, scopes and resources without data points are
+// copied through instead of dropped.
+func rewriteDataPointValues(data []byte, forMetric func(Metric) (dataPointRewriter, error)) ([]byte, error) {
+	return rewriteValues(data, metricPath, dataPointsRewriter(forMetric, true))
+}
+
+// dataPointsRewriter returns the recordRewriter of a Metric for
+// rewriteDataPoints and rewriteDataPointValues.
+func dataPointsRewriter(forMetric func(Metric) (dataPointRewriter, error), keepEmpty bool) recordRewriter {
+	return func(dst, metric []byte) ([]byte, bool, error) {
+		rewrite, err := forMetric(Metric(metric))
+		if err != nil {
+			return dst, false, err
+		}
+		typ, err := metricBodyType(metric)
+		if err != nil {
+			return dst, false, err
+		}
+		if rewrite == nil || typ == 0 {
+			return append(dst, metric...), true, nil
+		}
+		dst, kept, err := appendRewrittenEntries(dst, metric, []protowire.Number{protowire.Number(typ), 1}, func(dst, dp []byte) ([]byte, bool, error) {
+			return rewrite(dst, DataPoint{raw: dp, typ: typ})
+		}, keepEmpty)
+		return dst, kept > 0 || keepEmpty, err
+	}
+}
+
+// metricBodyType returns the type of the oneof body of a Metric message, or
+// zero if it has none.
+func metricBodyType(metric []byte) (MetricType, error) {
+	var typ MetricType
+	pos := 0
+
+	for pos < len(metric) {
+		fieldNum, wireType, tagLen := consumeTag(metric[pos:])
+		if tagLen < 0 {
+			return 0, errors.New("malformed protobuf tag in metric")
+		}
+		pos += tagLen
+
+		switch t := MetricType(fieldNum); t {
+		case MetricTypeGauge, MetricTypeSum, MetricTypeHistogram, MetricTypeExponentialHistogram, MetricTypeSummary:
+			if wireType != protowire.BytesType {
+				return 0, errors.New("wrong wire type for metric data")
+			}
+			typ = t
+		}
+
+		n := skipField(metric[pos:], wireType)
+		if n < 0 {
+			return 0, errors.New("failed to skip field")
+		}
+		pos += n
+	}
+
+	return typ, nil
+}
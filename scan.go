@@ -0,0 +1,22 @@
+package otlpwire
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.olly.garden/otlp-wire/protoscan"
+)
+
+// The scanning loops of this package decode a tag and usually a length
+// prefix for every field they visit. These helpers are the fast-path
+// replacements for the protowire functions from the protoscan package; see
+// there for details.
+
+func consumeTag(b []byte) (protowire.Number, protowire.Type, int) { return protoscan.ConsumeTag(b) }
+
+func consumeVarint(b []byte) (uint64, int) { return protoscan.ConsumeVarint(b) }
+
+func consumeBytes(b []byte) ([]byte, int) { return protoscan.ConsumeBytes(b) }
+
+// skipField skips a field based on its wire type.
+// Returns the number of bytes skipped. Returns negative value on error.
+func skipField(data []byte, wireType protowire.Type) int { return protoscan.SkipValue(data, wireType) }
@@ -0,0 +1,109 @@
+package otlpwire
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// messageSchema lists the fields of an OTLP message.
+type messageSchema struct {
+	fields map[protowire.Number]schemaField
+}
+
+// schemaField describes one field of an OTLP message.
+type schemaField struct {
+	// typ is the wire type of the field, or of its elements if it is a
+	// packed repeated scalar.
+	typ protowire.Type
+	// packed marks a repeated scalar field, which is length-delimited when
+	// packed and has wire type typ when not.
+	packed bool
+	// message is the schema of a nested message field, nil for any other
+	// field.
+	message *messageSchema
+}
+
+// The schemas of the OTLP export requests, as of OTLP 1.10. They are built
+// by newRequestSchemas because AnyValue and KeyValue refer to each other.
+var tracesSchema, metricsSchema, logsSchema = newRequestSchemas()
+
+func newRequestSchemas() (traces, metrics, logs *messageSchema) {
+	type fields = map[protowire.Number]schemaField
+	var (
+		varint  = schemaField{typ: protowire.VarintType}
+		fixed64 = schemaField{typ: protowire.Fixed64Type}
+		fixed32 = schemaField{typ: protowire.Fixed32Type}
+		bytes   = schemaField{typ: protowire.BytesType} // also strings
+
+		packedVarint  = schemaField{typ: protowire.VarintType, packed: true}
+		packedFixed64 = schemaField{typ: protowire.Fixed64Type, packed: true}
+	)
+	message := func(f fields) schemaField {
+		return schemaField{typ: protowire.BytesType, message: &messageSchema{fields: f}}
+	}
+
+	anyValue := &messageSchema{}
+	keyValue := message(fields{1: bytes, 2: {typ: protowire.BytesType, message: anyValue}, 3: varint})
+	anyValue.fields = fields{
+		1: bytes, 2: varint, 3: varint, 4: fixed64,
+		5: message(fields{1: {typ: protowire.BytesType, message: anyValue}}), // ArrayValue
+		6: message(fields{1: keyValue}),                                      // KeyValueList
+		7: bytes, 8: varint,
+	}
+
+	resource := message(fields{
+		1: keyValue, 2: varint,
+		3: message(fields{1: bytes, 2: bytes, 3: bytes, 4: bytes}), // EntityRef
+	})
+	scope := message(fields{1: bytes, 2: bytes, 3: keyValue, 4: varint})
+	request := func(record schemaField) *messageSchema {
+		scoped := message(fields{1: scope, 2: record, 3: bytes})
+		return &messageSchema{fields: fields{
+			1: message(fields{1: resource, 2: scoped, 3: bytes}),
+		}}
+	}
+
+	span := message(fields{
+		1: bytes, 2: bytes, 3: bytes, 4: bytes, 5: bytes, 6: varint, 7: fixed64, 8: fixed64,
+		9:  keyValue,
+		10: varint,
+		11: message(fields{1: fixed64, 2: bytes, 3: keyValue, 4: varint}), // Event
+		12: varint,
+		13: message(fields{1: bytes, 2: bytes, 3: bytes, 4: keyValue, 5: varint, 6: fixed32}), // Link
+		14: varint,
+		15: message(fields{2: bytes, 3: varint}), // Status
+		16: fixed32,
+	})
+
+	exemplar := message(fields{2: fixed64, 3: fixed64, 4: bytes, 5: bytes, 6: fixed64, 7: keyValue})
+	numberPoint := message(fields{
+		2: fixed64, 3: fixed64, 4: fixed64, 5: exemplar, 6: fixed64, 7: keyValue, 8: varint,
+	})
+	histogramPoint := message(fields{
+		2: fixed64, 3: fixed64, 4: fixed64, 5: fixed64, 6: packedFixed64, 7: packedFixed64,
+		8: exemplar, 9: keyValue, 10: varint, 11: fixed64, 12: fixed64,
+	})
+	buckets := message(fields{1: varint, 2: packedVarint})
+	expHistogramPoint := message(fields{
+		1: keyValue, 2: fixed64, 3: fixed64, 4: fixed64, 5: fixed64, 6: varint, 7: fixed64,
+		8: buckets, 9: buckets, 10: varint, 11: exemplar, 12: fixed64, 13: fixed64, 14: fixed64,
+	})
+	summaryPoint := message(fields{
+		2: fixed64, 3: fixed64, 4: fixed64, 5: fixed64,
+		6: message(fields{1: fixed64, 2: fixed64}), // ValueAtQuantile
+		7: keyValue, 8: varint,
+	})
+	metric := message(fields{
+		1: bytes, 2: bytes, 3: bytes,
+		5:  message(fields{1: numberPoint}),                       // Gauge
+		7:  message(fields{1: numberPoint, 2: varint, 3: varint}), // Sum
+		9:  message(fields{1: histogramPoint, 2: varint}),         // Histogram
+		10: message(fields{1: expHistogramPoint, 2: varint}),      // ExponentialHistogram
+		11: message(fields{1: summaryPoint}),                      // Summary
+		12: keyValue,                                              // metadata
+	})
+
+	logRecord := message(fields{
+		1: fixed64, 2: varint, 3: bytes, 5: {typ: protowire.BytesType, message: anyValue},
+		6: keyValue, 7: varint, 8: fixed32, 9: bytes, 10: bytes, 11: fixed64, 12: bytes,
+	})
+
+	return request(span), request(metric), request(logRecord)
+}
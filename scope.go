@@ -0,0 +1,274 @@
+package otlpwire
+
+import (
+	"iter"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SplitByScope returns an iterator over single-scope requests, one per
+// ScopeSpans of t in wire order. Each keeps the other fields of its
+// enclosing ResourceSpans, such as the Resource and schema_url, verbatim.
+// Resources without scopes yield nothing. Each request is newly allocated.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) SplitByScope() (iter.Seq[ExportTracesServiceRequest], func() error) {
+	var iterErr error
+	seq := func(yield func(ExportTracesServiceRequest) bool) {
+		iterErr = splitByScope(t, func(req []byte) bool {
+			return yield(ExportTracesServiceRequest(req))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// SplitByScope returns an iterator over single-scope requests, as
+// ExportTracesServiceRequest.SplitByScope.
+func (m ExportMetricsServiceRequest) SplitByScope() (iter.Seq[ExportMetricsServiceRequest], func() error) {
+	var iterErr error
+	seq := func(yield func(ExportMetricsServiceRequest) bool) {
+		iterErr = splitByScope(m, func(req []byte) bool {
+			return yield(ExportMetricsServiceRequest(req))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// SplitByScope returns an iterator over single-scope requests, as
+// ExportTracesServiceRequest.SplitByScope.
+func (l ExportLogsServiceRequest) SplitByScope() (iter.Seq[ExportLogsServiceRequest], func() error) {
+	var iterErr error
+	seq := func(yield func(ExportLogsServiceRequest) bool) {
+		iterErr = splitByScope(l, func(req []byte) bool {
+			return yield(ExportLogsServiceRequest(req))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// splitByScope calls yield with a request for every scope entry (field 2) of
+// every resource entry of data, stopping when yield returns false. The
+// scope keeps its position among the fields of the resource entry.
+func splitByScope(data []byte, yield func([]byte) bool) error {
+	var err error
+	forEachRepeatedField(data, 1, func(entry []byte, iterErr error) bool {
+		if iterErr != nil {
+			err = iterErr
+			return false
+		}
+		var scopes int
+		if scopes, err = countOccurrences(entry, 2); err != nil {
+			return false
+		}
+		for i := range scopes {
+			var req []byte
+			req, err = appendMessageField(make([]byte, 0, len(entry)+8), 1, func(dst []byte) ([]byte, error) {
+				seen := 0
+				return appendFieldsWhere(dst, entry, func(num protowire.Number) bool {
+					if num != 2 {
+						return true
+					}
+					seen++
+					return seen == i+1
+				})
+			})
+			if err != nil || !yield(req) {
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// ScopeName returns the name of the InstrumentationScope of this ScopeSpans
+// as a view into the underlying buffer, or nil if the scope or its name is
+// not present.
+func (s ScopeSpans) ScopeName() ([]byte, error) { return scopeField(s, 1) }
+
+// ScopeVersion returns the version of the InstrumentationScope of this
+// ScopeSpans, as ScopeName.
+func (s ScopeSpans) ScopeVersion() ([]byte, error) { return scopeField(s, 2) }
+
+// ScopeName returns the name of the InstrumentationScope of this
+// ScopeMetrics, as ScopeSpans.ScopeName.
+func (s ScopeMetrics) ScopeName() ([]byte, error) { return scopeField(s, 1) }
+
+// ScopeVersion returns the version of the InstrumentationScope of this
+// ScopeMetrics, as ScopeSpans.ScopeName.
+func (s ScopeMetrics) ScopeVersion() ([]byte, error) { return scopeField(s, 2) }
+
+// ScopeName returns the name of the InstrumentationScope of this ScopeLogs,
+// as ScopeSpans.ScopeName.
+func (s ScopeLogs) ScopeName() ([]byte, error) { return scopeField(s, 1) }
+
+// ScopeVersion returns the version of the InstrumentationScope of this
+// ScopeLogs, as ScopeSpans.ScopeName.
+func (s ScopeLogs) ScopeVersion() ([]byte, error) { return scopeField(s, 2) }
+
+// scopeField returns the string field num of the InstrumentationScope
+// (field 1) of a scope-level message.
+func scopeField(msg []byte, num protowire.Number) ([]byte, error) {
+	scope, err := extractBytesField(msg, 1)
+	if err != nil || scope == nil {
+		return nil, err
+	}
+	return extractBytesField(scope, num)
+}
+
+// WrapWithResource returns an export request holding this ScopeSpans in a
+// single ResourceSpans with the given Resource message and schema_url. A nil
+// resource or empty schemaURL omits the field.
+func (s ScopeSpans) WrapWithResource(resource []byte, schemaURL string) ExportTracesServiceRequest {
+	return ExportTracesServiceRequest(wrapWithResource(s, resource, schemaURL))
+}
+
+// WrapWithResource returns an export request holding this ScopeMetrics in a
+// single ResourceMetrics, as ScopeSpans.WrapWithResource.
+func (s ScopeMetrics) WrapWithResource(resource []byte, schemaURL string) ExportMetricsServiceRequest {
+	return ExportMetricsServiceRequest(wrapWithResource(s, resource, schemaURL))
+}
+
+// WrapWithResource returns an export request holding this ScopeLogs in a
+// single ResourceLogs, as ScopeSpans.WrapWithResource.
+func (s ScopeLogs) WrapWithResource(resource []byte, schemaURL string) ExportLogsServiceRequest {
+	return ExportLogsServiceRequest(wrapWithResource(s, resource, schemaURL))
+}
+
+// AppendWithResource appends the request WrapWithResource returns to dst
+// and returns the extended buffer. Pass a pooled buffer to avoid
+// allocating.
+func (s ScopeSpans) AppendWithResource(dst, resource []byte, schemaURL string) []byte {
+	return appendWithResource(dst, s, resource, schemaURL)
+}
+
+// AppendWithResource appends the request WrapWithResource returns to dst,
+// as ScopeSpans.AppendWithResource.
+func (s ScopeMetrics) AppendWithResource(dst, resource []byte, schemaURL string) []byte {
+	return appendWithResource(dst, s, resource, schemaURL)
+}
+
+// AppendWithResource appends the request WrapWithResource returns to dst,
+// as ScopeSpans.AppendWithResource.
+func (s ScopeLogs) AppendWithResource(dst, resource []byte, schemaURL string) []byte {
+	return appendWithResource(dst, s, resource, schemaURL)
+}
+
+// SizeOfAsExportRequest returns the exact encoded size of the request
+// WrapWithResource would return for resource and schemaURL, without
+// building it.
+func (s ScopeSpans) SizeOfAsExportRequest(resource []byte, schemaURL string) int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(wrappedResourceSize(s, resource, schemaURL))
+}
+
+// SizeOfAsExportRequest returns the exact encoded size of the request
+// WrapWithResource would return, as ScopeSpans.SizeOfAsExportRequest.
+func (s ScopeMetrics) SizeOfAsExportRequest(resource []byte, schemaURL string) int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(wrappedResourceSize(s, resource, schemaURL))
+}
+
+// SizeOfAsExportRequest returns the exact encoded size of the request
+// WrapWithResource would return, as ScopeSpans.SizeOfAsExportRequest.
+func (s ScopeLogs) SizeOfAsExportRequest(resource []byte, schemaURL string) int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(wrappedResourceSize(s, resource, schemaURL))
+}
+
+// wrapWithResource encodes an export request with one resource entry
+// holding resource (field 1), scope (field 2) and schemaURL (field 3). The
+// three signals share this layout.
+func wrapWithResource(scope, resource []byte, schemaURL string) []byte {
+	size := wrappedResourceSize(scope, resource, schemaURL)
+	return appendWithResource(make([]byte, 0, protowire.SizeTag(1)+protowire.SizeBytes(size)), scope, resource, schemaURL)
+}
+
+// appendWithResource appends the request wrapWithResource builds to dst.
+func appendWithResource(dst, scope, resource []byte, schemaURL string) []byte {
+	dst = protowire.AppendTag(dst, 1, protowire.BytesType)
+	dst = protowire.AppendVarint(dst, uint64(wrappedResourceSize(scope, resource, schemaURL)))
+	if resource != nil {
+		dst = protowire.AppendTag(dst, 1, protowire.BytesType)
+		dst = protowire.AppendBytes(dst, resource)
+	}
+	dst = protowire.AppendTag(dst, 2, protowire.BytesType)
+	dst = protowire.AppendBytes(dst, scope)
+	if schemaURL != "" {
+		dst = protowire.AppendTag(dst, 3, protowire.BytesType)
+		dst = protowire.AppendString(dst, schemaURL)
+	}
+	return dst
+}
+
+// wrappedResourceSize returns the encoded size of the resource entry
+// wrapWithResource builds around scope.
+func wrappedResourceSize(scope, resource []byte, schemaURL string) int {
+	size := protowire.SizeTag(2) + protowire.SizeBytes(len(scope))
+	if resource != nil {
+		size += protowire.SizeTag(1) + protowire.SizeBytes(len(resource))
+	}
+	if schemaURL != "" {
+		size += protowire.SizeTag(3) + protowire.SizeBytes(len(schemaURL))
+	}
+	return size
+}
+
+// ScopeSpans returns an iterator over every ScopeSpans of the batch together
+// with its enclosing ResourceSpans, in wire order, replacing nested loops
+// over ResourceSpans and their ScopeSpans.
+// The returned function should be called after iteration to check for errors.
+func (t ExportTracesServiceRequest) ScopeSpans() (iter.Seq2[ResourceSpans, ScopeSpans], func() error) {
+	var iterErr error
+	seq := func(yield func(ResourceSpans, ScopeSpans) bool) {
+		iterErr = forEachScope(t, func(resource, scope []byte) bool {
+			return yield(ResourceSpans(resource), ScopeSpans(scope))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// ScopeMetrics returns an iterator over every ScopeMetrics of the batch
+// together with its enclosing ResourceMetrics, as
+// ExportTracesServiceRequest.ScopeSpans.
+func (m ExportMetricsServiceRequest) ScopeMetrics() (iter.Seq2[ResourceMetrics, ScopeMetrics], func() error) {
+	var iterErr error
+	seq := func(yield func(ResourceMetrics, ScopeMetrics) bool) {
+		iterErr = forEachScope(m, func(resource, scope []byte) bool {
+			return yield(ResourceMetrics(resource), ScopeMetrics(scope))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// ScopeLogs returns an iterator over every ScopeLogs of the batch together
+// with its enclosing ResourceLogs, as ExportTracesServiceRequest.ScopeSpans.
+func (l ExportLogsServiceRequest) ScopeLogs() (iter.Seq2[ResourceLogs, ScopeLogs], func() error) {
+	var iterErr error
+	seq := func(yield func(ResourceLogs, ScopeLogs) bool) {
+		iterErr = forEachScope(l, func(resource, scope []byte) bool {
+			return yield(ResourceLogs(resource), ScopeLogs(scope))
+		})
+	}
+	return seq, func() error { return iterErr }
+}
+
+// forEachScope calls fn with every resource entry (field 1) of data and
+// each of its scope entries (field 2), stopping when fn returns false.
+func forEachScope(data []byte, fn func(resource, scope []byte) bool) error {
+	var err error
+	forEachRepeatedField(data, 1, func(resource []byte, iterErr error) bool {
+		if iterErr != nil {
+			err = iterErr
+			return false
+		}
+		cont := true
+		forEachRepeatedField(resource, 2, func(scope []byte, iterErr error) bool {
+			if iterErr != nil {
+				err = iterErr
+				cont = false
+				return false
+			}
+			cont = fn(resource, scope)
+			return cont
+		})
+		return cont
+	})
+	return err
+}
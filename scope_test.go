@@ -0,0 +1,305 @@
+package otlpwire
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestSplitByScope(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("resource-schema")
+	rs.Resource().Attributes().PutStr("service.name", "api")
+	for _, name := range []string{"http", "db"} {
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(name)
+		ss.SetSchemaUrl(name + "-schema")
+		ss.Spans().AppendEmpty().SetName(name + "-op")
+	}
+	rs = traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "worker")
+	rs.ScopeSpans().AppendEmpty().Scope().SetName("queue")
+	// A resource without scopes yields nothing.
+	traces.ResourceSpans().AppendEmpty().Resource().Attributes().PutStr("service.name", "idle")
+
+	seq, errFn := ExportTracesServiceRequest(marshalTraces(t, traces)).SplitByScope()
+	type split struct{ service, schema, scope, scopeSchema string }
+	var got []split
+	for req := range seq {
+		td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(req)
+		require.NoError(t, err)
+		require.Equal(t, 1, td.ResourceSpans().Len())
+		rs := td.ResourceSpans().At(0)
+		require.Equal(t, 1, rs.ScopeSpans().Len())
+		service, _ := rs.Resource().Attributes().Get("service.name")
+		ss := rs.ScopeSpans().At(0)
+		got = append(got, split{service.Str(), rs.SchemaUrl(), ss.Scope().Name(), ss.SchemaUrl()})
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []split{
+		{"api", "resource-schema", "http", "http-schema"},
+		{"api", "resource-schema", "db", "db-schema"},
+		{"worker", "", "queue", ""},
+	}, got)
+
+	// Stopping early.
+	n := 0
+	for range seq {
+		n++
+		break
+	}
+	require.Equal(t, 1, n)
+	require.NoError(t, errFn())
+}
+
+func TestSplitByScope_MetricsAndLogs(t *testing.T) {
+	metrics := buildScopedMetrics(t, 2, 3, 2)
+	seq, errFn := ExportMetricsServiceRequest(metrics).SplitByScope()
+	n := 0
+	for req := range seq {
+		md, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(req)
+		require.NoError(t, err)
+		require.Equal(t, 1, md.ResourceMetrics().Len())
+		require.Equal(t, 1, md.ResourceMetrics().At(0).ScopeMetrics().Len())
+		require.Equal(t, 2, md.DataPointCount())
+		n++
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, 6, n)
+
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	logSeq, logErr := ExportLogsServiceRequest(marshalLogs(t, logs)).SplitByScope()
+	n = 0
+	for req := range logSeq {
+		count, err := req.LogRecordCount()
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+		n++
+	}
+	require.NoError(t, logErr())
+	require.Equal(t, 2, n)
+
+	logSeq, logErr = ExportLogsServiceRequest{0x0a, 0x02, 0x12, 0x05}.SplitByScope()
+	for range logSeq {
+		t.Fatal("no request expected")
+	}
+	require.Error(t, logErr())
+}
+
+func TestScopeNameAndVersion(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("go.opentelemetry.io/contrib/net/http")
+	ss.Scope().SetVersion("0.53.0")
+	rs.ScopeSpans().AppendEmpty().Scope().SetName("no-version")
+
+	type scope struct{ name, version string }
+	var got []scope
+	rsSeq, rsErr := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range rsSeq {
+		ssSeq, ssErr := rs.ScopeSpans()
+		for ss := range ssSeq {
+			name, err := ss.ScopeName()
+			require.NoError(t, err)
+			version, err := ss.ScopeVersion()
+			require.NoError(t, err)
+			got = append(got, scope{string(name), string(version)})
+		}
+		require.NoError(t, ssErr())
+	}
+	require.NoError(t, rsErr())
+	require.Equal(t, []scope{{"go.opentelemetry.io/contrib/net/http", "0.53.0"}, {"no-version", ""}}, got)
+
+	// A scope-level message without a scope.
+	name, err := ScopeMetrics{}.ScopeName()
+	require.NoError(t, err)
+	require.Nil(t, name)
+
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().Scope().SetVersion("v2")
+	rlSeq, rlErr := ExportLogsServiceRequest(marshalLogs(t, logs)).ResourceLogs()
+	for rl := range rlSeq {
+		slSeq, slErr := rl.ScopeLogs()
+		for sl := range slSeq {
+			version, err := sl.ScopeVersion()
+			require.NoError(t, err)
+			require.Equal(t, "v2", string(version))
+		}
+		require.NoError(t, slErr())
+	}
+	require.NoError(t, rlErr())
+
+	_, err = ScopeLogs{0x0a, 0x02, 0x08, 0x01}.ScopeName()
+	require.Error(t, err)
+}
+
+func TestWrapWithResource(t *testing.T) {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "api")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("http")
+	ss.Spans().AppendEmpty().SetName("GET /")
+	ss.Spans().AppendEmpty().SetName("POST /")
+
+	var resource []byte
+	var scopes []ScopeSpans
+	rsSeq, rsErr := ExportTracesServiceRequest(marshalTraces(t, traces)).ResourceSpans()
+	for rs := range rsSeq {
+		var err error
+		resource, err = rs.Resource()
+		require.NoError(t, err)
+		ssSeq, ssErr := rs.ScopeSpans()
+		for ss := range ssSeq {
+			scopes = append(scopes, ss)
+		}
+		require.NoError(t, ssErr())
+	}
+	require.NoError(t, rsErr())
+	require.Len(t, scopes, 1)
+
+	req := scopes[0].WrapWithResource(resource, "https://opentelemetry.io/schemas/1.26.0")
+	td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(req)
+	require.NoError(t, err)
+	require.Equal(t, 2, td.SpanCount())
+	got := td.ResourceSpans().At(0)
+	require.Equal(t, "https://opentelemetry.io/schemas/1.26.0", got.SchemaUrl())
+	require.Equal(t, map[string]any{"service.name": "api"}, got.Resource().Attributes().AsRaw())
+	require.Equal(t, "http", got.ScopeSpans().At(0).Scope().Name())
+
+	// Without a resource or schema URL only the scope is wrapped.
+	req = scopes[0].WrapWithResource(nil, "")
+	rsSeq, rsErr = req.ResourceSpans()
+	for rs := range rsSeq {
+		_, err := rs.Resource()
+		require.Error(t, err, "the resource is absent")
+		url, err := rs.SchemaURL()
+		require.NoError(t, err)
+		require.Empty(t, url)
+	}
+	require.NoError(t, rsErr())
+
+	logs := ScopeLogs{}.WrapWithResource([]byte{}, "")
+	count, err := logs.LogRecordCount()
+	require.NoError(t, err)
+	require.Zero(t, count)
+	ld, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(logs)
+	require.NoError(t, err)
+	require.Equal(t, 1, ld.ResourceLogs().Len())
+
+	metrics := ScopeMetrics{}.WrapWithResource(nil, "m")
+	md, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(metrics)
+	require.NoError(t, err)
+	require.Equal(t, "m", md.ResourceMetrics().At(0).SchemaUrl())
+}
+
+func TestScopePairs(t *testing.T) {
+	metrics := buildScopedMetrics(t, 2, 3, 1)
+	type pair struct{ service, scope string }
+	var got []pair
+	seq, errFn := ExportMetricsServiceRequest(metrics).ScopeMetrics()
+	for rm, sm := range seq {
+		resource, err := rm.Resource()
+		require.NoError(t, err)
+		service, _, err := stringAttribute(resource, 1, "service.name")
+		require.NoError(t, err)
+		scope, err := sm.ScopeName()
+		require.NoError(t, err)
+		got = append(got, pair{service, string(scope)})
+	}
+	require.NoError(t, errFn())
+	require.Equal(t, []pair{
+		{"service-0", "scope-0"}, {"service-0", "scope-1"}, {"service-0", "scope-2"},
+		{"service-1", "scope-0"}, {"service-1", "scope-1"}, {"service-1", "scope-2"},
+	}, got)
+
+	// Stopping inside the second resource.
+	n := 0
+	for range seq {
+		n++
+		if n == 4 {
+			break
+		}
+	}
+	require.Equal(t, 4, n)
+	require.NoError(t, errFn())
+
+	traces := ptrace.NewTraces()
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	traces.ResourceSpans().AppendEmpty()
+	spanSeq, spanErr := ExportTracesServiceRequest(marshalTraces(t, traces)).ScopeSpans()
+	n = 0
+	for _, ss := range spanSeq {
+		count, err := ss.SpanCount()
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+		n++
+	}
+	require.NoError(t, spanErr())
+	require.Equal(t, 1, n)
+
+	// A scope entry with the wrong wire type.
+	logSeq, logErr := ExportLogsServiceRequest{0x0a, 0x02, 0x10, 0x01}.ScopeLogs()
+	for range logSeq {
+		t.Fatal("no scope expected")
+	}
+	require.Error(t, logErr())
+}
+
+func TestSizeOfAsExportRequest(t *testing.T) {
+	// Span counts chosen so that the length prefixes cross varint sizes.
+	for _, spans := range []int{1, 10, 1000} {
+		traces := ptrace.NewTraces()
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", "api")
+		ss := rs.ScopeSpans().AppendEmpty()
+		for range spans {
+			ss.Spans().AppendEmpty().SetName("op")
+		}
+		data := ExportTracesServiceRequest(marshalTraces(t, traces))
+
+		entries, errFn := data.ResourceSpans()
+		for rs := range entries {
+			require.Equal(t, len(data), rs.SizeOfAsExportRequest())
+			var buf bytes.Buffer
+			n, err := rs.WriteTo(&buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(rs.SizeOfAsExportRequest()), n)
+			prefix := []byte("prefix")
+			require.Equal(t, append(slices.Clip(prefix), buf.Bytes()...), rs.AppendAsExportRequest(prefix))
+
+			resource, err := rs.Resource()
+			require.NoError(t, err)
+			scopes, scopeErr := rs.ScopeSpans()
+			for ss := range scopes {
+				for _, url := range []string{"", "https://opentelemetry.io/schemas/1.26.0"} {
+					require.Len(t, ss.WrapWithResource(resource, url), ss.SizeOfAsExportRequest(resource, url))
+					require.Len(t, ss.WrapWithResource(nil, url), ss.SizeOfAsExportRequest(nil, url))
+					dst := ss.AppendWithResource([]byte("x"), resource, url)
+					require.Equal(t, append([]byte("x"), ss.WrapWithResource(resource, url)...), dst)
+				}
+			}
+			require.NoError(t, scopeErr())
+		}
+		require.NoError(t, errFn())
+	}
+
+	require.Len(t, ScopeLogs{}.WrapWithResource([]byte{}, ""), ScopeLogs{}.SizeOfAsExportRequest([]byte{}, ""))
+	require.Len(t, ScopeMetrics{}.WrapWithResource(nil, "m"), ScopeMetrics{}.SizeOfAsExportRequest(nil, "m"))
+	require.Equal(t, 2, ResourceMetrics{}.SizeOfAsExportRequest())
+	require.Equal(t, 2, ResourceLogs{}.SizeOfAsExportRequest())
+	require.Equal(t, []byte{0x0a, 0x00}, ResourceMetrics{}.AppendAsExportRequest(nil))
+	require.Equal(t, []byte{0x0a, 0x00}, ResourceLogs{}.AppendAsExportRequest(nil))
+	require.Len(t, ScopeLogs{}.AppendWithResource(nil, nil, ""), ScopeLogs{}.SizeOfAsExportRequest(nil, ""))
+	require.Len(t, ScopeMetrics{}.AppendWithResource(nil, nil, "m"), ScopeMetrics{}.SizeOfAsExportRequest(nil, "m"))
+}
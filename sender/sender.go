@@ -0,0 +1,345 @@
+// Package sender implements the export loop of a raw-bytes OTLP forwarder:
+// retries with exponential backoff and jitter, server-provided throttling
+// delays, and split-and-retry when a request is too large for the backend.
+package sender
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// SendFunc delivers one export request. Return an error wrapped with
+// Permanent, Throttled or TooLarge to steer the retry policy; any other
+// error is retried with backoff.
+type SendFunc func(ctx context.Context, e otlpwire.Envelope) error
+
+// permanentError marks an error that must not be retried.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return "permanent: " + e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the Sender gives up immediately.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err, or an error it wraps, was marked with
+// Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// ThrottledError is a retryable error that carries the delay requested by
+// the server, for example from an HTTP Retry-After header or a gRPC
+// RetryInfo detail.
+type ThrottledError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("throttled, retry after %s: %v", e.RetryAfter, e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error { return e.Err }
+
+// Throttled wraps err with a server-requested retry delay. The next attempt
+// waits at least retryAfter, regardless of the backoff schedule.
+func Throttled(err error, retryAfter time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &ThrottledError{Err: err, RetryAfter: retryAfter}
+}
+
+// tooLargeError marks a request rejected because of its size.
+type tooLargeError struct{ err error }
+
+func (e *tooLargeError) Error() string { return "request too large: " + e.err.Error() }
+func (e *tooLargeError) Unwrap() error { return e.err }
+
+// TooLarge wraps err to signal that the backend rejected the request because
+// of its size (for example HTTP 413 or gRPC RESOURCE_EXHAUSTED on message
+// size). The Sender splits the request and sends the halves.
+func TooLarge(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &tooLargeError{err: err}
+}
+
+// StatusError converts the gRPC status of a failed export, decoded with
+// otlpwire.ParseStatus, into an error for a SendFunc to return. Statuses the
+// OTLP specification deems retryable are retried, waiting at least their
+// RetryInfo delay; all others are Permanent. It returns nil for an OK status.
+func StatusError(st otlpwire.Status) error {
+	switch {
+	case st.Code == otlpwire.GRPCCodeOK:
+		return nil
+	case !st.Retryable():
+		return Permanent(st)
+	case st.HasRetryInfo:
+		return Throttled(st, st.RetryDelay)
+	default:
+		return st
+	}
+}
+
+// Config is the retry policy of a Sender.
+type Config struct {
+	// InitialInterval is the delay before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between retries.
+	MaxInterval time.Duration
+	// Multiplier grows the delay after each retry.
+	Multiplier float64
+	// RandomizationFactor spreads each delay uniformly over
+	// [d×(1-f), d×(1+f)]. Zero disables jitter.
+	RandomizationFactor float64
+	// MaxElapsedTime bounds the total time spent on one request, including
+	// attempts and waits. Zero means no limit.
+	MaxElapsedTime time.Duration
+	// MaxAttempts bounds the number of attempts per request. Zero means no
+	// limit.
+	MaxAttempts int
+}
+
+// DefaultConfig returns the retry policy of the OpenTelemetry Collector's
+// exporter helper.
+func DefaultConfig() Config {
+	return Config{
+		InitialInterval:     5 * time.Second,
+		MaxInterval:         30 * time.Second,
+		Multiplier:          1.5,
+		RandomizationFactor: 0.5,
+		MaxElapsedTime:      5 * time.Minute,
+	}
+}
+
+// Sender sends export requests with retries. It is safe for concurrent use
+// if the SendFunc is.
+type Sender struct {
+	send SendFunc
+	cfg  Config
+
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func() float64 // uniform in [0, 1)
+}
+
+// New returns a Sender that delivers requests with send under cfg.
+func New(send SendFunc, cfg Config) (*Sender, error) {
+	if send == nil {
+		return nil, errors.New("sender: nil SendFunc")
+	}
+	if cfg.InitialInterval <= 0 || cfg.MaxInterval < cfg.InitialInterval {
+		return nil, errors.New("sender: invalid retry intervals")
+	}
+	if cfg.Multiplier < 1 {
+		return nil, errors.New("sender: multiplier must be at least 1")
+	}
+	if cfg.RandomizationFactor < 0 || cfg.RandomizationFactor >= 1 {
+		return nil, errors.New("sender: randomization factor must be in [0, 1)")
+	}
+	return &Sender{
+		send:   send,
+		cfg:    cfg,
+		now:    time.Now,
+		sleep:  sleepContext,
+		jitter: rand.Float64,
+	}, nil
+}
+
+// Send delivers e, retrying retryable failures until it succeeds, the retry
+// budget is exhausted, or ctx is done. If the backend reports the request as
+// too large, Send splits it into two requests by resource, or by spans, data
+// points or log records if it holds a single resource, and sends each with
+// its own retry budget; a request with a single item cannot be split and
+// fails permanently.
+func (s *Sender) Send(ctx context.Context, e otlpwire.Envelope) error {
+	start := s.now()
+	interval := s.cfg.InitialInterval
+
+	for attempt := 1; ; attempt++ {
+		err := s.send(ctx, e)
+		if err == nil {
+			return nil
+		}
+
+		var tooLarge *tooLargeError
+		if errors.As(err, &tooLarge) {
+			return s.sendSplit(ctx, e, err)
+		}
+		if IsPermanent(err) {
+			return err
+		}
+		if s.cfg.MaxAttempts > 0 && attempt >= s.cfg.MaxAttempts {
+			return fmt.Errorf("sender: giving up after %d attempts: %w", attempt, err)
+		}
+
+		wait := s.randomize(interval)
+		var throttled *ThrottledError
+		if errors.As(err, &throttled) && throttled.RetryAfter > wait {
+			wait = throttled.RetryAfter
+		}
+		if s.cfg.MaxElapsedTime > 0 && s.now().Add(wait).Sub(start) > s.cfg.MaxElapsedTime {
+			return fmt.Errorf("sender: retry budget exhausted: %w", err)
+		}
+		if sleepErr := s.sleep(ctx, wait); sleepErr != nil {
+			return errors.Join(sleepErr, err)
+		}
+		interval = min(time.Duration(float64(interval)*s.cfg.Multiplier), s.cfg.MaxInterval)
+	}
+}
+
+// sendSplit splits e as splitInHalf and sends every part, returning the
+// joined errors of the parts.
+func (s *Sender) sendSplit(ctx context.Context, e otlpwire.Envelope, cause error) error {
+	parts, err := splitInHalf(e)
+	if err != nil {
+		return Permanent(errors.Join(cause, err))
+	}
+	if parts == nil {
+		return Permanent(cause)
+	}
+	errs := make([]error, len(parts))
+	for i, part := range parts {
+		errs[i] = s.Send(ctx, part)
+	}
+	return errors.Join(errs...)
+}
+
+// randomize applies RandomizationFactor to d.
+func (s *Sender) randomize(d time.Duration) time.Duration {
+	f := s.cfg.RandomizationFactor
+	if f == 0 {
+		return d
+	}
+	delta := f * float64(d)
+	return time.Duration(float64(d) - delta + s.jitter()*2*delta)
+}
+
+// splitInHalf splits the payload of e into two requests holding the first
+// and second half of its resources. A request with a single resource is
+// split between its items instead, into requests of at most half of them,
+// which may take a third request where scopes do not divide evenly. The
+// result is nil if e holds a single item.
+func splitInHalf(e otlpwire.Envelope) ([]otlpwire.Envelope, error) {
+	var resources []io.WriterTo
+	var err error
+	switch e.Signal {
+	case otlpwire.SignalTraces:
+		seq, errFn := otlpwire.ExportTracesServiceRequest(e.Payload).ResourceSpans()
+		for r := range seq {
+			resources = append(resources, r)
+		}
+		err = errFn()
+	case otlpwire.SignalMetrics:
+		seq, errFn := otlpwire.ExportMetricsServiceRequest(e.Payload).ResourceMetrics()
+		for r := range seq {
+			resources = append(resources, r)
+		}
+		err = errFn()
+	case otlpwire.SignalLogs:
+		seq, errFn := otlpwire.ExportLogsServiceRequest(e.Payload).ResourceLogs()
+		for r := range seq {
+			resources = append(resources, r)
+		}
+		err = errFn()
+	default:
+		err = errors.New("sender: envelope has unknown signal")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) < 2 {
+		return splitItemsInHalf(e)
+	}
+
+	mid := len(resources) / 2
+	first, second := e, e
+	if first.Payload, err = concatRequests(resources[:mid]); err != nil {
+		return nil, err
+	}
+	if second.Payload, err = concatRequests(resources[mid:]); err != nil {
+		return nil, err
+	}
+	return []otlpwire.Envelope{first, second}, nil
+}
+
+// splitItemsInHalf splits the payload of e into requests of at most half
+// of its items each, or returns nil if e holds fewer than two items.
+func splitItemsInHalf(e otlpwire.Envelope) ([]otlpwire.Envelope, error) {
+	items, err := e.ItemCount()
+	if err != nil || items < 2 {
+		return nil, err
+	}
+	half := (items + 1) / 2
+	var parts []otlpwire.Envelope
+	add := func(payload []byte) {
+		part := e
+		part.Payload = payload
+		parts = append(parts, part)
+	}
+	switch e.Signal {
+	case otlpwire.SignalTraces:
+		seq, errFn := otlpwire.ExportTracesServiceRequest(e.Payload).SplitByCount(half)
+		for req := range seq {
+			add(req)
+		}
+		err = errFn()
+	case otlpwire.SignalMetrics:
+		seq, errFn := otlpwire.ExportMetricsServiceRequest(e.Payload).SplitByCount(half)
+		for req := range seq {
+			add(req)
+		}
+		err = errFn()
+	case otlpwire.SignalLogs:
+		seq, errFn := otlpwire.ExportLogsServiceRequest(e.Payload).SplitByCount(half)
+		for req := range seq {
+			add(req)
+		}
+		err = errFn()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// concatRequests writes each resource as a single-resource export request
+// into one buffer. Concatenated protobuf messages merge, so the result is a
+// valid export request holding all the resources.
+func concatRequests(resources []io.WriterTo) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, r := range resources {
+		if _, err := r.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
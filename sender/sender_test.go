@@ -0,0 +1,273 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+func tracesEnvelope(t *testing.T, resources int) otlpwire.Envelope {
+	t.Helper()
+	traces := ptrace.NewTraces()
+	for i := 0; i < resources; i++ {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("svc-%d", i))
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("op")
+	}
+	marshaler := &ptrace.ProtoMarshaler{}
+	data, err := marshaler.MarshalTraces(traces)
+	require.NoError(t, err)
+	return otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: data}
+}
+
+// newTestSender returns a Sender with a virtual clock that records every
+// wait instead of sleeping.
+func newTestSender(t *testing.T, cfg Config, send SendFunc) (*Sender, *[]time.Duration) {
+	t.Helper()
+	s, err := New(send, cfg)
+	require.NoError(t, err)
+	var waits []time.Duration
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		now = now.Add(d)
+		return nil
+	}
+	s.jitter = func() float64 { return 0.5 } // centre of the jitter range
+	return s, &waits
+}
+
+func testConfig() Config {
+	return Config{
+		InitialInterval: time.Second,
+		MaxInterval:     4 * time.Second,
+		Multiplier:      2,
+	}
+}
+
+func TestSender_RetriesWithBackoff(t *testing.T) {
+	calls := 0
+	s, waits := newTestSender(t, testConfig(), func(context.Context, otlpwire.Envelope) error {
+		calls++
+		if calls < 5 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+
+	require.NoError(t, s.Send(context.Background(), tracesEnvelope(t, 1)))
+	require.Equal(t, 5, calls)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}, *waits)
+}
+
+func TestSender_Jitter(t *testing.T) {
+	cfg := testConfig()
+	cfg.RandomizationFactor = 0.5
+	cfg.MaxAttempts = 2
+	s, waits := newTestSender(t, cfg, func(context.Context, otlpwire.Envelope) error {
+		return errors.New("unavailable")
+	})
+	s.jitter = func() float64 { return 0 }
+
+	require.Error(t, s.Send(context.Background(), tracesEnvelope(t, 1)))
+	require.Equal(t, []time.Duration{500 * time.Millisecond}, *waits)
+}
+
+func TestSender_Permanent(t *testing.T) {
+	calls := 0
+	bad := errors.New("bad request")
+	s, waits := newTestSender(t, testConfig(), func(context.Context, otlpwire.Envelope) error {
+		calls++
+		return Permanent(bad)
+	})
+
+	err := s.Send(context.Background(), tracesEnvelope(t, 1))
+	require.ErrorIs(t, err, bad)
+	require.True(t, IsPermanent(err))
+	require.Equal(t, 1, calls)
+	require.Empty(t, *waits)
+}
+
+func TestSender_ThrottledHonorsRetryAfter(t *testing.T) {
+	calls := 0
+	s, waits := newTestSender(t, testConfig(), func(context.Context, otlpwire.Envelope) error {
+		calls++
+		if calls == 1 {
+			return Throttled(errors.New("429"), 10*time.Second)
+		}
+		if calls == 2 {
+			// A hint shorter than the backoff does not shorten the wait.
+			return Throttled(errors.New("429"), time.Millisecond)
+		}
+		return nil
+	})
+
+	require.NoError(t, s.Send(context.Background(), tracesEnvelope(t, 1)))
+	require.Equal(t, []time.Duration{10 * time.Second, 2 * time.Second}, *waits)
+}
+
+func TestStatusError(t *testing.T) {
+	require.NoError(t, StatusError(otlpwire.Status{}))
+
+	invalid := otlpwire.Status{Code: otlpwire.GRPCCodeInvalidArgument, Message: "bad span"}
+	err := StatusError(invalid)
+	require.True(t, IsPermanent(err))
+	require.EqualError(t, err, "permanent: rpc error: code = INVALID_ARGUMENT desc = bad span")
+
+	// RESOURCE_EXHAUSTED is only retryable with a RetryInfo.
+	require.True(t, IsPermanent(StatusError(otlpwire.Status{Code: otlpwire.GRPCCodeResourceExhausted})))
+	err = StatusError(otlpwire.Status{
+		Code:         otlpwire.GRPCCodeResourceExhausted,
+		RetryDelay:   3 * time.Second,
+		HasRetryInfo: true,
+	})
+	var throttled *ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	require.Equal(t, 3*time.Second, throttled.RetryAfter)
+
+	err = StatusError(otlpwire.Status{Code: otlpwire.GRPCCodeUnavailable})
+	require.False(t, IsPermanent(err))
+	require.False(t, errors.As(err, &throttled))
+	var st otlpwire.Status
+	require.ErrorAs(t, err, &st)
+	require.Equal(t, otlpwire.GRPCCodeUnavailable, st.Code)
+}
+
+func TestSender_MaxAttemptsAndElapsed(t *testing.T) {
+	fail := func(context.Context, otlpwire.Envelope) error { return errors.New("unavailable") }
+
+	cfg := testConfig()
+	cfg.MaxAttempts = 3
+	s, waits := newTestSender(t, cfg, fail)
+	require.ErrorContains(t, s.Send(context.Background(), tracesEnvelope(t, 1)), "3 attempts")
+	require.Len(t, *waits, 2)
+
+	cfg = testConfig()
+	cfg.MaxElapsedTime = 5 * time.Second
+	s, waits = newTestSender(t, cfg, fail)
+	require.ErrorContains(t, s.Send(context.Background(), tracesEnvelope(t, 1)), "budget exhausted")
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *waits)
+}
+
+func TestSender_ContextCanceled(t *testing.T) {
+	s, err := New(func(context.Context, otlpwire.Envelope) error {
+		return errors.New("unavailable")
+	}, testConfig())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.Send(ctx, tracesEnvelope(t, 1))
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "unavailable")
+}
+
+func TestSender_SplitOnTooLarge(t *testing.T) {
+	const limit = 2 // resources per request accepted by the fake backend
+	var delivered []int
+	s, _ := newTestSender(t, testConfig(), func(_ context.Context, e otlpwire.Envelope) error {
+		req, ok := e.Traces()
+		require.True(t, ok)
+		count, err := req.SpanCount()
+		require.NoError(t, err)
+		if count > limit {
+			return TooLarge(errors.New("413"))
+		}
+		delivered = append(delivered, count)
+		return nil
+	})
+
+	require.NoError(t, s.Send(context.Background(), tracesEnvelope(t, 7)))
+	total := 0
+	for _, c := range delivered {
+		require.LessOrEqual(t, c, limit)
+		total += c
+	}
+	require.Equal(t, 7, total)
+}
+
+func TestSender_TooLargeSingleResource(t *testing.T) {
+	// One resource with 7 spans in two scopes; the backend accepts 2 spans
+	// per request.
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "svc")
+	for _, spans := range []int{4, 3} {
+		ss := rs.ScopeSpans().AppendEmpty()
+		for range spans {
+			ss.Spans().AppendEmpty().SetName("op")
+		}
+	}
+	data, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(traces)
+	require.NoError(t, err)
+
+	var delivered []int
+	s, _ := newTestSender(t, testConfig(), func(_ context.Context, e otlpwire.Envelope) error {
+		req, ok := e.Traces()
+		require.True(t, ok)
+		count, err := req.SpanCount()
+		require.NoError(t, err)
+		if count > 2 {
+			return TooLarge(errors.New("413"))
+		}
+		seq, errFn := req.ResourceSpans()
+		resources := 0
+		for range seq {
+			resources++
+		}
+		require.NoError(t, errFn())
+		require.Equal(t, 1, resources)
+		delivered = append(delivered, count)
+		return nil
+	})
+	require.NoError(t, s.Send(context.Background(), otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: data}))
+	total := 0
+	for _, c := range delivered {
+		total += c
+	}
+	require.Equal(t, 7, total)
+}
+
+func TestSender_TooLargeSingleItem(t *testing.T) {
+	s, _ := newTestSender(t, testConfig(), func(context.Context, otlpwire.Envelope) error {
+		return TooLarge(errors.New("413"))
+	})
+	err := s.Send(context.Background(), tracesEnvelope(t, 1))
+	require.True(t, IsPermanent(err))
+}
+
+func TestSender_TooLargeMalformed(t *testing.T) {
+	s, _ := newTestSender(t, testConfig(), func(context.Context, otlpwire.Envelope) error {
+		return TooLarge(errors.New("413"))
+	})
+	err := s.Send(context.Background(), otlpwire.Envelope{Signal: otlpwire.SignalLogs, Payload: []byte{0x0a, 0x05}})
+	require.True(t, IsPermanent(err))
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	send := func(context.Context, otlpwire.Envelope) error { return nil }
+	_, err := New(nil, DefaultConfig())
+	require.Error(t, err)
+
+	for _, mutate := range []func(*Config){
+		func(c *Config) { c.InitialInterval = 0 },
+		func(c *Config) { c.MaxInterval = time.Millisecond },
+		func(c *Config) { c.Multiplier = 0.5 },
+		func(c *Config) { c.RandomizationFactor = 1 },
+	} {
+		cfg := DefaultConfig()
+		mutate(&cfg)
+		_, err := New(send, cfg)
+		require.Error(t, err)
+	}
+	_, err = New(send, DefaultConfig())
+	require.NoError(t, err)
+}
@@ -0,0 +1,286 @@
+// Package spill provides a spill-to-disk buffer for OTLP export requests in
+// wire format. Requests are stored under a partition key (typically a tenant
+// or a resource fingerprint) while downstream is unavailable and drained in
+// arrival order once it recovers. Storage is pluggable through the Store
+// interface; DirStore keeps one plain file per entry.
+package spill
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// ErrNotFound is returned by Store.Get for a missing key.
+var ErrNotFound = errors.New("spill: key not found")
+
+// Store is the key-value backend of a Buffer. Keys are opaque, printable
+// strings chosen by the Buffer. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	Put(key string, value []byte) error
+	// Get returns ErrNotFound if key does not exist.
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	// List returns every key in the store, in any order.
+	List() ([]string, error)
+}
+
+// Options configures a Buffer.
+type Options struct {
+	// MaxBytes bounds the total stored payload size. When a Put would
+	// exceed it, the oldest entries are evicted. Zero means unbounded.
+	MaxBytes int64
+	// MaxAge evicts entries stored longer ago than this. Zero means
+	// entries never expire.
+	MaxAge time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Stats reports the state of a Buffer.
+type Stats struct {
+	Entries       int
+	Bytes         int64
+	EvictedBySize uint64
+	EvictedByAge  uint64
+}
+
+// entry is the in-memory index record for one stored request.
+type entry struct {
+	key       string
+	seq       uint64
+	partition string
+	size      int64
+	storedAt  time.Time
+}
+
+// Buffer stores wire requests in a Store and drains them in arrival order.
+// Put is safe for concurrent use; Drain and DrainPartition serialize with
+// each other.
+type Buffer struct {
+	store Store
+	opts  Options
+
+	drainMu sync.Mutex
+
+	mu      sync.Mutex
+	entries []entry // ascending seq
+	nextSeq uint64
+	stats   Stats
+}
+
+// New returns a Buffer over store, indexing any entries already present so
+// that a restarted process drains what an earlier one spilled.
+func New(store Store, opts Options) (*Buffer, error) {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	keys, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Buffer{store: store, opts: opts, nextSeq: 1}
+	for _, key := range keys {
+		seq, partition, ok := parseKey(key)
+		if !ok {
+			continue
+		}
+		value, err := store.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		storedAt, payloadSize, err := decodeValueMeta(value)
+		if err != nil {
+			return nil, fmt.Errorf("spill: entry %q: %w", key, err)
+		}
+		b.entries = append(b.entries, entry{key: key, seq: seq, partition: partition, size: payloadSize, storedAt: storedAt})
+		b.stats.Bytes += payloadSize
+		b.nextSeq = max(b.nextSeq, seq+1)
+	}
+	slices.SortFunc(b.entries, func(a, b entry) int { return cmp.Compare(a.seq, b.seq) })
+	b.stats.Entries = len(b.entries)
+	return b, nil
+}
+
+// Put stores e under partition. Entries that exceed MaxAge, and then the
+// oldest entries beyond MaxBytes, are evicted first. A single request larger
+// than MaxBytes is rejected.
+func (b *Buffer) Put(partition string, e otlpwire.Envelope) error {
+	size := int64(len(e.Payload))
+	if b.opts.MaxBytes > 0 && size > b.opts.MaxBytes {
+		return errors.New("spill: request larger than MaxBytes")
+	}
+	now := b.opts.Now()
+	value := binary.BigEndian.AppendUint64(nil, uint64(now.UnixNano()))
+	value, err := e.AppendBinary(value)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.expireLocked(now); err != nil {
+		return err
+	}
+	for b.opts.MaxBytes > 0 && b.stats.Bytes+size > b.opts.MaxBytes && len(b.entries) > 0 {
+		if err := b.removeLocked(0); err != nil {
+			return err
+		}
+		b.stats.EvictedBySize++
+	}
+
+	seq := b.nextSeq
+	key := formatKey(seq, partition)
+	if err := b.store.Put(key, value); err != nil {
+		return err
+	}
+	b.nextSeq++
+	b.entries = append(b.entries, entry{key: key, seq: seq, partition: partition, size: size, storedAt: now})
+	b.stats.Entries++
+	b.stats.Bytes += size
+	return nil
+}
+
+// Drain calls fn for every stored request across all partitions, oldest
+// first, and deletes each entry once fn returns nil. It stops at the first
+// error from fn, leaving that entry in place for a later attempt.
+func (b *Buffer) Drain(fn func(partition string, e otlpwire.Envelope) error) error {
+	return b.drain(func(entry) bool { return true }, fn)
+}
+
+// DrainPartition is like Drain but only visits entries stored under
+// partition.
+func (b *Buffer) DrainPartition(partition string, fn func(partition string, e otlpwire.Envelope) error) error {
+	return b.drain(func(e entry) bool { return e.partition == partition }, fn)
+}
+
+// Stats returns a snapshot of the buffer's size and eviction counters.
+func (b *Buffer) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+func (b *Buffer) drain(match func(entry) bool, fn func(string, otlpwire.Envelope) error) error {
+	b.drainMu.Lock()
+	defer b.drainMu.Unlock()
+
+	var after uint64
+	for {
+		b.mu.Lock()
+		if err := b.expireLocked(b.opts.Now()); err != nil {
+			b.mu.Unlock()
+			return err
+		}
+		i := slices.IndexFunc(b.entries, func(e entry) bool { return e.seq > after && match(e) })
+		if i < 0 {
+			b.mu.Unlock()
+			return nil
+		}
+		next := b.entries[i]
+		b.mu.Unlock()
+		after = next.seq
+
+		value, err := b.store.Get(next.key)
+		if errors.Is(err, ErrNotFound) {
+			continue // evicted concurrently
+		}
+		if err != nil {
+			return err
+		}
+		if len(value) < 8 {
+			return fmt.Errorf("spill: entry %q: stored value truncated", next.key)
+		}
+		var env otlpwire.Envelope
+		if err := env.UnmarshalBinary(value[8:]); err != nil {
+			return fmt.Errorf("spill: entry %q: %w", next.key, err)
+		}
+		if err := fn(next.partition, env); err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		if j := slices.IndexFunc(b.entries, func(e entry) bool { return e.seq == next.seq }); j >= 0 {
+			err = b.removeLocked(j)
+		}
+		b.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// expireLocked evicts entries older than MaxAge. b.mu must be held.
+func (b *Buffer) expireLocked(now time.Time) error {
+	if b.opts.MaxAge <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-b.opts.MaxAge)
+	for i := 0; i < len(b.entries); {
+		if !b.entries[i].storedAt.Before(cutoff) {
+			i++
+			continue
+		}
+		if err := b.removeLocked(i); err != nil {
+			return err
+		}
+		b.stats.EvictedByAge++
+	}
+	return nil
+}
+
+// removeLocked deletes entry i from the store and the index. b.mu must be
+// held.
+func (b *Buffer) removeLocked(i int) error {
+	e := b.entries[i]
+	if err := b.store.Delete(e.key); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	b.entries = slices.Delete(b.entries, i, i+1)
+	b.stats.Entries--
+	b.stats.Bytes -= e.size
+	return nil
+}
+
+// formatKey builds the store key for an entry: a zero-padded sequence number
+// followed by the partition, so keys also sort in arrival order.
+func formatKey(seq uint64, partition string) string {
+	return fmt.Sprintf("%020d/%s", seq, partition)
+}
+
+func parseKey(key string) (uint64, string, bool) {
+	seqStr, partition, ok := strings.Cut(key, "/")
+	if !ok {
+		return 0, "", false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return seq, partition, true
+}
+
+// decodeValueMeta returns the store time and payload size of a stored value:
+// an 8-byte big-endian Unix-nanosecond timestamp followed by an
+// otlpwire.Envelope encoding.
+func decodeValueMeta(value []byte) (time.Time, int64, error) {
+	if len(value) < 8 {
+		return time.Time{}, 0, errors.New("stored value truncated")
+	}
+	var env otlpwire.Envelope
+	if err := env.UnmarshalBinary(value[8:]); err != nil {
+		return time.Time{}, 0, err
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value))), int64(len(env.Payload)), nil
+}
@@ -0,0 +1,145 @@
+package spill
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+func logsEnvelope(size int) otlpwire.Envelope {
+	// The buffer never parses payloads, so zero bytes suffice.
+	payload := make([]byte, size)
+	return otlpwire.Envelope{Signal: otlpwire.SignalLogs, Payload: payload}
+}
+
+type drained struct {
+	partition string
+	size      int
+}
+
+func drainAll(t *testing.T, b *Buffer) []drained {
+	t.Helper()
+	var got []drained
+	require.NoError(t, b.Drain(func(partition string, e otlpwire.Envelope) error {
+		got = append(got, drained{partition, len(e.Payload)})
+		return nil
+	}))
+	return got
+}
+
+func TestBuffer_DrainInOrder(t *testing.T) {
+	for name, newStore := range map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemoryStore() },
+		"dir": func(t *testing.T) Store {
+			s, err := NewDirStore(t.TempDir())
+			require.NoError(t, err)
+			return s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b, err := New(newStore(t), Options{})
+			require.NoError(t, err)
+
+			require.NoError(t, b.Put("tenant/a", logsEnvelope(1)))
+			require.NoError(t, b.Put("tenant-b", logsEnvelope(2)))
+			require.NoError(t, b.Put("tenant/a", logsEnvelope(3)))
+			require.Equal(t, Stats{Entries: 3, Bytes: 6}, b.Stats())
+
+			require.Equal(t, []drained{{"tenant/a", 1}, {"tenant-b", 2}, {"tenant/a", 3}}, drainAll(t, b))
+			require.Equal(t, Stats{}, b.Stats())
+		})
+	}
+}
+
+func TestBuffer_DrainPartition(t *testing.T) {
+	b, err := New(NewMemoryStore(), Options{})
+	require.NoError(t, err)
+	for i, p := range []string{"a", "b", "a", "b"} {
+		require.NoError(t, b.Put(p, logsEnvelope(i+1)))
+	}
+
+	var sizes []int
+	require.NoError(t, b.DrainPartition("b", func(_ string, e otlpwire.Envelope) error {
+		sizes = append(sizes, len(e.Payload))
+		return nil
+	}))
+	require.Equal(t, []int{2, 4}, sizes)
+	require.Equal(t, []drained{{"a", 1}, {"a", 3}}, drainAll(t, b))
+}
+
+func TestBuffer_DrainStopsOnError(t *testing.T) {
+	b, err := New(NewMemoryStore(), Options{})
+	require.NoError(t, err)
+	require.NoError(t, b.Put("a", logsEnvelope(1)))
+	require.NoError(t, b.Put("a", logsEnvelope(2)))
+
+	unavailable := errors.New("downstream unavailable")
+	err = b.Drain(func(string, otlpwire.Envelope) error { return unavailable })
+	require.ErrorIs(t, err, unavailable)
+	require.Equal(t, 2, b.Stats().Entries)
+	require.Len(t, drainAll(t, b), 2)
+}
+
+func TestBuffer_SizeEviction(t *testing.T) {
+	b, err := New(NewMemoryStore(), Options{MaxBytes: 10})
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, b.Put(fmt.Sprint(i), logsEnvelope(4)))
+	}
+	stats := b.Stats()
+	require.Equal(t, 2, stats.Entries)
+	require.Equal(t, uint64(2), stats.EvictedBySize)
+	require.Equal(t, []drained{{"2", 4}, {"3", 4}}, drainAll(t, b))
+
+	require.Error(t, b.Put("big", logsEnvelope(11)))
+}
+
+func TestBuffer_AgeEviction(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b, err := New(NewMemoryStore(), Options{MaxAge: time.Minute, Now: func() time.Time { return now }})
+	require.NoError(t, err)
+	require.NoError(t, b.Put("old", logsEnvelope(1)))
+	now = now.Add(45 * time.Second)
+	require.NoError(t, b.Put("new", logsEnvelope(1)))
+	now = now.Add(30 * time.Second)
+
+	require.Equal(t, []drained{{"new", 1}}, drainAll(t, b))
+	require.Equal(t, uint64(1), b.Stats().EvictedByAge)
+}
+
+func TestBuffer_ReopenDirStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDirStore(dir)
+	require.NoError(t, err)
+	b, err := New(store, Options{})
+	require.NoError(t, err)
+	require.NoError(t, b.Put("x", logsEnvelope(5)))
+	require.NoError(t, b.Put("y", logsEnvelope(6)))
+
+	store, err = NewDirStore(dir)
+	require.NoError(t, err)
+	b, err = New(store, Options{})
+	require.NoError(t, err)
+	require.Equal(t, Stats{Entries: 2, Bytes: 11}, b.Stats())
+
+	require.NoError(t, b.Put("z", logsEnvelope(7)))
+	require.Equal(t, []drained{{"x", 5}, {"y", 6}, {"z", 7}}, drainAll(t, b))
+}
+
+func TestBuffer_CorruptEntry(t *testing.T) {
+	store := NewMemoryStore()
+	require.NoError(t, store.Put(formatKey(1, "a"), []byte{0x01}))
+	_, err := New(store, Options{})
+	require.Error(t, err)
+}
+
+func TestBuffer_RejectsInvalidEnvelope(t *testing.T) {
+	b, err := New(NewMemoryStore(), Options{})
+	require.NoError(t, err)
+	require.Error(t, b.Put("a", otlpwire.Envelope{}))
+}
@@ -0,0 +1,36 @@
+package otlpwire
+
+// W3C trace-context flag bits carried in the low byte of Span.flags.
+const spanFlagSampled = 0x01
+
+// PartitionBySampled splits the request into two valid requests: one with
+// the spans whose W3C sampled flag (bit 0 of Span.flags) is set, and one with
+// all other spans, including spans that carry no flags field. Resource and
+// scope envelopes are preserved; a resource or scope with no spans on one
+// side is omitted from that side's request.
+func (t ExportTracesServiceRequest) PartitionBySampled() (sampled, unsampled ExportTracesServiceRequest, err error) {
+	return t.PartitionBySampledWith(nil)
+}
+
+// PartitionBySampledWith is PartitionBySampled writing both outputs into
+// buffers from alloc. A nil alloc allocates from the heap.
+func (t ExportTracesServiceRequest) PartitionBySampledWith(alloc Allocator) (sampled, unsampled ExportTracesServiceRequest, err error) {
+	isSampled := func(span []byte) (bool, error) {
+		flags, err := Span(span).Flags()
+		return flags&spanFlagSampled != 0, err
+	}
+	isUnsampled := func(span []byte) (bool, error) {
+		ok, err := isSampled(span)
+		return !ok, err
+	}
+
+	s, err := appendFiltered(allocate(alloc, len(t)), []byte(t), spanPath, isSampled)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := appendFiltered(allocate(alloc, len(t)), []byte(t), spanPath, isUnsampled)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ExportTracesServiceRequest(s), ExportTracesServiceRequest(u), nil
+}
@@ -0,0 +1,102 @@
+package otlpwire
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// spanNames unmarshals a traces request with pdata and returns
+// "resource/scope/span" for every span, in order.
+func spanNames(t *testing.T, data []byte) []string {
+	t.Helper()
+	unmarshaler := &ptrace.ProtoUnmarshaler{}
+	traces, err := unmarshaler.UnmarshalTraces(data)
+	require.NoError(t, err)
+
+	var names []string
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		rs := traces.ResourceSpans().At(i)
+		svc, _ := rs.Resource().Attributes().Get("service.name")
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			require.Positive(t, ss.Spans().Len(), "empty scope in output")
+			for k := 0; k < ss.Spans().Len(); k++ {
+				names = append(names, fmt.Sprintf("%s/%s/%s", svc.Str(), ss.Scope().Name(), ss.Spans().At(k).Name()))
+			}
+		}
+	}
+	return names
+}
+
+func TestPartitionBySampled(t *testing.T) {
+	traces := ptrace.NewTraces()
+	for r, flagsByScope := range [][][]uint32{
+		{{1, 0}, {0x101}},
+		{{0, 0}},
+		{{3}},
+	} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("svc%d", r))
+		rs.SetSchemaUrl("https://opentelemetry.io/schemas/1.26.0")
+		for s, flags := range flagsByScope {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(fmt.Sprintf("scope%d", s))
+			for i, f := range flags {
+				span := ss.Spans().AppendEmpty()
+				span.SetName(fmt.Sprintf("span%d", i))
+				span.SetFlags(f)
+			}
+		}
+	}
+	data := marshalTraces(t, traces)
+
+	sampled, unsampled, err := ExportTracesServiceRequest(data).PartitionBySampled()
+	require.NoError(t, err)
+	require.Equal(t, []string{"svc0/scope0/span0", "svc0/scope1/span0", "svc2/scope0/span0"}, spanNames(t, sampled))
+	require.Equal(t, []string{"svc0/scope0/span1", "svc1/scope0/span0", "svc1/scope0/span1"}, spanNames(t, unsampled))
+
+	// Resource-level fields such as schema_url survive the rebuild.
+	unmarshaler := &ptrace.ProtoUnmarshaler{}
+	out, err := unmarshaler.UnmarshalTraces(sampled)
+	require.NoError(t, err)
+	require.Equal(t, "https://opentelemetry.io/schemas/1.26.0", out.ResourceSpans().At(0).SchemaUrl())
+}
+
+func TestPartitionBySampled_AllOneSide(t *testing.T) {
+	data := marshalTraces(t, createBenchTraces()) // no flags set
+	sampled, unsampled, err := ExportTracesServiceRequest(data).PartitionBySampled()
+	require.NoError(t, err)
+	require.Empty(t, sampled)
+	require.Equal(t, data, []byte(unsampled))
+}
+
+func TestPartitionBySampled_Malformed(t *testing.T) {
+	// Span with flags (field 16) encoded as varint.
+	var span []byte
+	span = protowire.AppendTag(span, 16, protowire.VarintType)
+	span = protowire.AppendVarint(span, 1)
+	_, _, err := ExportTracesServiceRequest(wrapRecord(span)).PartitionBySampled()
+	require.Error(t, err)
+
+	_, _, err = ExportTracesServiceRequest{0x0a, 0x05}.PartitionBySampled()
+	require.Error(t, err)
+}
+
+func TestAppendMessageField_LongBody(t *testing.T) {
+	for _, size := range []int{0, 1, 127, 128, 300, 20000} {
+		body := []byte(strings.Repeat("x", size))
+		got, err := appendMessageField([]byte{0xff}, 3, func(b []byte) ([]byte, error) {
+			return append(b, body...), nil
+		})
+		require.NoError(t, err)
+
+		want := protowire.AppendTag([]byte{0xff}, 3, protowire.BytesType)
+		want = protowire.AppendBytes(want, body)
+		require.Equal(t, want, got, "size %d", size)
+	}
+}
@@ -0,0 +1,187 @@
+package otlpwire
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SplunkHECConfig configures AppendSplunkHEC.
+type SplunkHECConfig struct {
+	// Host is used when a resource has no host.name string attribute.
+	Host string
+	// Source, SourceType and Index set the matching event metadata. Empty
+	// values are omitted so that the token defaults apply.
+	Source, SourceType, Index string
+}
+
+// AppendSplunkHEC appends the log records of the request to dst as Splunk
+// HTTP Event Collector events, one JSON object per line, and returns the
+// extended buffer and the number of events. The result can be posted as is
+// to the /services/collector/event endpoint. Each event carries:
+//
+//   - time from time_unix_nano, or observed_time_unix_nano when unset, in
+//     seconds with microsecond precision; omitted when neither is set
+//   - host from the host.name resource attribute
+//   - event from the record body, as JSON
+//   - fields from the resource attributes and the record attributes, a
+//     record attribute replacing a resource attribute with the same key,
+//     followed by otel.log.severity.text, otel.log.severity.number,
+//     trace_id and span_id when set
+//
+// HEC indexed fields only hold strings, so field values are rendered as
+// text, with arrays and maps as JSON. Records with an empty body are
+// skipped, as HEC rejects blank events. On error the returned buffer holds
+// the events appended so far.
+func (l ExportLogsServiceRequest) AppendSplunkHEC(dst []byte, cfg SplunkHECConfig) ([]byte, int, error) {
+	var scratch []byte
+	count := 0
+	err := forEachLogRecord([]byte(l), func(resource, _, record []byte) error {
+		body, err := extractBytesField(record, 5)
+		if err != nil {
+			return err
+		}
+		typ, err := anyValueType(body)
+		if err != nil || typ == ValueTypeEmpty {
+			return err
+		}
+
+		start := len(dst)
+		dst, scratch, err = appendSplunkHECEvent(dst, scratch, resource, record, body, cfg)
+		if err != nil {
+			dst = dst[:start]
+			return err
+		}
+		count++
+		return nil
+	})
+	return dst, count, err
+}
+
+func appendSplunkHECEvent(dst, scratch, resource, record, body []byte, cfg SplunkHECConfig) ([]byte, []byte, error) {
+	dst = append(dst, '{')
+	ts, err := logRecordTime(record)
+	if err != nil {
+		return dst, scratch, err
+	}
+	if ts != 0 {
+		dst = append(dst, `"time":`...)
+		dst = appendUnixSeconds(dst, ts)
+		dst = append(dst, ',')
+	}
+
+	host, ok, err := stringAttribute(resource, 1, "host.name")
+	if err != nil {
+		return dst, scratch, err
+	}
+	if !ok {
+		host = cfg.Host
+	}
+	dst = appendJSONStringMember(dst, "host", host)
+	dst = appendJSONStringMember(dst, "source", cfg.Source)
+	dst = appendJSONStringMember(dst, "sourcetype", cfg.SourceType)
+	dst = appendJSONStringMember(dst, "index", cfg.Index)
+
+	dst = append(dst, `"event":`...)
+	dst, err = appendAnyValueJSON(dst, body, 0)
+	if err != nil {
+		return dst, scratch, err
+	}
+
+	dst = append(dst, `,"fields":{`...)
+	first := true
+	addField := func(key, value []byte) {
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = appendJSONString(dst, key)
+		dst = append(dst, ':')
+		dst = appendJSONString(dst, value)
+	}
+	// addAttributes adds the attributes of msg whose keys are not also
+	// attributes of shadow.
+	addAttributes := func(msg []byte, num protowire.Number, shadow []byte) error {
+		return forEachMessage(msg, num, func(kv []byte) error {
+			key, err := extractBytesField(kv, 1)
+			if err != nil {
+				return err
+			}
+			if shadow != nil {
+				shadowed, err := hasAttribute(shadow, 6, key)
+				if err != nil || shadowed {
+					return err
+				}
+			}
+			value, err := extractBytesField(kv, 2)
+			if err != nil {
+				return err
+			}
+			scratch, err = appendAnyValueText(scratch[:0], value)
+			addField(key, scratch)
+			return err
+		})
+	}
+	if err := addAttributes(resource, 1, record); err != nil {
+		return dst, scratch, err
+	}
+	if err := addAttributes(record, 6, nil); err != nil {
+		return dst, scratch, err
+	}
+
+	severityText, err := extractBytesField(record, 3)
+	if err != nil {
+		return dst, scratch, err
+	}
+	if len(severityText) > 0 {
+		addField([]byte("otel.log.severity.text"), severityText)
+	}
+	severity, err := extractVarintField(record, 2)
+	if err != nil {
+		return dst, scratch, err
+	}
+	if severity != 0 {
+		addField([]byte("otel.log.severity.number"), strconv.AppendUint(scratch[:0], severity, 10))
+	}
+	for _, id := range []struct {
+		key string
+		num protowire.Number
+	}{{"trace_id", 9}, {"span_id", 10}} {
+		var ok bool
+		scratch, ok, err = appendHexField(scratch[:0], record, id.num)
+		if err != nil {
+			return dst, scratch, err
+		}
+		if ok {
+			addField([]byte(id.key), scratch)
+		}
+	}
+	dst = append(dst, "}}\n"...)
+	return dst, scratch, nil
+}
+
+// appendJSONStringMember appends a "key":"value" member and a trailing comma,
+// or nothing when value is empty.
+func appendJSONStringMember(dst []byte, key, value string) []byte {
+	if value == "" {
+		return dst
+	}
+	dst = appendJSONString(dst, []byte(key))
+	dst = append(dst, ':')
+	dst = appendJSONString(dst, []byte(value))
+	return append(dst, ',')
+}
+
+// hasAttribute reports whether the repeated KeyValue field num of msg holds
+// key.
+func hasAttribute(msg []byte, num protowire.Number, key []byte) (bool, error) {
+	found := false
+	err := forEachMessage(msg, num, func(kv []byte) error {
+		k, err := extractBytesField(kv, 1)
+		if string(k) == string(key) {
+			found = true
+		}
+		return err
+	})
+	return found, err
+}
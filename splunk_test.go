@@ -0,0 +1,72 @@
+package otlpwire
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestAppendSplunkHEC(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	rl.Resource().Attributes().PutStr("env", "prod")
+	rl.Resource().Attributes().PutInt("replicas", 3)
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+
+	lr := records.AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(time.Unix(1715000000, 123456789)))
+	lr.SetSeverityText("Error")
+	lr.SetSeverityNumber(plog.SeverityNumberError)
+	lr.SetTraceID(pcommon.TraceID{0x01, 0x02})
+	lr.SetSpanID(pcommon.SpanID{0xab})
+	lr.Attributes().PutStr("env", "canary")
+	lr.Attributes().PutEmptySlice("tags").AppendEmpty().SetStr("a")
+	lr.Body().SetStr("payment failed")
+
+	lr = records.AppendEmpty()
+	lr.Body().SetEmptyMap().PutInt("attempt", 2)
+
+	// Skipped: HEC rejects blank events.
+	records.AppendEmpty()
+
+	data := ExportLogsServiceRequest(marshalLogs(t, logs))
+	out, n, err := data.AppendSplunkHEC(nil, SplunkHECConfig{Host: "fallback", SourceType: "otel"})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	lines := strings.Split(string(out), "\n")
+	require.Equal(t, []string{
+		`{"time":1715000000.123456,"host":"fallback","sourcetype":"otel","event":"payment failed","fields":{` +
+			`"service.name":"checkout","replicas":"3","env":"canary","tags":"[\"a\"]",` +
+			`"otel.log.severity.text":"Error","otel.log.severity.number":"17",` +
+			`"trace_id":"01020000000000000000000000000000","span_id":"ab00000000000000"}}`,
+		`{"host":"fallback","sourcetype":"otel","event":{"attempt":2},"fields":{"service.name":"checkout","env":"prod","replicas":"3"}}`,
+		"",
+	}, lines)
+	for _, line := range lines[:2] {
+		require.True(t, json.Valid([]byte(line)), line)
+	}
+}
+
+func TestAppendSplunkHEC_HostAttribute(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("host.name", "web-1")
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("x")
+
+	out, n, err := ExportLogsServiceRequest(marshalLogs(t, logs)).AppendSplunkHEC([]byte("prev\n"), SplunkHECConfig{Host: "fallback", Index: "main", Source: "app"})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, "prev\n"+`{"host":"web-1","source":"app","index":"main","event":"x","fields":{"host.name":"web-1"}}`+"\n", string(out))
+}
+
+func TestAppendSplunkHEC_Malformed(t *testing.T) {
+	_, _, err := ExportLogsServiceRequest([]byte{0x0a, 0x05}).AppendSplunkHEC(nil, SplunkHECConfig{})
+	require.Error(t, err)
+}
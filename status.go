@@ -0,0 +1,181 @@
+package otlpwire
+
+import (
+	"strconv"
+	"time"
+)
+
+// Any type URLs of the google.rpc error details decoded by ParseStatus.
+const (
+	errorInfoTypeURL    = "type.googleapis.com/google.rpc.ErrorInfo"
+	quotaFailureTypeURL = "type.googleapis.com/google.rpc.QuotaFailure"
+)
+
+var grpcCodeNames = [...]string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// String returns the canonical upper-case name of the code, such as
+// "RESOURCE_EXHAUSTED", or "CODE(n)" for codes gRPC does not define.
+func (c GRPCCode) String() string {
+	if int(c) < len(grpcCodeNames) {
+		return grpcCodeNames[c]
+	}
+	return "CODE(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// Status is a decoded google.rpc.Status, the error payload of a failed
+// OTLP/gRPC export (the grpc-status-details-bin trailer) and of a failed
+// OTLP/HTTP export (the response body).
+type Status struct {
+	Code    GRPCCode
+	Message string
+	// RetryDelay is the delay of the first RetryInfo detail, valid if
+	// HasRetryInfo is set.
+	RetryDelay   time.Duration
+	HasRetryInfo bool
+	// ErrorInfo holds the ErrorInfo details.
+	ErrorInfo []ErrorInfo
+	// QuotaViolations holds the violations of all QuotaFailure details.
+	QuotaViolations []QuotaViolation
+	// Details holds every detail, decoded or not, in wire order.
+	Details []StatusDetail
+}
+
+// StatusDetail is an undecoded google.protobuf.Any detail of a Status.
+type StatusDetail struct {
+	TypeURL string
+	Value   []byte // aliases the parsed status
+}
+
+// ErrorInfo is a google.rpc.ErrorInfo detail.
+type ErrorInfo struct {
+	Reason   string
+	Domain   string
+	Metadata map[string]string
+}
+
+// QuotaViolation is one violation of a google.rpc.QuotaFailure detail.
+type QuotaViolation struct {
+	Subject     string
+	Description string
+}
+
+// ParseStatus decodes an encoded google.rpc.Status. Details of other types
+// are only listed in Details.
+func ParseStatus(data []byte) (Status, error) {
+	var s Status
+	code, err := extractVarintField(data, 1)
+	if err != nil {
+		return Status{}, err
+	}
+	s.Code = GRPCCode(code)
+	message, err := extractBytesField(data, 2)
+	if err != nil {
+		return Status{}, err
+	}
+	s.Message = string(message)
+
+	err = forEachMessage(data, 3, func(detail []byte) error {
+		typeURL, err := extractBytesField(detail, 1)
+		if err != nil {
+			return err
+		}
+		value, err := extractBytesField(detail, 2)
+		if err != nil {
+			return err
+		}
+		s.Details = append(s.Details, StatusDetail{TypeURL: string(typeURL), Value: value})
+
+		switch string(typeURL) {
+		case retryInfoTypeURL:
+			if s.HasRetryInfo {
+				return nil
+			}
+			s.RetryDelay, err = parseRetryInfo(value)
+			s.HasRetryInfo = err == nil
+			return err
+		case errorInfoTypeURL:
+			info, err := parseErrorInfo(value)
+			s.ErrorInfo = append(s.ErrorInfo, info)
+			return err
+		case quotaFailureTypeURL:
+			return forEachMessage(value, 1, func(violation []byte) error {
+				subject, err := extractBytesField(violation, 1)
+				if err != nil {
+					return err
+				}
+				description, err := extractBytesField(violation, 2)
+				s.QuotaViolations = append(s.QuotaViolations, QuotaViolation{
+					Subject:     string(subject),
+					Description: string(description),
+				})
+				return err
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return Status{}, err
+	}
+	return s, nil
+}
+
+// parseErrorInfo decodes a google.rpc.ErrorInfo.
+func parseErrorInfo(value []byte) (ErrorInfo, error) {
+	var info ErrorInfo
+	reason, err := extractBytesField(value, 1)
+	if err != nil {
+		return info, err
+	}
+	domain, err := extractBytesField(value, 2)
+	if err != nil {
+		return info, err
+	}
+	info.Reason, info.Domain = string(reason), string(domain)
+	err = forEachMessage(value, 3, func(entry []byte) error {
+		key, err := extractBytesField(entry, 1)
+		if err != nil {
+			return err
+		}
+		val, err := extractBytesField(entry, 2)
+		if err != nil {
+			return err
+		}
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		info.Metadata[string(key)] = string(val)
+		return nil
+	})
+	return info, err
+}
+
+// Retryable reports whether the OTLP specification allows the failed export
+// to be retried: for the CANCELLED, DEADLINE_EXCEEDED, ABORTED,
+// OUT_OF_RANGE, UNAVAILABLE and DATA_LOSS codes, and for RESOURCE_EXHAUSTED
+// only when the server sent a RetryInfo saying recovery is possible. Every
+// other failure is permanent.
+func (s Status) Retryable() bool {
+	switch s.Code {
+	case GRPCCodeCanceled, GRPCCodeDeadlineExceeded, GRPCCodeAborted,
+		GRPCCodeOutOfRange, GRPCCodeUnavailable, GRPCCodeDataLoss:
+		return true
+	case GRPCCodeResourceExhausted:
+		return s.HasRetryInfo
+	default:
+		return false
+	}
+}
+
+// Error formats the code and message, so that a Status can be returned as
+// an error.
+func (s Status) Error() string {
+	if s.Message == "" {
+		return "rpc error: code = " + s.Code.String()
+	}
+	return "rpc error: code = " + s.Code.String() + " desc = " + s.Message
+}
@@ -0,0 +1,81 @@
+package otlpwire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestParseStatus(t *testing.T) {
+	detail := func(m proto.Message) *anypb.Any {
+		a, err := anypb.New(m)
+		require.NoError(t, err)
+		return a
+	}
+	data, err := proto.Marshal(&status.Status{
+		Code:    int32(GRPCCodeResourceExhausted),
+		Message: "quota exceeded",
+		Details: []*anypb.Any{
+			detail(&errdetails.ErrorInfo{
+				Reason:   "RATE_LIMIT_EXCEEDED",
+				Domain:   "ingest.example.com",
+				Metadata: map[string]string{"tenant": "acme", "limit": "1000"},
+			}),
+			detail(&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{
+				{Subject: "tenant:acme", Description: "spans per second"},
+				{Subject: "tenant:acme", Description: "bytes per second"},
+			}}),
+			detail(&errdetails.RetryInfo{RetryDelay: durationpb.New(5 * time.Second)}),
+			detail(&errdetails.DebugInfo{Detail: "shard 3"}),
+		},
+	})
+	require.NoError(t, err)
+
+	st, err := ParseStatus(data)
+	require.NoError(t, err)
+	require.Equal(t, GRPCCodeResourceExhausted, st.Code)
+	require.Equal(t, "quota exceeded", st.Message)
+	require.True(t, st.HasRetryInfo)
+	require.Equal(t, 5*time.Second, st.RetryDelay)
+	require.Equal(t, []ErrorInfo{{
+		Reason:   "RATE_LIMIT_EXCEEDED",
+		Domain:   "ingest.example.com",
+		Metadata: map[string]string{"tenant": "acme", "limit": "1000"},
+	}}, st.ErrorInfo)
+	require.Equal(t, []QuotaViolation{
+		{Subject: "tenant:acme", Description: "spans per second"},
+		{Subject: "tenant:acme", Description: "bytes per second"},
+	}, st.QuotaViolations)
+	require.Len(t, st.Details, 4)
+	require.Equal(t, "type.googleapis.com/google.rpc.DebugInfo", st.Details[3].TypeURL)
+	require.True(t, st.Retryable())
+	require.EqualError(t, st, "rpc error: code = RESOURCE_EXHAUSTED desc = quota exceeded")
+
+	st, err = ParseStatus(nil)
+	require.NoError(t, err)
+	require.Equal(t, Status{}, st)
+
+	_, err = ParseStatus([]byte{0x1a, 0x05})
+	require.Error(t, err)
+	_, err = ParseStatus([]byte{0x1a, 0x02, 0x0a, 0x05})
+	require.Error(t, err)
+}
+
+func TestStatus_Retryable(t *testing.T) {
+	retryable := map[GRPCCode]bool{
+		GRPCCodeCanceled: true, GRPCCodeDeadlineExceeded: true, GRPCCodeAborted: true,
+		GRPCCodeOutOfRange: true, GRPCCodeUnavailable: true, GRPCCodeDataLoss: true,
+	}
+	for code := GRPCCodeOK; code <= GRPCCodeUnauthenticated+1; code++ {
+		require.Equal(t, retryable[code], Status{Code: code}.Retryable(), code.String())
+	}
+	require.True(t, Status{Code: GRPCCodeResourceExhausted, HasRetryInfo: true}.Retryable())
+	require.Equal(t, "CODE(17)", GRPCCode(17).String())
+	require.Equal(t, "rpc error: code = UNAVAILABLE", Status{Code: GRPCCodeUnavailable}.Error())
+}
@@ -0,0 +1,87 @@
+package otlpwire
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// StripUnknownFields returns a copy of the request without any field that
+// is not part of the OTLP schema, at any depth, for egress paths that must
+// not forward opaque vendor extensions. It also returns the number of fields
+// dropped. Known fields are copied verbatim and in their original order.
+func (t ExportTracesServiceRequest) StripUnknownFields() (ExportTracesServiceRequest, int, error) {
+	out, dropped, err := appendKnownFields(nil, []byte(t), tracesSchema)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportTracesServiceRequest(out), dropped, nil
+}
+
+// StripUnknownFields returns a copy of the request without any field that
+// is not part of the OTLP schema, as ExportTracesServiceRequest.StripUnknownFields.
+func (m ExportMetricsServiceRequest) StripUnknownFields() (ExportMetricsServiceRequest, int, error) {
+	out, dropped, err := appendKnownFields(nil, []byte(m), metricsSchema)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportMetricsServiceRequest(out), dropped, nil
+}
+
+// StripUnknownFields returns a copy of the request without any field that
+// is not part of the OTLP schema, as ExportTracesServiceRequest.StripUnknownFields.
+func (l ExportLogsServiceRequest) StripUnknownFields() (ExportLogsServiceRequest, int, error) {
+	out, dropped, err := appendKnownFields(nil, []byte(l), logsSchema)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportLogsServiceRequest(out), dropped, nil
+}
+
+// appendKnownFields appends the fields of msg that schema knows to dst,
+// recursing into nested messages, and returns the number of fields dropped.
+func appendKnownFields(dst, msg []byte, schema *messageSchema) ([]byte, int, error) {
+	dropped := 0
+	pos := 0
+
+	for pos < len(msg) {
+		fieldStart := pos
+		num, wireType, tagLen := consumeTag(msg[pos:])
+		if tagLen < 0 {
+			return nil, 0, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		n := skipField(msg[pos:], wireType)
+		if n < 0 {
+			return nil, 0, errors.New("failed to skip field")
+		}
+		pos += n
+
+		field, known := schema.fields[num]
+		switch {
+		case !known:
+			dropped++
+		case field.message == nil:
+			dst = append(dst, msg[fieldStart:pos]...)
+		default:
+			if wireType != protowire.BytesType {
+				return nil, 0, errors.New("wrong wire type for field")
+			}
+			sub, _ := consumeBytes(msg[fieldStart+tagLen : pos])
+			var err error
+			dst, err = appendMessageField(dst, num, func(dst []byte) ([]byte, error) {
+				var childDropped int
+				var err error
+				dst, childDropped, err = appendKnownFields(dst, sub, field.message)
+				dropped += childDropped
+				return dst, err
+			})
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	return dst, dropped, nil
+}
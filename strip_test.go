@@ -0,0 +1,203 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// putAllValueTypes sets an attribute of every AnyValue type on m.
+func putAllValueTypes(m pcommon.Map) {
+	m.PutStr("str", "v")
+	m.PutBool("bool", true)
+	m.PutInt("int", 1)
+	m.PutDouble("double", 1.5)
+	m.PutEmptyBytes("bytes").FromRaw([]byte{1})
+	m.PutEmptySlice("slice").AppendEmpty().SetStr("elem")
+	m.PutEmptyMap("map").PutInt("nested", 2)
+}
+
+func fullTraces(t *testing.T) []byte {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("https://opentelemetry.io/schemas/1.26.0")
+	putAllValueTypes(rs.Resource().Attributes())
+	rs.Resource().SetDroppedAttributesCount(1)
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.SetSchemaUrl("scope-schema")
+	ss.Scope().SetName("lib")
+	ss.Scope().SetVersion("1.0")
+	ss.Scope().Attributes().PutStr("k", "v")
+	ss.Scope().SetDroppedAttributesCount(1)
+
+	span := ss.Spans().AppendEmpty()
+	span.SetTraceID(traceID(1))
+	span.SetSpanID(spanID(1))
+	span.SetParentSpanID(spanID(2))
+	span.TraceState().FromRaw("a=b")
+	span.SetFlags(1)
+	span.SetName("op")
+	span.SetKind(ptrace.SpanKindServer)
+	span.SetStartTimestamp(1)
+	span.SetEndTimestamp(2)
+	putAllValueTypes(span.Attributes())
+	span.SetDroppedAttributesCount(1)
+	event := span.Events().AppendEmpty()
+	event.SetName("event")
+	event.SetTimestamp(1)
+	event.Attributes().PutStr("k", "v")
+	event.SetDroppedAttributesCount(1)
+	span.SetDroppedEventsCount(1)
+	link := span.Links().AppendEmpty()
+	link.SetTraceID(traceID(2))
+	link.SetSpanID(spanID(3))
+	link.TraceState().FromRaw("c=d")
+	link.Attributes().PutStr("k", "v")
+	link.SetDroppedAttributesCount(1)
+	link.SetFlags(1)
+	span.SetDroppedLinksCount(1)
+	span.Status().SetCode(ptrace.StatusCodeError)
+	span.Status().SetMessage("failed")
+	return marshalTraces(t, traces)
+}
+
+// fullMetrics sets the fields of metrics that buildAllTypesMetrics leaves
+// empty: exemplars, buckets, bounds and quantiles.
+func fullMetrics(t *testing.T) []byte {
+	metrics := pmetric.NewMetrics()
+	sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	setExemplar := func(e pmetric.Exemplar) {
+		e.SetTimestamp(1)
+		e.SetDoubleValue(1.5)
+		e.SetTraceID(traceID(1))
+		e.SetSpanID(spanID(1))
+		e.FilteredAttributes().PutStr("k", "v")
+	}
+
+	m := sm.Metrics().AppendEmpty()
+	m.SetName("sum")
+	m.SetDescription("d")
+	m.SetUnit("1")
+	m.Metadata().PutStr("k", "v")
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	dp := sum.DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(1)
+	dp.SetDoubleValue(2)
+	dp.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+	setExemplar(dp.Exemplars().AppendEmpty())
+
+	hist := sm.Metrics().AppendEmpty().SetEmptyHistogram()
+	hist.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	hdp := hist.DataPoints().AppendEmpty()
+	hdp.SetCount(3)
+	hdp.SetSum(4)
+	hdp.SetMin(1)
+	hdp.SetMax(2)
+	hdp.BucketCounts().FromRaw([]uint64{1, 2})
+	hdp.ExplicitBounds().FromRaw([]float64{1})
+	hdp.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+	setExemplar(hdp.Exemplars().AppendEmpty())
+
+	exp := sm.Metrics().AppendEmpty().SetEmptyExponentialHistogram()
+	exp.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	edp := exp.DataPoints().AppendEmpty()
+	edp.SetCount(3)
+	edp.SetSum(4)
+	edp.SetScale(2)
+	edp.SetZeroCount(1)
+	edp.SetZeroThreshold(0.5)
+	edp.SetMin(1)
+	edp.SetMax(2)
+	edp.Positive().SetOffset(-1)
+	edp.Positive().BucketCounts().FromRaw([]uint64{1})
+	edp.Negative().SetOffset(1)
+	edp.Negative().BucketCounts().FromRaw([]uint64{1})
+	edp.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+	setExemplar(edp.Exemplars().AppendEmpty())
+
+	sdp := sm.Metrics().AppendEmpty().SetEmptySummary().DataPoints().AppendEmpty()
+	sdp.SetStartTimestamp(1)
+	sdp.SetCount(1)
+	sdp.SetSum(2)
+	q := sdp.QuantileValues().AppendEmpty()
+	q.SetQuantile(0.5)
+	q.SetValue(1)
+	sdp.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+	return marshalMetrics(t, metrics)
+}
+
+func TestStripUnknownFields_KnownFieldsKept(t *testing.T) {
+	traces := fullTraces(t)
+	out, dropped, err := ExportTracesServiceRequest(traces).StripUnknownFields()
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+	require.Equal(t, traces, []byte(out))
+
+	for _, metrics := range [][]byte{buildAllTypesMetrics(t), fullMetrics(t)} {
+		out, dropped, err := ExportMetricsServiceRequest(metrics).StripUnknownFields()
+		require.NoError(t, err)
+		require.Zero(t, dropped)
+		require.Equal(t, metrics, []byte(out))
+	}
+
+	logs := plog.NewLogs()
+	record := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.SetTimestamp(1)
+	record.SetObservedTimestamp(2)
+	record.SetSeverityNumber(plog.SeverityNumberWarn)
+	record.SetSeverityText("WARN")
+	putAllValueTypes(record.Body().SetEmptyMap())
+	putAllValueTypes(record.Attributes())
+	record.SetDroppedAttributesCount(1)
+	record.SetFlags(1)
+	record.SetTraceID(traceID(1))
+	record.SetSpanID(spanID(1))
+	record.SetEventName("event")
+	logsData := marshalLogs(t, logs)
+	outLogs, dropped, err := ExportLogsServiceRequest(logsData).StripUnknownFields()
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+	require.Equal(t, logsData, []byte(outLogs))
+}
+
+func TestStripUnknownFields_Dropped(t *testing.T) {
+	traces := fullTraces(t)
+	unknown := func(dst []byte, num protowire.Number) []byte {
+		dst = protowire.AppendTag(dst, num, protowire.BytesType)
+		return protowire.AppendString(dst, "vendor")
+	}
+
+	// An unknown field on every span, and one on the request itself.
+	tainted, err := rewriteRecords(traces, spanPath, func(dst, span []byte) ([]byte, bool, error) {
+		dst = append(dst, span...)
+		dst = protowire.AppendTag(dst, 1000, protowire.VarintType)
+		return protowire.AppendVarint(dst, 7), true, nil
+	})
+	require.NoError(t, err)
+	tainted = unknown(tainted, 2)
+	tainted = unknown(tainted, 99)
+
+	out, dropped, err := ExportTracesServiceRequest(tainted).StripUnknownFields()
+	require.NoError(t, err)
+	require.Equal(t, 3, dropped)
+	require.Equal(t, traces, []byte(out))
+}
+
+func TestStripUnknownFields_Malformed(t *testing.T) {
+	// A known message field with a varint wire type.
+	_, _, err := ExportLogsServiceRequest([]byte{0x08, 0x01}).StripUnknownFields()
+	require.ErrorContains(t, err, "wrong wire type")
+
+	_, _, err = ExportMetricsServiceRequest([]byte{0x0a, 0x05, 0x00}).StripUnknownFields()
+	require.Error(t, err)
+
+	_, _, err = ExportTracesServiceRequest([]byte{0x0a, 0x02, 0x0a, 0x05}).StripUnknownFields()
+	require.Error(t, err)
+}
@@ -0,0 +1,250 @@
+package otlpwire
+
+import (
+	"strconv"
+	"time"
+)
+
+// SyslogConfig configures AppendSyslog.
+type SyslogConfig struct {
+	// Facility is the syslog facility code, 1 to 23. Zero or an
+	// out-of-range value selects 1 (user-level messages); facility 0 is
+	// reserved for the kernel.
+	Facility int
+	// Hostname and AppName are used when a resource has no host.name or
+	// service.name string attribute. Empty means the NILVALUE "-".
+	Hostname, AppName string
+	// StructuredDataID is the SD-ID of the element carrying the resource
+	// attributes. It defaults to "resource@32473", under the private
+	// enterprise number reserved for documentation; forwarders should use
+	// their own.
+	StructuredDataID string
+	// OctetCounting frames each message with its length as in RFC 6587
+	// section 3.4.1 instead of terminating it with a newline.
+	OctetCounting bool
+}
+
+// AppendSyslog appends the log records of the request to dst as RFC 5424
+// syslog messages and returns the extended buffer and the number of messages.
+// Each message carries:
+//
+//   - PRI from the facility and severity_number: TRACE and DEBUG map to debug
+//     (7), INFO and UNSPECIFIED to informational (6), WARN to warning (4),
+//     ERROR to error (3) and FATAL to critical (2)
+//   - TIMESTAMP from time_unix_nano, or observed_time_unix_nano when unset, in
+//     UTC with microsecond precision
+//   - HOSTNAME and APP-NAME from the host.name and service.name resource
+//     attributes
+//   - one STRUCTURED-DATA element holding the resource attributes, omitted when
+//     the resource has none
+//   - MSG from the record body rendered as text
+//
+// Messages are newline-terminated unless cfg.OctetCounting is set. A newline
+// inside a body is written as is, so bodies that may contain newlines should
+// be sent with octet counting. On error the returned buffer holds the
+// messages appended so far.
+func (l ExportLogsServiceRequest) AppendSyslog(dst []byte, cfg SyslogConfig) ([]byte, int, error) {
+	facility := cfg.Facility
+	if facility <= 0 || facility > 23 {
+		facility = 1
+	}
+	sdID := cfg.StructuredDataID
+	if sdID == "" {
+		sdID = "resource@32473"
+	}
+
+	var msg []byte
+	count := 0
+	err := forEachLogRecord([]byte(l), func(resource, _, record []byte) error {
+		var err error
+		msg, err = appendSyslogMessage(msg[:0], resource, record, facility, sdID, cfg)
+		if err != nil {
+			return err
+		}
+		if cfg.OctetCounting {
+			dst = strconv.AppendInt(dst, int64(len(msg)), 10)
+			dst = append(dst, ' ')
+			dst = append(dst, msg...)
+		} else {
+			dst = append(dst, msg...)
+			dst = append(dst, '\n')
+		}
+		count++
+		return nil
+	})
+	return dst, count, err
+}
+
+func appendSyslogMessage(dst, resource, record []byte, facility int, sdID string, cfg SyslogConfig) ([]byte, error) {
+	severity, err := extractVarintField(record, 2)
+	if err != nil {
+		return dst, err
+	}
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(facility*8+syslogSeverity(severity)), 10)
+	dst = append(dst, ">1 "...)
+
+	ts, err := logRecordTime(record)
+	if err != nil {
+		return dst, err
+	}
+	if ts == 0 {
+		dst = append(dst, '-')
+	} else {
+		dst = time.Unix(0, int64(ts)).UTC().AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+	}
+	dst = append(dst, ' ')
+
+	host, ok, err := stringAttribute(resource, 1, "host.name")
+	if err != nil {
+		return dst, err
+	}
+	if !ok {
+		host = cfg.Hostname
+	}
+	dst = appendSyslogHeaderField(dst, host, 255)
+	dst = append(dst, ' ')
+
+	app, ok, err := stringAttribute(resource, 1, "service.name")
+	if err != nil {
+		return dst, err
+	}
+	if !ok {
+		app = cfg.AppName
+	}
+	dst = appendSyslogHeaderField(dst, app, 48)
+	// PROCID and MSGID are not derived.
+	dst = append(dst, " - - "...)
+
+	dst, err = appendSyslogStructuredData(dst, resource, sdID)
+	if err != nil {
+		return dst, err
+	}
+
+	body, err := extractBytesField(record, 5)
+	if err != nil || body == nil {
+		return dst, err
+	}
+	dst = append(dst, ' ')
+	n := len(dst)
+	dst, err = appendAnyValueText(dst, body)
+	if err == nil && len(dst) == n {
+		// An empty body leaves no MSG part.
+		dst = dst[:n-1]
+	}
+	return dst, err
+}
+
+// syslogSeverity maps an OTLP severity_number to a syslog severity.
+func syslogSeverity(n uint64) int {
+	if n == 0 || n > 24 {
+		return 6
+	}
+	return [...]int{7, 7, 6, 4, 3, 2}[(n-1)/4]
+}
+
+// appendSyslogHeaderField appends a header field of at most limit printable
+// US-ASCII characters, replacing other bytes with '_', or the NILVALUE when
+// s is empty.
+func appendSyslogHeaderField(dst []byte, s string, limit int) []byte {
+	if s == "" {
+		return append(dst, '-')
+	}
+	if len(s) > limit {
+		s = s[:limit]
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 33 || c > 126 {
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// appendSyslogStructuredData appends the resource attributes as one SD-ELEMENT,
+// or the NILVALUE when there are none.
+func appendSyslogStructuredData(dst, resource []byte, sdID string) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, '[')
+	dst = appendSyslogName(dst, sdID)
+	empty := true
+	err := forEachMessage(resource, 1, func(kv []byte) error {
+		key, err := extractBytesField(kv, 1)
+		if err != nil {
+			return err
+		}
+		value, err := extractBytesField(kv, 2)
+		if err != nil {
+			return err
+		}
+		empty = false
+		dst = append(dst, ' ')
+		dst = appendSyslogName(dst, string(key))
+		dst = append(dst, '=', '"')
+		valueStart := len(dst)
+		dst, err = appendAnyValueText(dst, value)
+		if err != nil {
+			return err
+		}
+		dst = escapeSyslogParamValue(dst, valueStart)
+		dst = append(dst, '"')
+		return nil
+	})
+	if err != nil {
+		return dst, err
+	}
+	if empty {
+		return append(dst[:start], '-'), nil
+	}
+	return append(dst, ']'), nil
+}
+
+// appendSyslogName appends an SD-ID or PARAM-NAME: at most 32 printable
+// US-ASCII characters other than '=', ' ', ']' and '"', with other bytes
+// replaced by '_'.
+func appendSyslogName(dst []byte, s string) []byte {
+	if s == "" {
+		return append(dst, '_')
+	}
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// escapeSyslogParamValue escapes '"', '\' and ']' in dst[start:] with a
+// backslash, as RFC 5424 requires inside PARAM-VALUE.
+func escapeSyslogParamValue(dst []byte, start int) []byte {
+	extra := 0
+	for _, c := range dst[start:] {
+		if c == '"' || c == '\\' || c == ']' {
+			extra++
+		}
+	}
+	if extra == 0 {
+		return dst
+	}
+	n := len(dst)
+	dst = append(dst, make([]byte, extra)...)
+	// Shift from the end so each byte moves at most once.
+	w := len(dst)
+	for r := n - 1; r >= start; r-- {
+		c := dst[r]
+		w--
+		dst[w] = c
+		if c == '"' || c == '\\' || c == ']' {
+			w--
+			dst[w] = '\\'
+		}
+	}
+	return dst
+}
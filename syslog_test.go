@@ -0,0 +1,69 @@
+package otlpwire
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestAppendSyslog(t *testing.T) {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	rl.Resource().Attributes().PutStr("host.name", "web 1")
+	rl.Resource().Attributes().PutStr("note", `a "b" ]c\`)
+	rl.Resource().Attributes().PutInt("replicas", 3)
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	lr := records.AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	lr.SetSeverityNumber(plog.SeverityNumberError)
+	lr.Body().SetStr("payment failed")
+
+	lr = records.AppendEmpty()
+	lr.SetObservedTimestamp(pcommon.NewTimestampFromTime(ts))
+	lr.SetSeverityNumber(plog.SeverityNumberDebug2)
+	lr.Body().SetEmptyMap().PutInt("attempt", 2)
+
+	// No resource attributes, no timestamps, no body.
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+
+	data := ExportLogsServiceRequest(marshalLogs(t, logs))
+	out, n, err := data.AppendSyslog([]byte("prefix\n"), SyslogConfig{Hostname: "fallback", AppName: "app"})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	const sd = `[resource@32473 service.name="checkout" host.name="web 1" note="a \"b\" \]c\\" replicas="3"]`
+	require.Equal(t, []string{
+		"prefix",
+		`<11>1 2024-05-06T07:08:09.123456Z web_1 checkout - - ` + sd + ` payment failed`,
+		`<15>1 2024-05-06T07:08:09.123456Z web_1 checkout - - ` + sd + ` {"attempt":2}`,
+		`<14>1 - fallback app - - -`,
+		"",
+	}, strings.Split(string(out), "\n"))
+}
+
+func TestAppendSyslog_OctetCounting(t *testing.T) {
+	logs := plog.NewLogs()
+	lr := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetSeverityNumber(plog.SeverityNumberFatal)
+	lr.Body().SetStr("line one\nline two")
+
+	data := ExportLogsServiceRequest(marshalLogs(t, logs))
+	out, n, err := data.AppendSyslog(nil, SyslogConfig{Facility: 16, OctetCounting: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	msg := "<130>1 - - - - - - line one\nline two"
+	require.Equal(t, "36 "+msg, string(out))
+}
+
+func TestAppendSyslog_Malformed(t *testing.T) {
+	_, _, err := ExportLogsServiceRequest([]byte{0x0a, 0x05}).AppendSyslog(nil, SyslogConfig{})
+	require.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package otlpwire
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// GRPCCode is a gRPC status code, as carried in the grpc-status trailer and
+// the code field of a google.rpc.Status.
+type GRPCCode uint32
+
+// gRPC status codes.
+const (
+	GRPCCodeOK                 GRPCCode = 0
+	GRPCCodeCanceled           GRPCCode = 1
+	GRPCCodeUnknown            GRPCCode = 2
+	GRPCCodeInvalidArgument    GRPCCode = 3
+	GRPCCodeDeadlineExceeded   GRPCCode = 4
+	GRPCCodeNotFound           GRPCCode = 5
+	GRPCCodeAlreadyExists      GRPCCode = 6
+	GRPCCodePermissionDenied   GRPCCode = 7
+	GRPCCodeResourceExhausted  GRPCCode = 8
+	GRPCCodeFailedPrecondition GRPCCode = 9
+	GRPCCodeAborted            GRPCCode = 10
+	GRPCCodeOutOfRange         GRPCCode = 11
+	GRPCCodeUnimplemented      GRPCCode = 12
+	GRPCCodeInternal           GRPCCode = 13
+	GRPCCodeUnavailable        GRPCCode = 14
+	GRPCCodeDataLoss           GRPCCode = 15
+	GRPCCodeUnauthenticated    GRPCCode = 16
+)
+
+// retryInfoTypeURL is the Any type URL of a google.rpc.RetryInfo detail.
+const retryInfoTypeURL = "type.googleapis.com/google.rpc.RetryInfo"
+
+// IsThrottlingHTTPStatus reports whether an OTLP/HTTP response status asks
+// the client to slow down: 429 Too Many Requests or 503 Service Unavailable.
+// The client should wait for the response's Retry-After delay, if any,
+// before retrying.
+func IsThrottlingHTTPStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// RetryAfter returns the delay of the Retry-After header of h, given either
+// as seconds or as an HTTP date relative to now. ok is false if the header is
+// absent or malformed. A date in the past yields a zero delay.
+func RetryAfter(h http.Header, now time.Time) (d time.Duration, ok bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseUint(v, 10, 64); err == nil {
+		if secs > uint64(math.MaxInt64/time.Second) {
+			return math.MaxInt64, true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// SetRetryAfter sets the Retry-After header of h to d in whole seconds,
+// rounded up so that clients never retry early.
+func SetRetryAfter(h http.Header, d time.Duration) {
+	secs := (max(d, 0) + time.Second - 1) / time.Second
+	h.Set("Retry-After", strconv.FormatInt(int64(secs), 10))
+}
+
+// AppendThrottlingStatus appends a google.rpc.Status with code, message and
+// a RetryInfo detail asking the client to wait retryDelay, as an OTLP/gRPC
+// server reports throttling. code is typically GRPCCodeResourceExhausted or
+// GRPCCodeUnavailable. The result is the value of the
+// grpc-status-details-bin trailer, before base64 encoding.
+func AppendThrottlingStatus(dst []byte, code GRPCCode, message string, retryDelay time.Duration) []byte {
+	retryDelay = max(retryDelay, 0)
+	var duration []byte
+	if secs := int64(retryDelay / time.Second); secs != 0 {
+		duration = protowire.AppendTag(duration, 1, protowire.VarintType)
+		duration = protowire.AppendVarint(duration, uint64(secs))
+	}
+	if nanos := int64(retryDelay % time.Second); nanos != 0 {
+		duration = protowire.AppendTag(duration, 2, protowire.VarintType)
+		duration = protowire.AppendVarint(duration, uint64(nanos))
+	}
+	retryInfo := protowire.AppendTag(nil, 1, protowire.BytesType)
+	retryInfo = protowire.AppendBytes(retryInfo, duration)
+
+	detail := protowire.AppendTag(nil, 1, protowire.BytesType)
+	detail = protowire.AppendString(detail, retryInfoTypeURL)
+	detail = protowire.AppendTag(detail, 2, protowire.BytesType)
+	detail = protowire.AppendBytes(detail, retryInfo)
+
+	if code != GRPCCodeOK {
+		dst = protowire.AppendTag(dst, 1, protowire.VarintType)
+		dst = protowire.AppendVarint(dst, uint64(code))
+	}
+	if message != "" {
+		dst = protowire.AppendTag(dst, 2, protowire.BytesType)
+		dst = protowire.AppendString(dst, message)
+	}
+	dst = protowire.AppendTag(dst, 3, protowire.BytesType)
+	return protowire.AppendBytes(dst, detail)
+}
+
+// StatusRetryDelay returns the delay of the first RetryInfo detail of an
+// encoded google.rpc.Status. ok is false if the status has no RetryInfo.
+func StatusRetryDelay(status []byte) (d time.Duration, ok bool, err error) {
+	err = forEachMessage(status, 3, func(detail []byte) error {
+		if ok {
+			return nil
+		}
+		typeURL, err := extractBytesField(detail, 1)
+		if err != nil || string(typeURL) != retryInfoTypeURL {
+			return err
+		}
+		value, err := extractBytesField(detail, 2)
+		if err != nil {
+			return err
+		}
+		d, err = parseRetryInfo(value)
+		ok = err == nil
+		return err
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return d, ok, nil
+}
+
+// parseRetryInfo decodes the retry_delay of a google.rpc.RetryInfo. A
+// negative delay is treated as zero.
+func parseRetryInfo(retryInfo []byte) (time.Duration, error) {
+	duration, err := extractBytesField(retryInfo, 1)
+	if err != nil {
+		return 0, err
+	}
+	secs, err := extractVarintField(duration, 1)
+	if err != nil {
+		return 0, err
+	}
+	nanos, err := extractVarintField(duration, 2)
+	if err != nil {
+		return 0, err
+	}
+	s, ns := int64(secs), int64(int32(nanos))
+	if s < 0 || s == 0 && ns < 0 {
+		return 0, nil
+	}
+	if s > int64(math.MaxInt64/time.Second)-1 {
+		return math.MaxInt64, nil
+	}
+	return time.Duration(s)*time.Second + time.Duration(ns), nil
+}
@@ -0,0 +1,108 @@
+package otlpwire
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		"absent":    {},
+		"seconds":   {header: "120", want: 2 * time.Minute, ok: true},
+		"zero":      {header: "0", ok: true},
+		"date":      {header: "Wed, 01 May 2024 12:00:30 GMT", want: 30 * time.Second, ok: true},
+		"past date": {header: "Wed, 01 May 2024 11:00:00 GMT", ok: true},
+		"negative":  {header: "-5"},
+		"garbage":   {header: "soon"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := http.Header{}
+			if tc.header != "" {
+				h.Set("Retry-After", tc.header)
+			}
+			d, ok := RetryAfter(h, now)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.want, d)
+		})
+	}
+
+	h := http.Header{}
+	SetRetryAfter(h, 1500*time.Millisecond)
+	require.Equal(t, "2", h.Get("Retry-After"))
+	SetRetryAfter(h, -time.Second)
+	require.Equal(t, "0", h.Get("Retry-After"))
+
+	require.True(t, IsThrottlingHTTPStatus(http.StatusTooManyRequests))
+	require.True(t, IsThrottlingHTTPStatus(http.StatusServiceUnavailable))
+	require.False(t, IsThrottlingHTTPStatus(http.StatusBadGateway))
+}
+
+func TestThrottlingStatus(t *testing.T) {
+	data := AppendThrottlingStatus(nil, GRPCCodeResourceExhausted, "slow down", 2500*time.Millisecond)
+
+	var st status.Status
+	require.NoError(t, proto.Unmarshal(data, &st))
+	require.Equal(t, int32(GRPCCodeResourceExhausted), st.Code)
+	require.Equal(t, "slow down", st.Message)
+	require.Len(t, st.Details, 1)
+	var info errdetails.RetryInfo
+	require.NoError(t, st.Details[0].UnmarshalTo(&info))
+	require.Equal(t, 2500*time.Millisecond, info.RetryDelay.AsDuration())
+
+	d, ok, err := StatusRetryDelay(data)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 2500*time.Millisecond, d)
+}
+
+func TestStatusRetryDelay(t *testing.T) {
+	detail := func(m proto.Message) *anypb.Any {
+		a, err := anypb.New(m)
+		require.NoError(t, err)
+		return a
+	}
+	marshal := func(st *status.Status) []byte {
+		data, err := proto.Marshal(st)
+		require.NoError(t, err)
+		return data
+	}
+
+	// The first RetryInfo wins; other details are skipped.
+	data := marshal(&status.Status{Code: 14, Details: []*anypb.Any{
+		detail(&errdetails.ErrorInfo{Reason: "overloaded"}),
+		detail(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)}),
+		detail(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Minute)}),
+	}})
+	d, ok, err := StatusRetryDelay(data)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, time.Second, d)
+
+	data = marshal(&status.Status{Code: 14, Details: []*anypb.Any{
+		detail(&errdetails.RetryInfo{RetryDelay: durationpb.New(-time.Second)}),
+	}})
+	d, ok, err = StatusRetryDelay(data)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Zero(t, d)
+
+	_, ok, err = StatusRetryDelay(marshal(&status.Status{Code: 8, Message: "no details"}))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = StatusRetryDelay([]byte{0x1a, 0x05})
+	require.Error(t, err)
+}
@@ -0,0 +1,415 @@
+package otlpwire
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"time"
+)
+
+// TraceIndex groups spans from one or more traces requests by trace ID and
+// links each span to its parent and children within the index. Spans are
+// views into the indexed requests, which must not be modified while the index
+// is in use; only the IDs needed for linking are decoded.
+type TraceIndex struct {
+	traces map[[16]byte]*IndexedTrace
+	order  []*IndexedTrace
+}
+
+// IndexedTrace holds the indexed spans of one trace, in the order they were
+// added.
+type IndexedTrace struct {
+	TraceID [16]byte
+	Spans   []IndexedSpan
+
+	byID map[[8]byte]int
+	// orphans holds, per parent span ID not yet indexed, the spans that
+	// reference it.
+	orphans map[[8]byte][]int
+}
+
+// IndexedSpan is one span of an IndexedTrace.
+type IndexedSpan struct {
+	Span Span
+	// Resource is the raw Resource message of the span's ResourceSpans, or
+	// nil if it has none.
+	Resource     []byte
+	SpanID       [8]byte
+	ParentSpanID [8]byte
+	// Parent is the index in IndexedTrace.Spans of the parent span, or -1 if
+	// the span is a root or its parent has not been indexed.
+	Parent int
+	// Children are the indexes in IndexedTrace.Spans of the span's children.
+	Children []int
+}
+
+// NewTraceIndex returns an empty TraceIndex.
+func NewTraceIndex() *TraceIndex {
+	return &TraceIndex{traces: make(map[[16]byte]*IndexedTrace)}
+}
+
+// Add indexes every span of req. Parents and children are linked across
+// requests, regardless of the order in which they are added. If req is
+// malformed, Add returns an error and leaves the index unchanged.
+func (x *TraceIndex) Add(req ExportTracesServiceRequest) error {
+	type pendingSpan struct {
+		traceID [16]byte
+		span    IndexedSpan
+	}
+	var pending []pendingSpan
+
+	err := forEachMessage([]byte(req), 1, func(rs []byte) error {
+		resource, err := extractBytesField(rs, 1)
+		if err != nil {
+			return err
+		}
+		return forEachMessage(rs, 2, func(ss []byte) error {
+			return forEachMessage(ss, 2, func(raw []byte) error {
+				span := Span(raw)
+				traceID, err := span.TraceID()
+				if err != nil {
+					return err
+				}
+				spanID, err := span.SpanID()
+				if err != nil {
+					return err
+				}
+				parentID, err := span.ParentSpanID()
+				if err != nil {
+					return err
+				}
+				pending = append(pending, pendingSpan{traceID, IndexedSpan{
+					Span:         span,
+					Resource:     resource,
+					SpanID:       spanID,
+					ParentSpanID: parentID,
+					Parent:       -1,
+				}})
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pending {
+		x.trace(p.traceID).add(p.span)
+	}
+	return nil
+}
+
+// Len returns the number of indexed traces.
+func (x *TraceIndex) Len() int { return len(x.order) }
+
+// Trace returns the indexed trace with the given ID.
+func (x *TraceIndex) Trace(id [16]byte) (*IndexedTrace, bool) {
+	t, ok := x.traces[id]
+	return t, ok
+}
+
+// Traces returns an iterator over the indexed traces in order of first
+// appearance.
+func (x *TraceIndex) Traces() iter.Seq[*IndexedTrace] {
+	return func(yield func(*IndexedTrace) bool) {
+		for _, t := range x.order {
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+func (x *TraceIndex) trace(id [16]byte) *IndexedTrace {
+	t, ok := x.traces[id]
+	if !ok {
+		t = &IndexedTrace{
+			TraceID: id,
+			byID:    make(map[[8]byte]int),
+			orphans: make(map[[8]byte][]int),
+		}
+		x.traces[id] = t
+		x.order = append(x.order, t)
+	}
+	return t
+}
+
+// add appends span and links it with its parent and any children already
+// indexed.
+func (t *IndexedTrace) add(span IndexedSpan) {
+	i := len(t.Spans)
+	for _, child := range t.orphans[span.SpanID] {
+		t.Spans[child].Parent = i
+		span.Children = append(span.Children, child)
+	}
+	delete(t.orphans, span.SpanID)
+	if parent, ok := t.byID[span.ParentSpanID]; ok && !span.isRoot() {
+		span.Parent = parent
+		t.Spans[parent].Children = append(t.Spans[parent].Children, i)
+	} else if !span.isRoot() {
+		t.orphans[span.ParentSpanID] = append(t.orphans[span.ParentSpanID], i)
+	}
+	t.byID[span.SpanID] = i
+	t.Spans = append(t.Spans, span)
+}
+
+// Roots returns the indexes of the spans that have no parent span ID.
+func (t *IndexedTrace) Roots() []int {
+	var roots []int
+	for i, s := range t.Spans {
+		if s.isRoot() {
+			roots = append(roots, i)
+		}
+	}
+	return roots
+}
+
+func (s IndexedSpan) isRoot() bool {
+	return s.ParentSpanID == [8]byte{}
+}
+
+// TraceCompleteness reports whether the spans of one trace in a batch form a
+// closed tree.
+type TraceCompleteness struct {
+	TraceID [16]byte
+	// Spans is the number of spans of the trace in the batch.
+	Spans int
+	// Roots is the number of spans without a parent span ID.
+	Roots int
+	// MissingParents lists the parent span IDs referenced by spans of the
+	// trace that are not present in the batch, in order of first reference.
+	MissingParents [][8]byte
+}
+
+// Complete reports whether every parent span ID referenced by the trace is
+// present in the batch.
+func (c TraceCompleteness) Complete() bool {
+	return len(c.MissingParents) == 0
+}
+
+// CompletenessReport returns, per trace ID in order of first appearance,
+// whether every parent_span_id referenced in the batch resolves to a span of
+// the same trace in the batch. A buffer can use it to decide which traces are
+// ready for a tail-sampling decision.
+func (t ExportTracesServiceRequest) CompletenessReport() ([]TraceCompleteness, error) {
+	index := NewTraceIndex()
+	if err := index.Add(t); err != nil {
+		return nil, err
+	}
+	reports := make([]TraceCompleteness, 0, index.Len())
+	for trace := range index.Traces() {
+		reports = append(reports, trace.completeness())
+	}
+	return reports, nil
+}
+
+func (t *IndexedTrace) completeness() TraceCompleteness {
+	c := TraceCompleteness{TraceID: t.TraceID, Spans: len(t.Spans)}
+	seen := make(map[[8]byte]bool)
+	for _, s := range t.Spans {
+		switch {
+		case s.isRoot():
+			c.Roots++
+		case s.Parent < 0 && !seen[s.ParentSpanID]:
+			seen[s.ParentSpanID] = true
+			c.MissingParents = append(c.MissingParents, s.ParentSpanID)
+		}
+	}
+	return c
+}
+
+// RootDuration returns the duration of the trace's root span, the span
+// without a parent span ID. If the trace has several roots the one that
+// starts first is used; ok is false if it has none. A root that ends before
+// it starts has zero duration.
+func (t *IndexedTrace) RootDuration() (d time.Duration, ok bool, err error) {
+	var rootStart uint64
+	for _, i := range t.Roots() {
+		start, end, err := spanInterval(t.Spans[i].Span)
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok || start < rootStart {
+			rootStart, d, ok = start, time.Duration(end-start), true
+		}
+	}
+	return d, ok, nil
+}
+
+// SelfTimes returns the self time of every span of the trace, indexed like
+// Spans: the part of the span's duration not covered by any of its indexed
+// children. Summing the result gives the trace's total self time.
+func (t *IndexedTrace) SelfTimes() ([]time.Duration, error) {
+	type interval struct{ start, end uint64 }
+	intervals := make([]interval, len(t.Spans))
+	for i, s := range t.Spans {
+		start, end, err := spanInterval(s.Span)
+		if err != nil {
+			return nil, err
+		}
+		intervals[i] = interval{start, end}
+	}
+
+	self := make([]time.Duration, len(t.Spans))
+	var children []interval
+	for i, s := range t.Spans {
+		span := intervals[i]
+		children = children[:0]
+		for _, c := range s.Children {
+			// Clip each child to its parent.
+			child := interval{max(intervals[c].start, span.start), min(intervals[c].end, span.end)}
+			if child.start < child.end {
+				children = append(children, child)
+			}
+		}
+		slices.SortFunc(children, func(a, b interval) int { return cmp.Compare(a.start, b.start) })
+
+		covered := uint64(0)
+		var cur interval
+		for j, c := range children {
+			switch {
+			case j == 0:
+				cur = c
+			case c.start <= cur.end:
+				cur.end = max(cur.end, c.end)
+			default:
+				covered += cur.end - cur.start
+				cur = c
+			}
+		}
+		if len(children) > 0 {
+			covered += cur.end - cur.start
+		}
+		self[i] = time.Duration(span.end - span.start - covered)
+	}
+	return self, nil
+}
+
+// spanInterval returns the start and end time of a span, with the end
+// clamped to the start if the span ends before it starts.
+func spanInterval(s Span) (start, end uint64, err error) {
+	if start, err = s.StartTime(); err != nil {
+		return 0, 0, err
+	}
+	if end, err = s.EndTime(); err != nil {
+		return 0, 0, err
+	}
+	return start, max(start, end), nil
+}
+
+// peerAttributes are the span attributes that name the remote side of a
+// client span whose server side is not instrumented, in order of preference.
+// They match the OpenTelemetry Collector's service graph connector defaults.
+var peerAttributes = []string{"peer.service", "db.name", "db.system"}
+
+// ServiceEdge counts the calls from one service to another.
+type ServiceEdge struct {
+	Client, Server string
+	Calls          int
+	// Errors counts calls where the client or the server span has an error
+	// status.
+	Errors int
+}
+
+// ServiceGraphEdges returns the service-to-service edges of the request, in
+// order of first appearance; see TraceIndex.ServiceGraphEdges.
+func (t ExportTracesServiceRequest) ServiceGraphEdges() ([]ServiceEdge, error) {
+	index := NewTraceIndex()
+	if err := index.Add(t); err != nil {
+		return nil, err
+	}
+	return index.ServiceGraphEdges()
+}
+
+// ServiceGraphEdges pairs every client or producer span with its server or
+// consumer child spans and counts the calls per (client service, server
+// service), where a service is the service.name resource attribute. A client
+// span without an indexed server child is counted against the first of its
+// peer.service, db.name or db.system attributes instead, if any. Edges are
+// returned in order of first appearance.
+func (x *TraceIndex) ServiceGraphEdges() ([]ServiceEdge, error) {
+	var edges []ServiceEdge
+	byPair := make(map[[2]string]int)
+	record := func(client, server string, failed bool) {
+		i, ok := byPair[[2]string{client, server}]
+		if !ok {
+			i = len(edges)
+			byPair[[2]string{client, server}] = i
+			edges = append(edges, ServiceEdge{Client: client, Server: server})
+		}
+		edges[i].Calls++
+		if failed {
+			edges[i].Errors++
+		}
+	}
+
+	for trace := range x.Traces() {
+		for _, s := range trace.Spans {
+			kind, err := s.Span.Kind()
+			if err != nil {
+				return nil, err
+			}
+			if kind != SpanKindClient && kind != SpanKindProducer {
+				continue
+			}
+			client, err := serviceName(s.Resource)
+			if err != nil {
+				return nil, err
+			}
+			clientFailed, err := spanFailed(s.Span)
+			if err != nil {
+				return nil, err
+			}
+
+			paired := false
+			for _, c := range s.Children {
+				child := trace.Spans[c]
+				kind, err := child.Span.Kind()
+				if err != nil {
+					return nil, err
+				}
+				if kind != SpanKindServer && kind != SpanKindConsumer {
+					continue
+				}
+				server, err := serviceName(child.Resource)
+				if err != nil {
+					return nil, err
+				}
+				serverFailed, err := spanFailed(child.Span)
+				if err != nil {
+					return nil, err
+				}
+				record(client, server, clientFailed || serverFailed)
+				paired = true
+			}
+			if paired {
+				continue
+			}
+
+			for _, attr := range peerAttributes {
+				peer, ok, err := stringAttribute(s.Span, 9, attr)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					record(client, peer, clientFailed)
+					break
+				}
+			}
+		}
+	}
+	return edges, nil
+}
+
+// serviceName returns the service.name attribute of a Resource message, or
+// "" if it has none.
+func serviceName(resource []byte) (string, error) {
+	name, _, err := stringAttribute(resource, 1, "service.name")
+	return name, err
+}
+
+// spanFailed reports whether a span's status code is ERROR.
+func spanFailed(span Span) (bool, error) {
+	code, err := span.StatusCode()
+	return code == StatusCodeError, err
+}
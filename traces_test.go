@@ -0,0 +1,254 @@
+package otlpwire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testSpan describes a span for buildTraceTree. IDs are single bytes for
+// readability; zero means unset.
+type testSpan struct {
+	trace, id, parent byte
+	name              string
+	start, end        uint64
+}
+
+func traceID(b byte) [16]byte { return [16]byte{15: b} }
+func spanID(b byte) [8]byte   { return [8]byte{7: b} }
+
+// buildTraceTree marshals spans, in order, into a traces request with a
+// single resource and scope.
+func buildTraceTree(t *testing.T, spans ...testSpan) []byte {
+	t.Helper()
+	traces := ptrace.NewTraces()
+	ss := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	for _, s := range spans {
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID(traceID(s.trace)))
+		span.SetSpanID(pcommon.SpanID(spanID(s.id)))
+		if s.parent != 0 {
+			span.SetParentSpanID(pcommon.SpanID(spanID(s.parent)))
+		}
+		span.SetName(s.name)
+		span.SetStartTimestamp(pcommon.Timestamp(s.start))
+		span.SetEndTimestamp(pcommon.Timestamp(s.end))
+	}
+	return marshalTraces(t, traces)
+}
+
+func TestCompletenessReport(t *testing.T) {
+	data := buildTraceTree(t,
+		testSpan{trace: 1, id: 2, parent: 1},
+		testSpan{trace: 2, id: 1},
+		testSpan{trace: 1, id: 1},
+		testSpan{trace: 2, id: 2, parent: 9},
+		testSpan{trace: 2, id: 3, parent: 9},
+		testSpan{trace: 2, id: 4, parent: 8},
+		// Parent present only in another trace does not count.
+		testSpan{trace: 3, id: 5, parent: 1},
+	)
+
+	reports, err := ExportTracesServiceRequest(data).CompletenessReport()
+	require.NoError(t, err)
+	require.Equal(t, []TraceCompleteness{
+		{TraceID: traceID(1), Spans: 2, Roots: 1},
+		{TraceID: traceID(2), Spans: 4, Roots: 1, MissingParents: [][8]byte{spanID(9), spanID(8)}},
+		{TraceID: traceID(3), Spans: 1, MissingParents: [][8]byte{spanID(1)}},
+	}, reports)
+	require.True(t, reports[0].Complete())
+	require.False(t, reports[1].Complete())
+}
+
+func TestCompletenessReport_Empty(t *testing.T) {
+	reports, err := ExportTracesServiceRequest(nil).CompletenessReport()
+	require.NoError(t, err)
+	require.Empty(t, reports)
+}
+
+func TestCompletenessReport_Malformed(t *testing.T) {
+	// Span with a 4-byte parent span ID.
+	span := protowire.AppendTag(nil, 4, protowire.BytesType)
+	span = protowire.AppendBytes(span, []byte{1, 2, 3, 4})
+	_, err := ExportTracesServiceRequest(wrapRecord(span)).CompletenessReport()
+	require.Error(t, err)
+}
+
+func TestTraceIndex(t *testing.T) {
+	// Children arrive before their parent, split across two requests.
+	first := buildTraceTree(t,
+		testSpan{trace: 1, id: 3, parent: 2, name: "grandchild"},
+		testSpan{trace: 2, id: 1, name: "other"},
+		testSpan{trace: 1, id: 2, parent: 1, name: "child"},
+	)
+	second := buildTraceTree(t,
+		testSpan{trace: 1, id: 1, name: "root"},
+		testSpan{trace: 1, id: 4, parent: 1, name: "sibling"},
+	)
+
+	index := NewTraceIndex()
+	require.NoError(t, index.Add(first))
+	require.NoError(t, index.Add(second))
+	require.Equal(t, 2, index.Len())
+
+	var ids [][16]byte
+	for trace := range index.Traces() {
+		ids = append(ids, trace.TraceID)
+	}
+	require.Equal(t, [][16]byte{traceID(1), traceID(2)}, ids)
+
+	trace, ok := index.Trace(traceID(1))
+	require.True(t, ok)
+	require.Len(t, trace.Spans, 4)
+	require.Equal(t, []int{2}, trace.Roots())
+
+	type link struct {
+		name     string
+		parent   int
+		children []int
+	}
+	var links []link
+	for _, s := range trace.Spans {
+		name, err := protoStringField(s.Span, 5)
+		require.NoError(t, err)
+		links = append(links, link{name, s.Parent, s.Children})
+	}
+	require.Equal(t, []link{
+		{"grandchild", 1, nil},
+		{"child", 2, []int{0}},
+		{"root", -1, []int{1, 3}},
+		{"sibling", 2, nil},
+	}, links)
+	require.NotNil(t, trace.Spans[0].Resource)
+
+	_, ok = index.Trace(traceID(9))
+	require.False(t, ok)
+}
+
+func TestTraceIndex_AddMalformedLeavesIndexUnchanged(t *testing.T) {
+	index := NewTraceIndex()
+	valid := buildTraceTree(t, testSpan{trace: 1, id: 1})
+	require.Error(t, index.Add(append(append([]byte{}, valid...), 0x0a, 0x05)))
+	require.Zero(t, index.Len())
+}
+
+// protoStringField returns the string field num of a span for assertions.
+func protoStringField(span Span, num protowire.Number) (string, error) {
+	b, err := extractBytesField(span, num)
+	return string(b), err
+}
+
+func TestTraceIndex_SelfParent(t *testing.T) {
+	reports, err := ExportTracesServiceRequest(buildTraceTree(t, testSpan{trace: 1, id: 1, parent: 1})).CompletenessReport()
+	require.NoError(t, err)
+	require.Equal(t, [][8]byte{spanID(1)}, reports[0].MissingParents)
+}
+
+func TestIndexedTrace_Durations(t *testing.T) {
+	//	root      [0 ........................ 100]
+	//	a           [10 ..... 40]
+	//	b                [30 ....... 60]
+	//	c                                [90 ....... 120]  (overruns root)
+	//	a1            [15 .. 25]
+	data := buildTraceTree(t,
+		testSpan{trace: 1, id: 1, start: 0, end: 100},
+		testSpan{trace: 1, id: 2, parent: 1, start: 10, end: 40},
+		testSpan{trace: 1, id: 3, parent: 1, start: 30, end: 60},
+		testSpan{trace: 1, id: 4, parent: 1, start: 90, end: 120},
+		testSpan{trace: 1, id: 5, parent: 2, start: 15, end: 25},
+	)
+	index := NewTraceIndex()
+	require.NoError(t, index.Add(data))
+	trace, _ := index.Trace(traceID(1))
+
+	d, ok, err := trace.RootDuration()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, time.Duration(100), d)
+
+	self, err := trace.SelfTimes()
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{40, 20, 30, 30, 10}, self)
+}
+
+func TestIndexedTrace_RootDuration_MultipleAndNone(t *testing.T) {
+	index := NewTraceIndex()
+	require.NoError(t, index.Add(buildTraceTree(t,
+		testSpan{trace: 1, id: 1, start: 50, end: 60},
+		testSpan{trace: 1, id: 2, start: 10, end: 100},
+		testSpan{trace: 2, id: 3, parent: 9, start: 0, end: 5},
+		testSpan{trace: 3, id: 4, start: 20, end: 10}, // ends before it starts
+	)))
+
+	trace, _ := index.Trace(traceID(1))
+	d, ok, err := trace.RootDuration()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, time.Duration(90), d)
+
+	trace, _ = index.Trace(traceID(2))
+	_, ok, err = trace.RootDuration()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	trace, _ = index.Trace(traceID(3))
+	d, _, err = trace.RootDuration()
+	require.NoError(t, err)
+	require.Zero(t, d)
+}
+
+func TestServiceGraphEdges(t *testing.T) {
+	traces := ptrace.NewTraces()
+	addSpan := func(service string, trace, id, parent byte, kind ptrace.SpanKind, failed bool) ptrace.Span {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID(traceID(trace)))
+		span.SetSpanID(pcommon.SpanID(spanID(id)))
+		if parent != 0 {
+			span.SetParentSpanID(pcommon.SpanID(spanID(parent)))
+		}
+		span.SetKind(kind)
+		if failed {
+			span.Status().SetCode(ptrace.StatusCodeError)
+		}
+		return span
+	}
+	// Trace 1: frontend → checkout → payments (failed on the server side).
+	addSpan("frontend", 1, 1, 0, ptrace.SpanKindServer, false)
+	addSpan("frontend", 1, 2, 1, ptrace.SpanKindClient, false)
+	addSpan("checkout", 1, 3, 2, ptrace.SpanKindServer, false)
+	addSpan("checkout", 1, 4, 3, ptrace.SpanKindClient, false)
+	addSpan("payments", 1, 5, 4, ptrace.SpanKindServer, true)
+	// Trace 2: frontend → checkout again, plus an uninstrumented database.
+	addSpan("frontend", 2, 1, 0, ptrace.SpanKindClient, false)
+	addSpan("checkout", 2, 2, 1, ptrace.SpanKindServer, false)
+	db := addSpan("checkout", 2, 3, 2, ptrace.SpanKindClient, true)
+	db.Attributes().PutStr("db.system", "postgresql")
+	// Messaging: producer → consumer.
+	addSpan("orders", 3, 1, 0, ptrace.SpanKindProducer, false)
+	addSpan("billing", 3, 2, 1, ptrace.SpanKindConsumer, false)
+	// A client span without server side or peer attributes is not counted.
+	addSpan("frontend", 4, 1, 0, ptrace.SpanKindClient, false)
+
+	edges, err := ExportTracesServiceRequest(marshalTraces(t, traces)).ServiceGraphEdges()
+	require.NoError(t, err)
+	require.Equal(t, []ServiceEdge{
+		{Client: "frontend", Server: "checkout", Calls: 2},
+		{Client: "checkout", Server: "payments", Calls: 1, Errors: 1},
+		{Client: "checkout", Server: "postgresql", Calls: 1, Errors: 1},
+		{Client: "orders", Server: "billing", Calls: 1},
+	}, edges)
+}
+
+func TestServiceGraphEdges_Malformed(t *testing.T) {
+	// Client span whose kind is encoded as fixed64.
+	span := protowire.AppendTag(nil, 6, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, uint64(SpanKindClient))
+	_, err := ExportTracesServiceRequest(wrapRecord(span)).ServiceGraphEdges()
+	require.Error(t, err)
+}
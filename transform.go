@@ -0,0 +1,533 @@
+package otlpwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// BackfillObservedTimestamps returns a copy of the request in which every
+// log record whose observed_time_unix_nano (field 11) is zero or absent has
+// it set to observed, as the logs data model requires of collectors that
+// receive records without one. It also returns the number of records that
+// were updated. Records that already carry an observed timestamp are copied
+// unchanged.
+func (l ExportLogsServiceRequest) BackfillObservedTimestamps(observed time.Time) (ExportLogsServiceRequest, int, error) {
+	nanos := uint64(observed.UnixNano())
+	updated := 0
+
+	out, err := rewriteValues([]byte(l), logRecordPath, func(dst, record []byte) ([]byte, bool, error) {
+		current, err := extractFixed64Field(record, 11)
+		if err != nil {
+			return dst, false, err
+		}
+		if current != 0 {
+			return append(dst, record...), true, nil
+		}
+		dst, err = appendFieldsExcept(dst, record, 11)
+		if err != nil {
+			return dst, false, err
+		}
+		dst = protowire.AppendTag(dst, 11, protowire.Fixed64Type)
+		dst = protowire.AppendFixed64(dst, nanos)
+		updated++
+		return dst, true, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportLogsServiceRequest(out), updated, nil
+}
+
+// timestampLayout describes where fixed64 Unix-nanosecond timestamps live in
+// a message: fields are timestamp fields of the message itself, nested maps a
+// sub-message field to its own layout.
+type timestampLayout struct {
+	fields []protowire.Number
+	nested map[protowire.Number]*timestampLayout
+}
+
+var (
+	exemplarTimestamps = &timestampLayout{fields: []protowire.Number{2}}
+
+	numberPointTimestamps = &timestampLayout{
+		fields: []protowire.Number{2, 3},
+		nested: map[protowire.Number]*timestampLayout{5: exemplarTimestamps},
+	}
+	histogramPointTimestamps = &timestampLayout{
+		fields: []protowire.Number{2, 3},
+		nested: map[protowire.Number]*timestampLayout{8: exemplarTimestamps},
+	}
+	expHistogramPointTimestamps = &timestampLayout{
+		fields: []protowire.Number{2, 3},
+		nested: map[protowire.Number]*timestampLayout{11: exemplarTimestamps},
+	}
+	summaryPointTimestamps = &timestampLayout{fields: []protowire.Number{2, 3}}
+
+	metricTimestamps = &timestampLayout{nested: map[protowire.Number]*timestampLayout{
+		5:  {nested: map[protowire.Number]*timestampLayout{1: numberPointTimestamps}},
+		7:  {nested: map[protowire.Number]*timestampLayout{1: numberPointTimestamps}},
+		9:  {nested: map[protowire.Number]*timestampLayout{1: histogramPointTimestamps}},
+		10: {nested: map[protowire.Number]*timestampLayout{1: expHistogramPointTimestamps}},
+		11: {nested: map[protowire.Number]*timestampLayout{1: summaryPointTimestamps}},
+	}}
+	spanTimestamps = &timestampLayout{
+		fields: []protowire.Number{7, 8},
+		nested: map[protowire.Number]*timestampLayout{
+			11: {fields: []protowire.Number{1}}, // Span.Event
+		},
+	}
+	logRecordTimestamps = &timestampLayout{fields: []protowire.Number{1, 11}}
+)
+
+// requestTimestamps wraps the layout of a leaf record in the
+// request → resource → scope nesting shared by all three signals.
+func requestTimestamps(record *timestampLayout) *timestampLayout {
+	return &timestampLayout{nested: map[protowire.Number]*timestampLayout{
+		1: {nested: map[protowire.Number]*timestampLayout{
+			2: {nested: map[protowire.Number]*timestampLayout{2: record}},
+		}},
+	}}
+}
+
+var (
+	tracesTimestamps  = requestTimestamps(spanTimestamps)
+	metricsTimestamps = requestTimestamps(metricTimestamps)
+	logsTimestamps    = requestTimestamps(logRecordTimestamps)
+)
+
+// ShiftTimestamps returns a copy of the request with the start and end time
+// of every span and the time of every span event moved by delta. Zero
+// timestamps mean "unset" and are left alone. It returns an error if a
+// shifted timestamp would fall outside the range of uint64 nanoseconds.
+func (t ExportTracesServiceRequest) ShiftTimestamps(delta time.Duration) (ExportTracesServiceRequest, error) {
+	out, err := shiftTimestamps([]byte(t), tracesTimestamps, delta)
+	return ExportTracesServiceRequest(out), err
+}
+
+// ShiftTimestamps returns a copy of the request with the start and sample
+// time of every data point, and the time of every exemplar, moved by delta.
+// Zero timestamps mean "unset" and are left alone.
+func (m ExportMetricsServiceRequest) ShiftTimestamps(delta time.Duration) (ExportMetricsServiceRequest, error) {
+	out, err := shiftTimestamps([]byte(m), metricsTimestamps, delta)
+	return ExportMetricsServiceRequest(out), err
+}
+
+// ShiftTimestamps returns a copy of the request with the time and observed
+// time of every log record moved by delta. Zero timestamps mean "unset" and
+// are left alone.
+func (l ExportLogsServiceRequest) ShiftTimestamps(delta time.Duration) (ExportLogsServiceRequest, error) {
+	out, err := shiftTimestamps([]byte(l), logsTimestamps, delta)
+	return ExportLogsServiceRequest(out), err
+}
+
+// shiftTimestamps copies data and shifts every timestamp described by layout
+// in the copy. Fixed64 fields keep their size, so the rewrite is done in
+// place without re-encoding any length prefix.
+func shiftTimestamps(data []byte, layout *timestampLayout, delta time.Duration) ([]byte, error) {
+	out := append([]byte(nil), data...)
+	if err := shiftTimestampsInPlace(out, layout, int64(delta)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func shiftTimestampsInPlace(msg []byte, layout *timestampLayout, delta int64) error {
+	pos := 0
+
+	for pos < len(msg) {
+		num, wireType, tagLen := consumeTag(msg[pos:])
+		if tagLen < 0 {
+			return errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if slices.Contains(layout.fields, num) {
+			if wireType != protowire.Fixed64Type {
+				return errors.New("wrong wire type for timestamp field")
+			}
+			if len(msg)-pos < 8 {
+				return errors.New("invalid fixed64 in field")
+			}
+			ts := binary.LittleEndian.Uint64(msg[pos:])
+			if ts != 0 {
+				shifted, ok := addDelta(ts, delta)
+				if !ok {
+					return errors.New("shifted timestamp out of range")
+				}
+				binary.LittleEndian.PutUint64(msg[pos:], shifted)
+			}
+			pos += 8
+			continue
+		}
+
+		if child, ok := layout.nested[num]; ok {
+			if wireType != protowire.BytesType {
+				return errors.New("wrong wire type for field")
+			}
+			sub, n := consumeBytes(msg[pos:])
+			if n < 0 {
+				return errors.New("invalid bytes in field")
+			}
+			if err := shiftTimestampsInPlace(sub, child, delta); err != nil {
+				return err
+			}
+			pos += n
+			continue
+		}
+
+		n := skipField(msg[pos:], wireType)
+		if n < 0 {
+			return errors.New("failed to skip field")
+		}
+		pos += n
+	}
+
+	return nil
+}
+
+// addDelta adds a signed nanosecond delta to an unsigned timestamp,
+// reporting false if the result would leave (0, MaxUint64].
+func addDelta(ts uint64, delta int64) (uint64, bool) {
+	if delta >= 0 {
+		d := uint64(delta)
+		return ts + d, ts <= math.MaxUint64-d
+	}
+	d := uint64(-delta)
+	return ts - d, ts > d
+}
+
+// DownsampleDataPoints returns a copy of the request that keeps only every
+// keepEvery-th data point of each series, starting with the first, and the
+// number of data points dropped. A series is a run of data points of the
+// same Metric with identical attribute bytes; decimation does not carry over
+// between requests. Metrics, scopes and resources left without data points
+// are dropped. keepEvery must be at least 1.
+func (m ExportMetricsServiceRequest) DownsampleDataPoints(keepEvery int) (ExportMetricsServiceRequest, int, error) {
+	if keepEvery < 1 {
+		return nil, 0, errors.New("keepEvery must be at least 1")
+	}
+	dropped := 0
+
+	out, err := rewriteDataPoints([]byte(m), func(Metric) (dataPointRewriter, error) {
+		seen := make(map[string]int)
+		return func(dst []byte, dp DataPoint) ([]byte, bool, error) {
+			var key []byte
+			for kv, err := range dp.AttributesSeq {
+				if err != nil {
+					return dst, false, err
+				}
+				key = protowire.AppendBytes(key, kv)
+			}
+			n := seen[string(key)]
+			seen[string(key)] = n + 1
+			if n%keepEvery != 0 {
+				dropped++
+				return dst, false, nil
+			}
+			return append(dst, dp.raw...), true, nil
+		}, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportMetricsServiceRequest(out), dropped, nil
+}
+
+// MergeDuplicateMetrics returns a copy of the request in which Metric entries
+// of one scope that describe the same stream are merged into a single Metric
+// holding all their data points, in order, and the number of entries merged
+// away. Two entries describe the same stream when their name, unit, type and
+// aggregation settings (temporality, monotonicity) are identical; the
+// description and metadata of the first entry are kept. The merged Metric
+// takes the position of the first entry.
+func (m ExportMetricsServiceRequest) MergeDuplicateMetrics() (ExportMetricsServiceRequest, int, error) {
+	merged := 0
+
+	out, err := rewriteValues([]byte(m), scopePath, func(dst, scope []byte) ([]byte, bool, error) {
+		// Group the scope's metrics by stream identity.
+		type group struct {
+			typ     MetricType
+			members [][]byte // Metric messages
+		}
+		groups := make(map[string]*group)
+		var order []*group
+		var parseErr error
+		forEachRepeatedField(scope, 2, func(metric []byte, err error) bool {
+			if err != nil {
+				parseErr = err
+				return false
+			}
+			key, typ, err := metricStreamKey(metric)
+			if err != nil {
+				parseErr = err
+				return false
+			}
+			g, ok := groups[key]
+			if !ok || typ == 0 {
+				g = &group{typ: typ}
+				groups[key] = g
+				order = append(order, g)
+			}
+			g.members = append(g.members, metric)
+			return true
+		})
+		if parseErr != nil {
+			return dst, false, parseErr
+		}
+
+		// Re-emit the scope with each group in place of its first member.
+		dst, err := appendFieldsExcept(dst, scope, 2)
+		if err != nil {
+			return dst, false, err
+		}
+		for _, g := range order {
+			merged += len(g.members) - 1
+			dst, err = appendMessageField(dst, 2, func(b []byte) ([]byte, error) {
+				return appendMergedMetric(b, g.typ, g.members)
+			})
+			if err != nil {
+				return dst, false, err
+			}
+		}
+		return dst, true, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportMetricsServiceRequest(out), merged, nil
+}
+
+// metricStreamKey returns the stream identity of a Metric message (name,
+// unit, body type and body fields other than data points) together with its
+// body type. A Metric without a body has type zero.
+func metricStreamKey(metric []byte) (string, MetricType, error) {
+	typ, err := metricBodyType(metric)
+	if err != nil || typ == 0 {
+		return "", 0, err
+	}
+	name, err := extractBytesField(metric, 1)
+	if err != nil {
+		return "", 0, err
+	}
+	unit, err := extractBytesField(metric, 3)
+	if err != nil {
+		return "", 0, err
+	}
+	body, err := extractBytesField(metric, protowire.Number(typ))
+	if err != nil {
+		return "", 0, err
+	}
+
+	key := protowire.AppendBytes(nil, name)
+	key = protowire.AppendBytes(key, unit)
+	key = protowire.AppendVarint(key, uint64(typ))
+	key, err = appendFieldsExcept(key, body, 1)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(key), typ, nil
+}
+
+// appendMergedMetric appends the body of a Metric message that combines the
+// data points of members, which share a stream key, to dst.
+func appendMergedMetric(dst []byte, typ MetricType, members [][]byte) ([]byte, error) {
+	if len(members) == 1 {
+		return append(dst, members[0]...), nil
+	}
+	bodyNum := protowire.Number(typ)
+
+	dst, err := appendFieldsExcept(dst, members[0], bodyNum)
+	if err != nil {
+		return nil, err
+	}
+	isDataPoint := func(num protowire.Number) bool { return num == 1 }
+	return appendMessageField(dst, bodyNum, func(b []byte) ([]byte, error) {
+		for i, metric := range members {
+			body, err := extractBytesField(metric, bodyNum)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				if b, err = appendFieldsExcept(b, body, 1); err != nil {
+					return nil, err
+				}
+			}
+			if b, err = appendFieldsWhere(b, body, isDataPoint); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	})
+}
+
+// IntValuesToDouble returns a copy of the request in which every gauge and
+// sum data point recorded as as_int (field 6) is recorded as as_double
+// (field 4) instead, and the number of data points converted. Integers
+// beyond ±2^53 lose precision. Exemplars are left unchanged.
+func (m ExportMetricsServiceRequest) IntValuesToDouble() (ExportMetricsServiceRequest, int, error) {
+	converted := 0
+
+	out, err := rewriteDataPointValues([]byte(m), func(metric Metric) (dataPointRewriter, error) {
+		typ, err := metricBodyType(metric)
+		if err != nil || !isNumberDataPoint(typ) {
+			return nil, err
+		}
+		return func(dst []byte, dp DataPoint) ([]byte, bool, error) {
+			data := dp.raw
+			pos := 0
+			for pos < len(data) {
+				fieldStart := pos
+				num, wireType, tagLen := consumeTag(data[pos:])
+				if tagLen < 0 {
+					return dst, false, errors.New("malformed protobuf tag")
+				}
+				pos += tagLen
+
+				if num == 6 {
+					if wireType != protowire.Fixed64Type {
+						return dst, false, errors.New("wrong wire type for field")
+					}
+					v, n := protowire.ConsumeFixed64(data[pos:])
+					if n < 0 {
+						return dst, false, errors.New("invalid fixed64 in field")
+					}
+					pos += n
+					dst = protowire.AppendTag(dst, 4, protowire.Fixed64Type)
+					dst = protowire.AppendFixed64(dst, math.Float64bits(float64(int64(v))))
+					converted++
+					continue
+				}
+
+				n := skipField(data[pos:], wireType)
+				if n < 0 {
+					return dst, false, errors.New("failed to skip field")
+				}
+				pos += n
+				dst = append(dst, data[fieldStart:pos]...)
+			}
+			return dst, true, nil
+		}, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportMetricsServiceRequest(out), converted, nil
+}
+
+// RebucketHistograms returns a copy of the request in which every histogram
+// data point with explicit bucket boundaries other than bounds is re-bucketed
+// onto bounds, and the number of data points changed. Each source bucket is
+// added to the target bucket that contains its upper boundary, so the result
+// is exact when bounds is a subset of the source boundaries and coarser
+// otherwise. bounds must be strictly increasing and free of NaN. Count, sum,
+// min, max and exemplars are kept as they are.
+func (m ExportMetricsServiceRequest) RebucketHistograms(bounds []float64) (ExportMetricsServiceRequest, int, error) {
+	for i, b := range bounds {
+		if math.IsNaN(b) || (i > 0 && b <= bounds[i-1]) {
+			return nil, 0, errors.New("bounds must be strictly increasing and not NaN")
+		}
+	}
+	rebucketed := 0
+
+	out, err := rewriteDataPointValues([]byte(m), func(metric Metric) (dataPointRewriter, error) {
+		typ, err := metricBodyType(metric)
+		if err != nil || typ != MetricTypeHistogram {
+			return nil, err
+		}
+		return func(dst []byte, dp DataPoint) ([]byte, bool, error) {
+			counts, err := repeatedFixed64(dp.raw, 6)
+			if err != nil {
+				return dst, false, err
+			}
+			rawBounds, err := repeatedFixed64(dp.raw, 7)
+			if err != nil {
+				return dst, false, err
+			}
+			if len(counts) == 0 || slices.EqualFunc(rawBounds, bounds, func(raw uint64, b float64) bool {
+				return math.Float64frombits(raw) == b
+			}) {
+				return append(dst, dp.raw...), true, nil
+			}
+			if len(counts) != len(rawBounds)+1 {
+				return dst, false, errors.New("bucket_counts does not match explicit_bounds")
+			}
+
+			target := make([]uint64, len(bounds)+1)
+			for i, c := range counts {
+				upper := math.Inf(1)
+				if i < len(rawBounds) {
+					upper = math.Float64frombits(rawBounds[i])
+				}
+				j, _ := slices.BinarySearch(bounds, upper)
+				target[j] += c
+			}
+
+			dst, err = appendFieldsExcept(dst, dp.raw, 6, 7)
+			if err != nil {
+				return dst, false, err
+			}
+			dst = appendPackedFixed64(dst, 6, target)
+			dst = appendPackedDouble(dst, 7, bounds)
+			rebucketed++
+			return dst, true, nil
+		}, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return ExportMetricsServiceRequest(out), rebucketed, nil
+}
+
+// repeatedFixed64 decodes every value of the repeated fixed64 or double
+// field num, accepting both packed and unpacked encodings.
+func repeatedFixed64(data []byte, num protowire.Number) ([]uint64, error) {
+	var values []uint64
+	pos := 0
+
+	for pos < len(data) {
+		fieldNum, wireType, tagLen := consumeTag(data[pos:])
+		if tagLen < 0 {
+			return nil, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if fieldNum == num {
+			switch wireType {
+			case protowire.Fixed64Type:
+				v, n := protowire.ConsumeFixed64(data[pos:])
+				if n < 0 {
+					return nil, errors.New("invalid fixed64 in field")
+				}
+				values = append(values, v)
+				pos += n
+				continue
+			case protowire.BytesType:
+				packed, n := consumeBytes(data[pos:])
+				if n < 0 || len(packed)%8 != 0 {
+					return nil, errors.New("invalid packed fixed64 field")
+				}
+				for i := 0; i < len(packed); i += 8 {
+					v, _ := protowire.ConsumeFixed64(packed[i:])
+					values = append(values, v)
+				}
+				pos += n
+				continue
+			default:
+				return nil, errors.New("wrong wire type for field")
+			}
+		}
+
+		n := skipField(data[pos:], wireType)
+		if n < 0 {
+			return nil, errors.New("failed to skip field")
+		}
+		pos += n
+	}
+
+	return values, nil
+}
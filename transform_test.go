@@ -0,0 +1,533 @@
+package otlpwire
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestBackfillObservedTimestamps(t *testing.T) {
+	existing := pcommon.NewTimestampFromTime(time.Unix(100, 0))
+	received := time.Unix(200, 5)
+
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "svc")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	missing := records.AppendEmpty()
+	missing.Body().SetStr("no observed time")
+	missing.Attributes().PutStr("k", "v")
+	present := records.AppendEmpty()
+	present.Body().SetStr("has observed time")
+	present.SetObservedTimestamp(existing)
+	data := marshalLogs(t, logs)
+
+	out, updated, err := ExportLogsServiceRequest(data).BackfillObservedTimestamps(received)
+	require.NoError(t, err)
+	require.Equal(t, 1, updated)
+
+	unmarshaler := &plog.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalLogs(out)
+	require.NoError(t, err)
+
+	// Only the observed timestamp of the first record changed.
+	missing.SetObservedTimestamp(pcommon.NewTimestampFromTime(received))
+	require.Equal(t, logs, got)
+}
+
+func TestBackfillObservedTimestamps_KeepsEmptyContainers(t *testing.T) {
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().Resource().Attributes().PutStr("service.name", "empty")
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.ScopeLogs().AppendEmpty().Scope().SetName("empty")
+	record := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.SetObservedTimestamp(1)
+	data := marshalLogs(t, logs)
+
+	out, updated, err := ExportLogsServiceRequest(data).BackfillObservedTimestamps(time.Now())
+	require.NoError(t, err)
+	require.Zero(t, updated)
+	require.Equal(t, data, []byte(out))
+
+	record.SetObservedTimestamp(0)
+	out, updated, err = ExportLogsServiceRequest(marshalLogs(t, logs)).BackfillObservedTimestamps(time.Unix(0, 7))
+	require.NoError(t, err)
+	require.Equal(t, 1, updated)
+	got, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(out)
+	require.NoError(t, err)
+	record.SetObservedTimestamp(7)
+	require.Equal(t, logs, got)
+}
+
+func TestBackfillObservedTimestamps_NoneMissing(t *testing.T) {
+	logs := plog.NewLogs()
+	record := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.SetObservedTimestamp(1)
+	data := marshalLogs(t, logs)
+
+	out, updated, err := ExportLogsServiceRequest(data).BackfillObservedTimestamps(time.Now())
+	require.NoError(t, err)
+	require.Zero(t, updated)
+	require.Equal(t, data, []byte(out))
+}
+
+func TestBackfillObservedTimestamps_ExplicitZero(t *testing.T) {
+	// A record that encodes observed_time_unix_nano = 0 explicitly gets a
+	// single replacement field rather than a duplicate.
+	record := protowire.AppendTag(nil, 11, protowire.Fixed64Type)
+	record = protowire.AppendFixed64(record, 0)
+
+	out, updated, err := ExportLogsServiceRequest(wrapRecord(record)).BackfillObservedTimestamps(time.Unix(0, 42))
+	require.NoError(t, err)
+	require.Equal(t, 1, updated)
+
+	want := protowire.AppendTag(nil, 11, protowire.Fixed64Type)
+	want = protowire.AppendFixed64(want, 42)
+	require.Equal(t, wrapRecord(want), []byte(out))
+}
+
+func TestBackfillObservedTimestamps_Malformed(t *testing.T) {
+	// observed_time_unix_nano encoded as varint.
+	record := protowire.AppendTag(nil, 11, protowire.VarintType)
+	record = protowire.AppendVarint(record, 1)
+	_, _, err := ExportLogsServiceRequest(wrapRecord(record)).BackfillObservedTimestamps(time.Now())
+	require.Error(t, err)
+
+	_, _, err = ExportLogsServiceRequest([]byte{0x0a, 0x05}).BackfillObservedTimestamps(time.Now())
+	require.Error(t, err)
+}
+
+func TestShiftTimestamps_Traces(t *testing.T) {
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	span := spans.AppendEmpty()
+	span.SetName("op")
+	span.SetStartTimestamp(1000)
+	span.SetEndTimestamp(2000)
+	span.Events().AppendEmpty().SetTimestamp(1500)
+	unset := spans.AppendEmpty()
+	unset.SetName("unset")
+	data := marshalTraces(t, traces)
+	original := append([]byte(nil), data...)
+
+	out, err := ExportTracesServiceRequest(data).ShiftTimestamps(-500)
+	require.NoError(t, err)
+	require.Equal(t, original, data, "input modified")
+
+	span.SetStartTimestamp(500)
+	span.SetEndTimestamp(1500)
+	span.Events().At(0).SetTimestamp(1000)
+	unmarshaler := &ptrace.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalTraces(out)
+	require.NoError(t, err)
+	require.Equal(t, traces, got)
+}
+
+func TestShiftTimestamps_Metrics(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	gauge := ms.AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty()
+	gauge.SetTimestamp(100)
+	gauge.SetDoubleValue(1)
+	gauge.Exemplars().AppendEmpty().SetTimestamp(90)
+	sum := ms.AppendEmpty().SetEmptySum().DataPoints().AppendEmpty()
+	sum.SetStartTimestamp(10)
+	sum.SetTimestamp(100)
+	hist := ms.AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty()
+	hist.SetStartTimestamp(10)
+	hist.SetTimestamp(100)
+	hist.Exemplars().AppendEmpty().SetTimestamp(95)
+	exp := ms.AppendEmpty().SetEmptyExponentialHistogram().DataPoints().AppendEmpty()
+	exp.SetStartTimestamp(10)
+	exp.SetTimestamp(100)
+	exp.Exemplars().AppendEmpty().SetTimestamp(95)
+	summary := ms.AppendEmpty().SetEmptySummary().DataPoints().AppendEmpty()
+	summary.SetStartTimestamp(10)
+	summary.SetTimestamp(100)
+	data := marshalMetrics(t, metrics)
+
+	out, err := ExportMetricsServiceRequest(data).ShiftTimestamps(time.Duration(1000))
+	require.NoError(t, err)
+
+	gauge.SetTimestamp(1100)
+	gauge.Exemplars().At(0).SetTimestamp(1090)
+	sum.SetStartTimestamp(1010)
+	sum.SetTimestamp(1100)
+	hist.SetStartTimestamp(1010)
+	hist.SetTimestamp(1100)
+	hist.Exemplars().At(0).SetTimestamp(1095)
+	exp.SetStartTimestamp(1010)
+	exp.SetTimestamp(1100)
+	exp.Exemplars().At(0).SetTimestamp(1095)
+	summary.SetStartTimestamp(1010)
+	summary.SetTimestamp(1100)
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	require.Equal(t, metrics, got)
+}
+
+func TestShiftTimestamps_Logs(t *testing.T) {
+	logs := plog.NewLogs()
+	record := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.SetTimestamp(pcommon.NewTimestampFromTime(time.Unix(10, 0)))
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Unix(11, 0)))
+	record.Body().SetStr("hello")
+	data := marshalLogs(t, logs)
+
+	out, err := ExportLogsServiceRequest(data).ShiftTimestamps(time.Hour)
+	require.NoError(t, err)
+
+	record.SetTimestamp(pcommon.NewTimestampFromTime(time.Unix(10, 0).Add(time.Hour)))
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Unix(11, 0).Add(time.Hour)))
+	unmarshaler := &plog.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalLogs(out)
+	require.NoError(t, err)
+	require.Equal(t, logs, got)
+}
+
+func TestShiftTimestamps_OutOfRange(t *testing.T) {
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().SetTimestamp(100)
+	data := marshalLogs(t, logs)
+
+	_, err := ExportLogsServiceRequest(data).ShiftTimestamps(-100)
+	require.Error(t, err)
+	_, err = ExportLogsServiceRequest(data).ShiftTimestamps(-99)
+	require.NoError(t, err)
+}
+
+func TestShiftTimestamps_Malformed(t *testing.T) {
+	// Log record time encoded as varint.
+	record := protowire.AppendTag(nil, 1, protowire.VarintType)
+	record = protowire.AppendVarint(record, 1)
+	_, err := ExportLogsServiceRequest(wrapRecord(record)).ShiftTimestamps(time.Second)
+	require.Error(t, err)
+
+	// Truncated fixed64.
+	record = protowire.AppendTag(nil, 11, protowire.Fixed64Type)
+	record = append(record, 1, 2, 3)
+	_, err = ExportLogsServiceRequest(wrapRecord(record)).ShiftTimestamps(time.Second)
+	require.Error(t, err)
+
+	_, err = ExportTracesServiceRequest([]byte{0x0a, 0x05}).ShiftTimestamps(time.Second)
+	require.Error(t, err)
+}
+
+func TestDownsampleDataPoints(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	gauge := ms.AppendEmpty()
+	gauge.SetName("interleaved")
+	points := gauge.SetEmptyGauge().DataPoints()
+	for i := 0; i < 6; i++ {
+		dp := points.AppendEmpty()
+		dp.Attributes().PutStr("host", []string{"a", "b"}[i%2])
+		dp.SetTimestamp(pcommon.Timestamp(i))
+	}
+	sum := ms.AppendEmpty()
+	sum.SetName("single")
+	sum.SetEmptySum().DataPoints().AppendEmpty().SetTimestamp(7)
+	data := marshalMetrics(t, metrics)
+
+	out, dropped, err := ExportMetricsServiceRequest(data).DownsampleDataPoints(2)
+	require.NoError(t, err)
+	require.Equal(t, 2, dropped)
+
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	gotMetrics := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	var times []pcommon.Timestamp
+	points = gotMetrics.At(0).Gauge().DataPoints()
+	for i := 0; i < points.Len(); i++ {
+		times = append(times, points.At(i).Timestamp())
+	}
+	// The first and third point of each host series survive.
+	require.Equal(t, []pcommon.Timestamp{0, 1, 4, 5}, times)
+	require.Equal(t, 1, gotMetrics.At(1).Sum().DataPoints().Len())
+}
+
+func TestDownsampleDataPoints_KeepAll(t *testing.T) {
+	data := buildAllTypesMetrics(t)
+	out, dropped, err := ExportMetricsServiceRequest(data).DownsampleDataPoints(1)
+	require.NoError(t, err)
+	require.Zero(t, dropped)
+	require.Equal(t, data, []byte(out))
+
+	_, _, err = ExportMetricsServiceRequest(data).DownsampleDataPoints(0)
+	require.Error(t, err)
+}
+
+func TestDownsampleDataPoints_Malformed(t *testing.T) {
+	// Gauge body encoded as varint.
+	metric := protowire.AppendTag(nil, 5, protowire.VarintType)
+	metric = protowire.AppendVarint(metric, 1)
+	_, _, err := ExportMetricsServiceRequest(wrapRecord(metric)).DownsampleDataPoints(2)
+	require.Error(t, err)
+}
+
+func TestMergeDuplicateMetrics(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("lib")
+	addSum := func(name, unit string, temporality pmetric.AggregationTemporality, values ...int64) {
+		m := sm.Metrics().AppendEmpty()
+		m.SetName(name)
+		m.SetUnit(unit)
+		sum := m.SetEmptySum()
+		sum.SetAggregationTemporality(temporality)
+		for _, v := range values {
+			sum.DataPoints().AppendEmpty().SetIntValue(v)
+		}
+	}
+	addSum("requests", "1", pmetric.AggregationTemporalityCumulative, 1, 2)
+	addSum("errors", "1", pmetric.AggregationTemporalityCumulative, 10)
+	addSum("requests", "1", pmetric.AggregationTemporalityCumulative, 3)
+	addSum("requests", "By", pmetric.AggregationTemporalityCumulative, 20) // other unit
+	addSum("requests", "1", pmetric.AggregationTemporalityDelta, 30)       // other temporality
+	addSum("requests", "1", pmetric.AggregationTemporalityCumulative, 4)
+	gauge := sm.Metrics().AppendEmpty() // other type
+	gauge.SetName("requests")
+	gauge.SetUnit("1")
+	gauge.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(40)
+	data := marshalMetrics(t, metrics)
+
+	out, merged, err := ExportMetricsServiceRequest(data).MergeDuplicateMetrics()
+	require.NoError(t, err)
+	require.Equal(t, 2, merged)
+
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	gotScope := got.ResourceMetrics().At(0).ScopeMetrics().At(0)
+	require.Equal(t, "lib", gotScope.Scope().Name())
+
+	var summary []string
+	for i := 0; i < gotScope.Metrics().Len(); i++ {
+		m := gotScope.Metrics().At(i)
+		var values []int64
+		switch m.Type() {
+		case pmetric.MetricTypeSum:
+			for j := 0; j < m.Sum().DataPoints().Len(); j++ {
+				values = append(values, m.Sum().DataPoints().At(j).IntValue())
+			}
+		case pmetric.MetricTypeGauge:
+			values = append(values, m.Gauge().DataPoints().At(0).IntValue())
+		}
+		summary = append(summary, fmt.Sprintf("%s/%s/%s%v", m.Name(), m.Unit(), m.Type(), values))
+	}
+	require.Equal(t, []string{
+		"requests/1/Sum[1 2 3 4]",
+		"errors/1/Sum[10]",
+		"requests/By/Sum[20]",
+		"requests/1/Sum[30]",
+		"requests/1/Gauge[40]",
+	}, summary)
+	require.Equal(t, pmetric.AggregationTemporalityCumulative, gotScope.Metrics().At(0).Sum().AggregationTemporality())
+}
+
+func TestMergeDuplicateMetrics_NoDuplicates(t *testing.T) {
+	data := buildAllTypesMetrics(t)
+	out, merged, err := ExportMetricsServiceRequest(data).MergeDuplicateMetrics()
+	require.NoError(t, err)
+	require.Zero(t, merged)
+
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	want, err := unmarshaler.UnmarshalMetrics(data)
+	require.NoError(t, err)
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestMergeDuplicateMetrics_KeepsEmptyResources(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	metrics.ResourceMetrics().AppendEmpty().Resource().Attributes().PutStr("service.name", "empty")
+	sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sm.Metrics().AppendEmpty().SetName("requests")
+	data := marshalMetrics(t, metrics)
+
+	out, merged, err := ExportMetricsServiceRequest(data).MergeDuplicateMetrics()
+	require.NoError(t, err)
+	require.Zero(t, merged)
+	got, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(out)
+	require.NoError(t, err)
+	require.Equal(t, metrics, got)
+}
+
+func TestMergeDuplicateMetrics_Malformed(t *testing.T) {
+	// Sum body encoded as varint.
+	metric := protowire.AppendTag(nil, 7, protowire.VarintType)
+	metric = protowire.AppendVarint(metric, 1)
+	_, _, err := ExportMetricsServiceRequest(wrapRecord(metric)).MergeDuplicateMetrics()
+	require.Error(t, err)
+}
+
+func TestIntValuesToDouble(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	gauge := ms.AppendEmpty().SetEmptyGauge().DataPoints()
+	gauge.AppendEmpty().SetIntValue(-7)
+	gauge.AppendEmpty().SetDoubleValue(1.5)
+	sum := ms.AppendEmpty().SetEmptySum().DataPoints().AppendEmpty()
+	sum.SetIntValue(42)
+	sum.Exemplars().AppendEmpty().SetIntValue(3)
+	hist := ms.AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty()
+	hist.SetCount(2)
+	data := marshalMetrics(t, metrics)
+
+	out, converted, err := ExportMetricsServiceRequest(data).IntValuesToDouble()
+	require.NoError(t, err)
+	require.Equal(t, 2, converted)
+
+	gauge.At(0).SetDoubleValue(-7)
+	sum.SetDoubleValue(42)
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	require.Equal(t, metrics, got)
+}
+
+func TestIntValuesToDouble_KeepsEmptyContainers(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	metrics.ResourceMetrics().AppendEmpty().Resource().Attributes().PutStr("service.name", "empty")
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.ScopeMetrics().AppendEmpty().Scope().SetName("empty")
+	ms := rm.ScopeMetrics().AppendEmpty().Metrics()
+	noPoints := ms.AppendEmpty()
+	noPoints.SetName("no-points")
+	noPoints.SetEmptyGauge()
+	ms.AppendEmpty().SetName("no-body")
+	dp := ms.AppendEmpty().SetEmptySum().DataPoints().AppendEmpty()
+	dp.SetIntValue(3)
+	data := marshalMetrics(t, metrics)
+
+	out, converted, err := ExportMetricsServiceRequest(data).IntValuesToDouble()
+	require.NoError(t, err)
+	require.Equal(t, 1, converted)
+	got, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(out)
+	require.NoError(t, err)
+	dp.SetDoubleValue(3)
+	require.Equal(t, metrics, got)
+
+	// With nothing to convert, the request round-trips byte for byte.
+	data = marshalMetrics(t, metrics)
+	out, converted, err = ExportMetricsServiceRequest(data).IntValuesToDouble()
+	require.NoError(t, err)
+	require.Zero(t, converted)
+	require.Equal(t, data, []byte(out))
+}
+
+func TestIntValuesToDouble_Malformed(t *testing.T) {
+	// Gauge data point with as_int encoded as varint.
+	dp := protowire.AppendTag(nil, 6, protowire.VarintType)
+	dp = protowire.AppendVarint(dp, 1)
+	gauge := protowire.AppendTag(nil, 1, protowire.BytesType)
+	gauge = protowire.AppendBytes(gauge, dp)
+	metric := protowire.AppendTag(nil, 5, protowire.BytesType)
+	metric = protowire.AppendBytes(metric, gauge)
+	_, _, err := ExportMetricsServiceRequest(wrapRecord(metric)).IntValuesToDouble()
+	require.Error(t, err)
+}
+
+func TestRebucketHistograms(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	points := ms.AppendEmpty().SetEmptyHistogram().DataPoints()
+	fine := points.AppendEmpty()
+	fine.ExplicitBounds().FromRaw([]float64{1, 5, 10, 50, 100})
+	fine.BucketCounts().FromRaw([]uint64{1, 2, 3, 4, 5, 6})
+	fine.SetCount(21)
+	fine.SetSum(123)
+	fine.Exemplars().AppendEmpty().SetDoubleValue(7)
+	odd := points.AppendEmpty()
+	odd.ExplicitBounds().FromRaw([]float64{2, 20})
+	odd.BucketCounts().FromRaw([]uint64{1, 1, 1})
+	points.AppendEmpty().SetCount(0) // no buckets
+	ms.AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	data := marshalMetrics(t, metrics)
+
+	out, rebucketed, err := ExportMetricsServiceRequest(data).RebucketHistograms([]float64{10, 100})
+	require.NoError(t, err)
+	require.Equal(t, 2, rebucketed)
+
+	fine.ExplicitBounds().FromRaw([]float64{10, 100})
+	fine.BucketCounts().FromRaw([]uint64{6, 9, 6})
+	// Upper boundaries 2, 20 and +Inf fall into the first, second and third
+	// target bucket.
+	odd.ExplicitBounds().FromRaw([]float64{10, 100})
+	odd.BucketCounts().FromRaw([]uint64{1, 1, 1})
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	require.Equal(t, metrics, got)
+
+	// Already on the target boundaries: nothing changes.
+	again, rebucketed, err := ExportMetricsServiceRequest(out).RebucketHistograms([]float64{10, 100})
+	require.NoError(t, err)
+	require.Zero(t, rebucketed)
+	require.Equal(t, out, again)
+}
+
+func TestRebucketHistograms_KeepsEmptyContainers(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	metrics.ResourceMetrics().AppendEmpty().Resource().Attributes().PutStr("service.name", "empty")
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.ScopeMetrics().AppendEmpty().Scope().SetName("empty")
+	ms := rm.ScopeMetrics().AppendEmpty().Metrics()
+	ms.AppendEmpty().SetEmptyHistogram()
+	dp := ms.AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty()
+	dp.ExplicitBounds().FromRaw([]float64{1, 10})
+	dp.BucketCounts().FromRaw([]uint64{1, 2, 3})
+	data := marshalMetrics(t, metrics)
+
+	out, rebucketed, err := ExportMetricsServiceRequest(data).RebucketHistograms([]float64{10})
+	require.NoError(t, err)
+	require.Equal(t, 1, rebucketed)
+	got, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(out)
+	require.NoError(t, err)
+	dp.ExplicitBounds().FromRaw([]float64{10})
+	dp.BucketCounts().FromRaw([]uint64{3, 3})
+	require.Equal(t, metrics, got)
+}
+
+func TestRebucketHistograms_SingleBucket(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	dp := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty()
+	dp.ExplicitBounds().FromRaw([]float64{1, 2})
+	dp.BucketCounts().FromRaw([]uint64{1, 2, 3})
+
+	out, _, err := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).RebucketHistograms(nil)
+	require.NoError(t, err)
+	unmarshaler := &pmetric.ProtoUnmarshaler{}
+	got, err := unmarshaler.UnmarshalMetrics(out)
+	require.NoError(t, err)
+	gotDP := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Histogram().DataPoints().At(0)
+	require.Equal(t, []uint64{6}, gotDP.BucketCounts().AsRaw())
+	require.Zero(t, gotDP.ExplicitBounds().Len())
+}
+
+func TestRebucketHistograms_Invalid(t *testing.T) {
+	data := buildAllTypesMetrics(t)
+	for _, bounds := range [][]float64{{1, 1}, {2, 1}, {math.NaN()}} {
+		_, _, err := ExportMetricsServiceRequest(data).RebucketHistograms(bounds)
+		require.Error(t, err)
+	}
+
+	// bucket_counts with one entry too many for explicit_bounds.
+	metrics := pmetric.NewMetrics()
+	dp := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty()
+	dp.ExplicitBounds().FromRaw([]float64{1})
+	dp.BucketCounts().FromRaw([]uint64{1, 2, 3})
+	_, _, err := ExportMetricsServiceRequest(marshalMetrics(t, metrics)).RebucketHistograms([]float64{5})
+	require.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package otlpwire
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxNestingDepth bounds the message nesting Validate descends into, and the
+// AnyValue nesting the JSON encoders of log records do. OTLP requests nest
+// about ten levels deep; only AnyValue arrays and key-value lists nest
+// further, and hostile input could nest them deep enough to exhaust the
+// stack.
+const maxNestingDepth = 100
+
+// ValidationMode selects the checks of Validate.
+type ValidationMode uint8
+
+const (
+	// ValidateLenient accepts what the accessors accept: every field must
+	// be well formed and fields of the OTLP schema must have their schema
+	// wire types, while unknown fields are skipped and over-long varints
+	// are decoded like any other.
+	ValidateLenient ValidationMode = iota
+	// ValidateStrict additionally rejects encodings that no conforming
+	// encoder produces, for receivers exposed to hostile input: varints in
+	// tags, lengths and values, packed or not, that are longer than needed.
+	ValidateStrict
+)
+
+// Validate checks every byte of the request against mode. The accessors
+// only parse the fields they read and stop at the first occurrence they look
+// for, so they never see corruption elsewhere, such as garbage after the
+// last resource or inside an attribute nobody asked for. Validate reads the
+// whole request once, descending into every message of the OTLP schema, and
+// returns the first problem it finds. Group wire types, which OTLP does not
+// use, are rejected in both modes, as are messages nested more than 100
+// levels deep.
+func (t ExportTracesServiceRequest) Validate(mode ValidationMode) error {
+	return validateMessage([]byte(t), tracesSchema, mode == ValidateStrict, 0)
+}
+
+// Validate checks every byte of the request against mode, as
+// ExportTracesServiceRequest.Validate.
+func (m ExportMetricsServiceRequest) Validate(mode ValidationMode) error {
+	return validateMessage([]byte(m), metricsSchema, mode == ValidateStrict, 0)
+}
+
+// Validate checks every byte of the request against mode, as
+// ExportTracesServiceRequest.Validate.
+func (l ExportLogsServiceRequest) Validate(mode ValidationMode) error {
+	return validateMessage([]byte(l), logsSchema, mode == ValidateStrict, 0)
+}
+
+// validateMessage checks the fields of msg, a message at depth, against
+// schema, recursing into nested messages. Unknown fields are checked for
+// well-formedness only.
+func validateMessage(msg []byte, schema *messageSchema, strict bool, depth int) error {
+	if depth > maxNestingDepth {
+		return errors.New("messages nested too deeply")
+	}
+	pos := 0
+
+	for pos < len(msg) {
+		num, wireType, tagLen := consumeTag(msg[pos:])
+		if tagLen < 0 {
+			return errors.New("malformed protobuf tag")
+		}
+		if strict && tagLen != protowire.SizeTag(num) {
+			return errors.New("non-canonical protobuf tag")
+		}
+		pos += tagLen
+
+		field, known := schema.fields[num]
+		packed := known && field.packed && wireType == protowire.BytesType
+		if known && wireType != field.typ && !packed {
+			return errors.New("wrong wire type for field")
+		}
+
+		switch wireType {
+		case protowire.VarintType:
+			v, n := consumeVarint(msg[pos:])
+			if n < 0 {
+				return errors.New("invalid varint in field")
+			}
+			if strict && n != protowire.SizeVarint(v) {
+				return errors.New("non-canonical varint in field")
+			}
+			pos += n
+		case protowire.BytesType:
+			value, n := consumeBytes(msg[pos:])
+			if n < 0 {
+				return errors.New("invalid bytes in field")
+			}
+			if strict && n != protowire.SizeBytes(len(value)) {
+				return errors.New("non-canonical length in field")
+			}
+			pos += n
+
+			switch {
+			case packed:
+				if err := validatePacked(value, field.typ, strict); err != nil {
+					return err
+				}
+			case known && field.message != nil:
+				if err := validateMessage(value, field.message, strict, depth+1); err != nil {
+					return err
+				}
+			}
+		case protowire.StartGroupType, protowire.EndGroupType:
+			return errors.New("group wire type in field")
+		default:
+			n := skipField(msg[pos:], wireType)
+			if n < 0 {
+				return errors.New("failed to skip field")
+			}
+			pos += n
+		}
+	}
+
+	return nil
+}
+
+// validatePacked checks the payload of a packed repeated field whose
+// elements have wire type typ.
+func validatePacked(value []byte, typ protowire.Type, strict bool) error {
+	switch typ {
+	case protowire.Fixed64Type:
+		if len(value)%8 != 0 {
+			return errors.New("packed fixed64 field has a partial element")
+		}
+	case protowire.VarintType:
+		for pos := 0; pos < len(value); {
+			v, n := consumeVarint(value[pos:])
+			if n < 0 {
+				return errors.New("invalid varint in packed field")
+			}
+			if strict && n != protowire.SizeVarint(v) {
+				return errors.New("non-canonical varint in packed field")
+			}
+			pos += n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestValidate_Valid(t *testing.T) {
+	for _, mode := range []ValidationMode{ValidateLenient, ValidateStrict} {
+		require.NoError(t, ExportTracesServiceRequest(fullTraces(t)).Validate(mode))
+		require.NoError(t, ExportMetricsServiceRequest(fullMetrics(t)).Validate(mode))
+		require.NoError(t, ExportMetricsServiceRequest(buildAllTypesMetrics(t)).Validate(mode))
+		require.NoError(t, ExportLogsServiceRequest(marshalLogs(t, createBenchLogs())).Validate(mode))
+		require.NoError(t, ExportLogsServiceRequest(nil).Validate(mode))
+	}
+
+	// Unknown fields are skipped, and repeated scalars may be unpacked.
+	unpacked := protowire.AppendTag(nil, 6, protowire.Fixed64Type)
+	unpacked = protowire.AppendFixed64(unpacked, 3)
+	unpacked = protowire.AppendTag(unpacked, 99, protowire.Fixed32Type)
+	unpacked = protowire.AppendFixed32(unpacked, 1)
+	require.NoError(t, ExportMetricsServiceRequest(histogramRequest(unpacked)).Validate(ValidateStrict))
+}
+
+// histogramRequest wraps the fields of a HistogramDataPoint in a metrics
+// export request.
+func histogramRequest(point []byte) []byte {
+	wrap := func(num protowire.Number, msg []byte) []byte {
+		b := protowire.AppendTag(nil, num, protowire.BytesType)
+		return protowire.AppendBytes(b, msg)
+	}
+	return wrap(1, wrap(2, wrap(2, wrap(9, wrap(1, point)))))
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data    []byte
+		lenient string // expected lenient error, empty if valid
+		strict  string
+	}{
+		"truncated": {
+			data: []byte{0x0a, 0x05}, lenient: "invalid bytes", strict: "invalid bytes",
+		},
+		"wrong wire type": {
+			data: []byte{0x08, 0x01}, lenient: "wrong wire type", strict: "wrong wire type",
+		},
+		"group": {
+			data: []byte{0x9b, 0x06}, lenient: "group wire type", strict: "group wire type",
+		},
+		"garbage in attribute": {
+			// ResourceLogs → Resource → KeyValue → AnyValue with a
+			// truncated string, which no accessor reads.
+			data:    []byte{0x0a, 0x08, 0x0a, 0x06, 0x0a, 0x04, 0x12, 0x02, 0x0a, 0x05},
+			lenient: "invalid bytes", strict: "invalid bytes",
+		},
+		"overlong varint": {
+			// Unknown varint field 5 holding a two-byte zero.
+			data:   []byte{5 << 3, 0x80, 0x00},
+			strict: "non-canonical varint",
+		},
+		"overlong tag": {
+			// Unknown fixed64 field 2 with a two-byte tag.
+			data:   []byte{0x80 | 2<<3 | 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0},
+			strict: "non-canonical protobuf tag",
+		},
+		"overlong length": {
+			data:   []byte{0x0a, 0x80, 0x00},
+			strict: "non-canonical length",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			check := func(mode ValidationMode, want string) {
+				err := ExportLogsServiceRequest(tc.data).Validate(mode)
+				if want == "" {
+					require.NoError(t, err)
+				} else {
+					require.ErrorContains(t, err, want)
+				}
+			}
+			check(ValidateLenient, tc.lenient)
+			check(ValidateStrict, tc.strict)
+		})
+	}
+}
+
+func TestValidate_Packed(t *testing.T) {
+	partial := protowire.AppendTag(nil, 6, protowire.BytesType)
+	partial = protowire.AppendBytes(partial, make([]byte, 12))
+	err := ExportMetricsServiceRequest(histogramRequest(partial)).Validate(ValidateLenient)
+	require.ErrorContains(t, err, "partial element")
+
+	// ExponentialHistogram → data point → positive buckets with an
+	// over-long packed varint.
+	buckets := protowire.AppendTag(nil, 2, protowire.BytesType)
+	buckets = protowire.AppendBytes(buckets, []byte{0x01, 0x81, 0x00})
+	point := protowire.AppendTag(nil, 8, protowire.BytesType)
+	point = protowire.AppendBytes(point, buckets)
+	histogram := protowire.AppendTag(nil, 1, protowire.BytesType)
+	histogram = protowire.AppendBytes(histogram, point)
+	data := protowire.AppendTag(nil, 10, protowire.BytesType)
+	data = protowire.AppendBytes(data, histogram)
+	for _, num := range []protowire.Number{2, 2, 1} {
+		wrapped := protowire.AppendTag(nil, num, protowire.BytesType)
+		data = protowire.AppendBytes(wrapped, data)
+	}
+	require.NoError(t, ExportMetricsServiceRequest(data).Validate(ValidateLenient))
+	require.ErrorContains(t, ExportMetricsServiceRequest(data).Validate(ValidateStrict), "non-canonical varint in packed field")
+}
+
+// nestedArrayValue returns an AnyValue holding levels nested single-element
+// arrays around an empty AnyValue.
+func nestedArrayValue(levels int) []byte {
+	var value []byte
+	for range levels {
+		array := protowire.AppendTag(nil, 1, protowire.BytesType)
+		array = protowire.AppendBytes(array, value)
+		value = protowire.AppendTag(nil, 5, protowire.BytesType)
+		value = protowire.AppendBytes(value, array)
+	}
+	return value
+}
+
+func TestValidate_Nesting(t *testing.T) {
+	body := func(value []byte) ExportLogsServiceRequest {
+		record := protowire.AppendTag(nil, 5, protowire.BytesType)
+		return wrapRecord(protowire.AppendBytes(record, value))
+	}
+	// The body is at depth 4 and every array adds two levels.
+	require.NoError(t, body(nestedArrayValue(48)).Validate(ValidateStrict))
+	require.ErrorContains(t, body(nestedArrayValue(49)).Validate(ValidateStrict), "nested too deeply")
+	require.ErrorContains(t, body(nestedArrayValue(5000)).Validate(ValidateLenient), "nested too deeply")
+}
+
+func BenchmarkValidate(b *testing.B) {
+	data := ExportMetricsServiceRequest(marshalMetrics(b, createBenchMetrics()))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := data.Validate(ValidateStrict); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
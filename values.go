@@ -0,0 +1,250 @@
+package otlpwire
+
+import (
+	"errors"
+	"iter"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SumValues returns the sum of the values of every NumberDataPoint (gauge and
+// sum metrics) that belongs to a metric named metricName. Integer values are
+// converted to float64 before they are added. Histogram, exponential
+// histogram and summary metrics are ignored even if their name matches.
+func (m ExportMetricsServiceRequest) SumValues(metricName string) (float64, error) {
+	var total float64
+	var dpErr error
+	err := forEachNested([]byte(m), metricPath, func(metric []byte) bool {
+		name, err := extractBytesField(metric, 1)
+		if err != nil {
+			dpErr = err
+			return false
+		}
+		if string(name) != metricName {
+			return true
+		}
+		for dp, err := range Metric(metric).DataPointsSeq {
+			if err != nil {
+				dpErr = err
+				return false
+			}
+			if !isNumberDataPoint(dp.typ) {
+				continue
+			}
+			v, err := numberValue(dp.raw)
+			if err != nil {
+				dpErr = err
+				return false
+			}
+			total += v
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if dpErr != nil {
+		return 0, dpErr
+	}
+	return total, nil
+}
+
+// LastValue returns the value of the most recent NumberDataPoint (gauge and
+// sum metrics), by time_unix_nano, that belongs to a metric named
+// metricName. When several data points share the latest timestamp, the one
+// that appears last in the request wins. The bool result is false if no
+// matching data point exists.
+func (m ExportMetricsServiceRequest) LastValue(metricName string) (float64, bool, error) {
+	var (
+		last   float64
+		lastTS uint64
+		found  bool
+		dpErr  error
+	)
+	err := forEachNested([]byte(m), metricPath, func(metric []byte) bool {
+		name, err := extractBytesField(metric, 1)
+		if err != nil {
+			dpErr = err
+			return false
+		}
+		if string(name) != metricName {
+			return true
+		}
+		for dp, err := range Metric(metric).DataPointsSeq {
+			if err != nil {
+				dpErr = err
+				return false
+			}
+			if !isNumberDataPoint(dp.typ) {
+				continue
+			}
+			ts, err := dp.Timestamp()
+			if err != nil {
+				dpErr = err
+				return false
+			}
+			if found && ts < lastTS {
+				continue
+			}
+			v, err := numberValue(dp.raw)
+			if err != nil {
+				dpErr = err
+				return false
+			}
+			last, lastTS, found = v, ts, true
+		}
+		return true
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if dpErr != nil {
+		return 0, false, dpErr
+	}
+	return last, found, nil
+}
+
+// isNumberDataPoint reports whether datapoints of typ are NumberDataPoint
+// messages.
+func isNumberDataPoint(typ MetricType) bool {
+	return typ == MetricTypeGauge || typ == MetricTypeSum
+}
+
+// numberValue returns the value of a NumberDataPoint message: as_double
+// (field 4, double) or as_int (field 6, sfixed64), both fixed64 on the wire.
+// If both are present the last one wins, matching protobuf oneof semantics.
+// Returns 0 if neither field is present.
+func numberValue(data []byte) (float64, error) {
+	var v float64
+	pos := 0
+
+	for pos < len(data) {
+		num, wireType, tagLen := consumeTag(data[pos:])
+		if tagLen < 0 {
+			return 0, errors.New("malformed protobuf tag")
+		}
+		pos += tagLen
+
+		if num == 4 || num == 6 {
+			if wireType != protowire.Fixed64Type {
+				return 0, errors.New("wrong wire type for field")
+			}
+			bits, n := protowire.ConsumeFixed64(data[pos:])
+			if n < 0 {
+				return 0, errors.New("invalid fixed64 in field")
+			}
+			pos += n
+			if num == 4 {
+				v = math.Float64frombits(bits)
+			} else {
+				v = float64(int64(bits))
+			}
+			continue
+		}
+
+		n := skipField(data[pos:], wireType)
+		if n < 0 {
+			return 0, errors.New("failed to skip field")
+		}
+		pos += n
+	}
+
+	return v, nil
+}
+
+// ValueAtQuantile is a single quantile_values entry of a SummaryDataPoint.
+type ValueAtQuantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// QuantileCount returns the total number of quantile_values entries across
+// all Summary data points in the batch.
+func (m ExportMetricsServiceRequest) QuantileCount() (int, error) {
+	return countRepeatedField([]byte(m), 1, countQuantilesInResourceMetrics)
+}
+
+// QuantileCount returns the number of quantile_values entries across all
+// Summary data points in this resource.
+func (r ResourceMetrics) QuantileCount() (int, error) {
+	return countQuantilesInResourceMetrics([]byte(r))
+}
+
+// QuantileCount returns the number of quantile_values entries (field 6) in a
+// Summary data point. Returns 0 for every other data point type.
+func (d DataPoint) QuantileCount() (int, error) {
+	if d.typ != MetricTypeSummary {
+		return 0, nil
+	}
+	return countOccurrences(d.raw, 6)
+}
+
+// QuantileValues returns an iterator over the quantile_values entries
+// (field 6) of a Summary data point. It yields nothing for every other data
+// point type.
+// The returned function should be called after iteration to check for errors.
+func (d DataPoint) QuantileValues() (iter.Seq[ValueAtQuantile], func() error) {
+	var iterErr error
+
+	seq := func(yield func(ValueAtQuantile) bool) {
+		if d.typ != MetricTypeSummary {
+			return
+		}
+		forEachRepeatedField(d.raw, 6, func(rb []byte, err error) bool {
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			q, err := parseValueAtQuantile(rb)
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			return yield(q)
+		})
+	}
+
+	errFunc := func() error {
+		return iterErr
+	}
+
+	return seq, errFunc
+}
+
+// parseValueAtQuantile decodes a ValueAtQuantile message: quantile (field 1)
+// and value (field 2), both doubles.
+func parseValueAtQuantile(data []byte) (ValueAtQuantile, error) {
+	q, err := extractFixed64Field(data, 1)
+	if err != nil {
+		return ValueAtQuantile{}, err
+	}
+	v, err := extractFixed64Field(data, 2)
+	if err != nil {
+		return ValueAtQuantile{}, err
+	}
+	return ValueAtQuantile{Quantile: math.Float64frombits(q), Value: math.Float64frombits(v)}, nil
+}
+
+func countQuantilesInResourceMetrics(data []byte) (int, error) {
+	return countRepeatedField(data, 2, countQuantilesInScopeMetrics)
+}
+
+func countQuantilesInScopeMetrics(data []byte) (int, error) {
+	return countRepeatedField(data, 2, countQuantilesInMetric)
+}
+
+func countQuantilesInMetric(data []byte) (int, error) {
+	count := 0
+	for dp, err := range Metric(data).DataPointsSeq {
+		if err != nil {
+			return 0, err
+		}
+		c, err := dp.QuantileCount()
+		if err != nil {
+			return 0, err
+		}
+		count += c
+	}
+	return count, nil
+}
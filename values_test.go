@@ -0,0 +1,258 @@
+package otlpwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// buildValueMetrics builds a request with int and double gauge/sum data points
+// named "requests" spread over two resources, plus a histogram and an
+// unrelated gauge that must be ignored by value aggregation.
+func buildValueMetrics(t *testing.T) []byte {
+	t.Helper()
+	metrics := pmetric.NewMetrics()
+
+	sm1 := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sum := sm1.Metrics().AppendEmpty()
+	sum.SetName("requests")
+	dp := sum.SetEmptySum().DataPoints().AppendEmpty()
+	dp.SetIntValue(10)
+	dp.SetTimestamp(100)
+	dp = sum.Sum().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(2.5)
+	dp.SetTimestamp(300)
+
+	other := sm1.Metrics().AppendEmpty()
+	other.SetName("other")
+	dp = other.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetIntValue(1000)
+	dp.SetTimestamp(900)
+
+	sm2 := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	gauge := sm2.Metrics().AppendEmpty()
+	gauge.SetName("requests")
+	dp = gauge.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetIntValue(-3)
+	dp.SetTimestamp(200)
+
+	hist := sm2.Metrics().AppendEmpty()
+	hist.SetName("requests")
+	hdp := hist.SetEmptyHistogram().DataPoints().AppendEmpty()
+	hdp.SetCount(99)
+	hdp.SetSum(99)
+	hdp.SetTimestamp(1000)
+
+	marshaler := &pmetric.ProtoMarshaler{}
+	bytes, err := marshaler.MarshalMetrics(metrics)
+	require.NoError(t, err)
+	return bytes
+}
+
+func TestSumValues(t *testing.T) {
+	req := ExportMetricsServiceRequest(buildValueMetrics(t))
+
+	total, err := req.SumValues("requests")
+	require.NoError(t, err)
+	require.InDelta(t, 9.5, total, 1e-9)
+
+	total, err = req.SumValues("missing")
+	require.NoError(t, err)
+	require.Zero(t, total)
+}
+
+func TestLastValue(t *testing.T) {
+	req := ExportMetricsServiceRequest(buildValueMetrics(t))
+
+	// The histogram (ts 1000) and the "other" gauge (ts 900) are newer but
+	// must not be considered.
+	v, ok, err := req.LastValue("requests")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.InDelta(t, 2.5, v, 1e-9)
+
+	_, ok, err = req.LastValue("missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestLastValue_TieKeepsLater(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	gauge := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	gauge.SetName("g")
+	body := gauge.SetEmptyGauge()
+	for _, v := range []int64{1, 7} {
+		dp := body.DataPoints().AppendEmpty()
+		dp.SetIntValue(v)
+		dp.SetTimestamp(50)
+	}
+
+	marshaler := &pmetric.ProtoMarshaler{}
+	bytes, err := marshaler.MarshalMetrics(metrics)
+	require.NoError(t, err)
+
+	v, ok, err := ExportMetricsServiceRequest(bytes).LastValue("g")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 7.0, v)
+}
+
+func TestSumValues_Empty(t *testing.T) {
+	total, err := ExportMetricsServiceRequest(nil).SumValues("requests")
+	require.NoError(t, err)
+	require.Zero(t, total)
+}
+
+func TestSumValues_Malformed(t *testing.T) {
+	// as_int (field 6) encoded as varint instead of sfixed64.
+	var dp []byte
+	dp = protowire.AppendTag(dp, 6, protowire.VarintType)
+	dp = protowire.AppendVarint(dp, 1)
+
+	var body []byte
+	body = protowire.AppendTag(body, 1, protowire.BytesType)
+	body = protowire.AppendBytes(body, dp)
+
+	var metric []byte
+	metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+	metric = protowire.AppendBytes(metric, []byte("requests"))
+	metric = protowire.AppendTag(metric, 5, protowire.BytesType)
+	metric = protowire.AppendBytes(metric, body)
+
+	req := ExportMetricsServiceRequest(wrapRecord(metric))
+	_, err := req.SumValues("requests")
+	require.Error(t, err)
+
+	_, _, err = req.LastValue("requests")
+	require.Error(t, err)
+
+	_, err = ExportMetricsServiceRequest{0x0a, 0x05}.SumValues("requests")
+	require.Error(t, err)
+}
+
+// wrapRecord wraps a single record message (a Metric, Span or LogRecord,
+// which are all field 2 of their scope message) into an export request with
+// one resource and one scope.
+func wrapRecord(record []byte) []byte {
+	var sm []byte
+	sm = protowire.AppendTag(sm, 2, protowire.BytesType)
+	sm = protowire.AppendBytes(sm, record)
+
+	var rm []byte
+	rm = protowire.AppendTag(rm, 2, protowire.BytesType)
+	rm = protowire.AppendBytes(rm, sm)
+
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	req = protowire.AppendBytes(req, rm)
+	return req
+}
+
+func TestForEachNested_EarlyStop(t *testing.T) {
+	req := buildScopedMetrics(t, 2, 2, 2)
+
+	visited := 0
+	err := forEachNested(req, metricPath, func([]byte) bool {
+		visited++
+		return visited < 3
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, visited)
+}
+
+func buildSummaryMetrics(t *testing.T) []byte {
+	t.Helper()
+	metrics := pmetric.NewMetrics()
+	for r := 0; r < 2; r++ {
+		sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+		summary := sm.Metrics().AppendEmpty()
+		summary.SetName("latency")
+		body := summary.SetEmptySummary()
+		for i := 0; i < 2; i++ {
+			dp := body.DataPoints().AppendEmpty()
+			dp.SetCount(10)
+			for _, q := range []float64{0.5, 0.9, 0.99} {
+				qv := dp.QuantileValues().AppendEmpty()
+				qv.SetQuantile(q)
+				qv.SetValue(q * 100)
+			}
+		}
+		// A gauge alongside must not contribute quantiles.
+		gauge := sm.Metrics().AppendEmpty()
+		gauge.SetName("g")
+		gauge.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	}
+	marshaler := &pmetric.ProtoMarshaler{}
+	bytes, err := marshaler.MarshalMetrics(metrics)
+	require.NoError(t, err)
+	return bytes
+}
+
+func TestQuantileCount(t *testing.T) {
+	req := ExportMetricsServiceRequest(buildSummaryMetrics(t))
+
+	count, err := req.QuantileCount()
+	require.NoError(t, err)
+	require.Equal(t, 12, count) // 2 resources × 2 dps × 3 quantiles
+
+	resources, resErr := req.ResourceMetrics()
+	for rm := range resources {
+		c, err := rm.QuantileCount()
+		require.NoError(t, err)
+		require.Equal(t, 6, c)
+	}
+	require.NoError(t, resErr())
+}
+
+func TestQuantileValues(t *testing.T) {
+	var got []ValueAtQuantile
+	forEachTestDataPoint(t, buildSummaryMetrics(t), func(name string, dp DataPoint) {
+		seq, errFn := dp.QuantileValues()
+		for q := range seq {
+			got = append(got, q)
+		}
+		require.NoError(t, errFn())
+	})
+	require.Len(t, got, 12)
+	require.Equal(t, ValueAtQuantile{Quantile: 0.9, Value: 90}, got[1])
+}
+
+func TestQuantileValues_NonSummary(t *testing.T) {
+	forEachTestDataPoint(t, buildAllTypesMetrics(t), func(name string, dp DataPoint) {
+		if dp.Type() == MetricTypeSummary {
+			return
+		}
+		c, err := dp.QuantileCount()
+		require.NoError(t, err)
+		require.Zero(t, c)
+		seq, errFn := dp.QuantileValues()
+		for range seq {
+			t.Fatal("unexpected quantile")
+		}
+		require.NoError(t, errFn())
+	})
+}
+
+func TestQuantileValues_Malformed(t *testing.T) {
+	// quantile_values entry whose quantile (field 1) is a varint.
+	var q []byte
+	q = protowire.AppendTag(q, 1, protowire.VarintType)
+	q = protowire.AppendVarint(q, 1)
+
+	var raw []byte
+	raw = protowire.AppendTag(raw, 6, protowire.BytesType)
+	raw = protowire.AppendBytes(raw, q)
+
+	dp := DataPoint{raw: raw, typ: MetricTypeSummary}
+	seq, errFn := dp.QuantileValues()
+	for range seq {
+	}
+	require.Error(t, errFn())
+
+	// quantile_values with the wrong wire type fails counting.
+	dp = DataPoint{raw: []byte{0x30, 0x01}, typ: MetricTypeSummary}
+	_, err := dp.QuantileCount()
+	require.Error(t, err)
+}
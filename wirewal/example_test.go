@@ -0,0 +1,59 @@
+package wirewal_test
+
+import (
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	otlpwire "go.olly.garden/otlp-wire"
+	"go.olly.garden/otlp-wire/wirewal"
+)
+
+// Example demonstrates using the log as a durable retry buffer: requests are
+// appended before they are sent, replayed after a restart, and truncated
+// once delivered.
+func Example() {
+	dir, _ := os.MkdirTemp("", "wal")
+	defer os.RemoveAll(dir)
+
+	traces := ptrace.NewTraces()
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	payload, _ := (&ptrace.ProtoMarshaler{}).MarshalTraces(traces)
+
+	w, err := wirewal.Open(dir, wirewal.Options{Sync: true})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	for range 3 {
+		if _, err := w.Append(otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: payload, Tenant: "acme"}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+	_ = w.Close()
+
+	// After a restart, resend everything that was not acknowledged.
+	w, err = wirewal.Open(dir, wirewal.Options{})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer w.Close()
+	var last wirewal.Position
+	err = w.Replay(func(pos wirewal.Position, e otlpwire.Envelope) error {
+		n, _ := e.ItemCount()
+		fmt.Printf("resend %s for %s: %d spans\n", e.Signal, e.Tenant, n)
+		last = pos
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	_ = w.TruncateBefore(last)
+
+	// Output:
+	// resend traces for acme: 1 spans
+	// resend traces for acme: 1 spans
+	// resend traces for acme: 1 spans
+}
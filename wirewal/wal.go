@@ -0,0 +1,350 @@
+// Package wirewal provides a write-ahead log for OTLP export requests in wire
+// format. Requests are appended to checksummed segment files as
+// otlpwire.Envelope encodings and replayed on restart, so a raw-bytes
+// forwarder gets a durable retry buffer without converting to pdata.
+package wirewal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+// DefaultMaxSegmentBytes is the segment rotation threshold used when
+// Options.MaxSegmentBytes is zero.
+const DefaultMaxSegmentBytes = 64 << 20
+
+const (
+	segmentSuffix = ".wal"
+	// recordHeaderSize is the per-record framing: 4-byte big-endian length
+	// followed by a 4-byte big-endian CRC-32C of the record body.
+	recordHeaderSize = 8
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrClosed is returned by operations on a closed WAL.
+var ErrClosed = errors.New("wirewal: closed")
+
+// Options configures a WAL.
+type Options struct {
+	// MaxSegmentBytes rotates to a new segment file once the current one
+	// reaches this size. Zero means DefaultMaxSegmentBytes.
+	MaxSegmentBytes int64
+	// Sync calls fsync after every append. Without it, durability is
+	// bounded by the operating system's write-back.
+	Sync bool
+}
+
+// Position identifies a record in the log.
+type Position struct {
+	Segment uint64 // segment sequence number
+	Offset  int64  // byte offset of the record within the segment
+}
+
+// WAL is a segmented, append-only log of envelopes. It is safe for
+// concurrent use.
+type WAL struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex
+	segments []uint64 // ascending; the last one is open for append
+	current  segmentFile
+	size     int64
+	closed   bool
+	// broken is set when a failed append left bytes past size that could
+	// not be truncated away; later appends would land after them and be
+	// unreadable, so they fail with this error instead.
+	broken error
+}
+
+// segmentFile is the open segment; *os.File in production, replaceable in
+// tests to inject write failures.
+type segmentFile interface {
+	io.Writer
+	Seek(offset int64, whence int) (int64, error)
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// Open opens or creates the log in dir. If the newest segment ends with a
+// torn record, typically from a crash mid-append, the segment is truncated to
+// its last complete record.
+func Open(dir string, opts Options) (*WAL, error) {
+	if opts.MaxSegmentBytes <= 0 {
+		opts.MaxSegmentBytes = DefaultMaxSegmentBytes
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAL{dir: dir, opts: opts, segments: segments}
+	if len(segments) == 0 {
+		if err := w.openSegment(1); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+
+	last := segments[len(segments)-1]
+	data, err := os.ReadFile(w.segmentPath(last))
+	if err != nil {
+		return nil, err
+	}
+	valid, _ := scanRecords(data, nil)
+	f, err := os.OpenFile(w.segmentPath(last), os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if valid < int64(len(data)) {
+		if err := f.Truncate(valid); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.current = f
+	w.size = valid
+	return w, nil
+}
+
+// Append writes e to the log and returns its position. If the write, or
+// with Options.Sync the fsync after it, fails, the segment is truncated back
+// to its previous end, so that neither a torn record sits in front of later
+// appends nor a retry of e leaves it in the log twice.
+func (w *WAL) Append(e otlpwire.Envelope) (Position, error) {
+	body, err := e.AppendBinary(make([]byte, recordHeaderSize, recordHeaderSize+len(e.Payload)+32))
+	if err != nil {
+		return Position{}, err
+	}
+	n := len(body) - recordHeaderSize
+	binary.BigEndian.PutUint32(body[0:], uint32(n))
+	binary.BigEndian.PutUint32(body[4:], crc32.Checksum(body[recordHeaderSize:], castagnoli))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return Position{}, ErrClosed
+	}
+	if w.broken != nil {
+		return Position{}, w.broken
+	}
+	if w.size > 0 && w.size+int64(len(body)) > w.opts.MaxSegmentBytes {
+		if err := w.rotate(); err != nil {
+			return Position{}, err
+		}
+	}
+
+	pos := Position{Segment: w.segments[len(w.segments)-1], Offset: w.size}
+	if _, err := w.current.Write(body); err != nil {
+		w.discardTail()
+		return Position{}, err
+	}
+	if w.opts.Sync {
+		if err := w.current.Sync(); err != nil {
+			w.discardTail()
+			return Position{}, err
+		}
+	}
+	w.size += int64(len(body))
+	return pos, nil
+}
+
+// Replay calls fn for every record in the log, oldest first. Envelope
+// payloads alias a buffer that is not reused, so fn may retain them.
+// Replay stops at the first error returned by fn, or at a corrupt record in
+// any segment, and returns that error.
+//
+// The lock is not held while fn runs, so fn may call Append or
+// TruncateBefore. Each segment is read whole before its records are
+// visited; records appended during Replay may or may not be visited.
+func (w *WAL) Replay(fn func(Position, otlpwire.Envelope) error) error {
+	w.mu.Lock()
+	segments := slices.Clone(w.segments)
+	w.mu.Unlock()
+
+	for _, seg := range segments {
+		data, ok, err := w.readSegment(seg)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		var fnErr error
+		valid, decodeErr := scanRecords(data, func(offset int64, body []byte) bool {
+			var e otlpwire.Envelope
+			if err := e.UnmarshalBinary(body); err != nil {
+				fnErr = fmt.Errorf("wirewal: segment %d offset %d: %w", seg, offset, err)
+				return false
+			}
+			fnErr = fn(Position{Segment: seg, Offset: offset}, e)
+			return fnErr == nil
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		if decodeErr != nil {
+			return fmt.Errorf("wirewal: segment %d offset %d: %w", seg, valid, decodeErr)
+		}
+	}
+	return nil
+}
+
+// TruncateBefore deletes every segment that only holds records older than
+// pos, typically after those records were delivered. The segment containing
+// pos and the open segment are kept.
+func (w *WAL) TruncateBefore(pos Position) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+
+	keep := 0
+	for keep < len(w.segments)-1 && w.segments[keep] < pos.Segment {
+		if err := os.Remove(w.segmentPath(w.segments[keep])); err != nil {
+			w.segments = w.segments[keep:]
+			return err
+		}
+		keep++
+	}
+	w.segments = w.segments[keep:]
+	return nil
+}
+
+// Close syncs and closes the open segment.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	syncErr := w.current.Sync()
+	closeErr := w.current.Close()
+	return errors.Join(syncErr, closeErr)
+}
+
+// readSegment reads segment seq under the lock. It reports false if the
+// segment was truncated away since Replay started.
+func (w *WAL) readSegment(seq uint64) ([]byte, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, false, ErrClosed
+	}
+	if !slices.Contains(w.segments, seq) {
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(w.segmentPath(seq))
+	return data, err == nil, err
+}
+
+// discardTail drops whatever a failed write or fsync left past w.size. If
+// that is not possible the WAL refuses further appends.
+func (w *WAL) discardTail() {
+	err := w.current.Truncate(w.size)
+	if err == nil {
+		_, err = w.current.Seek(w.size, io.SeekStart)
+	}
+	if err != nil {
+		w.broken = fmt.Errorf("wirewal: segment %d unusable after failed append: %w", w.segments[len(w.segments)-1], err)
+	}
+}
+
+// rotate closes the open segment and starts the next one.
+func (w *WAL) rotate() error {
+	if err := w.current.Sync(); err != nil {
+		return err
+	}
+	if err := w.current.Close(); err != nil {
+		return err
+	}
+	return w.openSegment(w.segments[len(w.segments)-1] + 1)
+}
+
+// openSegment creates segment seq and makes it the append target.
+func (w *WAL) openSegment(seq uint64) error {
+	f, err := os.OpenFile(w.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w.current = f
+	w.size = 0
+	w.segments = append(w.segments, seq)
+	return nil
+}
+
+func (w *WAL) segmentPath(seq uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// listSegments returns the sequence numbers of the segment files in dir in
+// ascending order. Other files are ignored.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seq)
+	}
+	slices.Sort(segments)
+	return segments, nil
+}
+
+// scanRecords walks the framed records in a segment, calling fn (if non-nil)
+// with each record's offset and body. It returns the offset just past the
+// last complete, checksum-valid record, and an error describing why scanning
+// stopped early, if it did. Returning false from fn stops the scan.
+func scanRecords(data []byte, fn func(offset int64, body []byte) bool) (int64, error) {
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < recordHeaderSize {
+			return int64(pos), errors.New("torn record header")
+		}
+		n := int(binary.BigEndian.Uint32(data[pos:]))
+		sum := binary.BigEndian.Uint32(data[pos+4:])
+		if n > len(data)-pos-recordHeaderSize {
+			return int64(pos), errors.New("torn record body")
+		}
+		body := data[pos+recordHeaderSize : pos+recordHeaderSize+n]
+		if crc32.Checksum(body, castagnoli) != sum {
+			return int64(pos), errors.New("record checksum mismatch")
+		}
+		if fn != nil && !fn(int64(pos), body) {
+			return int64(pos), nil
+		}
+		pos += recordHeaderSize + n
+	}
+	return int64(pos), nil
+}
@@ -0,0 +1,319 @@
+package wirewal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	otlpwire "go.olly.garden/otlp-wire"
+)
+
+func tracesPayload(t *testing.T, spans int) []byte {
+	t.Helper()
+	traces := ptrace.NewTraces()
+	ss := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	for i := 0; i < spans; i++ {
+		ss.Spans().AppendEmpty().SetName(fmt.Sprintf("span-%d", i))
+	}
+	marshaler := &ptrace.ProtoMarshaler{}
+	data, err := marshaler.MarshalTraces(traces)
+	require.NoError(t, err)
+	return data
+}
+
+func replayAll(t *testing.T, w *WAL) []otlpwire.Envelope {
+	t.Helper()
+	var got []otlpwire.Envelope
+	require.NoError(t, w.Replay(func(_ Position, e otlpwire.Envelope) error {
+		got = append(got, e)
+		return nil
+	}))
+	return got
+}
+
+func TestWAL_AppendReplayAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, Options{Sync: true})
+	require.NoError(t, err)
+
+	received := time.Unix(1700000000, 0)
+	for i := 1; i <= 3; i++ {
+		_, err := w.Append(otlpwire.Envelope{
+			Signal:     otlpwire.SignalTraces,
+			Payload:    tracesPayload(t, i),
+			Tenant:     "acme",
+			ReceivedAt: received,
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	w, err = Open(dir, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+
+	got := replayAll(t, w)
+	require.Len(t, got, 3)
+	for i, e := range got {
+		req, ok := e.Traces()
+		require.True(t, ok)
+		count, err := req.SpanCount()
+		require.NoError(t, err)
+		require.Equal(t, i+1, count)
+		require.Equal(t, "acme", e.Tenant)
+		require.True(t, received.Equal(e.ReceivedAt))
+	}
+}
+
+func TestWAL_RotationAndTruncate(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, Options{MaxSegmentBytes: 128})
+	require.NoError(t, err)
+	defer w.Close()
+
+	var positions []Position
+	for i := 0; i < 6; i++ {
+		pos, err := w.Append(otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 2)})
+		require.NoError(t, err)
+		positions = append(positions, pos)
+	}
+	require.Greater(t, positions[5].Segment, positions[0].Segment)
+
+	require.NoError(t, w.TruncateBefore(positions[3]))
+	want := 0
+	for _, pos := range positions {
+		if pos.Segment >= positions[3].Segment {
+			want++
+		}
+	}
+	require.Len(t, replayAll(t, w), want)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	require.Len(t, files, int(positions[5].Segment-positions[3].Segment)+1)
+}
+
+func TestWAL_TornTailIsTruncated(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, Options{})
+	require.NoError(t, err)
+	_, err = w.Append(otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 1)})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// Simulate a crash in the middle of writing a second record.
+	seg := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, segmentSuffix))
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x00, 0x00, 0x01, 0x00, 0xaa})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, err = Open(dir, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	require.Len(t, replayAll(t, w), 1)
+
+	_, err = w.Append(otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 1)})
+	require.NoError(t, err)
+	require.Len(t, replayAll(t, w), 2)
+}
+
+func TestWAL_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, Options{MaxSegmentBytes: 64})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = w.Append(otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 1)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	// Flip a payload byte in the first, already rotated segment.
+	seg := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, segmentSuffix))
+	data, err := os.ReadFile(seg)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(seg, data, 0o644))
+
+	w, err = Open(dir, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	err = w.Replay(func(Position, otlpwire.Envelope) error { return nil })
+	require.ErrorContains(t, err, "checksum")
+}
+
+func TestWAL_ReplayStopsOnCallbackError(t *testing.T) {
+	w, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	for i := 0; i < 3; i++ {
+		_, err = w.Append(otlpwire.Envelope{Signal: otlpwire.SignalLogs, Payload: []byte{}})
+		require.NoError(t, err)
+	}
+
+	stop := fmt.Errorf("stop")
+	calls := 0
+	err = w.Replay(func(Position, otlpwire.Envelope) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+}
+
+func TestWAL_Closed(t *testing.T) {
+	w, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+
+	_, err = w.Append(otlpwire.Envelope{Signal: otlpwire.SignalLogs})
+	require.ErrorIs(t, err, ErrClosed)
+	require.ErrorIs(t, w.Replay(nil), ErrClosed)
+	require.ErrorIs(t, w.TruncateBefore(Position{}), ErrClosed)
+}
+
+func TestWAL_AppendRejectsInvalidEnvelope(t *testing.T) {
+	w, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = w.Append(otlpwire.Envelope{Payload: []byte{0x01}})
+	require.Error(t, err)
+}
+
+// failingFile writes at most limit bytes and then fails.
+type failingFile struct {
+	*os.File
+	limit int
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if len(p) <= f.limit {
+		f.limit -= len(p)
+		return f.File.Write(p)
+	}
+	n, _ := f.File.Write(p[:f.limit])
+	f.limit = 0
+	return n, errors.New("injected write failure")
+}
+
+func TestWAL_FailedAppendIsDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, Options{})
+	require.NoError(t, err)
+
+	first := otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 1)}
+	_, err = w.Append(first)
+	require.NoError(t, err)
+
+	// The next write fails halfway through the record.
+	ff := &failingFile{File: w.current.(*os.File), limit: 20}
+	w.current = ff
+	_, err = w.Append(otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 2)})
+	require.ErrorContains(t, err, "injected write failure")
+
+	ff.limit = 1 << 20
+	third := otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 3)}
+	pos, err := w.Append(third)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	w, err = Open(dir, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	got := replayAll(t, w)
+	require.Len(t, got, 2)
+	require.Equal(t, first.Payload, got[0].Payload)
+	require.Equal(t, third.Payload, got[1].Payload)
+	require.NoError(t, w.Replay(func(p Position, _ otlpwire.Envelope) error {
+		if p.Offset > 0 {
+			require.Equal(t, pos, p)
+		}
+		return nil
+	}))
+}
+
+// syncFailingFile fails the next fsync.
+type syncFailingFile struct {
+	*os.File
+	fail bool
+}
+
+func (f *syncFailingFile) Sync() error {
+	if f.fail {
+		f.fail = false
+		return errors.New("injected sync failure")
+	}
+	return f.File.Sync()
+}
+
+func TestWAL_FailedSyncIsDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, Options{Sync: true})
+	require.NoError(t, err)
+
+	first := otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 1)}
+	_, err = w.Append(first)
+	require.NoError(t, err)
+
+	// The record is written but not synced; retrying it must not leave it
+	// in the log twice.
+	sf := &syncFailingFile{File: w.current.(*os.File), fail: true}
+	w.current = sf
+	second := otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 2)}
+	_, err = w.Append(second)
+	require.ErrorContains(t, err, "injected sync failure")
+	_, err = w.Append(second)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	w, err = Open(dir, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	got := replayAll(t, w)
+	require.Len(t, got, 2)
+	require.Equal(t, first.Payload, got[0].Payload)
+	require.Equal(t, second.Payload, got[1].Payload)
+}
+
+// truncateFailingFile fails every write and cannot be truncated.
+type truncateFailingFile struct{ *os.File }
+
+func (truncateFailingFile) Write([]byte) (int, error) { return 1, errors.New("injected write failure") }
+func (truncateFailingFile) Truncate(int64) error      { return errors.New("injected truncate failure") }
+
+func TestWAL_UntruncatableFailureStopsAppends(t *testing.T) {
+	w, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	w.current = truncateFailingFile{w.current.(*os.File)}
+
+	e := otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 1)}
+	_, err = w.Append(e)
+	require.ErrorContains(t, err, "injected write failure")
+	_, err = w.Append(e)
+	require.ErrorContains(t, err, "injected truncate failure")
+}
+
+func TestWAL_ReplayMayAppend(t *testing.T) {
+	w, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	e := otlpwire.Envelope{Signal: otlpwire.SignalTraces, Payload: tracesPayload(t, 1)}
+	_, err = w.Append(e)
+	require.NoError(t, err)
+
+	// Re-queueing from the callback must not deadlock.
+	require.NoError(t, w.Replay(func(_ Position, e otlpwire.Envelope) error {
+		_, err := w.Append(e)
+		return err
+	}))
+	require.Len(t, replayAll(t, w), 2)
+}